@@ -144,7 +144,7 @@ func getPotentialPaths(
 
 		var targets []string
 
-		targets, err = earthfile2llb.GetTargets(ctx, resolver, gwClient, target)
+		targets, err = earthfile2llb.GetTargets(ctx, resolver, gwClient, target, false)
 		if err != nil {
 			return nil, err
 		}
@@ -261,6 +261,9 @@ func getPotentialTargets(prefix, dirPath string, replaceHomePrefix func(string)
 	return potentials
 }
 
+// getPotentialTargetBuildArgs returns the build args of a target as flags,
+// e.g. --name=. Args limited to a set of values get a flag for each of them
+// instead, e.g. --name=value.
 func getPotentialTargetBuildArgs(
 	ctx context.Context, resolver *buildcontext.Resolver, gwClient gwclient.Client, targetStr string,
 ) ([]string, error) {
@@ -274,7 +277,21 @@ func getPotentialTargetBuildArgs(
 		return nil, err
 	}
 
-	return envArgs, nil
+	potentials := make([]string, 0, len(envArgs))
+
+	for _, arg := range envArgs {
+		values := arg.Constraint.Values()
+		if len(values) == 0 {
+			potentials = append(potentials, "--"+arg.Name+"=")
+			continue
+		}
+
+		for _, v := range values {
+			potentials = append(potentials, "--"+arg.Name+"="+v+" ")
+		}
+	}
+
+	return potentials, nil
 }
 
 func getPotentialArtifactBuildArgs(
@@ -499,7 +516,7 @@ func GetPotentials(
 			}
 		case state == targetState || state == targetFlagState:
 			switch {
-			case !strings.HasPrefix(w, "-"), strings.HasSuffix(w, "="):
+			case !strings.HasPrefix(w, "-"):
 				state = endOfSuggestionsState
 			case artifactMode:
 				state = artifactFlagState
@@ -567,8 +584,6 @@ func GetPotentials(
 			return nil, err
 		}
 
-		potentials = padStrings(potentials, "--", "=")
-
 	case artifactFlagState:
 		var err error
 
@@ -576,8 +591,6 @@ func GetPotentials(
 		if err != nil {
 			return nil, err
 		}
-
-		potentials = padStrings(potentials, "--", "=")
 	}
 
 	filteredPotentials := []string{}
@@ -2,6 +2,8 @@ package autocomplete
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/EarthBuild/earthbuild/buildcontext"
@@ -158,3 +160,25 @@ func TestCommandSubSubCompletion2(t *testing.T) {
 	NoError(t, err, "GetPotentials failed")
 	Equal(t, []string{"dancing-queen "}, matches)
 }
+
+func TestTargetBuildArgCompletion(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	earthfile := "VERSION --typed-args 0.8\n" +
+		"build:\n" +
+		"    ARG --enum=dev,prod env = dev\n" +
+		"    ARG --type=bool verbose = false\n" +
+		"    ARG tag\n"
+
+	err := os.WriteFile(filepath.Join(dir, "Earthfile"), []byte(earthfile), 0o600)
+	NoError(t, err, "failed to write Earthfile")
+
+	matches, err := getPotentials("earthly " + dir + "+build --")
+	NoError(t, err, "GetPotentials failed")
+	Equal(t, []string{"--env=dev ", "--env=prod ", "--tag=", "--verbose=false ", "--verbose=true "}, matches)
+
+	matches, err = getPotentials("earthly " + dir + "+build --env=")
+	NoError(t, err, "GetPotentials failed")
+	Equal(t, []string{"--env=dev ", "--env=prod "}, matches)
+}
@@ -0,0 +1,138 @@
+package buildcontext
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/util/hint"
+)
+
+// Visibilities of targets and functions, as set by the VISIBILITY command.
+const (
+	// VisibilityPublic allows referencing from anywhere. It is the default.
+	VisibilityPublic = "public"
+	// VisibilityInternal allows referencing from the Earthfiles in the same
+	// directory tree.
+	VisibilityInternal = "internal"
+	// VisibilityPrivate allows referencing from the same Earthfile only.
+	VisibilityPrivate = "private"
+)
+
+// ErrNotVisible is returned when a target or function is referenced from
+// outside of its visibility.
+var ErrNotVisible = errors.New("not visible")
+
+// Visibility returns the visibility set by the VISIBILITY command of a target
+// or function recipe, or VisibilityPublic if there is none.
+func Visibility(recipe earthfile.Block) (string, error) {
+	for _, stmt := range recipe {
+		if stmt.Command == nil || stmt.Command.Name != earthfile.CmdVisibility {
+			continue
+		}
+
+		if len(stmt.Command.Args) != 1 {
+			return "", fmt.Errorf("invalid number of arguments for VISIBILITY: %v", stmt.Command.Args)
+		}
+
+		visibility := stmt.Command.Args[0]
+		switch visibility {
+		case VisibilityPublic, VisibilityInternal, VisibilityPrivate:
+			return visibility, nil
+		default:
+			return "", fmt.Errorf(
+				"invalid VISIBILITY %q, expected one of %s, %s or %s",
+				visibility, VisibilityPublic, VisibilityInternal, VisibilityPrivate,
+			)
+		}
+	}
+
+	return VisibilityPublic, nil
+}
+
+// CheckVisibility returns an error wrapping ErrNotVisible if the target or
+// function of the build context can't be referenced from referrer, the
+// reference of the Earthfile containing the reference. A nil referrer stands
+// for the command line, from which only public targets can be invoked.
+func (d *Data) CheckVisibility(referrer domain.Reference) error {
+	if d.Features == nil || !d.Features.TargetVisibility {
+		return nil
+	}
+
+	kind := "target"
+
+	var recipe earthfile.Block
+
+	switch d.Ref.(type) {
+	case domain.Target:
+		for _, t := range d.Earthfile.Targets {
+			if t.Name == d.Ref.GetName() {
+				recipe = t.Recipe
+				break
+			}
+		}
+	case domain.Command:
+		kind = "function"
+
+		for _, f := range d.Earthfile.Functions {
+			if f.Name == d.Ref.GetName() {
+				recipe = f.Recipe
+				break
+			}
+		}
+	}
+
+	visibility, err := Visibility(recipe)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", kind, d.Ref.StringCanonical(), err)
+	}
+
+	if visibility == VisibilityPublic {
+		return nil
+	}
+
+	if referrer == nil {
+		err := fmt.Errorf("%w: %s %s is %s and cannot be invoked from the command line",
+			ErrNotVisible, kind, d.Ref.StringCanonical(), visibility)
+
+		return hint.Wrapf(err, "Invoke a public target which references it instead, or remove 'VISIBILITY %s' from it.",
+			visibility)
+	}
+
+	dir, referrerDir := visibilityDir(d.Ref), visibilityDir(referrer)
+
+	switch {
+	case visibility == VisibilityPrivate && referrerDir == dir:
+		return nil
+	case visibility == VisibilityInternal && (referrerDir == dir || strings.HasPrefix(referrerDir, dir+"/")):
+		return nil
+	}
+
+	err = fmt.Errorf("%w: %s %s is %s and cannot be referenced from %s",
+		ErrNotVisible, kind, d.Ref.StringCanonical(), visibility, referrer.ProjectCanonical())
+	if visibility == VisibilityPrivate {
+		return hint.Wrap(err, "Private targets and functions can only be referenced from their own Earthfile.")
+	}
+
+	return hint.Wrapf(err, "Internal targets and functions can only be referenced from Earthfiles in %s "+
+		"or in its subdirectories.", d.Ref.ProjectCanonical())
+}
+
+// visibilityDir returns the directory of the Earthfile of ref, in a form that
+// can be compared between local or remote references.
+func visibilityDir(ref domain.Reference) string {
+	if !ref.IsLocalInternal() && !ref.IsLocalExternal() {
+		return path.Clean(ref.GetGitURL())
+	}
+
+	dir, err := filepath.Abs(ref.GetLocalPath())
+	if err != nil {
+		dir = ref.GetLocalPath()
+	}
+
+	return filepath.ToSlash(filepath.Clean(dir))
+}
@@ -0,0 +1,148 @@
+package buildcontext_test
+
+import (
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/buildcontext"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/features"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/stretchr/testify/require"
+)
+
+const visibilityEarthfile = `VERSION --target-visibility 0.8
+
+build:
+    FROM alpine
+
+deps:
+    VISIBILITY private
+    FROM alpine
+
+lib:
+    VISIBILITY internal
+    FROM alpine
+
+HELPER:
+    FUNCTION
+    VISIBILITY private
+    RUN true
+`
+
+func TestCheckVisibility(t *testing.T) {
+	t.Parallel()
+
+	ef, err := earthfile.Parse("Earthfile", visibilityEarthfile)
+	require.NoError(t, err)
+
+	tests := []struct {
+		ref      string
+		referrer string
+		name     string
+		function bool
+		visible  bool
+	}{
+		{name: "public from cli", ref: "./a+build", visible: true},
+		{name: "private from cli", ref: "./a+deps"},
+		{name: "internal from cli", ref: "./a+lib"},
+		{name: "private from same Earthfile", ref: "./a+deps", referrer: "./a+build", visible: true},
+		{name: "private from subdirectory", ref: "./a+deps", referrer: "./a/b+build"},
+		{name: "internal from subdirectory", ref: "./a+lib", referrer: "./a/b+build", visible: true},
+		{name: "internal from sibling", ref: "./a+lib", referrer: "./ab+build"},
+		{name: "internal from parent", ref: "./a+lib", referrer: ".+build"},
+		{
+			name:     "remote internal from subdirectory",
+			ref:      "github.com/foo/bar/a:main+lib",
+			referrer: "github.com/foo/bar/a/b:main+build",
+			visible:  true,
+		},
+		{name: "remote private from local", ref: "github.com/foo/bar/a+deps", referrer: "./a+build"},
+		{
+			name:     "private function from same Earthfile",
+			ref:      "./a+HELPER",
+			referrer: "./a+build",
+			function: true,
+			visible:  true,
+		},
+		{name: "private function from other Earthfile", ref: "./a+HELPER", referrer: "./b+build", function: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				ref domain.Reference
+				err error
+			)
+
+			if tt.function {
+				ref, err = domain.ParseCommand(tt.ref)
+			} else {
+				ref, err = domain.ParseTarget(tt.ref)
+			}
+
+			require.NoError(t, err)
+
+			var referrer domain.Reference
+			if tt.referrer != "" {
+				referrer, err = domain.ParseTarget(tt.referrer)
+				require.NoError(t, err)
+			}
+
+			bc := &buildcontext.Data{
+				Ref:       ref,
+				Features:  &features.Features{TargetVisibility: true},
+				Earthfile: ef,
+			}
+
+			err = bc.CheckVisibility(referrer)
+			if tt.visible {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, buildcontext.ErrNotVisible)
+		})
+	}
+}
+
+func TestCheckVisibility_FeatureDisabled(t *testing.T) {
+	t.Parallel()
+
+	ef, err := earthfile.Parse("Earthfile", visibilityEarthfile)
+	require.NoError(t, err)
+
+	ref, err := domain.ParseTarget("./a+deps")
+	require.NoError(t, err)
+
+	bc := &buildcontext.Data{Ref: ref, Features: &features.Features{}, Earthfile: ef}
+	require.NoError(t, bc.CheckVisibility(nil))
+}
+
+func TestVisibility(t *testing.T) {
+	t.Parallel()
+
+	ef, err := earthfile.Parse("Earthfile", visibilityEarthfile+`
+bad:
+    VISIBILITY secret
+`)
+	require.NoError(t, err)
+
+	want := map[string]string{
+		"build": buildcontext.VisibilityPublic,
+		"deps":  buildcontext.VisibilityPrivate,
+		"lib":   buildcontext.VisibilityInternal,
+	}
+
+	for _, target := range ef.Targets {
+		visibility, err := buildcontext.Visibility(target.Recipe)
+		if target.Name == "bad" {
+			require.ErrorContains(t, err, `invalid VISIBILITY "secret"`)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, want[target.Name], visibility)
+	}
+}
@@ -81,6 +81,7 @@ type Opt struct {
 	CacheExport                           string
 	Enttlmnts                             []entitlements.Entitlement
 	Attachables                           []session.Attachable
+	Breakpoints                           []earthfile2llb.Breakpoint
 	DarwinProxyWait                       time.Duration
 	GitLogLevel                           buildkitgitutil.GitLogLevel
 	ImageResolveMode                      llb.ResolveMode
@@ -106,6 +107,7 @@ type ProjectAdder interface {
 // BuildOpt is a collection of build options.
 type BuildOpt struct {
 	ProjectAdder               ProjectAdder
+	ExportCoordinator          *gatewaycrafter.ExportCoordinator // a new one is used when nil
 	OnlyArtifact               *domain.Artifact
 	Logbus                     *logbus.Bus
 	LocalArtifactWhiteList     *gatewaycrafter.LocalArtifactWhiteList
@@ -255,13 +257,17 @@ func (b *Builder) convertAndBuild(
 		manifestLists         = make(map[string][]dockerutil.Manifest) // parent image -> child images
 		platformImgNames      = make(map[string]struct{})              // ensure that these are unique
 		singPlatImgNames      = make(map[string]struct{})              // ensure that these are unique
-		exportCoordinator     = gatewaycrafter.NewExportCoordinator()
+		exportCoordinator     = opt.ExportCoordinator
 
 		// dirIDs maps a dirIndex to a dirID; the "dir-id" field was introduced
 		// to accommodate parallelism in the WAIT/END PopWaitBlock handling
 		dirIDs = map[int]string{}
 	)
 
+	if exportCoordinator == nil {
+		exportCoordinator = gatewaycrafter.NewExportCoordinator()
+	}
+
 	var (
 		depIndex   = 0
 		imageIndex = 0
@@ -332,6 +338,7 @@ func (b *Builder) convertAndBuild(
 				LLBCaps:                              &caps,
 				InteractiveDebuggerEnabled:           b.opt.InteractiveDebugging,
 				InteractiveDebuggerDebugLevelLogging: b.opt.InteractiveDebuggingDebugLevelLogging,
+				Breakpoints:                          b.opt.Breakpoints,
 				Logbus:                               opt.Logbus,
 				Runner:                               opt.Runner,
 				ProjectAdder:                         opt.ProjectAdder,
@@ -731,11 +738,13 @@ func (b *Builder) convertAndBuild(
 		b.opt.Log.PrintPhaseHeader(PhaseBuild, false, "")
 	}
 
-	err := b.s.buildMainMulti(ctx, buildFunc, onImage, onArtifact, onFinalArtifact, onPull, b.opt.Log)
+	exporterResponse, err := b.s.buildMainMulti(ctx, buildFunc, onImage, onArtifact, onFinalArtifact, onPull, b.opt.Log)
 	if err != nil {
 		return nil, fmt.Errorf("build main: %w", err)
 	}
 
+	exportCoordinator.AddImageDigests(exporterResponse)
+
 	if opt.PrintPhases {
 		b.opt.Log.PrintPhaseFooter(PhaseBuild)
 	}
@@ -761,10 +770,12 @@ func (b *Builder) convertAndBuild(
 		}
 
 		if hasRunPush {
-			err = b.s.buildMainMulti(ctx, buildFunc, onImage, onArtifact, onFinalArtifact, onPull, b.opt.Log)
+			exporterResponse, err = b.s.buildMainMulti(ctx, buildFunc, onImage, onArtifact, onFinalArtifact, onPull, b.opt.Log)
 			if err != nil {
 				return nil, fmt.Errorf("build push: %w", err)
 			}
+
+			exportCoordinator.AddImageDigests(exporterResponse)
 		}
 	}
 
@@ -53,7 +53,7 @@ func (s *solver) buildMainMulti(
 	onFinalArtifact onFinalArtifactFunc,
 	onPullCallback pullping.PullCallback,
 	log *conslogging.ConsoleLogger,
-) error {
+) (map[string]string, error) {
 	ch := make(chan *client.SolveStatus, statusChanSize)
 
 	ctx, cancel := context.WithCancel(ctx)
@@ -63,13 +63,16 @@ func (s *solver) buildMainMulti(
 
 	solveOpt, err := s.newSolveOptMulti(ctx, eg, onImage, onArtifact, onFinalArtifact, onPullCallback, log)
 	if err != nil {
-		return fmt.Errorf("new solve opt: %w", err)
+		return nil, fmt.Errorf("new solve opt: %w", err)
 	}
 
-	var buildErr error
+	var (
+		buildErr         error
+		exporterResponse map[string]string
+	)
 
 	eg.Go(func() error {
-		_, inErr := s.bkClient.Build(ctx, *solveOpt, "", bf, ch)
+		resp, inErr := s.bkClient.Build(ctx, *solveOpt, "", bf, ch)
 		if inErr != nil {
 			if grpcErr, ok := grpcerrors.AsGRPCStatus(inErr); ok {
 				interpreterErr := earthfile2llb.FromError(errors.New(grpcErr.Message()))
@@ -84,6 +87,8 @@ func (s *solver) buildMainMulti(
 			return inErr
 		}
 
+		exporterResponse = resp.ExporterResponse
+
 		return nil
 	})
 	eg.Go(func() error {
@@ -92,14 +97,14 @@ func (s *solver) buildMainMulti(
 	err = eg.Wait()
 
 	if buildErr != nil {
-		return buildErr
+		return nil, buildErr
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return exporterResponse, nil
 }
 
 func (s *solver) newSolveOptMulti(
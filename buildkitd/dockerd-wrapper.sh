@@ -2,6 +2,17 @@
 set -eu
 
 EARTHLY_DOCKERD_CACHE_DATA=${EARTHLY_DOCKERD_CACHE_DATA:-"false"}
+EARTHLY_DOCKER_LAYER_REUSE=${EARTHLY_DOCKER_LAYER_REUSE:-"false"}
+
+# The number of minutes after which the data roots kept to reuse the layers of
+# the loaded images are removed, if they have not been used.
+EARTHLY_DOCKER_LAYER_REUSE_TTL_MINUTES=${EARTHLY_DOCKER_LAYER_REUSE_TTL_MINUTES:-1440}
+case "$EARTHLY_DOCKER_LAYER_REUSE_TTL_MINUTES" in
+    ''|*[!0-9]*)
+        echo >&2 "EARTHLY_DOCKER_LAYER_REUSE_TTL_MINUTES must be a number of minutes"
+        exit 1
+        ;;
+esac
 
 EARTHLY_DOCKER_WRAPPER_DEBUG=${EARTHLY_DOCKER_WRAPPER_DEBUG:-''}
 if [ "$EARTHLY_DOCKER_WRAPPER_DEBUG" = "1" ]; then
@@ -28,12 +39,18 @@ detect_docker_compose_cmd() {
     return 1
 }
 
-# Runs docker-compose with the right -f flags.
+# Runs docker-compose with the right -f, --env-file and --profile flags.
 docker_compose_cmd() {
     compose_file_flags=""
     for f in $EARTHLY_COMPOSE_FILES; do
         compose_file_flags="$compose_file_flags -f $f"
     done
+    for f in ${EARTHLY_COMPOSE_ENV_FILES:-}; do
+        compose_file_flags="$compose_file_flags --env-file $f"
+    done
+    for p in ${EARTHLY_COMPOSE_PROFILES:-}; do
+        compose_file_flags="$compose_file_flags --profile $p"
+    done
     export COMPOSE_HTTP_TIMEOUT=600
     docker_compose="$(detect_docker_compose_cmd)"
     export COMPOSE_PROJECT_NAME="default" # newer versions of docker fail if this is not set; older versions used "default" when it was not set
@@ -41,6 +58,63 @@ docker_compose_cmd() {
     $docker_compose $compose_file_flags "$@"
 }
 
+# Waits for the containers of the compose services to be healthy, or running
+# for those without a healthcheck; containers which exited successfully, such
+# as one-off setup jobs, are ready too. The logs of the services are printed if
+# they are not ready within EARTHLY_COMPOSE_WAIT_TIMEOUT seconds.
+wait_for_compose_services() {
+    wait_timeout="${EARTHLY_COMPOSE_WAIT_TIMEOUT:-120}"
+    echo "Waiting for compose services to be healthy..."
+    start="$(date +%s)"
+    while true; do
+        pending=""
+        failed=""
+        # shellcheck disable=SC2086
+        for container in $(docker_compose_cmd ps -a -q $EARTHLY_COMPOSE_SERVICES); do
+            # shellcheck disable=SC2046
+            set -- $(docker inspect -f '{{index .Config.Labels "com.docker.compose.service"}} {{if .State.Health}}{{.State.Health.Status}}{{else}}{{.State.Status}}{{end}} {{.State.ExitCode}}' "$container")
+            case "${2:-}" in
+                healthy|running)
+                    ;;
+                exited)
+                    if [ "${3:-}" != "0" ]; then
+                        failed="$failed $1"
+                    fi
+                    ;;
+                unhealthy|dead)
+                    failed="$failed $1"
+                    ;;
+                *)
+                    pending="$pending ${1:-$container}"
+                    ;;
+            esac
+        done
+        if [ -n "$failed" ]; then
+            compose_services_failed "compose services failed:$failed"
+        fi
+        if [ -z "$pending" ]; then
+            break
+        fi
+        if [ "$(($(date +%s) - start))" -ge "$wait_timeout" ]; then
+            compose_services_failed "compose services not healthy after ${wait_timeout}s:$pending"
+        fi
+        sleep 1
+    done
+    echo "...done"
+}
+
+compose_services_failed() {
+    echo "ERROR: $1"
+    docker_compose_cmd ps -a || true
+    echo "==== Begin compose services logs ===="
+    # shellcheck disable=SC2086
+    docker_compose_cmd logs --no-color --tail 100 $EARTHLY_COMPOSE_SERVICES || true
+    echo "==== End compose services logs ===="
+    docker_compose_cmd down --remove-orphans || true
+    stop_dockerd
+    exit 1
+}
+
 write_compose_config() {
     mkdir -p /tmp/earthbuild
     docker_compose_cmd config >/tmp/earthbuild/compose-config.yml
@@ -121,6 +195,10 @@ execute() {
         docker images -a --format '{{.Repository}}:{{.Tag}}' | grep -v "^$earthly_cached_docker_image_prefix" | xargs --no-run-if-empty docker rmi --force
     fi
 
+    if [ "$EARTHLY_DOCKER_LAYER_REUSE" = "true" ]; then
+        layers_before="$(list_layers)"
+    fi
+
     load_file_images
     load_registry_images
 
@@ -130,9 +208,16 @@ execute() {
         docker images -f "dangling=true" -q | xargs --no-run-if-empty docker rmi --force
     fi
 
+    if [ "$EARTHLY_DOCKER_LAYER_REUSE" = "true" ]; then
+        print_present_layers "$layers_before"
+    fi
+
     if [ "$EARTHLY_START_COMPOSE" = "true" ]; then
         # shellcheck disable=SC2086
         docker_compose_cmd up -d $EARTHLY_COMPOSE_SERVICES
+        if [ "${EARTHLY_COMPOSE_WAIT:-false}" = "true" ]; then
+            wait_for_compose_services
+        fi
     fi
 
     shift
@@ -146,6 +231,12 @@ execute() {
         docker_compose_cmd down --remove-orphans
     fi
     stop_dockerd
+
+    if [ "$EARTHLY_DOCKER_LAYER_REUSE" = "true" ]; then
+        echo "$EARTHLY_DOCKER_LAYER_REUSE_TTL_MINUTES" >"$EARTHLY_DOCKERD_DATA_ROOT/.earthly-layer-reuse-ttl"
+        touch "$EARTHLY_DOCKERD_DATA_ROOT"
+        remove_stale_layer_data_roots
+    fi
     return "$exit_code"
 }
 
@@ -339,6 +430,50 @@ clean_leftover_docker_objects() {
         docker network prune --force
 }
 
+# Lists the layers of the images in the data root, by chain ID.
+list_layers() {
+    for layer in "$data_root"/image/*/layerdb/sha256/*; do
+        if [ -f "$layer/size" ]; then
+            basename "$layer"
+        fi
+    done
+}
+
+# Prints how many bytes of the layers of the images in the data root were
+# already there before the images were loaded, out of the bytes of all of them.
+# The layers already there are not downloaded again by a pull, but are still
+# read from the tar file of a load. The line is parsed by earthbuild for the
+# exec stats summary.
+print_present_layers() {
+    present=0
+    total=0
+    for layer in $(list_layers); do
+        size="$(cat "$data_root"/image/*/layerdb/sha256/"$layer"/size)"
+        total=$((total + size))
+        if printf '%s\n' "$1" | grep -qx "$layer"; then
+            present=$((present + size))
+        fi
+    done
+    echo "Docker layers already present: $present of $total bytes"
+}
+
+# Removes the data roots kept to reuse the layers of the loaded images, which
+# have not been used for the number of minutes in their
+# .earthly-layer-reuse-ttl file. The data roots in use are locked, and are
+# skipped. buildkitd/entrypoint.sh does the same when buildkitd starts.
+remove_stale_layer_data_roots() {
+    for dir in "$(dirname "$EARTHLY_DOCKERD_DATA_ROOT")"/layers_*; do
+        [ -d "$dir" ] || continue
+        ttl="$(cat "$dir/.earthly-layer-reuse-ttl" 2>/dev/null || true)"
+        case "$ttl" in
+            ''|*[!0-9]*) ttl=1440 ;;
+        esac
+        if [ -n "$(find "$dir" -maxdepth 0 -mmin +"$ttl")" ] && flock -n "$dir/.earthly-docker-lock" rm -rf "$dir"; then
+            echo "Removed unused docker layers in $dir"
+        fi
+    done
+}
+
 load_registry_images() {
     EARTHLY_DOCKER_LOAD_REGISTRY=${EARTHLY_DOCKER_LOAD_REGISTRY:-''}
     if [ -n "$EARTHLY_DOCKER_LOAD_REGISTRY" ]; then
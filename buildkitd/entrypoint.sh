@@ -120,7 +120,17 @@ fi
 ln -sf "/sbin/$IP_TABLES" /sbin/iptables
 
 # clear any leftovers (that aren't explicitly cached) in the dind dir
-find /tmp/earthbuild/dind/ -maxdepth 1 -mindepth 1 | grep -v cache_ | xargs -r rm -rf
+find /tmp/earthbuild/dind/ -maxdepth 1 -mindepth 1 | grep -v -e cache_ -e layers_ | xargs -r rm -rf
+# the layers of WITH DOCKER images are kept across restarts, unless they have not been used for the
+# EARTHLY_DOCKER_LAYER_REUSE_TTL_MINUTES their last WITH DOCKER command recorded (a day by default)
+for dir in /tmp/earthbuild/dind/layers_*; do
+    [ -d "$dir" ] || continue
+    ttl="$(cat "$dir/.earthly-layer-reuse-ttl" 2>/dev/null || true)"
+    case "$ttl" in
+        ''|*[!0-9]*) ttl=1440 ;;
+    esac
+    find "$dir" -maxdepth 0 -mmin +"$ttl" | xargs -r rm -rf
+done
 
 mkdir -p "$EARTHLY_TMP_DIR/dind"
 
@@ -0,0 +1,145 @@
+#!/bin/sh
+set -eu
+
+# services-wrapper.sh starts the services of a RUN --service command, waits for
+# them to be ready, runs the command, and stops the services once it exits.
+#
+# The services are defined by the script generated by earthbuild, which sets
+# $earthbuild_services to the ids of the services, and for each service <i>:
+#   service_<i>_name          the name of the service
+#   service_<i>_root          the root filesystem of the service
+#   service_<i>_port          the port to wait for, or 0
+#   service_<i>_start         a function which runs the service
+#   service_<i>_healthcheck   a function which runs the HEALTHCHECK of the service
+
+EARTHLY_SERVICES_WRAPPER_DEBUG=${EARTHLY_SERVICES_WRAPPER_DEBUG:-''}
+if [ "$EARTHLY_SERVICES_WRAPPER_DEBUG" = "1" ]; then
+    echo "enabling services wrapper debug mode"
+    set -x
+fi
+
+# The number of seconds to wait for each service to be ready.
+EARTHLY_SERVICES_TIMEOUT=${EARTHLY_SERVICES_TIMEOUT:-120}
+
+services_script=/run/earthbuild/services.sh
+services_logs=/run/earthbuild/services-logs
+
+# The services are run with an environment of their own, which may not have
+# chroot in its PATH.
+if ! earthbuild_chroot="$(command -v chroot)"; then
+    echo >&2 "RUN --service requires chroot in the image of the command"
+    exit 1
+fi
+export earthbuild_chroot
+
+# shellcheck source=/dev/null
+. "$services_script"
+
+service_var() {
+    eval "echo \"\$service_${1}_${2}\""
+}
+
+# Creates the devices and the network configuration the service needs in its
+# root filesystem.
+prepare_root() {
+    root="$1"
+    mkdir -p "$root/dev" "$root/etc" "$root/tmp"
+    for dev in null:1:3 zero:1:5 full:1:7 random:1:8 urandom:1:9 tty:5:0; do
+        dev_name="${dev%%:*}"
+        dev_numbers="${dev#*:}"
+        if [ ! -e "$root/dev/$dev_name" ]; then
+            mknod -m 666 "$root/dev/$dev_name" c "${dev_numbers%:*}" "${dev_numbers#*:}" 2>/dev/null || true
+        fi
+    done
+    chmod 1777 "$root/tmp"
+    for f in hosts resolv.conf; do
+        if [ -f "/etc/$f" ]; then
+            rm -f "$root/etc/$f"
+            cp "/etc/$f" "$root/etc/$f"
+        fi
+    done
+}
+
+# Returns 0 if a process listens on the TCP port $1.
+port_listening() {
+    hex_port="$(printf ':%04X' "$1")"
+    cat /proc/net/tcp /proc/net/tcp6 2>/dev/null | \
+        awk -v p="$hex_port" 'substr($2, length($2) - 4) == p && $4 == "0A" { found = 1 } END { exit !found }'
+}
+
+service_failed() {
+    echo >&2 "service $1 $2; last lines of its log:"
+    tail -n 50 "$services_logs/$1.log" >&2 || true
+    stop_services
+    exit 1
+}
+
+start_services() {
+    for id in $earthbuild_services; do
+        name="$(service_var "$id" name)"
+        prepare_root "$(service_var "$id" root)"
+        echo "starting service $name"
+        "service_${id}_start" >"$services_logs/$name.log" 2>&1 &
+        eval "service_${id}_pid=$!"
+    done
+}
+
+wait_for_services() {
+    for id in $earthbuild_services; do
+        name="$(service_var "$id" name)"
+        port="$(service_var "$id" port)"
+        pid="$(service_var "$id" pid)"
+        i=0
+        while true; do
+            if ! kill -0 "$pid" 2>/dev/null; then
+                service_failed "$name" "exited before it was ready"
+            fi
+            if { [ "$port" = "0" ] || port_listening "$port"; } && \
+                "service_${id}_healthcheck" >>"$services_logs/$name.log" 2>&1; then
+                break
+            fi
+            i=$((i + 1))
+            if [ "$i" -ge "$EARTHLY_SERVICES_TIMEOUT" ]; then
+                service_failed "$name" "was not ready after ${EARTHLY_SERVICES_TIMEOUT}s"
+            fi
+            sleep 1
+        done
+        echo "service $name is ready"
+    done
+}
+
+stop_services() {
+    for id in $earthbuild_services; do
+        pid="$(service_var "$id" pid)"
+        if [ -n "$pid" ]; then
+            kill -TERM "$pid" 2>/dev/null || true
+        fi
+    done
+    for id in $earthbuild_services; do
+        pid="$(service_var "$id" pid)"
+        if [ -z "$pid" ]; then
+            continue
+        fi
+        i=0
+        while kill -0 "$pid" 2>/dev/null && [ "$i" -lt 10 ]; do
+            i=$((i + 1))
+            sleep 1
+        done
+        kill -KILL "$pid" 2>/dev/null || true
+    done
+}
+
+for id in $earthbuild_services; do
+    eval "service_${id}_pid=''"
+done
+
+start_services
+wait_for_services
+
+set +e
+"$@"
+exit_code="$?"
+set -e
+
+stop_services
+exit "$exit_code"
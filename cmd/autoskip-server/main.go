@@ -0,0 +1,83 @@
+// Package main provides a reference server for the remote auto-skip protocol,
+// backed by a BoltDB database. Point earth at it with --auto-skip-remote.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
+)
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "autoskip-server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	addr := flag.String("addr", ":8080", "The address to listen on")
+	dbPath := flag.String("db", "auto-skip.db", "The path of the database storing auto-skip hashes")
+	tlsCert := flag.String("tls-cert", "", "The path to a TLS certificate; serves plain HTTP when empty")
+	tlsKey := flag.String("tls-key", "", "The path to the TLS key of --tls-cert")
+	ttl := flag.Duration("ttl", buildkitskipper.DefaultTTL, "How long entries are trusted; 0 means forever")
+	flag.Parse()
+
+	// The token is only read from the environment, so that it does not show
+	// up in process listings.
+	token := os.Getenv("AUTOSKIP_SERVER_TOKEN")
+
+	store, err := buildkitskipper.NewLocal(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close() // #nosec G104
+
+	store.SetTTL(*ttl)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           buildkitskipper.NewHandler(store, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		fmt.Fprintf(os.Stderr, "autoskip-server: listening on %s\n", *addr)
+
+		if *tlsCert != "" {
+			errCh <- srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx) //nolint:contextcheck // The signal context is already done.
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
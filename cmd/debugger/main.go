@@ -12,6 +12,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -187,11 +188,24 @@ func interactiveMode(
 		return err
 	}
 
+	// The host answers with the token of the session, with which earth-debug
+	// copies files from the shell.
+	connDataType, token, err := common.ReadDataPacket(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read the session token: %w", err)
+	}
+
+	if connDataType != common.SessionToken {
+		return fmt.Errorf("unexpected data type %d, expected the session token", connDataType)
+	}
+
 	c, err := cmdBuilder()
 	if err != nil {
 		return err
 	}
 
+	c.Env = append(c.Environ(), common.DebuggerSessionTokenEnv+"="+string(token))
+
 	// once the command completes, waitErr will be set to true, indicating the command finished (or failed)
 	// if it is not set, then that means the interactive debugger has exited before the wrapped command has finished.
 	waitErr := atomic.Pointer[waitError]{}
@@ -324,6 +338,17 @@ func getSettings(path string) (*common.DebuggerSettings, error) {
 func main() {
 	args := os.Args[1:]
 
+	if filepath.Base(os.Args[0]) == debugCommandName {
+		socketPath := common.DebuggerDefaultSocketPath
+
+		debuggerSettings, err := getSettings("/run/secrets/" + common.DebuggerSettingsSecretsKey)
+		if err == nil {
+			socketPath = debuggerSettings.SocketPath
+		}
+
+		os.Exit(debugCommand(context.Background(), socketPath, args))
+	}
+
 	if args[0] == "--version" {
 		return
 	}
@@ -374,7 +399,11 @@ func main() {
 
 		exitCode := 0
 
+		uninstall := installDebugCommand(log)
+
 		err = interactiveMode(ctx, debuggerSettings.SocketPath, cmdBuilder, log)
+		uninstall()
+
 		if err != nil {
 			log.Warnf("%v\n", err)
 
@@ -390,15 +419,101 @@ func main() {
 		os.Exit(exitCode)
 	}
 
+	if debuggerSettings.Break == common.BreakBefore {
+		exitCode := breakpoint(ctx, debuggerSettings, args, "before running", log)
+		if exitCode != 0 {
+			log.Warnf("Breakpoint shell exited with code %d, aborting\n", exitCode)
+			os.Exit(exitCode)
+		}
+	}
+
 	log.VerbosePrintf("running command: (%s); version: %s\n", args, Version)
 
+	err = runCommand(ctx, args)
+	if err != nil && debuggerSettings.Break == common.BreakOnError {
+		log.Warnf("Command %s failed: %v\n", shellescape.QuoteCommand(args), err)
+
+		if breakpoint(ctx, debuggerSettings, args, "after failing", log) == 0 {
+			log.Printf("Running the command again\n")
+
+			err = runCommand(ctx, args)
+		}
+	}
+
+	if err != nil {
+		handleError(ctx, err, debuggerSettings, args, log)
+	}
+}
+
+func runCommand(ctx context.Context, args []string) error {
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204,G702
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	err = cmd.Run()
+	return cmd.Run()
+}
+
+// breakpoint opens an interactive shell at a breakpoint, with the command in
+// the shell history, and returns the exit code of the shell. The build resumes
+// when the shell exits successfully.
+func breakpoint(
+	ctx context.Context,
+	debuggerSettings *common.DebuggerSettings,
+	args []string,
+	when string,
+	log *conslogging.ConsoleLogger,
+) int {
+	quotedCmd := shellescape.QuoteCommand(args)
+
+	log.PrintBar(color.New(color.FgHiMagenta), "🌍 Earth Build Breakpoint "+when, quotedCmd)
+
+	c := color.New(color.FgYellow)
+	c.Println("Exit the shell to resume the build, or exit with a non-zero code to abort it") // #nosec G104
+	// Sometimes the interactive shell doesn't correctly get a newline
+	// Take a brief pause and issue a new line as a work around.
+	time.Sleep(time.Millisecond * 5)
+
+	err := os.Setenv("TERM", debuggerSettings.Term)
 	if err != nil {
-		handleError(ctx, err, debuggerSettings, args, log)
+		log.Warnf("Failed to set term: %v\n", err)
+	}
+
+	exitCode := 0
+
+	uninstall := installDebugCommand(log)
+
+	err = interactiveMode(ctx, debuggerSettings.SocketPath, shellCmdBuilder(ctx, quotedCmd, log), log)
+	uninstall()
+
+	if err != nil {
+		exitCode = 127
+
+		if exitErr, ok := errors.AsType[*exec.ExitError](err); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			log.Warnf("%v\n", err)
+		}
+	}
+
+	log.PrintBar(color.New(color.FgHiMagenta), " End Breakpoint ", "")
+
+	return exitCode
+}
+
+// shellCmdBuilder returns a builder of the command of an interactive shell,
+// with the given command in its history.
+func shellCmdBuilder(ctx context.Context, quotedCmd string, log *conslogging.ConsoleLogger) func() (*exec.Cmd, error) {
+	return func() (*exec.Cmd, error) {
+		_ = populateShellHistory(quotedCmd) // best effort
+
+		shellPath, ok := getShellPath()
+		if !ok {
+			return nil, ErrNoShellFound
+		}
+
+		log.VerbosePrintf("found shell: (%s)\n", shellPath)
+
+		return exec.CommandContext(ctx, shellPath), nil // #nosec G204
 	}
 }
 
@@ -447,20 +562,11 @@ func handleError(
 			log.Warnf("Failed to set term: %v\n", err)
 		}
 
-		cmdBuilder := func() (*exec.Cmd, error) {
-			_ = populateShellHistory(quotedCmd) // best effort
-
-			shellPath, ok := getShellPath()
-			if !ok {
-				return nil, ErrNoShellFound
-			}
+		uninstall := installDebugCommand(log)
 
-			log.VerbosePrintf("found shell: (%s)\n", shellPath)
+		err = interactiveMode(ctx, debuggerSettings.SocketPath, shellCmdBuilder(ctx, quotedCmd, log), log)
+		uninstall()
 
-			return exec.CommandContext(ctx, shellPath), nil // #nosec G204
-		}
-
-		err = interactiveMode(ctx, debuggerSettings.SocketPath, cmdBuilder, log)
 		if err != nil {
 			log.Warnf("%v\n", err)
 		}
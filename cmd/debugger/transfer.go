@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/debugger/common"
+	"github.com/EarthBuild/earthbuild/debugger/filetransfer"
+	"github.com/dustin/go-humanize"
+)
+
+// debugCommandName is the name of the command which copies files between the
+// container and the host, in an interactive debugger session.
+const debugCommandName = "earth-debug"
+
+const debugCommandUsage = `usage:
+  earth-debug get [-f] <path> [<host-path>]  copy a file or directory of the container to the host
+  earth-debug put <host-path> [<path>]       copy a file or directory of the host into the container
+
+Host paths are relative to the directory earth was run from, and default to it.
+Container paths default to the current directory. get refuses to overwrite
+existing files of the host, unless -f is given and it is confirmed on the host.
+`
+
+// installDebugCommand makes the earth-debug command available in the PATH of
+// the interactive shell, and returns a func which removes it.
+func installDebugCommand(log *conslogging.ConsoleLogger) func() {
+	noop := func() {}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.VerbosePrintf("failed to find the debugger executable: %v\n", err)
+		return noop
+	}
+
+	dir, err := os.MkdirTemp("", "earth-debug")
+	if err != nil {
+		log.VerbosePrintf("failed to install %s: %v\n", debugCommandName, err)
+		return noop
+	}
+
+	err = os.Symlink(exe, filepath.Join(dir, debugCommandName))
+	if err != nil {
+		log.VerbosePrintf("failed to install %s: %v\n", debugCommandName, err)
+
+		_ = os.RemoveAll(dir) // Best effort.
+
+		return noop
+	}
+
+	oldPath := os.Getenv("PATH")
+
+	err = os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	if err != nil {
+		log.Warnf("Failed to set path: %v\n", err)
+	}
+
+	return func() {
+		_ = os.Setenv("PATH", oldPath) // Best effort.
+		_ = os.RemoveAll(dir)          // Best effort.
+	}
+}
+
+// debugCommand runs the earth-debug command, and returns its exit code.
+func debugCommand(ctx context.Context, socketPath string, args []string) int {
+	var overwrite bool
+
+	if len(args) > 1 && args[0] == "get" && (args[1] == "-f" || args[1] == "--force") {
+		overwrite = true
+		args = append(args[:1:1], args[2:]...)
+	}
+
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprint(os.Stderr, debugCommandUsage)
+		return 2
+	}
+
+	src := args[1]
+
+	dst := "."
+	if len(args) == 3 {
+		dst = args[2]
+	}
+
+	// Only the shell of the interactive session has its token.
+	token := os.Getenv(common.DebuggerSessionTokenEnv)
+	if token == "" {
+		fmt.Fprintf(os.Stderr, "%s can only be used in an interactive debugger session\n", debugCommandName)
+		return 1
+	}
+
+	var err error
+
+	switch args[0] {
+	case "get":
+		err = getFile(ctx, socketPath, token, src, dst, overwrite)
+	case "put":
+		err = putFile(ctx, socketPath, token, src, dst)
+	default:
+		fmt.Fprint(os.Stderr, debugCommandUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", debugCommandName, args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func getFile(ctx context.Context, socketPath, token, src, dst string, overwrite bool) error {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+
+	if abs == "/" {
+		return errors.New("cannot copy the root directory")
+	}
+
+	root, err := os.OpenRoot(filepath.Dir(abs))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = root.Close() }() // Best effort.
+
+	return withConn(ctx, socketPath, func(conn net.Conn) error {
+		p := newProgress(fmt.Sprintf("Copying %s to the host at %s", src, dst))
+
+		transferErr := filetransfer.Get(conn, token, root, filepath.Base(abs), dst, overwrite, p.update)
+		p.done(transferErr)
+
+		return transferErr
+	})
+}
+
+func putFile(ctx context.Context, socketPath, token, src, dst string) error {
+	abs, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot("/")
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = root.Close() }() // Best effort.
+
+	rel := strings.TrimPrefix(abs, "/")
+	if rel == "" {
+		rel = "."
+	}
+
+	return withConn(ctx, socketPath, func(conn net.Conn) error {
+		p := newProgress(fmt.Sprintf("Copying %s from the host to %s", src, dst))
+
+		transferErr := filetransfer.Put(conn, token, root, src, rel, p.update)
+		p.done(transferErr)
+
+		return transferErr
+	})
+}
+
+func withConn(ctx context.Context, socketPath string, f func(conn net.Conn) error) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to connect to remote debugger: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	return f(conn)
+}
+
+// progress prints the number of bytes copied so far, at most every
+// progressInterval.
+type progress struct {
+	last  time.Time
+	msg   string
+	bytes int64
+}
+
+const progressInterval = 100 * time.Millisecond
+
+func newProgress(msg string) *progress {
+	return &progress{msg: msg}
+}
+
+func (p *progress) update(n int64) {
+	p.bytes = n
+
+	if time.Since(p.last) < progressInterval {
+		return
+	}
+
+	p.last = time.Now()
+
+	fmt.Fprintf(os.Stderr, "\r%s: %s", p.msg, humanize.Bytes(uint64(n))) // #nosec G115
+}
+
+func (p *progress) done(err error) {
+	status := "done"
+	if err != nil {
+		status = "failed"
+	}
+
+	fmt.Fprintf(os.Stderr, "\r%s: %s, %s\n", p.msg, humanize.Bytes(uint64(p.bytes)), status) // #nosec G115
+}
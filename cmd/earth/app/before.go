@@ -64,7 +64,7 @@ func (app *EarthApp) before(ctx context.Context, cmd *cli.Command) (context.Cont
 		flags.Debug,
 		flags.Verbose,
 		flags.DisplayExecStats,
-		app.BaseCLI.Flags().InteractiveDebugging,
+		flags.InteractiveDebugging || len(flags.Breakpoints) > 0,
 		flags.LogstreamDebugFile,
 		uuid.NewString(),
 		execStatsTracker,
@@ -74,6 +74,14 @@ func (app *EarthApp) before(ctx context.Context, cmd *cli.Command) (context.Cont
 		return ctx, fmt.Errorf("logbus setup: %w", err)
 	}
 
+	if flags.JUnitReport != "" {
+		busSetup.SetJUnitReport(flags.JUnitReport)
+	}
+
+	if flags.HTMLReport != "" {
+		busSetup.SetHTMLReport(flags.HTMLReport)
+	}
+
 	app.BaseCLI.SetLogbusSetup(busSetup)
 
 	if cmd.IsSet("config") {
@@ -99,6 +107,11 @@ func (app *EarthApp) before(ctx context.Context, cmd *cli.Command) (context.Cont
 	app.BaseCLI.SetCfg(&cfg)
 	app.processDeprecatedCommandOptions(app.BaseCLI.Cfg())
 
+	// command line option overrides the config
+	if !cmd.IsSet("auto-skip-remote") && cfg.Global.AutoSkipRemote != "" {
+		flags.AutoSkipRemote = cfg.Global.AutoSkipRemote
+	}
+
 	err = app.parseFrontend(ctx)
 	if err != nil {
 		return ctx, err
@@ -232,8 +245,9 @@ func (app *EarthApp) warnDeprecatedEarthlyEnvVars() {
 }
 
 // warnDeprecatedAutoSkip warns when any of the auto-skip flags or env vars are
-// used. The cloud backend that once powered auto-skip has been removed; only
-// the local database (--auto-skip-db-path) still functions. The flags and env
+// used. The cloud backend that once powered auto-skip has been removed; the
+// local database (--auto-skip-db-path) and self-hosted remote stores
+// (--auto-skip-remote) still function. The flags and env
 // vars are deprecated, and we are collecting feedback to decide whether to
 // remove them in the future.
 func (app *EarthApp) warnDeprecatedAutoSkip() {
@@ -253,7 +267,8 @@ func autoSkipDeprecationWarning(skipBuildkit, noAutoSkip bool, localSkipDB strin
 
 	return "Deprecation: --auto-skip, --no-auto-skip and --auto-skip-db-path (and their " +
 		"EARTH_AUTO_SKIP* / EARTHLY_AUTO_SKIP* env vars) are deprecated. " +
-		"The cloud auto-skip backend has been removed; only the local database (--auto-skip-db-path) still functions. " +
+		"The cloud auto-skip backend has been removed; the local database (--auto-skip-db-path) " +
+		"and self-hosted remote stores (--auto-skip-remote) still function. " +
 		"We may remove these in a future release and are collecting feedback to help decide. " +
 		"Let us know how you use auto-skip at https://github.com/orgs/EarthBuild/discussions/707"
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/EarthBuild/earthbuild/inputgraph"
 	"github.com/EarthBuild/earthbuild/internal/env"
 	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/buildresult"
 	"github.com/EarthBuild/earthbuild/util/containerutil"
 	"github.com/EarthBuild/earthbuild/util/errutil"
 	"github.com/EarthBuild/earthbuild/util/hint"
@@ -118,6 +119,15 @@ func (app *EarthApp) run(ctx context.Context, args []string, lastSignal *syncuti
 					fmt.Fprintf(os.Stderr, "Error dumping manifest: %v\n", err)
 				}
 			}
+
+			if app.BaseCLI.Flags().ResultFile != "" {
+				result := buildresult.New(app.BaseCLI.LogbusSetup().Manifest(), app.BaseCLI.ExportCoordinator())
+
+				err := result.WriteFile(app.BaseCLI.Flags().ResultFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing result file: %v\n", err)
+				}
+			}
 		}
 	}()
 	defer app.BaseCLI.ExecuteDeferredFuncs()
@@ -7,6 +7,7 @@ import (
 	"github.com/EarthBuild/earthbuild/conslogging"
 	"github.com/EarthBuild/earthbuild/logbus"
 	"github.com/EarthBuild/earthbuild/logbus/setup"
+	"github.com/EarthBuild/earthbuild/util/gatewaycrafter"
 	"github.com/urfave/cli/v3"
 )
 
@@ -22,6 +23,7 @@ type CLI struct {
 	app                     *cli.Command
 	cfg                     *config.Config
 	logbusSetup             *setup.BusSetup
+	exportCoordinator       *gatewaycrafter.ExportCoordinator
 	logbus                  *logbus.Bus
 	log                     *conslogging.ConsoleLogger
 	flags                   flag.Global
@@ -218,6 +220,16 @@ func (c *CLI) Flags() *flag.Global {
 	return &c.flags
 }
 
+// ExportCoordinator returns the coordinator which collects the outputs of the
+// build, for --result-file.
+func (c *CLI) ExportCoordinator() *gatewaycrafter.ExportCoordinator {
+	if c.exportCoordinator == nil {
+		c.exportCoordinator = gatewaycrafter.NewExportCoordinator()
+	}
+
+	return c.exportCoordinator
+}
+
 // AddDeferredFunc adds a function to be executed after the app is run.
 func (c *CLI) AddDeferredFunc(f func()) {
 	c.deferredFuncs = append([]func(){f}, c.deferredFuncs...)
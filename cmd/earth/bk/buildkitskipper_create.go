@@ -2,27 +2,115 @@ package bk
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/EarthBuild/earthbuild/inputgraph"
 	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
 )
 
 // BuildkitSkipper adds new auto-skip hashes to the backing datastore & allows
 // us to check for their existence.
 type BuildkitSkipper interface {
-	Add(ctx context.Context, target string, key []byte) error
+	Add(ctx context.Context, entry buildkitskipper.Entry, key []byte) error
 	Exists(ctx context.Context, key []byte) (bool, error)
 }
 
-// NewBuildkitSkipper returns a local buildkitskipper when localSkipDB is specified.
-func NewBuildkitSkipper(localSkipDB string) (BuildkitSkipper, error) {
-	if localSkipDB == "" {
+// ManifestStore is implemented by the BuildkitSkipper implementations which
+// keep the input manifest of recorded builds, for earth explain-skip.
+type ManifestStore interface {
+	AddManifest(ctx context.Context, rec buildkitskipper.Record, manifest []byte) error
+}
+
+// AddManifest stores the input manifest of a recorded build, if the skipper
+// keeps manifests.
+func AddManifest(
+	ctx context.Context, skipper BuildkitSkipper, rec buildkitskipper.Record, manifest *inputgraph.Manifest,
+) error {
+	ms, ok := skipper.(ManifestStore)
+	if !ok {
+		return nil
+	}
+
+	dt, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	return ms.AddManifest(ctx, rec, dt)
+}
+
+// BuildkitSkipperOpt configures NewBuildkitSkipper.
+type BuildkitSkipperOpt struct {
+	// Warnf reports problems that do not prevent auto-skip from working, such
+	// as an unreachable remote store.
+	Warnf func(format string, args ...any)
+	// LocalDB is the path of the local database. With a remote store, it is
+	// used as a read-through cache.
+	LocalDB string
+	// RemoteURL is the address of a remote store shared between machines.
+	RemoteURL string
+	// RemoteToken is the bearer token sent to an HTTP remote store.
+	RemoteToken string
+	// TTL is how long entries are trusted; zero means forever.
+	TTL time.Duration
+}
+
+// NewBuildkitSkipper returns a remote buildkitskipper when a remote store is
+// configured, or a local one when only a local database is specified.
+func NewBuildkitSkipper(ctx context.Context, opt BuildkitSkipperOpt) (BuildkitSkipper, error) {
+	if opt.RemoteURL != "" {
+		return newRemoteSkipper(ctx, opt)
+	}
+
+	if opt.LocalDB == "" {
 		return nil, nil // will disable autoskipper.
 	}
 
-	skipDB, err := buildkitskipper.NewLocal(localSkipDB)
+	skipDB, err := buildkitskipper.NewLocal(opt.LocalDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buildkit skipper database %s: %w", opt.LocalDB, err)
+	}
+
+	skipDB.SetTTL(opt.TTL)
+
+	return skipDB, nil
+}
+
+func newRemoteSkipper(ctx context.Context, opt BuildkitSkipperOpt) (BuildkitSkipper, error) {
+	warnf := opt.Warnf
+	if warnf == nil {
+		warnf = func(string, ...any) {}
+	}
+
+	var cache *buildkitskipper.LocalBuildkitSkipper
+
+	if opt.LocalDB != "" {
+		var err error
+
+		cache, err = buildkitskipper.NewLocalCache(opt.LocalDB)
+		if err != nil {
+			// The cache is only an optimization; carry on without it.
+			warnf("Failed to open local auto-skip cache %s: %v", opt.LocalDB, err)
+
+			cache = nil
+		} else {
+			cache.SetTTL(opt.TTL)
+		}
+	}
+
+	skipDB, err := buildkitskipper.NewRemote(ctx, buildkitskipper.RemoteOpt{
+		URL:   opt.RemoteURL,
+		Token: opt.RemoteToken,
+		Cache: cache,
+		TTL:   opt.TTL,
+		OnUnavailable: func(err error) {
+			warnf("Remote auto-skip store is unavailable, continuing with the local cache only: %v", err)
+		},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open buildkit skipper database %s: %w", localSkipDB, err)
+		return nil, fmt.Errorf("failed to configure remote auto-skip store: %w", err)
 	}
 
 	return skipDB, nil
@@ -7,6 +7,7 @@ import (
 
 	"github.com/EarthBuild/earthbuild/buildkitd"
 	"github.com/EarthBuild/earthbuild/cmd/earth/common"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
 	"github.com/EarthBuild/earthbuild/util/containerutil"
 	"github.com/urfave/cli/v3"
 )
@@ -50,17 +51,26 @@ type Global struct {
 	RemoteCache                string
 	SecretFile                 string
 	LocalSkipDB                string
+	AutoSkipRemote             string
+	AutoSkipRemoteToken        string
 	LogstreamDebugFile         string
 	LogstreamDebugManifestFile string
+	ResultFile                 string
+	JUnitReport                string
+	HTMLReport                 string
 	GitLFSPullInclude          string
 	BuildkitHost               string
 	BuildkitdImage             string
 	ContainerName              string
 	ContainerFrontend          containerutil.ContainerFrontend
+	Breakpoints                []string
 	BuildkitdSettings          buildkitd.Settings
 	ServerConnTimeout          time.Duration
+	AutoSkipTTL                time.Duration
 	ConversionParallelism      int
 	InteractiveDebugging       bool
+	BreakBefore                bool
+	BreakOnError               bool
 	NoCache                    bool
 	NoBuildkitUpdate           bool
 	DisplayExecStats           bool
@@ -289,6 +299,24 @@ func (global *Global) RootFlags(installName string, bkImage string) []cli.Flag {
 			Usage:       common.Wrap("Do not output artifacts or images", "(using --push is still allowed)"),
 			Destination: &global.NoOutput,
 		},
+		&cli.StringFlag{
+			Name:        "result-file",
+			Sources:     EarthEnvVars("RESULT_FILE"),
+			Usage:       "Write a JSON summary of the targets, artifacts, images and failures of the build to a file",
+			Destination: &global.ResultFile,
+		},
+		&cli.StringFlag{
+			Name:        "junit-report",
+			Sources:     EarthEnvVars("JUNIT_REPORT"),
+			Usage:       "Write a JUnit XML report of the build to a file, with a testsuite per target",
+			Destination: &global.JUnitReport,
+		},
+		&cli.StringFlag{
+			Name:        "html-report",
+			Sources:     EarthEnvVars("HTML_REPORT"),
+			Usage:       "Write a self-contained HTML report of the build, with its timeline and logs, to a file",
+			Destination: &global.HTMLReport,
+		},
 		&cli.BoolFlag{
 			Name:        "no-cache",
 			Sources:     EarthEnvVars("NO_CACHE"),
@@ -334,6 +362,24 @@ func (global *Global) RootFlags(installName string, bkImage string) []cli.Flag {
 			Usage:       "Enable interactive debugging",
 			Destination: &global.InteractiveDebugging,
 		},
+		&cli.StringSliceFlag{
+			Name:        "break",
+			Sources:     EarthEnvVars("BREAK"),
+			Usage:       "Pause the build at the RUN command of a local target, specified as <target-ref>:<line>",
+			Destination: &global.Breakpoints,
+		},
+		&cli.BoolFlag{
+			Name:        "break-before",
+			Sources:     EarthEnvVars("BREAK_BEFORE"),
+			Usage:       "Open the shell of a --break breakpoint before running the command (default)",
+			Destination: &global.BreakBefore,
+		},
+		&cli.BoolFlag{
+			Name:        "break-on-error",
+			Sources:     EarthEnvVars("BREAK_ON_ERROR"),
+			Usage:       "Open the shell of a --break breakpoint only if the command fails",
+			Destination: &global.BreakOnError,
+		},
 		&cli.BoolFlag{
 			Name:        "no-fake-dep",
 			Sources:     EarthEnvVars("NO_FAKE_DEP"),
@@ -369,6 +415,26 @@ func (global *Global) RootFlags(installName string, bkImage string) []cli.Flag {
 			Usage:       "use a local database for auto-skip",
 			Destination: &global.LocalSkipDB,
 		},
+		&cli.StringFlag{
+			Name:    "auto-skip-remote",
+			Sources: EarthEnvVars("AUTO_SKIP_REMOTE"),
+			Usage: "Share auto-skip hashes through a remote store: an http(s):// URL of an auto-skip server, " +
+				"or s3://bucket/prefix for an S3-compatible store",
+			Destination: &global.AutoSkipRemote,
+		},
+		&cli.StringFlag{
+			Name:        "auto-skip-remote-token",
+			Sources:     EarthEnvVars("AUTO_SKIP_REMOTE_TOKEN"),
+			Usage:       "The bearer token to send to an http(s) auto-skip remote store",
+			Destination: &global.AutoSkipRemoteToken,
+		},
+		&cli.DurationFlag{
+			Name:        "auto-skip-ttl",
+			Sources:     EarthEnvVars("AUTO_SKIP_TTL"),
+			Usage:       "How long a recorded auto-skip entry is trusted before the target runs again; 0 means forever",
+			Value:       buildkitskipper.DefaultTTL,
+			Destination: &global.AutoSkipTTL,
+		},
 		&cli.StringFlag{
 			Name:        "buildkit-image",
 			Value:       bkImage,
@@ -0,0 +1,225 @@
+package subcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/EarthBuild/earthbuild/cmd/earth/common"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/inputgraph"
+	"github.com/EarthBuild/earthbuild/util/gitutil"
+	"github.com/EarthBuild/earthbuild/util/params"
+	"github.com/EarthBuild/earthbuild/variables"
+	"github.com/joho/godotenv"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	affectedFormatText = "text"
+	affectedFormatJSON = "json"
+)
+
+// Affected encapsulates the affected command logic.
+type Affected struct {
+	cli      CLI
+	buildCmd *Build
+
+	// out is stdout; nil means [os.Stdout]. Injectable so tests can capture
+	// output without hijacking the global.
+	out io.Writer
+
+	since  string
+	format string
+	build  bool
+}
+
+// NewAffected creates a new Affected command.
+func NewAffected(cli CLI, buildCmd *Build) *Affected {
+	return &Affected{
+		cli:      cli,
+		buildCmd: buildCmd,
+	}
+}
+
+func (a *Affected) writer() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+
+	return a.out
+}
+
+// Cmds returns the list of commands for the affected command.
+func (a *Affected) Cmds() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "affected",
+			Usage: "List or build the targets affected by the changes since a git ref",
+			UsageText: "earth [options] affected --since <git-ref> [--build] [--format text|json] " +
+				"[<target-ref>...] [--<build-arg-name>=<build-arg-value>...]",
+			Description: "Lists the targets whose inputs, or whose local dependencies' inputs, changed since " +
+				"the merge base of the git ref and HEAD: the files they copy and their Earthfiles. " +
+				"Without target references, all the targets of the Earthfiles in the current directory " +
+				"and its subdirectories are considered. Targets which can't be analyzed statically are " +
+				"reported, and listed as affected.",
+			Action: a.action,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "since",
+					Usage:       "The git ref to compare the working tree with",
+					Required:    true,
+					Destination: &a.since,
+				},
+				&cli.BoolFlag{
+					Name:        "build",
+					Usage:       "Build the affected targets, one after the other, instead of listing them",
+					Destination: &a.build,
+				},
+				&cli.StringFlag{
+					Name:        "format",
+					Usage:       "The output format: text, a target per line, or json, with the status of each target",
+					Value:       affectedFormatText,
+					Destination: &a.format,
+				},
+			},
+		},
+	}
+}
+
+func (a *Affected) action(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("affected")
+
+	if a.format != affectedFormatText && a.format != affectedFormatJSON {
+		return params.Errorf("invalid --format %q, expected %s or %s", a.format, affectedFormatText, affectedFormatJSON)
+	}
+
+	if a.build && (a.cli.Flags().ImageMode || a.cli.Flags().ArtifactMode) {
+		return params.Errorf("--build cannot be used with image or artifact modes")
+	}
+
+	flagArgs, nonFlagArgs, err := variables.ParseFlagArgsWithNonFlags(cmd.Args().Slice())
+	if err != nil {
+		return params.Errorf("%s", err.Error())
+	}
+
+	targets := make([]domain.Target, 0, len(nonFlagArgs))
+
+	for _, arg := range nonFlagArgs {
+		target, err := domain.ParseTarget(arg)
+		if err != nil {
+			return params.Errorf("invalid target %s", arg)
+		}
+
+		if target.IsRemote() {
+			return params.Errorf("remote target %s cannot be analyzed", arg)
+		}
+
+		targets = append(targets, target)
+	}
+
+	var invalid []inputgraph.InvalidEarthfile
+
+	if len(targets) == 0 {
+		targets, invalid, err = inputgraph.FindTargets(".")
+		if err != nil {
+			return err
+		}
+	}
+
+	flags := a.cli.Flags()
+
+	argMap, err := godotenv.Read(flags.ArgFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", flags.ArgFile, err)
+	}
+
+	overridingVars, err := common.CombineVariables(argMap, flagArgs, nil)
+	if err != nil {
+		return err
+	}
+
+	changed, err := gitutil.ChangedFiles(ctx, ".", a.since)
+	if err != nil {
+		return fmt.Errorf("failed to list the files changed since %s: %w", a.since, err)
+	}
+
+	a.cli.Log().VerbosePrintf("%d files changed since %s\n", len(changed), a.since)
+
+	results, err := inputgraph.Affected(ctx, inputgraph.AffectedOpt{
+		Targets:        targets,
+		Invalid:        invalid,
+		Changed:        changed,
+		Log:            a.cli.Log(),
+		CI:             flags.CI,
+		BuiltinArgs:    variables.DefaultArgs{EarthVersion: a.cli.Version(), EarthBuildSha: a.cli.GitSHA()},
+		OverridingVars: overridingVars,
+	})
+	if err != nil {
+		return err
+	}
+
+	var affected []string
+
+	for _, r := range results {
+		switch r.Status {
+		case inputgraph.StatusAffected:
+			a.cli.Log().VerbosePrintf("%s is affected: %s\n", r.Target, r.Reason)
+		case inputgraph.StatusUnknown:
+			a.cli.Log().Warnf("%s can't be analyzed statically, so it is considered affected: %s\n", r.Target, r.Reason)
+		case inputgraph.StatusUnaffected:
+			continue
+		}
+
+		affected = append(affected, r.Target)
+	}
+
+	if a.build {
+		return a.buildAffected(ctx, cmd, affected, invalid, flagArgs)
+	}
+
+	if a.format == affectedFormatJSON {
+		enc := json.NewEncoder(a.writer())
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(results)
+	}
+
+	for _, target := range affected {
+		fmt.Fprintln(a.writer(), target)
+	}
+
+	return nil
+}
+
+func (a *Affected) buildAffected(
+	ctx context.Context, cmd *cli.Command, targets []string, invalid []inputgraph.InvalidEarthfile, flagArgs []string,
+) error {
+	if len(targets) == 0 {
+		a.cli.Log().Printf("No target is affected by the changes since %s\n", a.since)
+		return nil
+	}
+
+	err := a.buildCmd.checkFlags()
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		// The Earthfiles which failed to parse are listed after the targets.
+		if slices.ContainsFunc(invalid, func(inv inputgraph.InvalidEarthfile) bool { return inv.Dir == target }) {
+			return fmt.Errorf("failed to build the targets in %s: its Earthfile failed to parse", target)
+		}
+
+		err = a.buildCmd.ActionBuildImp(ctx, cmd, flagArgs, []string{target})
+		if err != nil {
+			return fmt.Errorf("failed to build %s: %w", target, err)
+		}
+	}
+
+	return nil
+}
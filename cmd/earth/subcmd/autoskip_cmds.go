@@ -0,0 +1,303 @@
+package subcmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/cmd/earth/flag"
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
+	"github.com/EarthBuild/earthbuild/util/cliutil"
+	"github.com/dustin/go-humanize"
+	"github.com/urfave/cli/v3"
+)
+
+// autoSkipCacheName is the name of the local cache of a remote auto-skip
+// store, in the earth dir.
+const autoSkipCacheName = "auto-skip-cache.db"
+
+// AutoSkip encapsulates the autoskip command logic.
+type AutoSkip struct {
+	cli CLI
+
+	// out is stdout; nil means [os.Stdout]. Injectable so tests can capture
+	// output without hijacking the global.
+	out io.Writer
+
+	olderThan time.Duration
+	json      bool
+}
+
+// NewAutoSkip creates a new AutoSkip command.
+func NewAutoSkip(cli CLI) *AutoSkip {
+	return &AutoSkip{
+		cli: cli,
+	}
+}
+
+func (a *AutoSkip) writer() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+
+	return a.out
+}
+
+// Cmds returns the list of commands for the autoskip command.
+func (a *AutoSkip) Cmds() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "autoskip",
+			Usage: "Inspect and maintain the auto-skip database",
+			Description: "Inspect and maintain the local auto-skip database given by --auto-skip-db-path " +
+				"(or the local cache of --auto-skip-remote).",
+			Commands: []*cli.Command{
+				{
+					Name:        "ls",
+					Usage:       "List the recorded auto-skip entries",
+					UsageText:   "earth [options] autoskip ls [--json]",
+					Description: "Lists the recorded auto-skip entries, newest first, marking those past --auto-skip-ttl.",
+					Action:      a.actionLs,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:        "json",
+							Usage:       "Print the entries as JSON",
+							Destination: &a.json,
+						},
+					},
+				},
+				{
+					Name:      "prune",
+					Usage:     "Delete old auto-skip entries",
+					UsageText: "earth [options] autoskip prune [--older-than <duration>]",
+					Description: "Deletes the auto-skip entries older than --older-than, " +
+						"or past --auto-skip-ttl when it is not given.",
+					Action: a.actionPrune,
+					Flags: []cli.Flag{
+						&cli.DurationFlag{
+							Name:        "older-than",
+							Usage:       "Delete the entries older than this, e.g. 72h",
+							Destination: &a.olderThan,
+						},
+					},
+				},
+				{
+					Name:      "forget",
+					Usage:     "Delete the auto-skip entries of targets",
+					UsageText: "earth [options] autoskip forget <target-ref>...",
+					Description: "Deletes the auto-skip entries of the given targets, so that they run again. " +
+						"Local targets are resolved the same way as during a build.",
+					Action: a.actionForget,
+				},
+			},
+		},
+	}
+}
+
+func (a *AutoSkip) actionLs(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("autoSkipLs")
+
+	if cmd.NArg() != 0 {
+		return errors.New("invalid number of arguments provided")
+	}
+
+	db, err := openAutoSkipDB(a.cli.Flags())
+	if err != nil {
+		return err
+	}
+	defer db.Close() // #nosec G104
+
+	return a.list(ctx, db, time.Now())
+}
+
+func (a *AutoSkip) actionPrune(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("autoSkipPrune")
+
+	if cmd.NArg() != 0 {
+		return errors.New("invalid number of arguments provided")
+	}
+
+	olderThan := a.olderThan
+	if olderThan == 0 {
+		olderThan = a.cli.Flags().AutoSkipTTL
+	}
+
+	if olderThan <= 0 {
+		return errors.New("--older-than is required when --auto-skip-ttl is 0")
+	}
+
+	db, err := openAutoSkipDB(a.cli.Flags())
+	if err != nil {
+		return err
+	}
+	defer db.Close() // #nosec G104
+
+	n, err := db.Prune(ctx, time.Now().Add(-olderThan))
+	if err != nil {
+		return fmt.Errorf("failed to prune auto-skip database: %w", err)
+	}
+
+	a.cli.Log().Printf("Deleted %d auto-skip entries older than %s\n", n, olderThan)
+
+	return nil
+}
+
+func (a *AutoSkip) actionForget(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("autoSkipForget")
+
+	if cmd.NArg() == 0 {
+		return errors.New("at least one target is required")
+	}
+
+	var targets []string
+
+	for _, arg := range cmd.Args().Slice() {
+		names, err := autoSkipTargetNames(ctx, a.cli.Log(), arg, a.cli.Flags().GitBranchOverride)
+		if err != nil {
+			return err
+		}
+
+		targets = append(targets, names...)
+	}
+
+	db, err := openAutoSkipDB(a.cli.Flags())
+	if err != nil {
+		return err
+	}
+	defer db.Close() // #nosec G104
+
+	n, err := db.Forget(ctx, targets...)
+	if err != nil {
+		return fmt.Errorf("failed to forget auto-skip entries: %w", err)
+	}
+
+	a.cli.Log().Printf("Deleted %d auto-skip entries\n", n)
+
+	return nil
+}
+
+// openAutoSkipDB opens the configured local auto-skip database, which must
+// exist.
+func openAutoSkipDB(flags *flag.Global) (*buildkitskipper.LocalBuildkitSkipper, error) {
+	path := autoSkipDBPath(flags)
+	if path == "" {
+		return nil, errors.New("no auto-skip database configured; use --auto-skip-db-path")
+	}
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open auto-skip database: %w", err)
+	}
+
+	// Don't wait forever on a running build, which holds the database.
+	db, err := buildkitskipper.NewLocalCache(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open auto-skip database %s (is a build running?): %w", path, err)
+	}
+
+	return db, nil
+}
+
+// autoSkipListEntry is an entry as printed by autoskip ls --json.
+type autoSkipListEntry struct {
+	Hash string `json:"hash"`
+
+	buildkitskipper.Entry
+
+	Expired bool `json:"expired"`
+}
+
+func (a *AutoSkip) list(ctx context.Context, db *buildkitskipper.LocalBuildkitSkipper, now time.Time) error {
+	records, err := db.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list auto-skip entries: %w", err)
+	}
+
+	ttl := a.cli.Flags().AutoSkipTTL
+
+	if a.json {
+		entries := make([]autoSkipListEntry, 0, len(records))
+		for _, r := range records {
+			entries = append(entries, autoSkipListEntry{
+				Entry:   r.Entry,
+				Hash:    hex.EncodeToString(r.Hash),
+				Expired: r.Expired(ttl, now),
+			})
+		}
+
+		enc := json.NewEncoder(a.writer())
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(entries)
+	}
+
+	w := tabwriter.NewWriter(a.writer(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HASH\tTARGET\tCREATED\tVERSION\tARGS\t")
+
+	for _, r := range records {
+		created := "unknown"
+		if !r.Created.IsZero() {
+			created = humanize.RelTime(r.Created, now, "ago", "from now")
+		}
+
+		if r.Expired(ttl, now) {
+			created += " (expired)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n",
+			hex.EncodeToString(r.Hash)[:12], r.Target, created, r.EarthVersion, strings.Join(r.Args, " "))
+	}
+
+	return w.Flush()
+}
+
+// autoSkipDBPath returns the path of the local auto-skip database: the one
+// given by --auto-skip-db-path, or else the cache of the remote store in the
+// earth dir. It is empty when auto-skip has no database.
+func autoSkipDBPath(flags *flag.Global) string {
+	if flags.LocalSkipDB != "" || flags.AutoSkipRemote == "" {
+		return flags.LocalSkipDB
+	}
+
+	earthDir, err := cliutil.GetOrCreateEarthDir(flags.InstallationName)
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(earthDir, autoSkipCacheName)
+}
+
+// autoSkipTargetNames returns the canonical names under which the target may
+// have been recorded: as given, as a BUILD --auto-skip records it, and with
+// the git metadata a top-level --auto-skip build records.
+func autoSkipTargetNames(
+	ctx context.Context, console *conslogging.ConsoleLogger, ref, branchOverride string,
+) ([]string, error) {
+	target, err := domain.ParseTarget(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %w", ref, err)
+	}
+
+	names := []string{ref, target.StringCanonical()}
+
+	if target.IsRemote() {
+		return names, nil
+	}
+
+	recorded, err := autoSkipRecordedTarget(ctx, console, target, branchOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(names, recorded.StringCanonical()), nil
+}
@@ -0,0 +1,74 @@
+package subcmd
+
+import (
+	"bytes"
+	"crypto/sha1" // #nosec G505
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/cmd/earth/base"
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoSkipList(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	db, err := buildkitskipper.NewLocal(filepath.Join(t.TempDir(), "skip.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	newHash := sha1.Sum([]byte("new")) // #nosec G401
+	oldHash := sha1.Sum([]byte("old")) // #nosec G401
+
+	require.NoError(t, db.Add(ctx, buildkitskipper.Entry{
+		Created: now.Add(-time.Hour), Target: "+new", EarthVersion: "v1.0.0", Args: []string{"A=1", "B=2"},
+	}, newHash[:]))
+	require.NoError(t, db.Add(ctx, buildkitskipper.Entry{
+		Created: now.Add(-30 * 24 * time.Hour), Target: "+old",
+	}, oldHash[:]))
+
+	cli := base.NewCLI(new(conslogging.ConsoleLogger))
+	cli.Flags().AutoSkipTTL = buildkitskipper.DefaultTTL
+
+	var out bytes.Buffer
+
+	a := &AutoSkip{cli: cli, out: &out}
+	require.NoError(t, a.list(ctx, db, now))
+	require.Equal(t, ""+
+		"HASH          TARGET  CREATED                VERSION  ARGS     \n"+
+		"c2a6b03f190d  +new    1 hour ago             v1.0.0   A=1 B=2  \n"+
+		"c00dbbc9dadf  +old    1 month ago (expired)                    \n",
+		out.String())
+
+	out.Reset()
+
+	a.json = true
+	require.NoError(t, a.list(ctx, db, now))
+
+	var entries []autoSkipListEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	require.Equal(t, "+new", entries[0].Target)
+	require.False(t, entries[0].Expired)
+	require.Equal(t, []string{"A=1", "B=2"}, entries[0].Args)
+	require.True(t, entries[1].Expired)
+}
+
+func TestAutoSkipTargetNames(t *testing.T) {
+	t.Parallel()
+
+	names, err := autoSkipTargetNames(t.Context(), new(conslogging.ConsoleLogger), "github.com/foo/bar:main+build", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"github.com/foo/bar:main+build", "github.com/foo/bar:main+build"}, names)
+
+	_, err = autoSkipTargetNames(t.Context(), new(conslogging.ConsoleLogger), "not a target", "")
+	require.ErrorContains(t, err, "invalid target")
+}
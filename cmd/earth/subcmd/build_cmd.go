@@ -1,7 +1,10 @@
 package subcmd
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +14,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/EarthBuild/earthbuild/buildcontext"
 	"github.com/EarthBuild/earthbuild/buildcontext/provider"
@@ -20,12 +25,16 @@ import (
 	"github.com/EarthBuild/earthbuild/cmd/earth/bk"
 	"github.com/EarthBuild/earthbuild/cmd/earth/common"
 	"github.com/EarthBuild/earthbuild/cmd/earth/flag"
+	"github.com/EarthBuild/earthbuild/conslogging"
 	debuggercommon "github.com/EarthBuild/earthbuild/debugger/common"
+	"github.com/EarthBuild/earthbuild/debugger/filetransfer"
 	"github.com/EarthBuild/earthbuild/debugger/terminal"
 	"github.com/EarthBuild/earthbuild/docker2earth"
 	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/earthfile2llb"
 	"github.com/EarthBuild/earthbuild/inputgraph"
 	"github.com/EarthBuild/earthbuild/states"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
 	"github.com/EarthBuild/earthbuild/util/cliutil"
 	"github.com/EarthBuild/earthbuild/util/containerutil"
 	"github.com/EarthBuild/earthbuild/util/flagutil"
@@ -59,14 +68,20 @@ const autoSkipPrefix = "auto-skip"
 
 // Build encapsulates the build command logic.
 type Build struct {
-	cli          CLI
-	dockerTarget string
-	buildArgs    []string
-	platformsStr []string
-	secrets      []string
-	secretFiles  []string
-	cacheFrom    []string
-	dockerTags   []string
+	cli               CLI
+	dockerTarget      string
+	matrixName        string
+	buildArgs         []string
+	platformsStr      []string
+	secrets           []string
+	secretFiles       []string
+	cacheFrom         []string
+	dockerTags        []string
+	matrixInclude     []string
+	matrixExclude     []string
+	matrixMaxParallel int
+	matrix            bool
+	matrixContinue    bool
 }
 
 // NewBuild creates a new Build command.
@@ -133,6 +148,30 @@ func (b *Build) Cmds() []*cli.Command {
 func (b *Build) Action(ctx context.Context, cmd *cli.Command) error {
 	b.cli.SetCommandName("build")
 
+	err := b.checkFlags()
+	if err != nil {
+		return err
+	}
+
+	flagArgs, nonFlagArgs, err := variables.ParseFlagArgsWithNonFlags(cmd.Args().Slice())
+	if err != nil {
+		if invalidFlagErr, ok := errors.AsType[*variables.InvalidFlagError](err); ok {
+			return params.Errorf("%s", invalidFlagErr.Error())
+		}
+
+		return fmt.Errorf("parse args %s: %w", strings.Join(cmd.Args().Slice(), " "), err)
+	}
+
+	if b.isMatrix() {
+		return b.actionMatrixBuild(ctx, cmd, flagArgs, nonFlagArgs)
+	}
+
+	return b.ActionBuildImp(ctx, cmd, flagArgs, nonFlagArgs)
+}
+
+// checkFlags validates the combination of the global build flags, and
+// adjusts them for --ci.
+func (b *Build) checkFlags() error {
 	if b.cli.Flags().CI {
 		b.cli.Flags().NoOutput = !b.cli.Flags().Output && !b.cli.Flags().ArtifactMode && !b.cli.Flags().ImageMode
 		b.cli.Flags().Strict = true
@@ -140,6 +179,10 @@ func (b *Build) Action(ctx context.Context, cmd *cli.Command) error {
 		if b.cli.Flags().InteractiveDebugging {
 			return params.Errorf("unable to use --ci flag in combination with --interactive flag")
 		}
+
+		if len(b.cli.Flags().Breakpoints) > 0 {
+			return params.Errorf("unable to use --ci flag in combination with --break flag")
+		}
 	}
 
 	if b.cli.Flags().ImageMode && b.cli.Flags().ArtifactMode {
@@ -158,16 +201,36 @@ func (b *Build) Action(ctx context.Context, cmd *cli.Command) error {
 		return params.Errorf("A tty-terminal must be present in order to use the --interactive flag")
 	}
 
-	flagArgs, nonFlagArgs, err := variables.ParseFlagArgsWithNonFlags(cmd.Args().Slice())
-	if err != nil {
-		if invalidFlagErr, ok := errors.AsType[*variables.InvalidFlagError](err); ok {
-			return params.Errorf("%s", invalidFlagErr.Error())
+	if b.cli.Flags().BreakBefore && b.cli.Flags().BreakOnError {
+		return params.Errorf("--break-before and --break-on-error cannot be used together")
+	}
+
+	if len(b.cli.Flags().Breakpoints) > 0 && !termutil.IsTTY() {
+		return params.Errorf("A tty-terminal must be present in order to use the --break flag")
+	}
+
+	return nil
+}
+
+// breakpoints parses the --break breakpoints.
+func (b *Build) breakpoints() ([]earthfile2llb.Breakpoint, error) {
+	mode := debuggercommon.BreakBefore
+	if b.cli.Flags().BreakOnError {
+		mode = debuggercommon.BreakOnError
+	}
+
+	breakpoints := make([]earthfile2llb.Breakpoint, 0, len(b.cli.Flags().Breakpoints))
+
+	for _, spec := range b.cli.Flags().Breakpoints {
+		bp, err := earthfile2llb.ParseBreakpoint(spec, mode)
+		if err != nil {
+			return nil, params.Wrapf(err, "invalid --break")
 		}
 
-		return fmt.Errorf("parse args %s: %w", strings.Join(cmd.Args().Slice(), " "), err)
+		breakpoints = append(breakpoints, bp)
 	}
 
-	return b.ActionBuildImp(ctx, cmd, flagArgs, nonFlagArgs)
+	return breakpoints, nil
 }
 
 // warnIfArgContainsBuildArg will issue a warning if a flag is incorrectly prefixed with build-arg.
@@ -277,6 +340,11 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 		return err
 	}
 
+	breakpoints, err := b.breakpoints()
+	if err != nil {
+		return err
+	}
+
 	cleanCollection := cleanup.NewCollection()
 	defer cleanCollection.Close()
 
@@ -342,7 +410,7 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 		return err
 	}
 
-	skipDB, err := bk.NewBuildkitSkipper(b.cli.Flags().LocalSkipDB)
+	skipDB, err := b.newBuildkitSkipper(ctx)
 	if err != nil {
 		b.cli.Log().WithPrefix(autoSkipPrefix).Warnf("Failed to initialize auto-skip database: %v", err)
 	}
@@ -415,10 +483,16 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 		return fmt.Errorf("NewSecretProviderCmd: %w", err)
 	}
 
+	configuredSecretStore, err := secretprovider.NewConfiguredStore(b.cli.Cfg().Secrets)
+	if err != nil {
+		return fmt.Errorf("failed to configure secret stores: %w", err)
+	}
+
 	secretProvider := secretprovider.New(
 		internalSecretStore,
 		secretprovider.NewAWSCredentialProvider(),
 		secretprovider.NewMapStore(secretsMap),
+		configuredSecretStore,
 		customSecretProviderCmd,
 	)
 
@@ -471,21 +545,8 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 	localArtifactWhiteList := gatewaycrafter.NewLocalArtifactWhiteList()
 
 	socketProvider, err := socketprovider.NewSocketProvider(map[string]socketprovider.SocketAcceptCb{
-		"earthly_save_file": getTryCatchSaveFileHandler(localArtifactWhiteList),
-		"earthly_interactive": func(ctx context.Context, conn io.ReadWriteCloser) error {
-			if !termutil.IsTTY() {
-				return errors.New("interactive mode unavailable due to terminal not being tty")
-			}
-
-			debugTermConsole := b.cli.Log().WithPrefix("internal-term")
-
-			termErr := terminal.ConnectTerm(ctx, conn, debugTermConsole)
-			if termErr != nil {
-				return fmt.Errorf("interactive terminal: %w", termErr)
-			}
-
-			return nil
-		},
+		"earthly_save_file":   getTryCatchSaveFileHandler(localArtifactWhiteList),
+		"earthly_interactive": b.getInteractiveHandler(),
 	})
 	if err != nil {
 		return fmt.Errorf("ssh agent provider: %w", err)
@@ -574,7 +635,7 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 		GitBranchOverride:                     b.cli.Flags().GitBranchOverride,
 		UseFakeDep:                            !b.cli.Flags().NoFakeDep,
 		Strict:                                b.cli.Flags().Strict,
-		DisableNoOutputUpdates:                b.cli.Flags().InteractiveDebugging,
+		DisableNoOutputUpdates:                b.cli.Flags().InteractiveDebugging || len(breakpoints) > 0,
 		ParallelConversion:                    (b.cli.Cfg().Global.ConversionParallelism != 0),
 		Parallelism:                           parallelism,
 		LocalRegistryAddr:                     localRegistryAddr,
@@ -585,6 +646,7 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 		InternalSecretStore:                   internalSecretStore,
 		InteractiveDebugging:                  b.cli.Flags().InteractiveDebugging,
 		InteractiveDebuggingDebugLevelLogging: b.cli.Flags().Debug,
+		Breakpoints:                           breakpoints,
 		GitImage:                              b.cli.Cfg().Global.GitImage,
 		GitLFSInclude:                         b.cli.Flags().GitLFSPullInclude,
 		GitLogLevel:                           b.gitLogLevel(),
@@ -606,6 +668,7 @@ func (b *Build) ActionBuildImp(ctx context.Context, cmd *cli.Command, flagArgs,
 	}
 
 	buildOpts := builder.BuildOpt{
+		ExportCoordinator:          b.cli.ExportCoordinator(),
 		PrintPhases:                true,
 		Push:                       b.cli.Flags().Push,
 		CI:                         b.cli.Flags().CI,
@@ -670,6 +733,158 @@ func getTryCatchSaveFileHandler(
 	}
 }
 
+// getInteractiveHandler implements [socketprovider.SocketAcceptCb] - returns a
+// handler function for the earthly_interactive socket, which connects the
+// interactive debugger sessions to the terminal, and serves the file transfers
+// requested from them.
+func (b *Build) getInteractiveHandler() func(ctx context.Context, conn io.ReadWriteCloser) error {
+	sessions := &debugSessions{sessions: map[string]*debugSession{}}
+
+	return func(ctx context.Context, conn io.ReadWriteCloser) error {
+		connDataType, data, err := debuggercommon.ReadDataPacket(conn)
+		if err != nil {
+			return err
+		}
+
+		switch connDataType {
+		case debuggercommon.SessionToken:
+			return sessions.serveTransfer(ctx, conn, string(data))
+		case debuggercommon.GetFile, debuggercommon.GetFileOverwrite, debuggercommon.PutFile:
+			return filetransfer.Refuse(conn, errDebugSessionRequired)
+		}
+
+		if !termutil.IsTTY() {
+			return errors.New("interactive mode unavailable due to terminal not being tty")
+		}
+
+		token, session, err := sessions.start()
+		if err != nil {
+			return err
+		}
+
+		defer sessions.end(token)
+
+		// Only the shell of the session learns its token, so that files are
+		// only copied on request of the user.
+		err = debuggercommon.WriteDataPacket(conn, debuggercommon.SessionToken, []byte(token))
+		if err != nil {
+			return err
+		}
+
+		// The terminal reads the packet which starts the session again.
+		packet, err := debuggercommon.SerializeDataPacket(connDataType, data)
+		if err != nil {
+			return err
+		}
+
+		debugTermConsole := b.cli.Log().WithPrefix("internal-term")
+
+		termErr := terminal.ConnectTerm(ctx, replayedConn{
+			Reader:      io.MultiReader(bytes.NewReader(packet), conn),
+			WriteCloser: conn,
+		}, debugTermConsole, session.prompts)
+		if termErr != nil {
+			return fmt.Errorf("interactive terminal: %w", termErr)
+		}
+
+		return nil
+	}
+}
+
+// replayedConn is a connection from which data which was already read is read
+// again.
+type replayedConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+var errDebugSessionRequired = errors.New("files can only be copied from an interactive session")
+
+// debugSessions are the interactive debugger sessions connected to the
+// terminal, by token.
+type debugSessions struct {
+	sessions map[string]*debugSession
+	mu       sync.Mutex
+}
+
+// debugSession is an interactive debugger session connected to the terminal.
+type debugSession struct {
+	// prompts are asked to the user in the terminal of the session.
+	prompts chan terminal.Prompt
+	// done is closed once the session ends.
+	done chan struct{}
+}
+
+// start registers a new session, and returns its token.
+func (s *debugSessions) start() (string, *debugSession, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate a debugger session token: %w", err)
+	}
+
+	token := hex.EncodeToString(b)
+	session := &debugSession{prompts: make(chan terminal.Prompt), done: make(chan struct{})}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[token] = session
+
+	return token, session, nil
+}
+
+func (s *debugSessions) end(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	close(s.sessions[token].done)
+	delete(s.sessions, token)
+}
+
+// serveTransfer serves a file transfer requested from the session with the
+// token, from or to the current directory.
+func (s *debugSessions) serveTransfer(ctx context.Context, conn io.ReadWriter, token string) error {
+	reqType, hostPath, err := debuggercommon.ReadDataPacket(conn)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[token]
+	s.mu.Unlock()
+
+	if !ok {
+		return filetransfer.Refuse(conn, errDebugSessionRequired)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return filetransfer.Refuse(conn, err)
+	}
+
+	return filetransfer.Serve(conn, reqType, string(hostPath), wd, func(p string) bool {
+		return session.confirm(ctx, fmt.Sprintf("Overwrite %s on the host?", p))
+	})
+}
+
+// confirm asks the user in the terminal of the session, and returns whether
+// they agreed. It returns false if the session ends before they answer.
+func (s *debugSession) confirm(ctx context.Context, question string) bool {
+	answer := make(chan bool, 1)
+
+	select {
+	case s.prompts <- terminal.Prompt{Question: question, Answer: answer}:
+	case <-s.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+
+	return <-answer
+}
+
 func (b *Build) updateGitLookupConfig(gitLookup *buildcontext.GitLookup) error {
 	for k, v := range b.cli.Cfg().Git {
 		if k == "github" || k == "gitlab" || k == "bitbucket" {
@@ -864,6 +1079,19 @@ func (b *Build) platformResolver(
 	return platr, nil
 }
 
+// newBuildkitSkipper opens the auto-skip database.
+func (b *Build) newBuildkitSkipper(ctx context.Context) (bk.BuildkitSkipper, error) {
+	flags := b.cli.Flags()
+
+	return bk.NewBuildkitSkipper(ctx, bk.BuildkitSkipperOpt{
+		LocalDB:     autoSkipDBPath(flags),
+		RemoteURL:   flags.AutoSkipRemote,
+		RemoteToken: flags.AutoSkipRemoteToken,
+		TTL:         flags.AutoSkipTTL,
+		Warnf:       b.cli.Log().WithPrefix(autoSkipPrefix).Warnf,
+	})
+}
+
 func (b *Build) initAutoSkip(
 	ctx context.Context, skipDB bk.BuildkitSkipper, target domain.Target, overridingVars *variables.Scope,
 ) (func(), bool, error) {
@@ -887,12 +1115,15 @@ func (b *Build) initAutoSkip(
 		return nil, false, errors.New("--no-auto-skip cannot be used with --auto-skip")
 	}
 
+	manifest := &inputgraph.Manifest{}
+
 	targetHash, stats, err := inputgraph.HashTarget(ctx, inputgraph.HashOpt{
 		Target:         target,
 		Log:            b.cli.Log(),
 		CI:             b.cli.Flags().CI,
 		BuiltinArgs:    variables.DefaultArgs{EarthVersion: b.cli.Version(), EarthBuildSha: b.cli.GitSHA()},
 		OverridingVars: overridingVars,
+		Manifest:       manifest,
 	})
 	if err != nil {
 		return nil, false, fmt.Errorf("auto-skip is unable to calculate hash for %s: %w", target, err)
@@ -902,22 +1133,9 @@ func (b *Build) initAutoSkip(
 		stats.TargetsVisited, stats.TargetsHashed, stats.TargetCacheHits)
 	console.VerbosePrintf("hash calculation took %s", stats.Duration)
 
-	if !target.IsRemote() {
-		var meta *gitutil.GitMetadata
-
-		meta, err = gitutil.Metadata(ctx, target.GetLocalPath(), b.cli.Flags().GitBranchOverride)
-		if err != nil {
-			console.VerboseWarnf("unable to detect all git metadata: %v", err.Error())
-		}
-
-		var ok bool
-
-		target, ok = gitutil.ReferenceWithGitMeta(target, meta).(domain.Target)
-		if !ok {
-			return nil, false, fmt.Errorf("want domain.Target, got %T", target)
-		}
-
-		target.Tag = ""
+	target, err = autoSkipRecordedTarget(ctx, console, target, b.cli.Flags().GitBranchOverride)
+	if err != nil {
+		return nil, false, err
 	}
 
 	targetConsole := b.cli.Log().WithPrefix(target.String())
@@ -937,19 +1155,62 @@ func (b *Build) initAutoSkip(
 	}
 
 	addHashFn := func() {
-		err := skipDB.Add(ctx, target.StringCanonical(), targetHash)
+		entry := buildkitskipper.Entry{
+			Created:      time.Now().UTC(),
+			Target:       target.StringCanonical(),
+			Args:         overridingVars.BuildArgs(),
+			EarthVersion: b.cli.Version(),
+		}
+
+		err := skipDB.Add(ctx, entry, targetHash)
 		if err != nil {
 			b.cli.Log().WithPrefix(autoSkipPrefix).
 				Warnf("failed to record %s (hash %x) as completed: %s", target.String(), target, err)
+
+			return
+		}
+
+		err = bk.AddManifest(ctx, skipDB, buildkitskipper.Record{Entry: entry, Hash: targetHash}, manifest)
+		if err != nil {
+			b.cli.Log().WithPrefix(autoSkipPrefix).
+				Warnf("failed to record the input manifest of %s: %s", target.String(), err)
 		}
 	}
 
 	return addHashFn, false, nil
 }
 
+// autoSkipRecordedTarget returns the target as auto-skip records it: local
+// targets are referenced by their git metadata, without a tag.
+func autoSkipRecordedTarget(
+	ctx context.Context, console *conslogging.ConsoleLogger, target domain.Target, branchOverride string,
+) (domain.Target, error) {
+	if target.IsRemote() {
+		return target, nil
+	}
+
+	meta, err := gitutil.Metadata(ctx, target.GetLocalPath(), branchOverride)
+	if err != nil {
+		console.VerboseWarnf("unable to detect all git metadata: %v", err.Error())
+	}
+
+	ret, ok := gitutil.ReferenceWithGitMeta(target, meta).(domain.Target)
+	if !ok {
+		return domain.Target{}, fmt.Errorf("want domain.Target, got %T", target)
+	}
+
+	ret.Tag = ""
+
+	return ret, nil
+}
+
 func (b *Build) actionDockerBuild(ctx context.Context, cmd *cli.Command) error {
 	b.cli.SetCommandName("docker-build")
 
+	if b.isMatrix() {
+		return params.Errorf("the --matrix flags cannot be used with docker-build")
+	}
+
 	flagArgs, nonFlagArgs, err := variables.ParseFlagArgsWithNonFlags(cmd.Args().Slice())
 	if err != nil {
 		if invalidFlagErr, ok := errors.AsType[*variables.InvalidFlagError](err); ok {
@@ -1028,3 +1289,119 @@ func (b *Build) actionDockerBuild(ctx context.Context, cmd *cli.Command) error {
 
 	return b.ActionBuildImp(ctx, cmd, flagArgs, nonFlagArgs)
 }
+
+func (b *Build) isMatrix() bool {
+	return b.matrix || len(b.matrixInclude) > 0 || len(b.matrixExclude) > 0 || b.matrixName != "" ||
+		b.matrixMaxParallel != 0 || b.matrixContinue
+}
+
+// actionMatrixBuild builds the target for each cell of the matrix of its
+// build args, by wrapping it with a generated Earthfile which BUILDs it with
+// the --matrix-* options.
+func (b *Build) actionMatrixBuild(ctx context.Context, cmd *cli.Command, flagArgs, nonFlagArgs []string) error {
+	if b.cli.Flags().ImageMode || b.cli.Flags().ArtifactMode {
+		return params.Errorf("the --matrix flags cannot be used with image or artifact modes")
+	}
+
+	if b.matrixMaxParallel < 0 {
+		return params.Errorf("invalid --matrix-max-parallel %d", b.matrixMaxParallel)
+	}
+
+	if len(nonFlagArgs) != 1 {
+		_ = cli.ShowAppHelp(cmd)
+		return params.Errorf("invalid arguments %s", strings.Join(nonFlagArgs, " "))
+	}
+
+	target, err := domain.ParseTarget(nonFlagArgs[0])
+	if err != nil {
+		return params.Wrapf(err, "invalid target name %s", nonFlagArgs[0])
+	}
+
+	if target.IsLocalExternal() || target.IsLocalInternal() {
+		target.LocalPath, err = filepath.Abs(target.GetLocalPath())
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", target, err)
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "matrix-build")
+	if err != nil {
+		return fmt.Errorf("matrix build: failed to create temporary dir for Earthfile: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	content := matrixEarthfile(target.String(), flagutil.SplitFlagString(b.platformsStr), append(flagArgs, b.buildArgs...),
+		flagutil.MatrixOpt{Name: b.matrixName, Include: b.matrixInclude, Exclude: b.matrixExclude},
+		b.matrixMaxParallel, b.matrixContinue)
+
+	earthfilePath := filepath.Join(tempDir, buildcontext.Earthfile)
+
+	err = os.WriteFile(earthfilePath, []byte(content), 0o600)
+	if err != nil {
+		return fmt.Errorf("matrix build: failed to write to %q: %w", earthfilePath, err)
+	}
+
+	// The platforms and the build args are passed on by the generated Earthfile.
+	b.platformsStr = []string{}
+	b.buildArgs = []string{}
+
+	return b.ActionBuildImp(ctx, cmd, nil, []string{tempDir + "+matrix"})
+}
+
+// matrixEarthfile generates an Earthfile whose +matrix target BUILDs the
+// target for each cell of the matrix of the build args.
+func matrixEarthfile(
+	target string, platforms, buildArgs []string, opt flagutil.MatrixOpt, maxParallel int, continueOnError bool,
+) string {
+	var sb strings.Builder
+
+	sb.WriteString("VERSION --build-matrix 0.8\n\nmatrix:\n    BUILD --pass-args")
+
+	for _, platform := range platforms {
+		sb.WriteString(" --platform=" + quoteEarthfileArg(platform))
+	}
+
+	for _, include := range opt.Include {
+		sb.WriteString(" --matrix-include=" + quoteEarthfileArg(include))
+	}
+
+	for _, exclude := range opt.Exclude {
+		sb.WriteString(" --matrix-exclude=" + quoteEarthfileArg(exclude))
+	}
+
+	if opt.Name != "" {
+		sb.WriteString(" --matrix-name=" + quoteEarthfileArg(opt.Name))
+	}
+
+	if maxParallel > 0 {
+		fmt.Fprintf(&sb, " --matrix-max-parallel=%d", maxParallel)
+	}
+
+	if continueOnError {
+		sb.WriteString(" --matrix-continue")
+	}
+
+	sb.WriteString(" " + target)
+
+	for _, arg := range buildArgs {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			// A build arg without a value takes it from the environment.
+			sb.WriteString(" --" + k)
+			continue
+		}
+
+		sb.WriteString(" --" + k + "=" + quoteEarthfileArg(v))
+	}
+
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// quoteEarthfileArg quotes a value so that it is passed as is, without
+// expansion, to an Earthfile command.
+func quoteEarthfileArg(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,76 @@
+package subcmd
+
+import (
+	"net"
+	"testing"
+
+	debuggercommon "github.com/EarthBuild/earthbuild/debugger/common"
+	"github.com/EarthBuild/earthbuild/debugger/filetransfer"
+	"github.com/EarthBuild/earthbuild/util/flagutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixEarthfile(t *testing.T) {
+	t.Parallel()
+
+	got := matrixEarthfile(
+		"/src/app+test",
+		[]string{"linux/amd64"},
+		[]string{"GO=1.22", "GO=1.23", `MSG=say "$HI"`, "TOKEN"},
+		flagutil.MatrixOpt{Name: "go{GO}", Include: []string{"GO=1.21"}, Exclude: []string{"GO=1.22"}},
+		2,
+		true,
+	)
+	require.Equal(t, "VERSION --build-matrix 0.8\n\nmatrix:\n"+
+		`    BUILD --pass-args --platform="linux/amd64" --matrix-include="GO=1.21" --matrix-exclude="GO=1.22" `+
+		`--matrix-name="go{GO}" --matrix-max-parallel=2 --matrix-continue /src/app+test `+
+		`--GO="1.22" --GO="1.23" --MSG="say \"\$HI\"" --TOKEN`+"\n", got)
+}
+
+func TestDebugSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	sessions := &debugSessions{sessions: map[string]*debugSession{}}
+
+	token, session, err := sessions.start()
+	require.NoError(t, err)
+	require.Len(t, token, 64)
+
+	// A transfer is refused unless it is requested from a session.
+	for _, tok := range []string{"", "not-a-session"} {
+		host, client := net.Pipe()
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			defer func() { _ = host.Close() }()
+
+			_, data, readErr := debuggercommon.ReadDataPacket(host)
+			if readErr != nil {
+				errCh <- readErr
+				return
+			}
+
+			errCh <- sessions.serveTransfer(ctx, host, string(data))
+		}()
+
+		err = filetransfer.Put(client, tok, nil, "a.txt", ".", func(int64) {})
+		require.ErrorIs(t, <-errCh, errDebugSessionRequired)
+		require.EqualError(t, err, errDebugSessionRequired.Error())
+		require.NoError(t, client.Close())
+	}
+
+	go func() {
+		p := <-session.prompts
+		require.Equal(t, "Overwrite a.txt on the host?", p.Question)
+		p.Answer <- true
+	}()
+
+	require.True(t, session.confirm(ctx, "Overwrite a.txt on the host?"))
+
+	// Nobody is asked once the session ends.
+	sessions.end(token)
+	require.False(t, session.confirm(ctx, "Overwrite a.txt on the host?"))
+	require.Empty(t, sessions.sessions)
+}
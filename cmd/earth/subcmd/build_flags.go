@@ -42,6 +42,39 @@ func (b *Build) buildFlags() []cli.Flag {
 			Destination: &b.cacheFrom,
 			Hidden:      true, // Experimental
 		},
+		&cli.BoolFlag{
+			Name:        "matrix",
+			Sources:     flag.EarthEnvVars("MATRIX"),
+			Usage:       "Build the target for each combination of the values of repeated build args (experimental)",
+			Destination: &b.matrix,
+		},
+		&cli.StringSliceFlag{
+			Name:        "matrix-include",
+			Usage:       "An extra combination of build args to build, specified as <key>=<value>[,...] (experimental)",
+			Destination: &b.matrixInclude,
+		},
+		&cli.StringSliceFlag{
+			Name:        "matrix-exclude",
+			Usage:       "A combination of build args not to build, specified as <key>=<value>[,...] (experimental)",
+			Destination: &b.matrixExclude,
+		},
+		&cli.StringFlag{
+			Name:        "matrix-name",
+			Usage:       "The name of each combination of build args, in which {<key>} is the value of <key> (experimental)",
+			Destination: &b.matrixName,
+		},
+		&cli.IntFlag{
+			Name:        "matrix-max-parallel",
+			Sources:     flag.EarthEnvVars("MATRIX_MAX_PARALLEL"),
+			Usage:       "The maximum number of combinations of build args built in parallel (experimental)",
+			Destination: &b.matrixMaxParallel,
+		},
+		&cli.BoolFlag{
+			Name:        "matrix-continue",
+			Sources:     flag.EarthEnvVars("MATRIX_CONTINUE"),
+			Usage:       "Build the other combinations of build args when one fails (experimental)",
+			Destination: &b.matrixContinue,
+		},
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 	"github.com/EarthBuild/earthbuild/conslogging"
 	"github.com/EarthBuild/earthbuild/logbus"
 	"github.com/EarthBuild/earthbuild/logbus/setup"
+	"github.com/EarthBuild/earthbuild/util/gatewaycrafter"
 	"github.com/moby/buildkit/client"
 	"github.com/urfave/cli/v3"
 )
@@ -32,5 +33,7 @@ type CLI interface {
 	LogbusSetup() *setup.BusSetup
 	Logbus() *logbus.Bus
 
+	ExportCoordinator() *gatewaycrafter.ExportCoordinator
+
 	AddDeferredFunc(f func())
 }
@@ -11,6 +11,11 @@ import (
 	"time"
 
 	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/logbus/replay"
+	"github.com/EarthBuild/earthbuild/logbus/writersub"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/critpath"
+	"github.com/EarthBuild/earthbuild/util/deltautil"
 	"github.com/containerd/platforms"
 	"github.com/dustin/go-humanize"
 	"github.com/moby/buildkit/client"
@@ -21,6 +26,9 @@ import (
 type Debug struct {
 	cli CLI
 
+	replayTarget    string
+	replaySpeed     float64
+	criticalPathTop int
 	enableSourceMap bool
 }
 
@@ -90,6 +98,43 @@ func (a *Debug) Cmds() []*cli.Command {
 					Description: "Print the buildkit session history.",
 					Action:      a.actionBuildkitSessionHistory,
 				},
+				{
+					Name:      "replay",
+					Usage:     "Replay a recorded --logstream-debug-file as console output",
+					UsageText: "earth [options] debug replay [--speed <speed>] [--target <target>] <file>",
+					Description: "Replay a file recorded with --logstream-debug-file as the console output of the build. " +
+						"Files ending in .json are read as JSON.",
+					Action: a.actionReplay,
+					Flags: []cli.Flag{
+						&cli.FloatFlag{
+							Name:        "speed",
+							Usage:       "Replay at this speed relative to the build, e.g. 1 for real time; 0 replays instantly",
+							Destination: &a.replaySpeed,
+						},
+						&cli.StringFlag{
+							Name:        "target",
+							Usage:       "Only replay the output of this target, e.g. +build",
+							Destination: &a.replayTarget,
+						},
+					},
+				},
+				{
+					Name:      "critical-path",
+					Usage:     "Analyze where the time of a recorded --logstream-debug-file build went",
+					UsageText: "earth [options] debug critical-path [--top <n>] <file>",
+					Description: "Print the critical path of a build recorded with --logstream-debug-file, " +
+						"the wall and cumulative time of each target, and its slowest uncached commands. " +
+						"Files ending in .json are read as JSON.",
+					Action: a.actionCriticalPath,
+					Flags: []cli.Flag{
+						&cli.IntFlag{
+							Name:        "top",
+							Usage:       "The number of slowest commands to print",
+							Value:       critpath.DefaultTop,
+							Destination: &a.criticalPathTop,
+						},
+					},
+				},
 			},
 		},
 	}
@@ -127,6 +172,60 @@ func (a *Debug) actionAst(_ context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+func (a *Debug) actionCriticalPath(_ context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("debugCriticalPath")
+
+	if cmd.NArg() != 1 {
+		return errors.New("invalid number of arguments provided")
+	}
+
+	if a.criticalPathTop < 0 {
+		return fmt.Errorf("invalid --top %d: must not be negative", a.criticalPathTop)
+	}
+
+	deltas, err := readDeltas(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+
+	m := &logstream.RunManifest{}
+
+	for _, delta := range deltas {
+		err := deltautil.ApplyDelta(m, delta)
+		if err != nil {
+			return fmt.Errorf("failed to apply delta: %w", err)
+		}
+	}
+
+	fmt.Print(critpath.Analyze(m, a.criticalPathTop).String())
+
+	return nil
+}
+
+func (a *Debug) actionReplay(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("debugReplay")
+
+	if cmd.NArg() != 1 {
+		return errors.New("invalid number of arguments provided")
+	}
+
+	if a.criticalPathTop < 0 {
+		return fmt.Errorf("invalid --top %d: must not be negative", a.criticalPathTop)
+	}
+
+	deltas, err := readDeltas(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return replay.Replay(ctx, deltas, replay.Opt{
+		Out:     os.Stdout,
+		Target:  a.replayTarget,
+		Speed:   a.replaySpeed,
+		Verbose: a.cli.Flags().Verbose,
+	})
+}
+
 func (a *Debug) actionBuildkitSessionHistory(ctx context.Context, cmd *cli.Command) error {
 	a.cli.SetCommandName("debugBuildkitSessions")
 
@@ -319,6 +418,22 @@ func (a *Debug) actionBuildkitShutdownIfIdle(ctx context.Context, cmd *cli.Comma
 	return nil
 }
 
+// readDeltas reads the deltas recorded by --logstream-debug-file.
+func readDeltas(path string) ([]*logstream.Delta, error) {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close() // #nosec G307
+
+	deltas, err := writersub.ReadRaw(f, strings.HasSuffix(path, ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	return deltas, nil
+}
+
 func humanizeDuration(d time.Duration) string {
 	return fmt.Sprintf("%v", d.Round(time.Second))
 }
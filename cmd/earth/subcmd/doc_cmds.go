@@ -178,7 +178,10 @@ func docString(body string, names ...string) (string, error) {
 
 type docSection struct {
 	identifier string
-	body       string
+	// note is printed after the identifier in the help, e.g. the values
+	// allowed for an ARG.
+	note string
+	body string
 }
 
 func docSectionsOutput(currIndent, scopeIndent, title string, sections ...docSection) string {
@@ -193,7 +196,12 @@ func docSectionsOutput(currIndent, scopeIndent, title string, sections ...docSec
 
 	currIndent += scopeIndent
 	for _, section := range sections {
-		out.WriteString(indent(currIndent, section.identifier))
+		ident := section.identifier
+		if section.note != "" {
+			ident += " (" + section.note + ")"
+		}
+
+		out.WriteString(indent(currIndent, ident))
 		out.WriteByte('\n')
 
 		if section.body == "" {
@@ -267,10 +275,16 @@ func addArg(b *blockIO, ft *features.Features, stmt earthfile.Statement, isBase,
 		return nil
 	}
 
+	constraint, err := earthfile2llb.ArgConstraint(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to parse ARG statement: %w", err)
+	}
+
 	docs, _ := docString(cmd.Docs, ident)
 
 	doc := docSection{
 		identifier: "--" + ident,
+		note:       constraint.String(),
 		body:       docs,
 	}
 	if dflt != nil {
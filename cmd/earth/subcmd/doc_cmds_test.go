@@ -96,7 +96,7 @@ func TestDocRecipeBlockFixture(t *testing.T) {
 	require.Equal(t, []string{"--requiredArg"}, docIdentifiers(blockIO.requiredArgs))
 	require.Equal(
 		t,
-		[]string{"--globalArg", "--withDefault=foo", "--withDocs", "--withoutDocs"},
+		[]string{"--globalArg", "--withDefault=foo", "--withDocs", "--withoutDocs", "--env=dev"},
 		docIdentifiers(blockIO.optionalArgs),
 	)
 	require.Equal(t, []string{"bar.txt", "baz.txt"}, docIdentifiers(blockIO.artifacts))
@@ -120,7 +120,8 @@ func TestDocRecipeBlockFixture(t *testing.T) {
 	require.NoError(t, err)
 
 	require.Contains(t, out, "+foo --requiredArg")
-	require.Contains(t, out, "[--globalArg] [--withDefault=foo] [--withDocs] [--withoutDocs]\n")
+	require.Contains(t, out, "[--globalArg] [--withDefault=foo] [--withDocs] [--withoutDocs] [--env=dev]\n")
+	require.Contains(t, out, "--env=dev (one of dev, prod)\n")
 	require.Contains(t, out, "REQUIRED ARGS:")
 	require.Contains(t, out, "OPTIONAL ARGS:")
 	require.Contains(t, out, "ARTIFACTS:")
@@ -60,13 +60,17 @@ func (a *Doc2Earth) Cmds() []*cli.Command {
 func (a *Doc2Earth) action(context.Context, *cli.Command) error {
 	a.cli.SetCommandName("docker2earthly")
 
-	err := docker2earth.Docker2Earth(a.cli.Flags().DockerfilePath, a.earthfilePath, a.earthfileFinalImage)
+	conv, err := docker2earth.Docker2Earth(a.cli.Flags().DockerfilePath, a.earthfilePath, a.earthfileFinalImage)
 	if err != nil {
 		return err
 	}
 
-	format := "An Earthfile has been generated; to run it use: earth +build; then run with docker run -ti %s\n"
-	fmt.Fprintf(os.Stderr, format, a.earthfileFinalImage)
+	for _, w := range conv.Warnings {
+		a.cli.Log().Warnf("Warning: %s\n", w)
+	}
+
+	format := "An Earthfile has been generated; to run it use: earth +%s; then run with docker run -ti %s\n"
+	fmt.Fprintf(os.Stderr, format, conv.Target, a.earthfileFinalImage)
 
 	return nil
 }
@@ -0,0 +1,269 @@
+package subcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/cmd/earth/common"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/inputgraph"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
+	"github.com/EarthBuild/earthbuild/util/params"
+	"github.com/EarthBuild/earthbuild/variables"
+	"github.com/dustin/go-humanize"
+	"github.com/joho/godotenv"
+	"github.com/urfave/cli/v3"
+)
+
+// ExplainSkip encapsulates the explain-skip command logic.
+type ExplainSkip struct {
+	cli CLI
+
+	// out is stdout; nil means [os.Stdout]. Injectable so tests can capture
+	// output without hijacking the global.
+	out io.Writer
+}
+
+// NewExplainSkip creates a new ExplainSkip command.
+func NewExplainSkip(cli CLI) *ExplainSkip {
+	return &ExplainSkip{
+		cli: cli,
+	}
+}
+
+func (a *ExplainSkip) writer() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+
+	return a.out
+}
+
+// Cmds returns the list of commands for the explain-skip command.
+func (a *ExplainSkip) Cmds() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "explain-skip",
+			Usage:     "Explain why a target would or would not be auto-skipped",
+			UsageText: "earth [options] explain-skip <target-ref> [--<build-arg-name>=<build-arg-value>...]",
+			Description: "Hashes the inputs of a target as --auto-skip does, and compares them with those of " +
+				"its last recorded build with the same build args, printing each file, arg, command or " +
+				"dependency which changed.",
+			Action: a.action,
+		},
+	}
+}
+
+func (a *ExplainSkip) action(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("explainSkip")
+
+	flagArgs, nonFlagArgs, err := variables.ParseFlagArgsWithNonFlags(cmd.Args().Slice())
+	if err != nil {
+		return params.Errorf("%s", err.Error())
+	}
+
+	if len(nonFlagArgs) != 1 {
+		return params.Errorf("a single target reference is required")
+	}
+
+	target, err := domain.ParseTarget(nonFlagArgs[0])
+	if err != nil {
+		return params.Errorf("invalid target %s", nonFlagArgs[0])
+	}
+
+	flags := a.cli.Flags()
+
+	argMap, err := godotenv.Read(flags.ArgFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", flags.ArgFile, err)
+	}
+
+	overridingVars, err := common.CombineVariables(argMap, flagArgs, nil)
+	if err != nil {
+		return err
+	}
+
+	manifest := &inputgraph.Manifest{}
+
+	hash, _, err := inputgraph.HashTarget(ctx, inputgraph.HashOpt{
+		Target:         target,
+		Log:            a.cli.Log(),
+		CI:             flags.CI,
+		BuiltinArgs:    variables.DefaultArgs{EarthVersion: a.cli.Version(), EarthBuildSha: a.cli.GitSHA()},
+		OverridingVars: overridingVars,
+		Manifest:       manifest,
+	})
+	if err != nil {
+		return fmt.Errorf("auto-skip is unable to calculate hash for %s: %w", target, err)
+	}
+
+	recorded, err := autoSkipRecordedTarget(ctx, a.cli.Log(), target, flags.GitBranchOverride)
+	if err != nil {
+		return err
+	}
+
+	db, err := openAutoSkipDB(flags)
+	if err != nil {
+		// The local cache of a remote store may not have been created yet.
+		if flags.AutoSkipRemote == "" || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	} else {
+		defer db.Close() // #nosec G104
+	}
+
+	var remote *buildkitskipper.RemoteBuildkitSkipper
+
+	if flags.AutoSkipRemote != "" {
+		remote, err = buildkitskipper.NewRemote(ctx, buildkitskipper.RemoteOpt{
+			URL:   flags.AutoSkipRemote,
+			Token: flags.AutoSkipRemoteToken,
+			TTL:   flags.AutoSkipTTL,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to configure remote auto-skip store: %w", err)
+		}
+	}
+
+	return a.explain(ctx, db, remote, explainOpt{
+		target:    recorded.StringCanonical(),
+		args:      overridingVars.BuildArgs(),
+		hash:      hash,
+		manifest:  manifest,
+		remoteURL: flags.AutoSkipRemote,
+		ttl:       flags.AutoSkipTTL,
+		now:       time.Now(),
+	})
+}
+
+type explainOpt struct {
+	now       time.Time
+	manifest  *inputgraph.Manifest
+	target    string
+	remoteURL string
+	args      []string
+	hash      []byte
+	ttl       time.Duration
+}
+
+// explain looks the hash up as the build does, in the local database and then
+// in the remote store, either of which may be nil, and otherwise compares the
+// inputs with those of the last recorded build.
+func (a *ExplainSkip) explain(
+	ctx context.Context, db *buildkitskipper.LocalBuildkitSkipper, remote *buildkitskipper.RemoteBuildkitSkipper,
+	opt explainOpt,
+) error {
+	w := a.writer()
+
+	var (
+		expired      buildkitskipper.Entry
+		expiredStore string
+	)
+
+	if db != nil {
+		entry, found, err := db.Get(ctx, opt.hash)
+		if err != nil {
+			return fmt.Errorf("failed to read auto-skip database: %w", err)
+		}
+
+		if found && !entry.Expired(opt.ttl, opt.now) {
+			fmt.Fprintf(w, "%s (hash %x) would be skipped: an identical build was recorded %s in the local database.\n",
+				opt.target, opt.hash, age(entry.Created, opt.now))
+
+			return nil
+		}
+
+		if found {
+			expired, expiredStore = entry, "the local database"
+		}
+	}
+
+	if remote != nil {
+		entry, found, err := remote.Get(ctx, opt.hash)
+		if err != nil {
+			fmt.Fprintf(w, "%s (hash %x) would not be skipped: the remote auto-skip store %s is unavailable: %v\n",
+				opt.target, opt.hash, opt.remoteURL, err)
+
+			return nil
+		}
+
+		if found && !entry.Expired(opt.ttl, opt.now) {
+			fmt.Fprintf(w, "%s (hash %x) would be skipped: an identical build was recorded %s in the remote store %s.\n",
+				opt.target, opt.hash, age(entry.Created, opt.now), opt.remoteURL)
+
+			return nil
+		}
+
+		if found && expiredStore == "" {
+			expired, expiredStore = entry, "the remote store "+opt.remoteURL
+		}
+	}
+
+	if expiredStore != "" {
+		fmt.Fprintf(w, "%s (hash %x) would not be skipped: its identical build recorded %s in %s is past --auto-skip-ttl.\n",
+			opt.target, opt.hash, age(expired.Created, opt.now), expiredStore)
+
+		return nil
+	}
+
+	var rec *buildkitskipper.ManifestRecord
+
+	if db != nil {
+		var err error
+
+		rec, err = db.GetManifest(ctx, opt.target, opt.args)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rec == nil {
+		fmt.Fprintf(w, "%s (hash %x) would not be skipped: no build of it with these args was recorded.\n",
+			opt.target, opt.hash)
+
+		return nil
+	}
+
+	var prev inputgraph.Manifest
+
+	err := json.Unmarshal(rec.Manifest, &prev)
+	if err != nil {
+		return fmt.Errorf("failed to decode the recorded manifest: %w", err)
+	}
+
+	if bytes.Equal(rec.Hash, opt.hash) {
+		// The build was recorded, but its entry was since pruned or forgotten.
+		fmt.Fprintf(w, "%s (hash %x) would not be skipped: its identical build recorded %s was deleted.\n",
+			opt.target, opt.hash, age(rec.Created, opt.now))
+
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s (hash %x) would not be skipped: its inputs changed since its last build recorded %s (hash %x):\n",
+		opt.target, opt.hash, age(rec.Created, opt.now), rec.Hash)
+
+	changes := inputgraph.Diff(&prev, opt.manifest)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "  no input changed; the structure of the recipe did (e.g. an empty block was added)")
+	}
+
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+
+	return nil
+}
+
+func age(t, now time.Time) string {
+	if t.IsZero() {
+		return "at an unknown time"
+	}
+
+	return humanize.RelTime(t, now, "ago", "from now")
+}
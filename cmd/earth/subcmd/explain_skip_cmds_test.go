@@ -0,0 +1,134 @@
+package subcmd
+
+import (
+	"bytes"
+	"crypto/sha1" // #nosec G505
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/inputgraph"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainSkip(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	db, err := buildkitskipper.NewLocal(filepath.Join(t.TempDir(), "skip.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	oldHash := sha1.Sum([]byte("old")) // #nosec G401
+	newHash := sha1.Sum([]byte("new")) // #nosec G401
+
+	prev := &inputgraph.Manifest{Inputs: []inputgraph.Input{
+		{Kind: inputgraph.InputFile, Target: "+build", Name: "main.go", Value: "sha256:aa"},
+		{Kind: inputgraph.InputArg, Target: "+build", Name: "VERSION", Value: "1"},
+	}}
+	cur := &inputgraph.Manifest{Inputs: []inputgraph.Input{
+		{Kind: inputgraph.InputFile, Target: "+build", Name: "main.go", Value: "sha256:bb"},
+		{Kind: inputgraph.InputArg, Target: "+build", Name: "VERSION", Value: "1"},
+	}}
+
+	explain := func(hash []byte, args []string) string {
+		var out bytes.Buffer
+
+		a := &ExplainSkip{out: &out}
+		require.NoError(t, a.explain(ctx, db, nil, explainOpt{
+			target:   "+build",
+			args:     args,
+			hash:     hash,
+			manifest: cur,
+			ttl:      buildkitskipper.DefaultTTL,
+			now:      now,
+		}))
+
+		return out.String()
+	}
+
+	require.Equal(t,
+		"+build (hash c2a6b03f190dfb2b4aa91f8af8d477a9bc3401dc) would not be skipped: "+
+			"no build of it with these args was recorded.\n",
+		explain(newHash[:], nil))
+
+	entry := buildkitskipper.Entry{Created: now.Add(-2 * time.Hour), Target: "+build"}
+	require.NoError(t, db.Add(ctx, entry, oldHash[:]))
+
+	dt, err := json.Marshal(prev)
+	require.NoError(t, err)
+	require.NoError(t, db.AddManifest(ctx, buildkitskipper.Record{Entry: entry, Hash: oldHash[:]}, dt))
+
+	require.Equal(t, ""+
+		"+build (hash c2a6b03f190dfb2b4aa91f8af8d477a9bc3401dc) would not be skipped: "+
+		"its inputs changed since its last build recorded 2 hours ago (hash c00dbbc9dadfbe1e232e93a729dd4752fade0abf):\n"+
+		"  changed file main.go in +build: sha256:aa -> sha256:bb\n",
+		explain(newHash[:], nil))
+
+	require.Contains(t, explain(newHash[:], []string{"VERSION=2"}), "no build of it with these args was recorded")
+
+	require.Contains(t, explain(oldHash[:], nil), "would be skipped: an identical build was recorded 2 hours ago")
+
+	_, err = db.Forget(ctx, "+other")
+	require.NoError(t, err)
+
+	_, err = db.Prune(ctx, now)
+	require.NoError(t, err)
+	require.Contains(t, explain(oldHash[:], nil), "its identical build recorded 2 hours ago was deleted")
+
+	// Forgetting a target deletes its manifests too.
+	_, err = db.Forget(ctx, "+build")
+	require.NoError(t, err)
+	require.Contains(t, explain(oldHash[:], nil), "no build of it with these args was recorded")
+}
+
+func TestExplainSkipRemote(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Now()
+
+	store, err := buildkitskipper.NewLocal(filepath.Join(t.TempDir(), "store.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	srv := httptest.NewServer(buildkitskipper.NewHandler(store, ""))
+	t.Cleanup(srv.Close)
+
+	remote, err := buildkitskipper.NewRemote(ctx, buildkitskipper.RemoteOpt{URL: srv.URL})
+	require.NoError(t, err)
+
+	hash := sha1.Sum([]byte("remote")) // #nosec G401
+	require.NoError(t, store.Add(ctx, buildkitskipper.Entry{Created: now.Add(-3 * time.Hour), Target: "+build"}, hash[:]))
+
+	explain := func(ttl time.Duration) string {
+		var out bytes.Buffer
+
+		a := &ExplainSkip{out: &out}
+		require.NoError(t, a.explain(ctx, nil, remote, explainOpt{
+			target:    "+build",
+			hash:      hash[:],
+			manifest:  &inputgraph.Manifest{},
+			remoteURL: srv.URL,
+			ttl:       ttl,
+			now:       now,
+		}))
+
+		return out.String()
+	}
+
+	require.Contains(t, explain(buildkitskipper.DefaultTTL),
+		"would be skipped: an identical build was recorded 3 hours ago in the remote store "+srv.URL)
+	require.Contains(t, explain(time.Hour),
+		"its identical build recorded 3 hours ago in the remote store "+srv.URL+" is past --auto-skip-ttl")
+
+	srv.Close()
+	require.Contains(t, explain(buildkitskipper.DefaultTTL), "the remote auto-skip store "+srv.URL+" is unavailable")
+}
@@ -0,0 +1,250 @@
+package subcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/buildcontext"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/urfave/cli/v3"
+)
+
+// fmtStdin is the path argument that makes fmt read from stdin and write the
+// formatted Earthfile to stdout.
+const fmtStdin = "-"
+
+// errUnformatted is returned by fmt --check when at least one Earthfile is not
+// canonically formatted.
+var errUnformatted = errors.New("some Earthfiles are not formatted")
+
+// Fmt encapsulates the fmt command logic.
+type Fmt struct {
+	cli CLI
+
+	// in and out are stdin and stdout; nil means [os.Stdin] and [os.Stdout].
+	// Injectable so tests can capture output without hijacking the globals.
+	in  io.Reader
+	out io.Writer
+
+	check bool
+	diff  bool
+}
+
+// NewFmt creates a new Fmt command.
+func NewFmt(cli CLI) *Fmt {
+	return &Fmt{
+		cli: cli,
+	}
+}
+
+func (a *Fmt) reader() io.Reader {
+	if a.in == nil {
+		return os.Stdin
+	}
+
+	return a.in
+}
+
+func (a *Fmt) writer() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+
+	return a.out
+}
+
+// Cmds returns the list of commands for the fmt command.
+func (a *Fmt) Cmds() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "fmt",
+			Usage:     "Format Earthfiles",
+			UsageText: "earth [options] fmt [--check] [--diff] [<path>...]",
+			Description: "Rewrites Earthfiles in the canonical layout, preserving comments. " +
+				"Each path may be an Earthfile, a directory containing one, " +
+				"a directory followed by '/...' to format all Earthfiles below it, " +
+				"or '-' to format stdin to stdout.",
+			Action: a.action,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "check",
+					Usage:       "Do not write files; list those that are not formatted and fail if there are any",
+					Destination: &a.check,
+				},
+				&cli.BoolFlag{
+					Name:        "diff",
+					Usage:       "Do not write files; print a diff of the changes formatting would make",
+					Destination: &a.diff,
+				},
+			},
+		},
+	}
+}
+
+func (a *Fmt) action(_ context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("fmt")
+
+	args := cmd.Args().Slice()
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var unformatted int
+
+	for _, arg := range args {
+		if arg == fmtStdin {
+			err := a.formatStdin()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		paths, err := fmtPaths(arg)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			changed, err := a.formatFile(path)
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				unformatted++
+			}
+		}
+	}
+
+	if a.check && unformatted > 0 {
+		return fmt.Errorf("%w: %d file(s) need formatting; run 'earth fmt' to fix", errUnformatted, unformatted)
+	}
+
+	return nil
+}
+
+func (a *Fmt) formatStdin() error {
+	b, err := io.ReadAll(a.reader())
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	formatted, err := earthfile.Format("<stdin>", string(b))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(a.writer(), formatted)
+
+	return err
+}
+
+// formatFile formats a single Earthfile, reporting whether it was not already
+// formatted. The file is only rewritten when neither --check nor --diff is set.
+func (a *Fmt) formatFile(path string) (bool, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	formatted, err := earthfile.Format(path, string(b))
+	if err != nil {
+		return false, err
+	}
+
+	if formatted == string(b) {
+		return false, nil
+	}
+
+	w := a.writer()
+
+	if a.check {
+		fmt.Fprintln(w, path)
+	}
+
+	if a.diff {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(b)),
+			B:        difflib.SplitLines(formatted),
+			FromFile: path + ".orig",
+			ToFile:   path,
+			Context:  3,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to diff %s: %w", path, err)
+		}
+
+		fmt.Fprint(w, diff)
+	}
+
+	if a.check || a.diff {
+		return true, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	err = os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+	if err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// fmtPaths expands a fmt path argument into the Earthfiles it refers to. A
+// directory refers to the Earthfile inside it; a directory followed by "/..."
+// refers to every Earthfile below it.
+func fmtPaths(arg string) ([]string, error) {
+	if root, ok := strings.CutSuffix(arg, "..."); ok {
+		root = filepath.Clean(strings.TrimSuffix(root, "/"))
+		if root == "" {
+			root = "."
+		}
+
+		var paths []string
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if !d.IsDir() && d.Name() == buildcontext.Earthfile {
+				paths = append(paths, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+		}
+
+		return paths, nil
+	}
+
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Earthfile: %w", err)
+	}
+
+	if info.IsDir() {
+		return []string{filepath.Join(arg, buildcontext.Earthfile)}, nil
+	}
+
+	return []string{arg}, nil
+}
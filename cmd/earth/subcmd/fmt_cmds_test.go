@@ -0,0 +1,111 @@
+package subcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	unformattedEarthfile = "VERSION 0.8\nbuild:\n  RUN  echo hi\n"
+	formattedEarthfile   = "VERSION 0.8\n\nbuild:\n    RUN echo hi\n"
+)
+
+func writeEarthfile(t *testing.T, dir, content string) string {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+
+	path := filepath.Join(dir, "Earthfile")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestFmtPaths(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	top := writeEarthfile(t, root, formattedEarthfile)
+	nested := writeEarthfile(t, filepath.Join(root, "a", "b"), formattedEarthfile)
+	writeEarthfile(t, filepath.Join(root, ".hidden"), formattedEarthfile)
+
+	paths, err := fmtPaths(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{top}, paths)
+
+	paths, err = fmtPaths(nested)
+	require.NoError(t, err)
+	require.Equal(t, []string{nested}, paths)
+
+	paths, err = fmtPaths(root + "/...")
+	require.NoError(t, err)
+	require.Equal(t, []string{top, nested}, paths)
+
+	_, err = fmtPaths(filepath.Join(root, "missing"))
+	require.ErrorContains(t, err, "failed to find Earthfile")
+}
+
+func TestFmtFormatFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rewrites unformatted file", func(t *testing.T) {
+		t.Parallel()
+
+		path := writeEarthfile(t, t.TempDir(), unformattedEarthfile)
+
+		changed, err := (&Fmt{out: new(bytes.Buffer)}).formatFile(path)
+		require.NoError(t, err)
+		require.True(t, changed)
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, formattedEarthfile, string(b))
+	})
+
+	t.Run("leaves formatted file alone", func(t *testing.T) {
+		t.Parallel()
+
+		path := writeEarthfile(t, t.TempDir(), formattedEarthfile)
+
+		var out bytes.Buffer
+
+		changed, err := (&Fmt{out: &out, check: true}).formatFile(path)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Empty(t, out.String())
+	})
+
+	t.Run("check and diff do not write", func(t *testing.T) {
+		t.Parallel()
+
+		path := writeEarthfile(t, t.TempDir(), unformattedEarthfile)
+
+		var out bytes.Buffer
+
+		changed, err := (&Fmt{out: &out, check: true, diff: true}).formatFile(path)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.True(t, strings.HasPrefix(out.String(), path+"\n"))
+		require.Contains(t, out.String(), "-  RUN  echo hi\n")
+		require.Contains(t, out.String(), "+    RUN echo hi\n")
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, unformattedEarthfile, string(b))
+	})
+}
+
+func TestFmtStdin(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	a := &Fmt{in: strings.NewReader(unformattedEarthfile), out: &out}
+	require.NoError(t, a.formatStdin())
+	require.Equal(t, formattedEarthfile, out.String())
+}
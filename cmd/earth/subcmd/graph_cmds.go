@@ -0,0 +1,142 @@
+package subcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/EarthBuild/earthbuild/cmd/earth/common"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/inputgraph"
+	"github.com/EarthBuild/earthbuild/util/params"
+	"github.com/EarthBuild/earthbuild/variables"
+	"github.com/joho/godotenv"
+	"github.com/urfave/cli/v3"
+)
+
+// Graph encapsulates the graph command logic.
+type Graph struct {
+	cli CLI
+
+	// out is stdout; nil means [os.Stdout]. Injectable so tests can capture
+	// output without hijacking the global.
+	out io.Writer
+
+	format         string
+	depth          int
+	collapseRemote bool
+	showArgs       bool
+}
+
+// NewGraph creates a new Graph command.
+func NewGraph(cli CLI) *Graph {
+	return &Graph{
+		cli: cli,
+	}
+}
+
+func (a *Graph) writer() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+
+	return a.out
+}
+
+// Cmds returns the list of commands for the graph command.
+func (a *Graph) Cmds() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "graph",
+			Usage: "Print the dependency graph of a target",
+			UsageText: "earth [options] graph [--format dot|mermaid|json] [--depth <n>] [--collapse-remote] [--args] " +
+				"<target-ref> [--<build-arg-name>=<build-arg-value>...]",
+			Description: "Walks the FROM, BUILD, COPY, DO and WITH DOCKER --load references of a target, " +
+				"without executing anything, and prints the graph of the targets, functions and artifacts " +
+				"it depends on.",
+			Action: a.action,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "format",
+					Usage:       "The output format: dot, mermaid or json",
+					Value:       inputgraph.FormatDOT,
+					Destination: &a.format,
+				},
+				&cli.IntFlag{
+					Name:        "depth",
+					Usage:       "Only walk dependencies up to this depth; 0 means no limit",
+					Destination: &a.depth,
+				},
+				&cli.BoolFlag{
+					Name:        "collapse-remote",
+					Usage:       "Show a single node per remote project",
+					Destination: &a.collapseRemote,
+				},
+				&cli.BoolFlag{
+					Name:        "args",
+					Usage:       "Show the build args passed along each edge",
+					Destination: &a.showArgs,
+				},
+			},
+		},
+	}
+}
+
+func (a *Graph) action(ctx context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("graph")
+
+	switch a.format {
+	case inputgraph.FormatDOT, inputgraph.FormatMermaid, inputgraph.FormatJSON:
+	default:
+		return params.Errorf("invalid --format %q, expected one of %s, %s or %s",
+			a.format, inputgraph.FormatDOT, inputgraph.FormatMermaid, inputgraph.FormatJSON)
+	}
+
+	if a.depth < 0 {
+		return params.Errorf("--depth must not be negative")
+	}
+
+	flagArgs, nonFlagArgs, err := variables.ParseFlagArgsWithNonFlags(cmd.Args().Slice())
+	if err != nil {
+		return params.Errorf("%s", err.Error())
+	}
+
+	if len(nonFlagArgs) != 1 {
+		return params.Errorf("a single target reference is required")
+	}
+
+	target, err := domain.ParseTarget(nonFlagArgs[0])
+	if err != nil {
+		return params.Errorf("invalid target %s", nonFlagArgs[0])
+	}
+
+	flags := a.cli.Flags()
+
+	argMap, err := godotenv.Read(flags.ArgFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", flags.ArgFile, err)
+	}
+
+	overridingVars, err := common.CombineVariables(argMap, flagArgs, nil)
+	if err != nil {
+		return err
+	}
+
+	graph, err := inputgraph.BuildGraph(ctx, inputgraph.GraphOpt{
+		Target:         target,
+		Log:            a.cli.Log(),
+		CI:             flags.CI,
+		BuiltinArgs:    variables.DefaultArgs{EarthVersion: a.cli.Version(), EarthBuildSha: a.cli.GitSHA()},
+		OverridingVars: overridingVars,
+		Depth:          a.depth,
+		CollapseRemote: a.collapseRemote,
+		Args:           a.showArgs,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to build the graph of %s: %w", target, err)
+	}
+
+	return graph.Write(a.writer(), a.format)
+}
@@ -52,75 +52,77 @@ func (a *Init) action(ctx context.Context, _ *cli.Command) error {
 		return fmt.Errorf("could not get absolute path for %q: %w", wd, err)
 	}
 
-	efPath := filepath.Join(absWd, buildcontext.Earthfile)
-
-	_, err = os.Stat(efPath)
-	if err == nil {
-		return hint.Wrap(fs.ErrExist,
-			"an Earthfile already exists; if you want to re-init the project, remove the Earthfile first.")
-	}
-
-	if !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("could not check for existing Earthfile: %w", err)
-	}
-
-	projs, err := proj.All(ctx, absWd)
+	dirs, err := proj.NewDetector(proj.StdFS(), proj.StdExecer()).Find(ctx, absWd)
 	if err != nil {
 		return fmt.Errorf("could not get projects for %q: %w", absWd, err)
 	}
 
-	if len(projs) == 0 {
+	if len(dirs) == 0 {
 		return fmt.Errorf("no supported projects found in directory %q", absWd)
 	}
 
-	f, err := os.Create(efPath) // #nosec G304
-	if err != nil {
-		return fmt.Errorf("could not create %q: %w", efPath, err)
+	// A monorepo gets an Earthfile in each project directory, and one in the
+	// root which builds all of them.
+	monorepo := len(dirs) > 1 || dirs[0].Path != absWd
+
+	efDirs := make([]string, 0, len(dirs)+1)
+	for _, dir := range dirs {
+		efDirs = append(efDirs, dir.Path)
 	}
-	defer f.Close()
 
-	_, err = f.WriteString("VERSION --arg-scope-and-set 0.7\n\n")
-	if err != nil {
-		return fmt.Errorf("could not write version string in %q: %w", efPath, err)
+	if monorepo {
+		efDirs = append(efDirs, absWd)
+	}
+
+	for _, dir := range efDirs {
+		efPath := filepath.Join(dir, buildcontext.Earthfile)
+
+		_, err = os.Stat(efPath)
+		if err == nil {
+			return hint.Wrapf(fs.ErrExist,
+				"an Earthfile already exists in %q; if you want to re-init the project, remove the Earthfile first.", dir)
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not check for existing Earthfile: %w", err)
+		}
 	}
 
-	if len(projs) > 1 {
-		// This is easy enough to support when we have more than one project
-		// type, but for now there's no point.
-		return fmt.Errorf("%d projects detected, but multiple project types are not supported by init yet", len(projs))
+	for _, dir := range dirs {
+		err := createEarthfile(dir.Path, func(w io.Writer) error {
+			return proj.WriteEarthfile(ctx, w, dir.Projects, efIndent)
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	p := projs[0]
-	if p.Root(ctx) != absWd {
-		// In the distant future, this may be used to generate multiple
-		// Earthfiles over multiple directories and call them from a main
-		// Earthfile target with BUILD.
-		return fmt.Errorf("project type %T wants to generate an Earthfile in an unsupported directory: %q", p, p.Root(ctx))
+	if !monorepo {
+		return nil
 	}
 
-	return initSingleProject(f, p)
+	return createEarthfile(absWd, func(w io.Writer) error {
+		return proj.WriteAggregateEarthfile(w, absWd, dirs, efIndent)
+	})
 }
 
-func initSingleProject(w io.Writer, p proj.Project) error {
-	tgts, err := p.Targets()
+func createEarthfile(dir string, write func(io.Writer) error) error {
+	efPath := filepath.Join(dir, buildcontext.Earthfile)
+
+	f, err := os.Create(efPath) // #nosec G304
 	if err != nil {
-		return fmt.Errorf("could not generate targets for project type %T: %w", p, err)
+		return fmt.Errorf("could not create %q: %w", efPath, err)
 	}
+	defer f.Close()
 
-	for i, tgt := range tgts {
-		tgt.SetPrefix("")
-
-		if i > 0 {
-			_, err = w.Write([]byte("\n"))
-			if err != nil {
-				return fmt.Errorf("could not write newline separator between targets: %w", err)
-			}
-		}
+	_, err = f.WriteString("VERSION --arg-scope-and-set 0.7\n\n")
+	if err != nil {
+		return fmt.Errorf("could not write version string in %q: %w", efPath, err)
+	}
 
-		err := tgt.Format(w, efIndent)
-		if err != nil {
-			return fmt.Errorf("could not format target for project type %T: %w", p, err)
-		}
+	err = write(f)
+	if err != nil {
+		return fmt.Errorf("could not write %q: %w", efPath, err)
 	}
 
 	return nil
@@ -0,0 +1,193 @@
+package subcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EarthBuild/earthbuild/internal/lint"
+	"github.com/urfave/cli/v3"
+)
+
+// errLintProblems is returned by lint when a diagnostic is at least as severe
+// as --fail-on.
+var errLintProblems = errors.New("lint found problems")
+
+// Lint encapsulates the lint command logic.
+type Lint struct {
+	cli CLI
+
+	// out is stdout; nil means [os.Stdout]. Injectable so tests can capture
+	// output without hijacking the global.
+	out io.Writer
+
+	format    string
+	failOn    string
+	rules     []string
+	listRules bool
+}
+
+// NewLint creates a new Lint command.
+func NewLint(cli CLI) *Lint {
+	return &Lint{
+		cli: cli,
+	}
+}
+
+func (a *Lint) writer() io.Writer {
+	if a.out == nil {
+		return os.Stdout
+	}
+
+	return a.out
+}
+
+// Cmds returns the list of commands for the lint command.
+func (a *Lint) Cmds() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "lint",
+			Usage:     "Check Earthfiles for likely mistakes",
+			UsageText: "earth [options] lint [--format text|json|sarif] [--rule <id>=<severity>...] [<path>...]",
+			Description: "Checks Earthfiles against a set of rules and reports the problems found. " +
+				"Paths are interpreted as for 'earth fmt'. " +
+				"A comment '# earth-lint:ignore [<rule>...]' suppresses diagnostics on its line, " +
+				"or on the next line when it stands alone; " +
+				"'# earth-lint:ignore-file [<rule>...]' suppresses them in the whole file.",
+			Action: a.action,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "format",
+					Usage:       "The output format: text, json or sarif",
+					Value:       lint.FormatText,
+					Destination: &a.format,
+				},
+				&cli.StringSliceFlag{
+					Name:        "rule",
+					Usage:       "Override the severity of a rule, as <id>=<off|info|warning|error>",
+					Destination: &a.rules,
+				},
+				&cli.StringFlag{
+					Name:        "fail-on",
+					Usage:       "Fail when a problem is at least this severe: info, warning, error, or off to never fail",
+					Value:       lint.SeverityError.String(),
+					Destination: &a.failOn,
+				},
+				&cli.BoolFlag{
+					Name:        "list-rules",
+					Usage:       "List the available rules and their default severity",
+					Destination: &a.listRules,
+				},
+			},
+		},
+	}
+}
+
+func (a *Lint) action(_ context.Context, cmd *cli.Command) error {
+	a.cli.SetCommandName("lint")
+
+	if a.listRules {
+		return a.printRules()
+	}
+
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+
+	failOn, err := lint.ParseSeverity(a.failOn)
+	if err != nil {
+		return fmt.Errorf("invalid --fail-on: %w", err)
+	}
+
+	args := cmd.Args().Slice()
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	diags, err := a.lint(args, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = lint.Write(a.writer(), a.format, diags)
+	if err != nil {
+		return err
+	}
+
+	if failOn == lint.SeverityOff {
+		return nil
+	}
+
+	var failed int
+
+	for _, d := range diags {
+		if d.Severity >= failOn {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d problem(s) of severity %s or higher", errLintProblems, failed, failOn)
+	}
+
+	return nil
+}
+
+// config builds the lint config from the --rule flags.
+func (a *Lint) config() (lint.Config, error) {
+	cfg := lint.Config{Severities: map[string]lint.Severity{}}
+
+	for _, r := range a.rules {
+		id, sevStr, ok := strings.Cut(r, "=")
+		if !ok {
+			return lint.Config{}, fmt.Errorf("invalid --rule %q; should be <id>=<severity>", r)
+		}
+
+		sev, err := lint.ParseSeverity(sevStr)
+		if err != nil {
+			return lint.Config{}, fmt.Errorf("invalid --rule %q: %w", r, err)
+		}
+
+		cfg.Severities[id] = sev
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// lint lints the Earthfiles referred to by the path arguments.
+func (a *Lint) lint(args []string, cfg lint.Config) ([]lint.Diagnostic, error) {
+	var diags []lint.Diagnostic
+
+	for _, arg := range args {
+		paths, err := fmtPaths(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			fileDiags, err := lint.LintFile(path, cfg)
+			if err != nil {
+				return nil, err
+			}
+
+			diags = append(diags, fileDiags...)
+		}
+	}
+
+	return diags, nil
+}
+
+func (a *Lint) printRules() error {
+	w := tabwriter.NewWriter(a.writer(), 0, 0, 2, ' ', 0)
+
+	for _, r := range lint.Rules() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.Severity, r.Description)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,61 @@
+package subcmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/internal/lint"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := (&Lint{rules: []string{"unused-arg=error", "copy-whole-context=off"}}).config()
+	require.NoError(t, err)
+	require.Equal(t, map[string]lint.Severity{
+		"unused-arg":         lint.SeverityError,
+		"copy-whole-context": lint.SeverityOff,
+	}, cfg.Severities)
+
+	_, err = (&Lint{rules: []string{"unused-arg"}}).config()
+	require.ErrorContains(t, err, "should be <id>=<severity>")
+
+	_, err = (&Lint{rules: []string{"unused-arg=loud"}}).config()
+	require.ErrorContains(t, err, `invalid severity "loud"`)
+
+	_, err = (&Lint{rules: []string{"no-such-rule=error"}}).config()
+	require.ErrorContains(t, err, `unknown lint rule "no-such-rule"`)
+}
+
+func TestLintPaths(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	top := writeEarthfile(t, root, "VERSION 0.8\nbuild:\n    FROM alpine\n    ARG A\n")
+	nested := writeEarthfile(t, filepath.Join(root, "sub"), "VERSION 0.8\nbuild:\n    FROM alpine\n    COPY . /src\n")
+
+	diags, err := (&Lint{}).lint([]string{root + "/..."}, lint.Config{})
+	require.NoError(t, err)
+	require.Len(t, diags, 2)
+	require.Equal(t, top, diags[0].File)
+	require.Equal(t, "unused-arg", diags[0].Rule)
+	require.Equal(t, nested, diags[1].File)
+	require.Equal(t, "copy-whole-context", diags[1].Rule)
+
+	_, err = (&Lint{}).lint([]string{filepath.Join(root, "missing")}, lint.Config{})
+	require.ErrorContains(t, err, "failed to find Earthfile")
+}
+
+func TestLintListRules(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	require.NoError(t, (&Lint{out: &out}).printRules())
+
+	for _, r := range lint.Rules() {
+		require.Contains(t, out.String(), r.ID)
+	}
+}
@@ -21,6 +21,7 @@ type List struct {
 
 	showArgs bool
 	showLong bool
+	showAll  bool
 }
 
 // NewList creates a new List command.
@@ -52,6 +53,11 @@ func (a *List) Cmds() []*cli.Command {
 					Usage:       "Show full target-ref",
 					Destination: &a.showLong,
 				},
+				&cli.BoolFlag{
+					Name:        "all",
+					Usage:       "Show internal and private targets",
+					Destination: &a.showAll,
+				},
 			},
 		},
 	}
@@ -102,7 +108,7 @@ func (a *List) action(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	targets, err := earthfile2llb.GetTargets(ctx, resolver, gwClient, target)
+	targets, err := earthfile2llb.GetTargets(ctx, resolver, gwClient, target, a.showAll)
 	if err != nil {
 		if _, ok := errors.AsType[buildcontext.EarthfileNotExistError](err); ok {
 			return fmt.Errorf("unable to locate Earthfile under %s", targetToDisplay)
@@ -115,7 +121,7 @@ func (a *List) action(ctx context.Context, cmd *cli.Command) error {
 	sort.Strings(targets)
 
 	for _, t := range targets {
-		var args []string
+		var args []earthfile2llb.TargetArg
 
 		if t != earthfile.TargetBase {
 			target.Target = t
@@ -134,7 +140,12 @@ func (a *List) action(ctx context.Context, cmd *cli.Command) error {
 
 		if a.showArgs {
 			for _, arg := range args {
-				fmt.Printf("  --%s\n", arg)
+				if constraint := arg.Constraint.String(); constraint != "" {
+					fmt.Printf("  --%s (%s)\n", arg.Name, constraint)
+					continue
+				}
+
+				fmt.Printf("  --%s\n", arg.Name)
 			}
 		}
 	}
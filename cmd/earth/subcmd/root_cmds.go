@@ -25,12 +25,18 @@ func (a *Root) Cmds() []*cli.Command {
 		NewDebug(a.cli).Cmds(),
 		NewBootstrap(a.cli).Cmds(),
 		a.buildCmd.Cmds(),
+		NewAffected(a.cli, a.buildCmd).Cmds(),
 		NewConfig(a.cli).Cmds(),
 		NewDoc(a.cli).Cmds(),
 		NewDoc2Earth(a.cli).Cmds(),
+		NewExplainSkip(a.cli).Cmds(),
+		NewFmt(a.cli).Cmds(),
+		NewGraph(a.cli).Cmds(),
+		NewLint(a.cli).Cmds(),
 		NewInit(a.cli).Cmds(),
 		NewList(a.cli).Cmds(),
 		NewPrune(a.cli).Cmds(),
+		NewAutoSkip(a.cli).Cmds(),
 	})
 
 	return cmds
@@ -77,6 +77,7 @@ type GlobalConfig struct {
 	BuildkitImage              string        `help:"Choose a specific image for your buildkitd."                                                                                                         yaml:"buildkit_image"`                 //nolint:lll
 	CachePath                  string        `help:" *Deprecated* The path to keep earth's cache."                                                                                                       yaml:"cache_path"`                     //nolint:lll
 	SecretProvider             string        `help:"Command to execute to retrieve secret."                                                                                                              yaml:"secret_provider"`                //nolint:lll
+	AutoSkipRemote             string        `help:"The remote store to share auto-skip hashes through: an http(s):// URL of an auto-skip server, or s3://bucket/prefix for an S3-compatible store."     yaml:"auto_skip_remote"`               //nolint:lll
 	BuildkitAdditionalConfig   string        `help:"Additional config to use when starting the buildkit container; like using custom/self-signed certificates."                                          yaml:"buildkit_additional_config"`     //nolint:lll
 	IPTables                   string        `help:"Which iptables binary to use. Valid values are iptables-legacy or iptables-nft. Bypasses any autodetection."                                         yaml:"ip_tables"`                      //nolint:lll
 	ContainerFrontend          string        `help:"What program should be used to start and stop buildkitd, save images. Default is 'docker'. Valid options are 'docker' and 'podman' (experimental)."  yaml:"container_frontend"`             //nolint:lll
@@ -116,10 +117,29 @@ type GitConfig struct {
 	Port                  int    `help:"The port to connect to when using git; has no effect for http(s)."                                                                                                                                                                                                                yaml:"port"`                     //nolint:lll
 }
 
+// SecretStoreConfig contains the config values of a secret store, which
+// serves the secrets whose names start with its prefix.
+// #nosec G117
+type SecretStoreConfig struct {
+	Type       string `help:"The type of the store. Valid options are: vault, sops."                                                                  yaml:"type"`         //nolint:lll
+	Address    string `help:"The address of the Vault server, e.g. https://vault.example.com:8200. Defaults to $VAULT_ADDR."                          yaml:"address"`      //nolint:lll
+	Namespace  string `help:"The Vault Enterprise namespace. Defaults to $VAULT_NAMESPACE."                                                           yaml:"namespace"`    //nolint:lll
+	Mount      string `help:"The mount path of the Vault KV v2 secrets engine, defaults to secret."                                                   yaml:"mount"`        //nolint:lll
+	Path       string `help:"A path within the Vault mount which secret names are relative to."                                                       yaml:"path"`         //nolint:lll
+	Auth       string `help:"How to authenticate with Vault. Valid options are: token, approle. Defaults to token."                                   yaml:"auth"`         //nolint:lll
+	Token      string `help:"The Vault token used by token auth. Defaults to $VAULT_TOKEN, then ~/.vault-token."                                      yaml:"token"`        //nolint:lll
+	RoleID     string `help:"The role ID used by approle auth. Defaults to $VAULT_ROLE_ID."                                                           yaml:"role_id"`      //nolint:lll
+	SecretID   string `help:"The secret ID used by approle auth. Defaults to $VAULT_SECRET_ID."                                                       yaml:"secret_id"`    //nolint:lll
+	AuthMount  string `help:"The mount path of the Vault AppRole auth method, defaults to approle."                                                   yaml:"auth_mount"`   //nolint:lll
+	File       string `help:"The SOPS encrypted YAML file. Relative paths are interpreted as relative to the directory earth is run from."            yaml:"file"`         //nolint:lll
+	AgeKeyFile string `help:"The age identities used to decrypt the SOPS file. Defaults to $SOPS_AGE_KEY, $SOPS_AGE_KEY_FILE, then the sops default." yaml:"age_key_file"` //nolint:lll
+}
+
 // Config contains user's configuration values from ~/earthly/config.yml.
 type Config struct {
-	Git    map[string]GitConfig `help:"Git configuration object. Requires YAML literal to set directly."    yaml:"git"`
-	Global GlobalConfig         `help:"Global configuration object. Requires YAML literal to set directly." yaml:"global"`
+	Git     map[string]GitConfig         `help:"Git configuration object. Requires YAML literal to set directly."                                    keyhelp:"Git repository. Quote names with dots in them, like this: git.\"github.com\". Requires YAML literal to set directly."                 yaml:"git"`     //nolint:lll
+	Secrets map[string]SecretStoreConfig `help:"Secret stores, by the prefix of the secret names they serve. Requires YAML literal to set directly." keyhelp:"Secret store serving the secrets whose names start with this prefix, e.g. secrets.\"vault/\". Requires YAML literal to set directly." yaml:"secrets"` //nolint:lll
+	Global  GlobalConfig                 `help:"Global configuration object. Requires YAML literal to set directly."                                                                                                                                                                                yaml:"global"`  //nolint:lll
 }
 
 // PortOffset is the offset to use for dev ports.
@@ -309,19 +329,21 @@ func splitPath(path string) []string {
 }
 
 func validatePath(t reflect.Type, path []string) (reflect.Type, string, error) {
+	return validatePathKeyHelp(t, path, "")
+}
+
+// validatePathKeyHelp validates the path within t. keyHelp is the help of the
+// keys of t, when t is a map.
+func validatePathKeyHelp(t reflect.Type, path []string, keyHelp string) (reflect.Type, string, error) {
 	if len(path) == 0 {
 		return nil, "", errors.New("no path present")
 	}
 
 	if t.Kind() == reflect.Map {
-		// Maps are only for git repos. Grab the kind on the other side of the map
-		// and advance; to validate the path on the other side of the repo name
-
-		// path is a git."some.repo", so we can't advance
+		// Grab the kind on the other side of the map and advance, to validate the
+		// path on the other side of the key, e.g. the repo name of git."some.repo".
 		if len(path) == 1 {
-			// base case. I am not happy with this. Will need to change if we get more than one map in the config.
-			return t.Elem(), `Git repository. Quote names with dots in them, like this: git."github.com". " +
-			"Requires YAML literal to set directly.`, nil
+			return t.Elem(), keyHelp, nil
 		}
 
 		return validatePath(t.Elem(), path[1:])
@@ -337,7 +359,7 @@ func validatePath(t reflect.Type, path []string) (reflect.Type, string, error) {
 				return field.Type, helpTag, nil
 			}
 
-			return validatePath(field.Type, path[1:])
+			return validatePathKeyHelp(field.Type, path[1:], field.Tag.Get("keyhelp"))
 		}
 	}
 
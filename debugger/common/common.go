@@ -45,6 +45,27 @@ const (
 
 	// WinSizeData identifies the terminal window data payload packet.
 	WinSizeData = 0x04
+
+	// GetFile identifies a request to copy a file or directory from the
+	// container to the host, with the path on the host as payload.
+	GetFile = 0x05
+
+	// PutFile identifies a request to copy a file or directory from the host
+	// into the container, with the path on the host as payload.
+	PutFile = 0x06
+
+	// TransferResult identifies the result of a file transfer, with the error
+	// as payload, or no payload on success.
+	TransferResult = 0x07
+
+	// GetFileOverwrite identifies a GetFile request which may overwrite the
+	// existing files of the host, once the user confirms it on the host.
+	GetFileOverwrite = 0x08
+
+	// SessionToken identifies the token of an interactive session, which the
+	// host sends once the session starts, and which a file transfer requested
+	// from the session starts with.
+	SessionToken = 0x09
 )
 
 // End of network protocol magic numbers
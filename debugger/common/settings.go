@@ -10,12 +10,25 @@ const (
 	// DefaultSaveFileSocketPath is the default socket to connect to when sending back files
 	// (path is inside the container).
 	DefaultSaveFileSocketPath = "/var/run/earthly_save"
+
+	// DebuggerSessionTokenEnv is the environment variable which holds the token
+	// of the interactive session in its shell.
+	DebuggerSessionTokenEnv = "EARTH_DEBUG_SESSION"
+)
+
+const (
+	// BreakBefore pauses a command at a breakpoint before it runs.
+	BreakBefore = "before"
+
+	// BreakOnError pauses a command at a breakpoint only if it fails.
+	BreakOnError = "on-error"
 )
 
 // DebuggerSettings is used to pass settings to the debugger.
 type DebuggerSettings struct {
 	SocketPath        string              `json:"socketPath"`
 	Term              string              `json:"term"`
+	Break             string              `json:"break,omitempty"` // BreakBefore or BreakOnError, if set.
 	SaveFiles         []SaveFilesSettings `json:"saveFiles"`
 	DebugLevelLogging bool                `json:"debugLevel"`
 	Enabled           bool                `json:"enabled"`
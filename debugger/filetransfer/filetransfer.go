@@ -0,0 +1,512 @@
+// Package filetransfer copies files and directories between the container of
+// an interactive debugger session and the host, over the debugger socket.
+//
+// A transfer starts with a SessionToken data packet, with the token of the
+// interactive session it is requested from as payload, and a GetFile,
+// GetFileOverwrite or PutFile data packet, with the path on the host as
+// payload, which the host answers with a TransferResult data packet.
+// If it is accepted, the sender streams the file or directory as a tar archive
+// in uint16 prefixed chunks, ending with an empty chunk, and the host answers
+// with the TransferResult of the copy.
+package filetransfer
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/debugger/common"
+)
+
+// Get copies the file or directory name of root to the path dst on the host,
+// from the session with the token. Unless overwrite is set, the host refuses
+// to replace its existing files; otherwise it asks its user. The progress func
+// is called with the number of bytes sent so far.
+func Get(
+	conn io.ReadWriter, token string, root *os.Root, name, dst string, overwrite bool, progress func(int64),
+) error {
+	reqType := common.GetFile
+	if overwrite {
+		reqType = common.GetFileOverwrite
+	}
+
+	err := request(conn, token, reqType, dst)
+	if err != nil {
+		return err
+	}
+
+	err = send(&countingWriter{w: newChunkWriter(conn), progress: progress}, root, name)
+	if err != nil {
+		return err
+	}
+
+	return readResult(conn)
+}
+
+// Put copies the file or directory src of the host to the path dst of root,
+// from the session with the token. The progress func is called with the
+// number of bytes received so far.
+func Put(conn io.ReadWriter, token string, root *os.Root, src, dst string, progress func(int64)) error {
+	err := request(conn, token, common.PutFile, src)
+	if err != nil {
+		return err
+	}
+
+	err = receive(&countingReader{r: newChunkReader(conn), progress: progress}, root, dst, replaceExisting)
+	if err != nil {
+		return err
+	}
+
+	return readResult(conn)
+}
+
+// Serve serves a GetFile, GetFileOverwrite or PutFile request on the host, with
+// the path on the host, relative to dir, from which or to which the files are
+// copied. The existing path on the host to which a GetFileOverwrite request
+// copies files is only replaced if confirm returns true for it.
+func Serve(conn io.ReadWriter, reqType int, hostPath, dir string, confirm func(path string) bool) error {
+	name := filepath.Clean(hostPath)
+	if !filepath.IsLocal(name) {
+		return Refuse(conn, fmt.Errorf("%s is not a relative path inside %s", hostPath, dir))
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return Refuse(conn, err)
+	}
+
+	defer func() { _ = root.Close() }() // Best effort.
+
+	switch reqType {
+	case common.GetFile, common.GetFileOverwrite:
+		err = writeResult(conn, nil)
+		if err != nil {
+			return err
+		}
+
+		overwrite := refuseExisting
+		if reqType == common.GetFileOverwrite {
+			overwrite = func(p string) error {
+				if !confirm(p) {
+					return fmt.Errorf("%s already exists, and overwriting it was declined on the host", p)
+				}
+
+				return nil
+			}
+		}
+
+		err = receive(newChunkReader(conn), root, name, overwrite)
+
+		return errors.Join(err, writeResult(conn, err))
+	case common.PutFile:
+		_, err = root.Stat(name)
+		if err != nil {
+			return Refuse(conn, err)
+		}
+
+		err = writeResult(conn, nil)
+		if err != nil {
+			return err
+		}
+
+		err = send(newChunkWriter(conn), root, name)
+
+		return errors.Join(err, writeResult(conn, err))
+	default:
+		return Refuse(conn, fmt.Errorf("unexpected file transfer request %d", reqType))
+	}
+}
+
+// Refuse refuses a file transfer request, and returns the reason.
+func Refuse(conn io.Writer, reason error) error {
+	return errors.Join(reason, writeResult(conn, reason))
+}
+
+func request(conn io.ReadWriter, token string, reqType int, hostPath string) error {
+	err := common.WriteDataPacket(conn, common.SessionToken, []byte(token))
+	if err != nil {
+		return err
+	}
+
+	err = common.WriteDataPacket(conn, reqType, []byte(hostPath))
+	if err != nil {
+		return err
+	}
+
+	return readResult(conn)
+}
+
+func writeResult(w io.Writer, err error) error {
+	var msg []byte
+	if err != nil {
+		msg = []byte(err.Error())
+	}
+
+	return common.WriteDataPacket(w, common.TransferResult, msg)
+}
+
+func readResult(r io.Reader) error {
+	dataType, data, err := common.ReadDataPacket(r)
+	if err != nil {
+		return err
+	}
+
+	if dataType != common.TransferResult {
+		return fmt.Errorf("unexpected data type %d", dataType)
+	}
+
+	if len(data) != 0 {
+		return errors.New(string(data))
+	}
+
+	return nil
+}
+
+// send writes the file or directory name of root to w as a tar archive, and
+// ends the stream.
+func send(w io.WriteCloser, root *os.Root, name string) error {
+	bw := bufio.NewWriterSize(w, math.MaxUint16)
+	tw := tar.NewWriter(bw)
+
+	fsName := filepath.ToSlash(name)
+
+	top := path.Base(fsName)
+	if fsName == "." {
+		top = filepath.Base(root.Name())
+	}
+
+	err := fs.WalkDir(root.FS(), fsName, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err = root.Readlink(filepath.FromSlash(p))
+			if err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			return nil // Devices, sockets and pipes are not copied.
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, fsName), "/")
+		if fsName == "." {
+			rel = p
+		}
+
+		hdr.Name = path.Join(top, rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := root.Open(filepath.FromSlash(p))
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = f.Close() }() // Best effort.
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err == nil {
+		err = bw.Flush()
+	}
+
+	// The stream ends even if the archive is incomplete, so that the receiver
+	// can read the result of the transfer.
+	return errors.Join(err, w.Close())
+}
+
+// refuseExisting refuses to replace the existing path p.
+func refuseExisting(p string) error {
+	return fmt.Errorf("%s already exists, use earth-debug get -f to overwrite it", p)
+}
+
+// replaceExisting allows to replace the existing path p.
+func replaceExisting(string) error {
+	return nil
+}
+
+// receive extracts the tar archive read from r to the path dst of root, or
+// inside it if it is a directory, and reads the rest of the stream. If the
+// path to which the archive is extracted exists, it is only replaced if
+// overwrite returns no error for it.
+func receive(r io.Reader, root *os.Root, dst string, overwrite func(p string) error) error {
+	err := extract(tar.NewReader(r), root, dst, overwrite)
+
+	_, drainErr := io.Copy(io.Discard, r)
+
+	return errors.Join(err, drainErr)
+}
+
+func extract(tr *tar.Reader, root *os.Root, dst string, overwrite func(p string) error) error {
+	var (
+		top, base string
+		replace   bool
+	)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unsafe path %s in archive", hdr.Name)
+		}
+
+		entryTop, rest, _ := strings.Cut(name, "/")
+
+		if base == "" {
+			top = entryTop
+
+			base, err = extractBase(root, dst, top)
+			if err != nil {
+				return err
+			}
+
+			if _, err = root.Lstat(base); err == nil {
+				err = overwrite(base)
+				if err != nil {
+					return err
+				}
+
+				replace = true
+			}
+		}
+
+		if entryTop != top {
+			return fmt.Errorf("unexpected path %s in archive of %s", hdr.Name, top)
+		}
+
+		// An entry must not be written through a symlink extracted before it.
+		err = checkNoSymlinkParent(root, base, rest)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(base, filepath.FromSlash(rest))
+		mode := hdr.FileInfo().Mode().Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = root.MkdirAll(target, mode|0o700)
+		case tar.TypeSymlink:
+			if replace {
+				_ = root.Remove(target) // Best effort.
+			}
+
+			err = root.Symlink(hdr.Linkname, target)
+		case tar.TypeReg:
+			err = extractFile(tr, root, target, mode, replace)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if base == "" {
+		return errors.New("empty archive")
+	}
+
+	return nil
+}
+
+// extractBase returns the path to which the top-level entry of an archive is
+// extracted: dst, or top inside dst if it is a directory.
+func extractBase(root *os.Root, dst, top string) (string, error) {
+	info, err := root.Stat(dst)
+	if err == nil && info.IsDir() {
+		return filepath.Join(dst, top), nil
+	}
+
+	err = root.MkdirAll(filepath.Dir(dst), 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	return dst, nil
+}
+
+// checkNoSymlinkParent returns an error if one of the parent directories of
+// the path rest, inside base, is a symlink.
+func checkNoSymlinkParent(root *os.Root, base, rest string) error {
+	p := base
+
+	for elem := range strings.SplitSeq(path.Dir(rest), "/") {
+		if elem == "." {
+			break
+		}
+
+		p = filepath.Join(p, elem)
+
+		info, err := root.Lstat(p)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink, files are not copied through it", p)
+		}
+	}
+
+	return nil
+}
+
+func extractFile(r io.Reader, root *os.Root, target string, mode fs.FileMode, overwrite bool) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_EXCL
+	if overwrite {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+
+	f, err := root.OpenFile(target, flags, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+
+	return errors.Join(err, f.Close())
+}
+
+// chunkWriter writes a stream as uint16 prefixed chunks, and ends it with an
+// empty chunk when closed.
+type chunkWriter struct {
+	w io.Writer
+}
+
+func newChunkWriter(w io.Writer) *chunkWriter {
+	return &chunkWriter{w: w}
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		n := min(len(p), math.MaxUint16)
+
+		err := common.WriteUint16PrefixedData(cw.w, p[:n])
+		if err != nil {
+			return written, err
+		}
+
+		written += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+func (cw *chunkWriter) Close() error {
+	return common.WriteUint16PrefixedData(cw.w, nil)
+}
+
+// chunkReader reads a stream of uint16 prefixed chunks, up to its empty chunk.
+type chunkReader struct {
+	r   io.Reader
+	buf []byte
+	eof bool
+}
+
+func newChunkReader(r io.Reader) *chunkReader {
+	return &chunkReader{r: r}
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for len(cr.buf) == 0 {
+		if cr.eof {
+			return 0, io.EOF
+		}
+
+		data, err := common.ReadUint16PrefixedData(cr.r)
+		if err != nil {
+			return 0, err
+		}
+
+		cr.buf = data
+		cr.eof = len(data) == 0
+	}
+
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+
+	return n, nil
+}
+
+type countingWriter struct {
+	w        io.WriteCloser
+	progress func(int64)
+	n        int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.progress(cw.n)
+
+	return n, err
+}
+
+func (cw *countingWriter) Close() error {
+	return cw.w.Close()
+}
+
+type countingReader struct {
+	r        io.Reader
+	progress func(int64)
+	n        int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	cr.progress(cr.n)
+
+	return n, err
+}
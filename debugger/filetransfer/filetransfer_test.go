@@ -0,0 +1,243 @@
+package filetransfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/debugger/common"
+	"github.com/stretchr/testify/require"
+)
+
+// testToken is the token of the session from which the transfers are
+// requested in tests.
+const testToken = "session"
+
+// serve serves one file transfer request on the host side of a unix socket,
+// whose user answers confirm, and returns the client side.
+func serve(t *testing.T, hostDir string, confirm bool) (net.Conn, <-chan error) {
+	t.Helper()
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "sock"))
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		host, acceptErr := l.Accept()
+		if acceptErr != nil {
+			errCh <- acceptErr
+			return
+		}
+
+		defer func() { _ = host.Close() }()
+
+		tokenType, token, readErr := common.ReadDataPacket(host)
+		if readErr != nil {
+			errCh <- readErr
+			return
+		}
+
+		if tokenType != common.SessionToken || string(token) != testToken {
+			errCh <- Refuse(host, errors.New("unknown session"))
+			return
+		}
+
+		reqType, data, readErr := common.ReadDataPacket(host)
+		if readErr != nil {
+			errCh <- readErr
+			return
+		}
+
+		errCh <- Serve(host, reqType, string(data), hostDir, func(string) bool { return confirm })
+	}()
+
+	client, err := net.Dial("unix", l.Addr().String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = l.Close()
+	})
+
+	return client, errCh
+}
+
+func openRoot(t *testing.T, dir string) *os.Root {
+	t.Helper()
+
+	root, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = root.Close() })
+
+	return root
+}
+
+func writeTree(t *testing.T, dir string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "sub", "b.sh"), []byte("b"), 0o755))
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "src", "link")))
+}
+
+func requireTree(t *testing.T, dir string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+
+	info, err := os.Stat(filepath.Join(dir, "sub", "b.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	link, err := os.Readlink(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "a.txt", link)
+}
+
+func TestGetDirectory(t *testing.T) {
+	t.Parallel()
+
+	containerDir := t.TempDir()
+	hostDir := t.TempDir()
+	writeTree(t, containerDir)
+
+	conn, errCh := serve(t, hostDir, false)
+
+	var sent int64
+
+	err := Get(conn, testToken, openRoot(t, containerDir), "src", "out", false, func(n int64) { sent = n })
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+	require.Positive(t, sent)
+
+	requireTree(t, filepath.Join(hostDir, "out"))
+}
+
+func TestGetFileIntoDirectory(t *testing.T) {
+	t.Parallel()
+
+	containerDir := t.TempDir()
+	hostDir := t.TempDir()
+	writeTree(t, containerDir)
+
+	conn, errCh := serve(t, hostDir, false)
+
+	err := Get(conn, testToken, openRoot(t, filepath.Join(containerDir, "src")), "a.txt", ".", false, func(int64) {})
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+
+	data, err := os.ReadFile(filepath.Join(hostDir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+}
+
+func TestGetExistingFile(t *testing.T) {
+	t.Parallel()
+
+	containerDir := t.TempDir()
+	hostDir := t.TempDir()
+	writeTree(t, containerDir)
+	require.NoError(t, os.WriteFile(filepath.Join(hostDir, "a.txt"), []byte("host"), 0o644))
+
+	conn, errCh := serve(t, hostDir, false)
+
+	err := Get(conn, testToken, openRoot(t, filepath.Join(containerDir, "src")), "a.txt", ".", false, func(int64) {})
+	require.ErrorContains(t, err, "already exists, use earth-debug get -f to overwrite it")
+	require.Error(t, <-errCh)
+
+	data, err := os.ReadFile(filepath.Join(hostDir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "host", string(data))
+
+	// The user of the host is asked before a file is overwritten.
+	conn, errCh = serve(t, hostDir, false)
+
+	err = Get(conn, testToken, openRoot(t, filepath.Join(containerDir, "src")), "a.txt", ".", true, func(int64) {})
+	require.ErrorContains(t, err, "already exists, and overwriting it was declined on the host")
+	require.Error(t, <-errCh)
+
+	data, err = os.ReadFile(filepath.Join(hostDir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "host", string(data))
+
+	conn, errCh = serve(t, hostDir, true)
+
+	err = Get(conn, testToken, openRoot(t, filepath.Join(containerDir, "src")), "a.txt", ".", true, func(int64) {})
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+
+	data, err = os.ReadFile(filepath.Join(hostDir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+}
+
+func TestReceiveThroughSymlink(t *testing.T) {
+	t.Parallel()
+
+	hostDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(hostDir, "hooks"), 0o755))
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "out/", Mode: 0o755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "out/link", Linkname: "../hooks"}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "out/link/pre-commit", Mode: 0o755, Size: 1}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	err = receive(&buf, openRoot(t, hostDir), "out", refuseExisting)
+	require.ErrorContains(t, err, "is a symlink, files are not copied through it")
+	require.NoFileExists(t, filepath.Join(hostDir, "hooks", "pre-commit"))
+}
+
+func TestPutDirectory(t *testing.T) {
+	t.Parallel()
+
+	containerDir := t.TempDir()
+	hostDir := t.TempDir()
+	writeTree(t, hostDir)
+
+	conn, errCh := serve(t, hostDir, false)
+
+	err := Put(conn, testToken, openRoot(t, containerDir), "src", ".", func(int64) {})
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+
+	requireTree(t, filepath.Join(containerDir, "src"))
+}
+
+func TestHostPathOutsideDir(t *testing.T) {
+	t.Parallel()
+
+	for _, hostPath := range []string{"../out", "/tmp/out"} {
+		containerDir := t.TempDir()
+		writeTree(t, containerDir)
+
+		conn, errCh := serve(t, t.TempDir(), false)
+
+		err := Get(conn, testToken, openRoot(t, containerDir), "src", hostPath, false, func(int64) {})
+		require.ErrorContains(t, err, "is not a relative path")
+		require.Error(t, <-errCh)
+	}
+}
+
+func TestPutMissingFile(t *testing.T) {
+	t.Parallel()
+
+	conn, errCh := serve(t, t.TempDir(), false)
+
+	err := Put(conn, testToken, openRoot(t, t.TempDir()), "missing", ".", func(int64) {})
+	require.ErrorContains(t, err, "no such file or directory")
+	require.Error(t, <-errCh)
+}
@@ -1,2 +1,9 @@
 // Package terminal manages interactive terminal connections for debugging active earth builds.
 package terminal
+
+// Prompt is a yes or no question asked to the user of a terminal, while it is
+// connected to a shell. The answer is sent to Answer, which must be buffered.
+type Prompt struct {
+	Answer   chan<- bool
+	Question string
+}
@@ -42,12 +42,16 @@ func getWindowSizePayload() ([]byte, error) {
 	return common.SerializeDataPacket(common.WinSizeData, b)
 }
 
-// ConnectTerm presents a terminal to the shell repeater.
-func ConnectTerm(ctx context.Context, conn io.ReadWriteCloser, log *conslogging.ConsoleLogger) error {
+// ConnectTerm presents a terminal to the shell repeater. The prompts are asked
+// to the user in the terminal, whose next key press answers them.
+func ConnectTerm(
+	ctx context.Context, conn io.ReadWriteCloser, log *conslogging.ConsoleLogger, prompts <-chan Prompt,
+) error {
 	sigs := make(chan os.Signal, 10)
 	signal.Notify(sigs, syscall.SIGWINCH)
 
 	writeCh := make(chan []byte, 10)
+	stdinCh := make(chan []byte)
 
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -138,18 +142,38 @@ func ConnectTerm(ctx context.Context, conn io.ReadWriteCloser, log *conslogging.
 				break
 			}
 
-			buf = buf[:n]
+			select {
+			case stdinCh <- buf[:n]:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		cancel()
+	}()
+	go func() {
+		defer cancel()
+
+		for {
+			var buf []byte
+
+			select {
+			case <-ctx.Done():
+				return
+			case p := <-prompts:
+				askPrompt(ctx, p, stdinCh, log)
+				continue
+			case buf = <-stdinCh:
+			}
 
 			buf2, err := common.SerializeDataPacket(common.PtyData, buf)
 			if err != nil {
 				log.VerbosePrintf("failed to serialize data: %s\n", err.Error())
-				break
+				return
 			}
 
 			writeCh <- buf2
 		}
-
-		cancel()
 	}()
 
 	<-ctx.Done()
@@ -164,6 +188,27 @@ func ConnectTerm(ctx context.Context, conn io.ReadWriteCloser, log *conslogging.
 	return nil
 }
 
+// askPrompt asks the prompt, and answers it with the next key press read from
+// stdinCh. The input is not sent to the shell.
+func askPrompt(ctx context.Context, p Prompt, stdinCh <-chan []byte, log *conslogging.ConsoleLogger) {
+	err := handlePtyData([]byte("\r\n" + p.Question + " [y/N] "))
+	if err != nil {
+		log.VerbosePrintf("handlePtyData failed: %s\n", err.Error())
+	}
+
+	select {
+	case <-ctx.Done():
+		p.Answer <- false
+	case buf := <-stdinCh:
+		p.Answer <- len(buf) > 0 && (buf[0] == 'y' || buf[0] == 'Y')
+
+		err = handlePtyData([]byte("\r\n"))
+		if err != nil {
+			log.VerbosePrintf("handlePtyData failed: %s\n", err.Error())
+		}
+	}
+}
+
 type termState struct {
 	oldState *term.State
 	mu       sync.Mutex
@@ -10,6 +10,8 @@ import (
 	"github.com/EarthBuild/earthbuild/conslogging"
 )
 
-func ConnectTerm(ctx context.Context, addr io.ReadWriteCloser, log *conslogging.ConsoleLogger) error {
+func ConnectTerm(
+	ctx context.Context, addr io.ReadWriteCloser, log *conslogging.ConsoleLogger, prompts <-chan Prompt,
+) error {
 	return errors.New("debugger not supported on Windows yet")
 }
@@ -8,32 +8,20 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"text/template"
 
 	"github.com/EarthBuild/earthbuild/util/fileutil"
-
-	"github.com/moby/buildkit/frontend/dockerfile/instructions"
-	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
 // Ideally this would point to "the current version" rather than being hard-coded, but the single
 // "source of truth" (in ast/validator) isn't currently exported.
 const earthCurrentVersion = "0.7"
 
-func getArtifactName(s string) string {
-	split := strings.Split(s, "/")
-	n := len(split)
-
-	return split[n-1]
-}
-
 // Docker2Earth converts an existing Dockerfile in the current directory and writes out
 // an Earthfile in the current directory and error is returned if an Earthfile already exists.
-func Docker2Earth(dockerfilePath, earthfilePath, imageTag string) error {
+func Docker2Earth(dockerfilePath, earthfilePath, imageTag string) (*Conversion, error) {
 	if exists, _ := fileutil.FileExists(earthfilePath); exists {
-		return errors.New("earthfile already exists; please delete it if you wish to continue")
+		return nil, errors.New("earthfile already exists; please delete it if you wish to continue")
 	}
 
 	var in io.Reader
@@ -42,120 +30,33 @@ func Docker2Earth(dockerfilePath, earthfilePath, imageTag string) error {
 	} else {
 		in2, err := os.Open(dockerfilePath) // #nosec G304
 		if err != nil {
-			return fmt.Errorf("failed to open %q: %w", dockerfilePath, err)
+			return nil, fmt.Errorf("failed to open %q: %w", dockerfilePath, err)
 		}
 		defer in2.Close()
 
 		in = in2
 	}
 
-	targets := [][]string{
-		{
-			fmt.Sprintf("VERSION %s\n", earthCurrentVersion),
-			"# This Earthfile was generated using docker2earth",
-			"# the conversion is done on a best-effort basis",
-			"# and might not follow best practices, please",
-			"# visit https://docs.earthbuild.dev for Earthfile guides",
-		},
-	}
-
-	dockerfile, err := parser.Parse(in)
+	conv, err := Convert(in, imageTag)
 	if err != nil {
-		return fmt.Errorf("failed to parse Dockerfile located at %q: %w", dockerfilePath, err)
-	}
-
-	stages, initialArgs, err := instructions.Parse(dockerfile.AST)
-	if err != nil {
-		return fmt.Errorf("failed to parse Dockerfile located at %q: %w", dockerfilePath, err)
-	}
-
-	names := map[string]int{}
-
-	for i, stage := range stages {
-		fromCmd := []string{"FROM " + stage.BaseName}
-		// These args are in scope *only* for the very first FROM
-		if i == 0 && len(initialArgs) > 0 {
-			var fromArgs []string
-			for _, arg := range initialArgs {
-				fromArgs = append(fromArgs, arg.String())
-			}
-
-			fromCmd = append(fromArgs, fromCmd...)
-		}
-
-		targets = append(targets, fromCmd)
-
-		if stage.Name == "" {
-			names[strconv.Itoa(i)] = i
-		} else {
-			names[stage.Name] = i
-		}
-
-		for _, cmd := range stage.Commands {
-			l := fmt.Sprintf("%v", cmd)
-			if strings.HasPrefix(l, "COPY ") && strings.Contains(l, "--from") {
-				parts := strings.Split(l, " ")
-				if len(parts) != 4 {
-					return fmt.Errorf("failed to parse %q", l)
-				}
-
-				kv := strings.Split(parts[1], "=")
-				if len(kv) != 2 {
-					return fmt.Errorf("failed to parse %q", l)
-				}
-
-				fromStageName := kv[1]
-				n := names[fromStageName]
-				artifactName := getArtifactName(parts[2])
-				l = fmt.Sprintf("COPY +subbuild%d/%s %s", n+1, artifactName, parts[3])
-				targets[n+1] = append(targets[n+1], fmt.Sprintf("SAVE ARTIFACT %s %s\n", parts[2], artifactName))
-			}
-
-			if strings.HasPrefix(l, "ADD ") {
-				return errors.New("earth does not support ADD, please convert to COPY instead")
-			}
-
-			targets[i+1] = append(targets[i+1], l)
-		}
+		return nil, fmt.Errorf("failed to parse Dockerfile located at %q: %w", dockerfilePath, err)
 	}
 
-	i := len(targets) - 1
-	targets[i] = append(targets[i], "SAVE IMAGE "+imageTag)
-
-	var out io.Writer
-
 	if earthfilePath == "-" {
-		out2 := bufio.NewWriter(os.Stdout)
-		defer out2.Flush()
-
-		out = out2
-	} else {
-		out2, err := os.Create(earthfilePath) // #nosec G304
+		_, err = os.Stdout.WriteString(conv.Earthfile)
 		if err != nil {
-			return fmt.Errorf("failed to create Earthfile under %q: %w", earthfilePath, err)
+			return nil, fmt.Errorf("failed to write Earthfile: %w", err)
 		}
-		defer out2.Close()
 
-		out = out2
+		return conv, nil
 	}
 
-	for i, lines := range targets {
-		for j, l := range lines {
-			if i == 0 {
-				fmt.Fprintf(out, "%s\n", l) // #nosec G705
-			} else {
-				if j == 0 {
-					fmt.Fprintf(out, "subbuild%d:\n", i)
-				}
-
-				fmt.Fprintf(out, "    %s\n", l) // #nosec G705
-			}
-		}
+	err = os.WriteFile(earthfilePath, []byte(conv.Earthfile), 0o666) // #nosec G306
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Earthfile under %q: %w", earthfilePath, err)
 	}
 
-	fmt.Fprintf(out, "\nbuild:\n    BUILD +subbuild%d\n", i) // #nosec G705
-
-	return nil
+	return conv, nil
 }
 
 var earthfileTemplate = `
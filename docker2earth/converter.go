@@ -0,0 +1,731 @@
+package docker2earth
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// earthfileVersion is the version of the generated Earthfiles. RUN --network=none and global cache IDs need 0.8.
+const earthfileVersion = "0.8"
+
+const indent = "    "
+
+// nativeArgs maps the automatic platform ARGs of Dockerfiles to the builtin ARGs of Earthfiles.
+var nativeArgs = map[string]string{
+	"BUILDPLATFORM": "NATIVEPLATFORM",
+	"BUILDOS":       "NATIVEOS",
+	"BUILDARCH":     "NATIVEARCH",
+	"BUILDVARIANT":  "NATIVEVARIANT",
+}
+
+var (
+	invalidTargetCharsRE = regexp.MustCompile(`[^a-zA-Z0-9.\-]+`)
+	archiveRE            = regexp.MustCompile(`\.(tar|tgz|tbz2?|txz|tar\.(gz|bz2|xz|zst))$`)
+	shellSafeRE          = regexp.MustCompile(`^[a-zA-Z0-9@%_+=:,./\-]+$`)
+)
+
+// Warning is something in a Dockerfile which could not be converted exactly.
+type Warning struct {
+	Msg  string
+	Line int
+}
+
+// String returns the warning prefixed with its line in the Dockerfile.
+func (w Warning) String() string {
+	if w.Line == 0 {
+		return w.Msg
+	}
+
+	return fmt.Sprintf("line %d: %s", w.Line, w.Msg)
+}
+
+// Conversion is the result of converting a Dockerfile into an Earthfile.
+type Conversion struct {
+	// Earthfile is the content of the Earthfile.
+	Earthfile string
+	// Target is the target which builds the final stage of the Dockerfile.
+	Target string
+	// Warnings lists what could not be converted exactly.
+	Warnings []Warning
+}
+
+// Convert converts a Dockerfile into an Earthfile with a target per stage. The final stage saves its image as
+// imageTag.
+func Convert(in io.Reader, imageTag string) (*Conversion, error) {
+	dockerfile, err := parser.Parse(in)
+	if err != nil {
+		return nil, err
+	}
+
+	stages, metaArgs, err := instructions.Parse(dockerfile.AST)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stages) == 0 {
+		return nil, errors.New("dockerfile has no stages")
+	}
+
+	c := &converter{
+		stages:   map[string]*target{},
+		names:    map[string]bool{},
+		metaArgs: map[string]bool{},
+		features: map[string]bool{},
+	}
+
+	for _, arg := range metaArgs {
+		for _, kv := range arg.Args {
+			c.metaArgs[kv.Key] = true
+			c.globals = append(c.globals, "ARG --global "+argString(kv))
+		}
+	}
+
+	for i, stage := range stages {
+		name := stage.Name
+		if name == "" {
+			name = "stage-" + strconv.Itoa(i)
+			if i == len(stages)-1 {
+				name = "build"
+			}
+		}
+
+		t := c.newTarget(name)
+		t.comment = stage.Comment
+
+		c.from(t, stage)
+
+		for _, cmd := range stage.Commands {
+			c.command(t, cmd, line(cmd.Location()))
+		}
+
+		// Stages are only referenced by the stages after them, so they are registered once converted.
+		c.stages[strconv.Itoa(i)] = t
+		if stage.Name != "" {
+			c.stages[stage.Name] = t
+		}
+	}
+
+	final := c.stages[strconv.Itoa(len(stages)-1)]
+	final.lines = append(final.lines, strings.TrimSpace("SAVE IMAGE "+imageTag))
+
+	return &Conversion{
+		Earthfile: c.render(),
+		Target:    final.name,
+		Warnings:  c.warnings,
+	}, nil
+}
+
+type target struct {
+	name      string
+	comment   string
+	lines     []string
+	artifacts []artifact
+	onbuild   []onbuildTrigger
+}
+
+type artifact struct {
+	src  string
+	name string
+}
+
+type heredoc struct {
+	name string
+	data string
+}
+
+type onbuildTrigger struct {
+	expr string
+	line int
+}
+
+type converter struct {
+	stages   map[string]*target // by stage name and by stage index
+	images   map[string]*target // targets for COPY --from=<image>, by image
+	names    map[string]bool
+	metaArgs map[string]bool
+	features map[string]bool
+	targets  []*target
+	globals  []string
+	warnings []Warning
+}
+
+func (c *converter) warnf(line int, format string, args ...any) {
+	c.warnings = append(c.warnings, Warning{Msg: fmt.Sprintf(format, args...), Line: line})
+}
+
+// newTarget adds a target named after name, made unique and valid as a target name.
+func (c *converter) newTarget(name string) *target {
+	name = strings.Trim(invalidTargetCharsRE.ReplaceAllString(name, "-"), "-.")
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "stage-" + name
+	}
+
+	// The base target is the base recipe of the Earthfile.
+	if name == earthfile.TargetBase {
+		name += "-stage"
+	}
+
+	unique := name
+	for n := 2; c.names[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+
+	c.names[unique] = true
+
+	t := &target{name: unique}
+	c.targets = append(c.targets, t)
+
+	return t
+}
+
+func (c *converter) stage(name string) (*target, bool) {
+	t, ok := c.stages[strings.ToLower(name)]
+	return t, ok
+}
+
+func (c *converter) from(t *target, stage instructions.Stage) {
+	var platform string
+
+	switch p := strings.Trim(stage.Platform, "${}"); {
+	case p == "" || p == "TARGETPLATFORM":
+		// The target platform is the default in Earthfiles too.
+	case nativeArgs[p] != "":
+		t.lines = append(t.lines, "ARG "+nativeArgs[p])
+		platform = "--platform=$" + nativeArgs[p] + " "
+	default:
+		platform = "--platform=" + stage.Platform + " "
+	}
+
+	parent, ok := c.stage(stage.BaseName)
+	if !ok {
+		t.lines = append(t.lines, "FROM "+platform+stage.BaseName)
+		return
+	}
+
+	t.lines = append(t.lines, "FROM "+platform+"+"+parent.name)
+
+	// ONBUILD triggers run when a stage is built from the stage they are declared in.
+	for _, trigger := range parent.onbuild {
+		node, err := parser.Parse(strings.NewReader(trigger.expr))
+		if err != nil || len(node.AST.Children) != 1 {
+			c.warnf(trigger.line, "failed to parse ONBUILD instruction %q", trigger.expr)
+			continue
+		}
+
+		cmd, err := instructions.ParseCommand(node.AST.Children[0])
+		if err != nil {
+			c.warnf(trigger.line, "failed to parse ONBUILD instruction %q: %v", trigger.expr, err)
+			continue
+		}
+
+		c.command(t, cmd, trigger.line)
+	}
+}
+
+func (c *converter) command(t *target, cmd instructions.Command, line int) {
+	switch cmd := cmd.(type) {
+	case *instructions.ArgCommand:
+		for _, kv := range cmd.Args {
+			switch {
+			case kv.Value == nil && c.metaArgs[kv.Key]:
+				// The global ARG is in scope already.
+			case kv.Value == nil && nativeArgs[kv.Key] != "":
+				t.lines = append(t.lines, "ARG "+nativeArgs[kv.Key], fmt.Sprintf("ARG %s=$%s", kv.Key, nativeArgs[kv.Key]))
+			default:
+				t.lines = append(t.lines, "ARG "+argString(kv))
+			}
+		}
+	case *instructions.EnvCommand:
+		for _, kv := range cmd.Env {
+			t.lines = append(t.lines, "ENV "+kv.String())
+		}
+	case *instructions.LabelCommand:
+		labels := make([]string, 0, len(cmd.Labels))
+		for _, kv := range cmd.Labels {
+			labels = append(labels, kv.String())
+		}
+
+		t.lines = append(t.lines, "LABEL "+strings.Join(labels, " "))
+	case *instructions.MaintainerCommand:
+		t.lines = append(t.lines, "LABEL maintainer="+strconv.Quote(cmd.Maintainer))
+	case *instructions.WorkdirCommand:
+		t.lines = append(t.lines, "WORKDIR "+cmd.Path)
+	case *instructions.UserCommand:
+		t.lines = append(t.lines, "USER "+cmd.User)
+	case *instructions.ExposeCommand:
+		t.lines = append(t.lines, "EXPOSE "+strings.Join(cmd.Ports, " "))
+	case *instructions.VolumeCommand:
+		t.lines = append(t.lines, "VOLUME "+strings.Join(cmd.Volumes, " "))
+	case *instructions.StopSignalCommand:
+		c.features["--use-shell-and-stopsignal"] = true
+		t.lines = append(t.lines, "STOPSIGNAL "+cmd.Signal)
+	case *instructions.ShellCommand:
+		c.features["--use-shell-and-stopsignal"] = true
+		t.lines = append(t.lines, "SHELL "+execForm(cmd.Shell))
+	case *instructions.CmdCommand:
+		t.lines = append(t.lines, "CMD "+cmdLine(cmd.ShellDependantCmdLine))
+	case *instructions.EntrypointCommand:
+		t.lines = append(t.lines, "ENTRYPOINT "+cmdLine(cmd.ShellDependantCmdLine))
+	case *instructions.HealthCheckCommand:
+		c.healthcheck(t, cmd, line)
+	case *instructions.RunCommand:
+		c.run(t, cmd, line)
+	case *instructions.CopyCommand:
+		c.copy(t, cmd, line)
+	case *instructions.AddCommand:
+		c.add(t, cmd, line)
+	case *instructions.OnbuildCommand:
+		t.onbuild = append(t.onbuild, onbuildTrigger{expr: cmd.Expression, line: line})
+		c.warnf(line, "ONBUILD is not supported; %q is run in the targets built FROM +%s instead, "+
+			"but is not saved in its image", cmd.Expression, t.name)
+	default:
+		c.unsupported(t, cmd, line, fmt.Sprintf("%s is not supported", strings.ToUpper(cmd.Name())))
+	}
+}
+
+// unsupported comments out a Dockerfile instruction which can't be converted.
+func (c *converter) unsupported(t *target, cmd instructions.Command, line int, reason string) {
+	c.warnf(line, "%s, the instruction was commented out", reason)
+
+	src := strings.ToUpper(cmd.Name())
+	if s, ok := cmd.(fmt.Stringer); ok {
+		src = s.String()
+	}
+
+	for l := range strings.SplitSeq(src, "\n") {
+		t.lines = append(t.lines, "# "+l)
+	}
+}
+
+// unsupportedHeredoc comments out a Dockerfile instruction using heredocs, including their content.
+func (c *converter) unsupportedHeredoc(t *target, cmd instructions.Command, line int, heredocs []heredoc) {
+	c.unsupported(t, cmd, line, "heredocs are not supported")
+
+	for _, h := range heredocs {
+		for l := range strings.SplitSeq(strings.TrimSuffix(h.data, "\n"), "\n") {
+			t.lines = append(t.lines, strings.TrimSpace("# "+l))
+		}
+
+		t.lines = append(t.lines, "# "+h.name)
+	}
+}
+
+func (c *converter) run(t *target, cmd *instructions.RunCommand, line int) {
+	if len(cmd.Files) > 0 {
+		heredocs := make([]heredoc, 0, len(cmd.Files))
+		for _, f := range cmd.Files {
+			heredocs = append(heredocs, heredoc{name: f.Name, data: f.Data})
+		}
+
+		c.unsupportedHeredoc(t, cmd, line, heredocs)
+
+		return
+	}
+
+	// The mounts are only parsed fully when expanded, the values are kept as is to be expanded by Earthly.
+	err := cmd.Expand(func(word string) (string, error) { return word, nil })
+	if err != nil {
+		c.unsupported(t, cmd, line, fmt.Sprintf("failed to parse the mounts of RUN: %v", err))
+		return
+	}
+
+	args := []string{"RUN"}
+
+	for _, m := range instructions.GetMounts(cmd) {
+		switch m.Type {
+		case instructions.MountTypeCache:
+			opts := []string{"type=cache", "target=" + m.Target}
+			if m.CacheID != "" {
+				opts = append(opts, "id="+m.CacheID)
+			}
+
+			// Dockerfile cache mounts are shared by default, while Earthfile ones are locked.
+			sharing := cmp.Or(m.CacheSharing, instructions.MountSharingShared)
+			if sharing != instructions.MountSharingLocked {
+				opts = append(opts, "sharing="+string(sharing))
+			}
+
+			if m.From != "" || m.Source != "" || m.UID != nil || m.GID != nil || m.Mode != nil {
+				c.warnf(line, "the from, source, uid, gid and mode options of cache mounts are not supported")
+			}
+
+			args = append(args, "--mount="+strings.Join(opts, ","))
+		case instructions.MountTypeTmpfs:
+			if m.SizeLimit != 0 {
+				c.warnf(line, "the size option of tmpfs mounts is not supported")
+			}
+
+			args = append(args, "--mount=type=tmpfs,target="+m.Target)
+		case instructions.MountTypeSecret:
+			id := cmp.Or(m.Source, m.CacheID, path.Base(m.Target))
+
+			opts := []string{"type=secret", "id=" + id, "target=" + cmp.Or(m.Target, "/run/secrets/"+id)}
+			if m.Mode != nil {
+				opts = append(opts, fmt.Sprintf("chmod=0%o", *m.Mode))
+			}
+
+			if m.UID != nil || m.GID != nil {
+				c.warnf(line, "the uid and gid options of secret mounts are not supported")
+			}
+
+			args = append(args, "--mount="+strings.Join(opts, ","))
+		case instructions.MountTypeSSH:
+			if (m.CacheID != "" && m.CacheID != "default") || m.Target != "" || m.Mode != nil || m.UID != nil ||
+				m.GID != nil {
+				c.warnf(line, "only the default SSH agent socket is supported, the options of ssh mounts were ignored")
+			}
+
+			if !slices.Contains(args, "--ssh") {
+				args = append(args, "--ssh")
+			}
+		case instructions.MountTypeBind:
+			c.warnf(line, "bind mounts are not supported, COPY %s into the target instead", m.Target)
+		}
+	}
+
+	switch instructions.GetNetwork(cmd) {
+	case instructions.NetworkNone:
+		args = append(args, "--network=none")
+	case instructions.NetworkHost:
+		c.warnf(line, "RUN --network=host is not supported, the default network is used")
+	}
+
+	args = append(args, cmdLine(cmd.ShellDependantCmdLine))
+
+	// Long commands get a line per flag.
+	sep := " "
+	if len(args) > 2 && len(strings.Join(args, " ")) > 100 {
+		sep = " \\\n" + indent
+	}
+
+	t.lines = append(t.lines, args[0]+" "+strings.Join(args[1:], sep))
+}
+
+func (c *converter) copy(t *target, cmd *instructions.CopyCommand, line int) {
+	if len(cmd.SourceContents) > 0 {
+		c.unsupportedHeredoc(t, cmd, line, sourceContents(cmd.SourceContents))
+		return
+	}
+
+	if cmd.Link {
+		c.warnf(line, "COPY --link is not supported and was ignored")
+	}
+
+	if cmd.Parents {
+		c.warnf(line, "COPY --parents is not supported, the files are copied without their parent directories")
+	}
+
+	srcs := cmd.SourcePaths
+
+	if cmd.From != "" {
+		from, ok := c.stage(cmd.From)
+		if !ok {
+			from = c.imageTarget(cmd.From)
+		}
+
+		srcs = make([]string, 0, len(cmd.SourcePaths))
+		for _, src := range cmd.SourcePaths {
+			srcs = append(srcs, from.artifact(src))
+		}
+	}
+
+	args := append([]string{"COPY"}, ownershipFlags(cmd.Chown, cmd.Chmod)...)
+	args = append(args, srcs...)
+	args = append(args, cmd.DestPath)
+
+	t.lines = append(t.lines, strings.Join(args, " "))
+}
+
+func (c *converter) add(t *target, cmd *instructions.AddCommand, line int) {
+	if len(cmd.SourceContents) > 0 {
+		c.unsupportedHeredoc(t, cmd, line, sourceContents(cmd.SourceContents))
+		return
+	}
+
+	if cmd.Link {
+		c.warnf(line, "ADD --link is not supported and was ignored")
+	}
+
+	if len(cmd.SourcePaths) == 1 && isGitSource(cmd.SourcePaths[0]) {
+		c.gitClone(t, cmd, line)
+		return
+	}
+
+	// ADD only differs from COPY for URLs and archives.
+	name := "COPY"
+
+	for _, src := range cmd.SourcePaths {
+		if cmd.Checksum != "" || strings.Contains(src, "://") || archiveRE.MatchString(src) {
+			name = "ADD"
+			c.features["--use-add-command"] = true
+
+			break
+		}
+	}
+
+	args := append([]string{name}, ownershipFlags(cmd.Chown, cmd.Chmod)...)
+	if cmd.Checksum != "" {
+		args = append(args, "--checksum="+cmd.Checksum)
+	}
+
+	args = append(args, cmd.SourcePaths...)
+	args = append(args, cmd.DestPath)
+
+	t.lines = append(t.lines, strings.Join(args, " "))
+}
+
+// gitClone converts ADD of a git repository.
+func (c *converter) gitClone(t *target, cmd *instructions.AddCommand, line int) {
+	if cmd.Chown != "" || cmd.Chmod != "" || cmd.Checksum != "" {
+		c.warnf(line, "the --chown, --chmod and --checksum options of ADD are not supported for git repositories")
+	}
+
+	if !cmd.KeepGitDir {
+		c.warnf(line, "GIT CLONE keeps the .git directory of the repository")
+	}
+
+	url, ref, _ := strings.Cut(cmd.SourcePaths[0], "#")
+	ref, subdir, _ := strings.Cut(ref, ":")
+
+	if subdir != "" {
+		c.warnf(line, "cloning a subdirectory of a git repository is not supported, the whole repository is cloned")
+	}
+
+	args := []string{"GIT CLONE"}
+	if ref != "" {
+		args = append(args, "--branch="+ref)
+	}
+
+	t.lines = append(t.lines, strings.Join(append(args, url, cmd.DestPath), " "))
+}
+
+func (c *converter) healthcheck(t *target, cmd *instructions.HealthCheckCommand, line int) {
+	health := cmd.Health
+	if len(health.Test) == 0 || health.Test[0] == "NONE" {
+		t.lines = append(t.lines, "HEALTHCHECK NONE")
+		return
+	}
+
+	args := []string{"HEALTHCHECK"}
+
+	for _, flag := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"interval", health.Interval},
+		{"timeout", health.Timeout},
+		{"start-period", health.StartPeriod},
+		{"start-interval", health.StartInterval},
+	} {
+		if flag.value != 0 {
+			args = append(args, fmt.Sprintf("--%s=%s", flag.name, flag.value))
+		}
+	}
+
+	if health.Retries != 0 {
+		args = append(args, "--retries="+strconv.Itoa(health.Retries))
+	}
+
+	args = append(args, "CMD")
+
+	if health.Test[0] == "CMD-SHELL" {
+		args = append(args, health.Test[1:]...)
+	} else {
+		c.warnf(line, "HEALTHCHECK CMD only supports the shell form, the command is run by the shell")
+
+		for _, arg := range health.Test[1:] {
+			args = append(args, shellQuote(arg))
+		}
+	}
+
+	t.lines = append(t.lines, strings.Join(args, " "))
+}
+
+// imageTarget returns a target for copying artifacts out of an image.
+func (c *converter) imageTarget(image string) *target {
+	if t, ok := c.images[image]; ok {
+		return t
+	}
+
+	if c.images == nil {
+		c.images = map[string]*target{}
+	}
+
+	t := c.newTarget(path.Base(strings.SplitN(image, "@", 2)[0]))
+	t.lines = append(t.lines, "FROM "+image)
+	c.images[image] = t
+
+	return t
+}
+
+// artifact returns the reference to a file of the target, saving it as an artifact.
+func (t *target) artifact(src string) string {
+	// The sources of COPY --from are relative to the root of the stage, not its WORKDIR.
+	src = path.Join("/", src)
+
+	for _, a := range t.artifacts {
+		if a.src == src {
+			return "+" + t.name + "/" + a.name
+		}
+	}
+
+	base := path.Base(src)
+	if base == "/" {
+		base = "rootfs"
+	}
+
+	name := base
+	for n := 2; slices.ContainsFunc(t.artifacts, func(a artifact) bool { return a.name == name }); n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	t.artifacts = append(t.artifacts, artifact{src: src, name: name})
+
+	return "+" + t.name + "/" + name
+}
+
+func (c *converter) render() string {
+	var buf bytes.Buffer
+
+	features := make([]string, 0, len(c.features))
+	for f := range c.features {
+		features = append(features, f)
+	}
+
+	slices.Sort(features)
+
+	fmt.Fprintln(&buf, strings.Join(append(append([]string{"VERSION"}, features...), earthfileVersion), " "))
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "# This Earthfile was generated using docker2earth")
+	fmt.Fprintln(&buf, "# the conversion is done on a best-effort basis")
+	fmt.Fprintln(&buf, "# and might not follow best practices, please")
+	fmt.Fprintln(&buf, "# visit https://docs.earthbuild.dev for Earthfile guides")
+
+	if len(c.globals) > 0 {
+		fmt.Fprintln(&buf)
+
+		for _, l := range c.globals {
+			fmt.Fprintln(&buf, l)
+		}
+	}
+
+	for _, t := range c.targets {
+		fmt.Fprintln(&buf)
+
+		// Like stage comments, target doc comments start with the name of the target.
+		if t.comment != "" {
+			for l := range strings.SplitSeq(t.name+" "+t.comment, "\n") {
+				fmt.Fprintln(&buf, strings.TrimSpace("# "+l))
+			}
+		}
+
+		fmt.Fprintf(&buf, "%s:\n", t.name)
+
+		for _, l := range t.lines {
+			for l := range strings.SplitSeq(l, "\n") {
+				fmt.Fprintln(&buf, indent+l)
+			}
+		}
+
+		for _, a := range t.artifacts {
+			if a.name == path.Base(a.src) {
+				fmt.Fprintf(&buf, "%sSAVE ARTIFACT %s\n", indent, a.src)
+			} else {
+				fmt.Fprintf(&buf, "%sSAVE ARTIFACT %s %s\n", indent, a.src, a.name)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+func line(location []parser.Range) int {
+	if len(location) == 0 {
+		return 0
+	}
+
+	return location[0].Start.Line
+}
+
+func argString(kv instructions.KeyValuePairOptional) string {
+	if kv.Value == nil {
+		return kv.Key
+	}
+
+	return kv.String()
+}
+
+func cmdLine(cmd instructions.ShellDependantCmdLine) string {
+	if cmd.PrependShell {
+		return strings.Join(cmd.CmdLine, " ")
+	}
+
+	return execForm(cmd.CmdLine)
+}
+
+func execForm(args []string) string {
+	quoted := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		var buf bytes.Buffer
+
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(arg) // Encoding a string can't fail.
+
+		quoted = append(quoted, strings.TrimSpace(buf.String()))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func sourceContents(contents []instructions.SourceContent) []heredoc {
+	heredocs := make([]heredoc, 0, len(contents))
+	for _, content := range contents {
+		heredocs = append(heredocs, heredoc{name: content.Path, data: content.Data})
+	}
+
+	return heredocs
+}
+
+func ownershipFlags(chown, chmod string) []string {
+	var flags []string
+
+	if chown != "" {
+		flags = append(flags, "--chown="+chown)
+	}
+
+	if chmod != "" {
+		flags = append(flags, "--chmod="+chmod)
+	}
+
+	return flags
+}
+
+func isGitSource(src string) bool {
+	url, _, _ := strings.Cut(src, "#")
+
+	return strings.HasPrefix(url, "git@") || strings.HasPrefix(url, "git://") || strings.HasSuffix(url, ".git")
+}
+
+func shellQuote(s string) string {
+	if shellSafeRE.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
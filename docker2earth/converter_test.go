@@ -0,0 +1,108 @@
+package docker2earth_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/docker2earth"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+var update = flag.Bool("update", false, "Update the testdata for golden tests")
+
+// TestConvert converts testdata/<case>/Dockerfile and compares the result with the Earthfile.out and
+// warnings.out golden files next to it.
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	cases, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range cases {
+		dir := filepath.Join("testdata", tc.Name())
+
+		t.Run(tc.Name(), func(t *testing.T) {
+			t.Parallel()
+
+			in, err := os.Open(filepath.Join(dir, "Dockerfile"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+
+			conv, err := docker2earth.Convert(in, "example/app:latest")
+			if err != nil {
+				t.Fatalf("failed to convert Dockerfile: %v", err)
+			}
+
+			var warnings strings.Builder
+			for _, w := range conv.Warnings {
+				warnings.WriteString(w.String() + "\n")
+			}
+
+			_, err = earthfile.Parse(filepath.Join(dir, "Earthfile.out"), conv.Earthfile)
+			if err != nil {
+				t.Errorf("generated Earthfile is invalid: %v", err)
+			}
+
+			compareGoldenFile(t, filepath.Join(dir, "Earthfile.out"), conv.Earthfile)
+			compareGoldenFile(t, filepath.Join(dir, "warnings.out"), warnings.String())
+		})
+	}
+}
+
+func TestConvertTarget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dockerfile string
+		want       string
+	}{
+		{"FROM alpine\n", "build"},
+		{"FROM alpine AS build\nFROM alpine\n", "build-2"},
+		{"FROM alpine AS base\nFROM base AS release_image\n", "release-image"},
+	}
+
+	for _, tt := range tests {
+		conv, err := docker2earth.Convert(strings.NewReader(tt.dockerfile), "")
+		if err != nil {
+			t.Fatalf("failed to convert %q: %v", tt.dockerfile, err)
+		}
+
+		if conv.Target != tt.want {
+			t.Errorf("target of %q is %q, want %q", tt.dockerfile, conv.Target, tt.want)
+		}
+	}
+}
+
+func compareGoldenFile(t *testing.T, path, got string) {
+	t.Helper()
+
+	if *update {
+		// Golden files are tracked in version control and need to be readable by other processes/CI, so 0644 is
+		// appropriate.
+		// #nosec G306
+		err := os.WriteFile(path, []byte(got), 0o644)
+		if err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+
+	diff := cmp.Diff(string(want), got)
+	if diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", path, diff)
+	}
+}
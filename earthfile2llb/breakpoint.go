@@ -0,0 +1,173 @@
+package earthfile2llb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/buildcontext"
+	"github.com/EarthBuild/earthbuild/debugger/common"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+)
+
+// errNoBreakpointCommand is returned when a breakpoint is set on a line which
+// is not a RUN command of the target.
+var errNoBreakpointCommand = errors.New("no RUN command")
+
+// Breakpoint pauses a build at the RUN command at a line of the recipe of a
+// local target, to open an interactive shell in its container.
+type Breakpoint struct {
+	// Target is the target, with an absolute local path.
+	Target domain.Target
+	// Mode is common.BreakBefore or common.BreakOnError.
+	Mode string
+	Line int
+}
+
+// ParseBreakpoint parses a breakpoint given as <target-ref>:<line>, and
+// checks that the line is a RUN command of the recipe of the target.
+func ParseBreakpoint(spec, mode string) (Breakpoint, error) {
+	if mode != common.BreakBefore && mode != common.BreakOnError {
+		return Breakpoint{}, fmt.Errorf("invalid breakpoint mode %q", mode)
+	}
+
+	ref, lineStr, found := cutLast(spec, ":")
+	if !found {
+		return Breakpoint{}, fmt.Errorf("invalid breakpoint %q, expected <target-ref>:<line>", spec)
+	}
+
+	line, err := strconv.Atoi(lineStr)
+	if err != nil || line < 1 {
+		return Breakpoint{}, fmt.Errorf("invalid line %q of breakpoint %q", lineStr, spec)
+	}
+
+	target, err := domain.ParseTarget(ref)
+	if err != nil {
+		return Breakpoint{}, fmt.Errorf("invalid target of breakpoint %q: %w", spec, err)
+	}
+
+	if target.IsRemote() {
+		return Breakpoint{}, fmt.Errorf("breakpoint %q: breakpoints can only be set in local targets", spec)
+	}
+
+	target.LocalPath, err = filepath.Abs(target.GetLocalPath())
+	if err != nil {
+		return Breakpoint{}, fmt.Errorf("failed to get absolute path of %s: %w", target, err)
+	}
+
+	bp := Breakpoint{Target: target, Mode: mode, Line: line}
+
+	err = bp.check()
+	if err != nil {
+		return Breakpoint{}, fmt.Errorf("breakpoint %q: %w", spec, err)
+	}
+
+	return bp, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+// check checks that the line of the breakpoint is a RUN command of the recipe
+// of its target.
+func (bp Breakpoint) check() error {
+	path := filepath.Join(bp.Target.LocalPath, buildcontext.Earthfile)
+
+	ef, err := earthfile.ParseFile(path, earthfile.WithSourceMap())
+	if err != nil {
+		return err
+	}
+
+	for _, t := range ef.Targets {
+		if t.Name != bp.Target.Target {
+			continue
+		}
+
+		if !hasRunAtLine(t.Recipe, bp.Line) {
+			return fmt.Errorf("%w at line %d of %s", errNoBreakpointCommand, bp.Line, bp.Target.String())
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("target %s not found in %s", bp.Target.Target, path)
+}
+
+func hasRunAtLine(block earthfile.Block, line int) bool {
+	for _, stmt := range block {
+		var bodies []earthfile.Block
+
+		switch {
+		case stmt.Command != nil:
+			if stmt.Command.Name == earthfile.CmdRun && stmt.Command.SourceLocation != nil &&
+				stmt.Command.SourceLocation.StartLine == line {
+				return true
+			}
+		case stmt.If != nil:
+			bodies = append(bodies, stmt.If.IfBody)
+			for _, elseIf := range stmt.If.ElseIf {
+				bodies = append(bodies, elseIf.Body)
+			}
+
+			if stmt.If.ElseBody != nil {
+				bodies = append(bodies, *stmt.If.ElseBody)
+			}
+		case stmt.For != nil:
+			bodies = append(bodies, stmt.For.Body)
+		case stmt.Try != nil:
+			bodies = append(bodies, stmt.Try.TryBody)
+			if stmt.Try.CatchBody != nil {
+				bodies = append(bodies, *stmt.Try.CatchBody)
+			}
+
+			if stmt.Try.FinallyBody != nil {
+				bodies = append(bodies, *stmt.Try.FinallyBody)
+			}
+		case stmt.Wait != nil:
+			bodies = append(bodies, stmt.Wait.Body)
+		}
+
+		for _, body := range bodies {
+			if hasRunAtLine(body, line) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// breakpointMode returns the mode of the breakpoint set on the RUN command at
+// the source location, in the target being converted, if any.
+func (c *Converter) breakpointMode(srcLoc *earthfile.SourceLocation) string {
+	if len(c.opt.Breakpoints) == 0 || srcLoc == nil || c.target.IsRemote() {
+		return ""
+	}
+
+	dir, err := filepath.Abs(c.target.GetLocalPath())
+	if err != nil {
+		return ""
+	}
+
+	file, err := filepath.Abs(srcLoc.File)
+	if err != nil || file != filepath.Join(dir, buildcontext.Earthfile) {
+		return ""
+	}
+
+	for _, bp := range c.opt.Breakpoints {
+		if bp.Line == srcLoc.StartLine && bp.Target.Target == c.target.Target && bp.Target.LocalPath == dir {
+			return bp.Mode
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,106 @@
+package earthfile2llb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/debugger/common"
+)
+
+const breakpointEarthfile = `VERSION 0.8
+
+build:
+    FROM alpine:3.18
+    RUN echo build
+    IF [ -f foo ]
+        RUN echo if
+    ELSE
+        RUN echo else
+    END
+    FOR i IN a b
+        RUN echo $i
+    END
+    SAVE ARTIFACT foo
+`
+
+func TestParseBreakpoint(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "Earthfile"), []byte(breakpointEarthfile), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		wantErr error
+		spec    string
+		mode    string
+		line    int
+	}{
+		{spec: dir + "+build:5", mode: common.BreakBefore, line: 5},
+		{spec: dir + "+build:7", mode: common.BreakOnError, line: 7},
+		{spec: dir + "+build:9", mode: common.BreakBefore, line: 9},
+		{spec: dir + "+build:12", mode: common.BreakBefore, line: 12},
+		{spec: dir + "+build:4", mode: common.BreakBefore, wantErr: errNoBreakpointCommand},
+		{spec: dir + "+build:14", mode: common.BreakBefore, wantErr: errNoBreakpointCommand},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			t.Parallel()
+
+			bp, err := ParseBreakpoint(test.spec, test.mode)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("expected error %v, got %v", test.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if bp.Line != test.line || bp.Mode != test.mode || bp.Target.Target != "build" || bp.Target.LocalPath != dir {
+				t.Errorf("unexpected breakpoint %+v", bp)
+			}
+		})
+	}
+}
+
+func TestParseBreakpointErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "Earthfile"), []byte(breakpointEarthfile), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec string
+		mode string
+	}{
+		{spec: dir + "+build:5", mode: "after"},
+		{spec: dir + "+build", mode: common.BreakBefore},
+		{spec: dir + "+build:0", mode: common.BreakBefore},
+		{spec: dir + "+build:five", mode: common.BreakBefore},
+		{spec: dir + "+missing:5", mode: common.BreakBefore},
+		{spec: "github.com/foo/bar+build:5", mode: common.BreakBefore},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseBreakpoint(test.spec, test.mode)
+			if err == nil {
+				t.Errorf("expected error for breakpoint %q", test.spec)
+			}
+		})
+	}
+}
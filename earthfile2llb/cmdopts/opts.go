@@ -34,6 +34,7 @@ type Run struct {
 	Network         string   `description:"Network to use; currently network=none is only supported"                                       long:"network"`          //nolint:lll
 	Secrets         []string `description:"Make available a secret"                                                                        long:"secret"`           //nolint:lll
 	Mounts          []string `description:"Mount a file or directory"                                                                      long:"mount"`            //nolint:lll
+	Services        []string `description:"Run a service alongside the command"                                                            long:"service"`          //nolint:lll
 	Push            bool     `description:"Execute this command only if the build succeeds and also if earthbuild is invoked in push mode" long:"push"`             //nolint:lll
 	Privileged      bool     `description:"Enable privileged mode"                                                                         long:"privileged"`       //nolint:lll
 	WithEntrypoint  bool     `description:"Include the entrypoint of the image when running the command"                                   long:"entrypoint"`       //nolint:lll
@@ -79,6 +80,15 @@ type Copy struct {
 	PassArgs        bool     `description:"Pass arguments to external targets"                                      long:"pass-args"`         //nolint:lll
 }
 
+// Add contains options for the ADD command.
+type Add struct {
+	Chown    string `description:"Apply a specific group and/or owner to the added files and directories" long:"chown"`    //nolint:lll
+	Chmod    string `description:"Apply a mode to the added files and directories"                        long:"chmod"`    //nolint:lll
+	Checksum string `description:"The digest a downloaded file must match, e.g. sha256:<hex>"             long:"checksum"` //nolint:lll
+	KeepTs   bool   `description:"Keep created time file timestamps"                                      long:"keep-ts"`  //nolint:lll
+	KeepOwn  bool   `description:"Keep owner info"                                                        long:"keep-own"` //nolint:lll
+}
+
 // SaveArtifact contains options for the SAVE ARTIFACT command.
 type SaveArtifact struct {
 	KeepTs          bool `description:"Keep created time file timestamps"                                                                               long:"keep-ts"`           //nolint:lll
@@ -100,11 +110,16 @@ type SaveImage struct {
 
 // Build contains options for the BUILD command.
 type Build struct {
-	Platforms       []string `description:"The platform to use"                                         long:"platform"`         //nolint:lll
-	BuildArgs       []string `description:"A build arg override passed on to a referenced earth target" long:"build-arg"`        //nolint:lll
-	AllowPrivileged bool     `description:"Allow targets to assume privileged mode"                     long:"allow-privileged"` //nolint:lll
-	PassArgs        bool     `description:"Pass arguments to external targets"                          long:"pass-args"`        //nolint:lll
-	AutoSkip        bool     `description:"Use auto-skip to bypass the target if nothing has changed"   long:"auto-skip"`        //nolint:lll
+	MatrixName        string   `description:"The name of each combination of build args, in which {KEY} is the value of KEY"       long:"matrix-name"`         //nolint:lll
+	Platforms         []string `description:"The platform to use"                                                                  long:"platform"`            //nolint:lll
+	BuildArgs         []string `description:"A build arg override passed on to a referenced earth target"                          long:"build-arg"`           //nolint:lll
+	MatrixInclude     []string `description:"An extra combination of build args, as a comma-separated list of KEY=VALUE, to build" long:"matrix-include"`      //nolint:lll
+	MatrixExclude     []string `description:"A combination of build args, as a comma-separated list of KEY=VALUE, not to build"    long:"matrix-exclude"`      //nolint:lll
+	MatrixMaxParallel int      `description:"The maximum number of combinations of build args built in parallel"                   long:"matrix-max-parallel"` //nolint:lll
+	AllowPrivileged   bool     `description:"Allow targets to assume privileged mode"                                              long:"allow-privileged"`    //nolint:lll
+	PassArgs          bool     `description:"Pass arguments to external targets"                                                   long:"pass-args"`           //nolint:lll
+	AutoSkip          bool     `description:"Use auto-skip to bypass the target if nothing has changed"                            long:"auto-skip"`           //nolint:lll
+	MatrixContinue    bool     `description:"Build the other combinations of build args when one fails"                            long:"matrix-continue"`     //nolint:lll
 }
 
 // GitClone contains options for the GIT CLONE command.
@@ -131,8 +146,13 @@ type WithDocker struct {
 	Loads           []string `description:"An image produced by earth which is loaded as a Docker image"    long:"load"`
 	BuildArgs       []string `description:"A build arg override passed on to a referenced earth target"     long:"build-arg"` //nolint:lll
 	Pulls           []string `description:"An image which is pulled and made available in the docker cache" long:"pull"`
+	ComposeProfiles []string `description:"A compose profile to enable"                                     long:"compose-profile"`  //nolint:lll
+	ComposeEnvFiles []string `description:"An env file used to interpolate the compose files"               long:"compose-env-file"` //nolint:lll
 	AllowPrivileged bool     `description:"Allow targets referenced by load to assume privileged mode"      long:"allow-privileged"` //nolint:lll
 	PassArgs        bool     `description:"Pass arguments to external targets"                              long:"pass-args"`        //nolint:lll
+	ComposeWait     bool     `description:"Wait for the compose services to be healthy"                     long:"compose-wait"`     //nolint:lll
+
+	ComposeWaitTimeout time.Duration `description:"The time to wait for the compose services to be healthy" long:"compose-wait-timeout"` //nolint:lll
 }
 
 // Do contains options for the DO command.
@@ -149,8 +169,11 @@ type Import struct {
 
 // Arg contains options for the ARG command.
 type Arg struct {
-	Required bool `description:"Require argument to be non-empty"                       long:"required"`
-	Global   bool `description:"Global argument to make available to all other targets" long:"global"`
+	Type     string `description:"The type of the argument: string (default), bool or int" long:"type"`
+	Enum     string `description:"A comma-separated list of the values allowed"            long:"enum"`
+	Pattern  string `description:"A regular expression which values must match"            long:"pattern"`
+	Required bool   `description:"Require argument to be non-empty"                        long:"required"`
+	Global   bool   `description:"Global argument to make available to all other targets"  long:"global"`
 }
 
 // Project contains options for the PROJECT command.
@@ -17,12 +17,14 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"al.essio.dev/pkg/shellescape"
 	"github.com/EarthBuild/earthbuild/buildcontext"
+	"github.com/EarthBuild/earthbuild/cmd/earth/bk"
 	debuggercommon "github.com/EarthBuild/earthbuild/debugger/common"
 	"github.com/EarthBuild/earthbuild/domain"
 	"github.com/EarthBuild/earthbuild/earthfile2llb/cmdopts"
@@ -34,8 +36,10 @@ import (
 	"github.com/EarthBuild/earthbuild/states"
 	"github.com/EarthBuild/earthbuild/states/dedup"
 	"github.com/EarthBuild/earthbuild/states/image"
+	"github.com/EarthBuild/earthbuild/util/buildkitskipper"
 	"github.com/EarthBuild/earthbuild/util/containerutil"
 	"github.com/EarthBuild/earthbuild/util/fileutil"
+	"github.com/EarthBuild/earthbuild/util/flagutil"
 	"github.com/EarthBuild/earthbuild/util/gitutil"
 	"github.com/EarthBuild/earthbuild/util/hint"
 	"github.com/EarthBuild/earthbuild/util/inodeutil"
@@ -48,6 +52,7 @@ import (
 	"github.com/EarthBuild/earthbuild/util/shell"
 	"github.com/EarthBuild/earthbuild/util/stringutil"
 	"github.com/EarthBuild/earthbuild/util/syncutil/semutil"
+	"github.com/EarthBuild/earthbuild/util/syncutil/serrgroup"
 	"github.com/EarthBuild/earthbuild/util/vertexmeta"
 	"github.com/EarthBuild/earthbuild/variables"
 	"github.com/EarthBuild/earthbuild/variables/reserved"
@@ -62,6 +67,7 @@ import (
 	"github.com/moby/buildkit/session/localhost"
 	solverpb "github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/apicaps"
+	"github.com/opencontainers/go-digest"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -98,6 +104,9 @@ const (
 	projectCmd                           // "PROJECT"
 	setCmd                               // "SET"
 	letCmd                               // "LET"
+	addCmd                               // "ADD"
+	shellInstrCmd                        // "SHELL"
+	stopSignalCmd                        // "STOPSIGNAL"
 )
 
 // Converter turns earth commands to buildkit LLB representation.
@@ -118,6 +127,7 @@ type Converter struct {
 	target              domain.Target
 	opt                 ConvertOpt
 	nextCmdID           int
+	withDockerRuns      int
 	cmdSet              bool
 	ranSave             bool
 }
@@ -167,6 +177,10 @@ func NewConverter(
 
 	logbusTarget.SetStart(time.Now())
 
+	if opt.matrixCell != "" {
+		logbusTarget.SetMatrixCell(opt.matrixCell)
+	}
+
 	c := &Converter{
 		target:              target,
 		gitMeta:             bc.GitMetadata,
@@ -789,6 +803,95 @@ func (c *Converter) CopyClassical(
 	return nil
 }
 
+// Add applies the earth ADD command. Local sources are copied from the build
+// context like in COPY, except that tar archives are unpacked into dest. URL
+// sources are downloaded, and verified against checksum if it is set.
+func (c *Converter) Add(
+	ctx context.Context,
+	srcs []string,
+	dest string,
+	keepTs, keepOwn bool,
+	chown string,
+	chmod *fs.FileMode,
+	checksum digest.Digest,
+) error {
+	err := c.checkAllowed(addCmd)
+	if err != nil {
+		return err
+	}
+
+	var localSrcs []string
+
+	for _, src := range srcs {
+		if !stringutil.IsHTTPURL(src) {
+			localSrcs = append(localSrcs, src)
+		}
+	}
+
+	var srcState pllb.State
+
+	if len(localSrcs) > 0 {
+		if c.ftrs.UseCopyIncludePatterns {
+			srcStateFactory := addIncludePathAndSharedKeyHint(c.buildContextFactory, localSrcs)
+			srcState = c.opt.LocalStateCache.getOrConstruct(srcStateFactory)
+		} else {
+			srcState = c.buildContextFactory.Construct()
+		}
+	}
+
+	c.nonSaveCommand()
+
+	prefix, _, err := c.newVertexMeta(ctx, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	addSrcs := make([]llbutil.AddSrc, 0, len(srcs))
+
+	for _, src := range srcs {
+		if !stringutil.IsHTTPURL(src) {
+			addSrcs = append(addSrcs, llbutil.AddSrc{State: srcState, Path: src})
+			continue
+		}
+
+		filename := addURLFilename(src)
+		httpOpts := []llb.HTTPOption{
+			llb.Filename(filename),
+			llb.WithCustomNamef("%sADD %s", prefix, stringutil.ScrubCredentials(src)),
+		}
+
+		if checksum != "" {
+			httpOpts = append(httpOpts, llb.Checksum(checksum))
+		}
+
+		addSrcs = append(addSrcs, llbutil.AddSrc{
+			State:  pllb.HTTP(src, httpOpts...),
+			Path:   filename,
+			Remote: true,
+		})
+	}
+
+	c.mts.Final.MainState = llbutil.AddOp(
+		addSrcs, c.mts.Final.MainState, dest, keepTs, c.copyOwner(keepOwn, chown), chmod,
+		llb.WithCustomNamef("%sADD %s %s", prefix, stringutil.ScrubCredentialsAll(strings.Join(srcs, " ")), dest))
+
+	return nil
+}
+
+// addURLFilename returns the name of the file downloaded from an ADD URL, the
+// last element of its path.
+func addURLFilename(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err == nil {
+		name := path.Base(u.Path)
+		if name != "/" && name != "." {
+			return name
+		}
+	}
+
+	return "__unnamed__"
+}
+
 // ConvertRunOpts represents a set of options needed for the RUN command.
 type ConvertRunOpts struct {
 	// Internal.
@@ -797,10 +900,12 @@ type ConvertRunOpts struct {
 	shellWrap            shellWrapFun
 	OIDCInfo             *oidcutil.AWSOIDCInfo
 	CommandName          string
+	Break                string // The mode of the breakpoint set on the command, if any.
 	InteractiveSaveFiles []debuggercommon.SaveFilesSettings
 	Args                 []string
 	Mounts               []string
 	Secrets              []string
+	Services             []RunServiceOpt
 	// Internal.
 	extraRunOpts       []llb.RunOption
 	WithEntrypoint     bool
@@ -1588,6 +1693,203 @@ func (c *Converter) BuildAsync(
 	return nil
 }
 
+// MatrixBuild contains the options of a BUILD command which builds a matrix
+// of build args.
+type MatrixBuild struct {
+	Cells     []flagutil.MatrixCell
+	Platforms []platutil.Platform
+	// MaxParallel is the maximum number of cells built in parallel. When it is
+	// set, or when Continue is set, the cells are executed as part of the BUILD
+	// command, rather than with the rest of the build.
+	MaxParallel     int
+	AllowPrivileged bool
+	PassArgs        bool
+	AutoSkip        bool
+	// Continue makes the other cells build to completion when one fails,
+	// instead of canceling them.
+	Continue bool
+}
+
+// BuildMatrix applies the earth BUILD command to each cell of a matrix. Each
+// cell is reported as its own target, under the name of the cell.
+func (c *Converter) BuildMatrix(ctx context.Context, fullTargetName string, mb MatrixBuild) error {
+	err := c.checkAllowed(buildCmd)
+	if err != nil {
+		return err
+	}
+
+	c.nonSaveCommand()
+
+	cmdID, cmd, err := c.newLogbusCommand(ctx, "BUILD "+fullTargetName)
+	if err != nil {
+		return fmt.Errorf("failed to create command: %w", err)
+	}
+
+	if mb.MaxParallel > 0 || mb.Continue {
+		err = c.executeMatrix(ctx, fullTargetName, cmdID, mb)
+	} else {
+		err = c.convertMatrix(ctx, fullTargetName, cmdID, mb)
+	}
+
+	cmd.SetEndError(err)
+
+	return err
+}
+
+// convertMatrix converts the cells of a matrix one after the other, leaving
+// their execution to the rest of the build, like BUILD does.
+func (c *Converter) convertMatrix(ctx context.Context, fullTargetName, cmdID string, mb MatrixBuild) error {
+	for _, cell := range mb.Cells {
+		saveHashFn := func() {}
+
+		if mb.AutoSkip {
+			skip, fn, err := c.checkAutoSkip(ctx, fullTargetName, mb.AllowPrivileged, mb.PassArgs, cell.Args)
+			if err != nil {
+				return fmt.Errorf("failed to determine whether matrix cell %s can be skipped: %w", cell.Name, err)
+			}
+
+			if skip {
+				continue
+			}
+
+			saveHashFn = fn
+		}
+
+		onExecutionSuccess := newOnExecutionSuccess(len(mb.Platforms), saveHashFn)
+
+		for _, platform := range mb.Platforms {
+			target, opt, propagateBuildArgs, err := c.prepBuildTarget(
+				ctx, fullTargetName, platform, mb.AllowPrivileged, mb.PassArgs,
+				cell.Args, true, buildCmd, cmdID, onExecutionSuccess,
+			)
+			if err != nil {
+				return err
+			}
+
+			opt.matrixCell = cell.Name
+
+			mts, err := Earthfile2LLB(ctx, target, opt, false)
+			if err != nil {
+				return fmt.Errorf("earthfile2llb for %s, matrix cell %s: %w", fullTargetName, cell.Name, err)
+			}
+
+			c.addBuiltTarget(mts, opt, propagateBuildArgs, buildCmd)
+		}
+	}
+
+	return nil
+}
+
+// executeMatrix converts and executes the cells of a matrix in parallel, up to
+// MaxParallel at a time. Unless Continue is set, the first failing cell cancels
+// the others.
+func (c *Converter) executeMatrix(ctx context.Context, fullTargetName, cmdID string, mb MatrixBuild) error {
+	type matrixJob struct {
+		mts                *states.MultiTarget
+		err                error
+		target             domain.Target
+		cell               string
+		opt                ConvertOpt
+		propagateBuildArgs bool
+	}
+
+	jobs := make([]*matrixJob, 0, len(mb.Cells)*len(mb.Platforms))
+
+	// The targets are prepared upfront, as preparing them reads the state of
+	// the converter.
+	for _, cell := range mb.Cells {
+		for _, platform := range mb.Platforms {
+			target, opt, propagateBuildArgs, err := c.prepBuildTarget(
+				ctx, fullTargetName, platform, mb.AllowPrivileged, mb.PassArgs,
+				cell.Args, true, buildCmd, cmdID, nil,
+			)
+			if err != nil {
+				return err
+			}
+
+			opt.matrixCell = cell.Name
+			jobs = append(jobs, &matrixJob{
+				target:             target,
+				opt:                opt,
+				cell:               cell.Name,
+				propagateBuildArgs: propagateBuildArgs,
+			})
+		}
+	}
+
+	maxParallel := mb.MaxParallel
+	if maxParallel <= 0 {
+		maxParallel = len(jobs)
+	}
+
+	sem := semutil.NewWeighted(int64(maxParallel))
+	errGroup, groupCtx := serrgroup.WithContext(ctx)
+
+	for _, job := range jobs {
+		errGroup.Go(func() error {
+			job.mts, job.err = c.executeMatrixJob(groupCtx, sem, job.target, job.opt)
+			if job.err != nil && !mb.Continue {
+				return fmt.Errorf("matrix cell %s of %s: %w", job.cell, fullTargetName, job.err)
+			}
+
+			return nil
+		})
+	}
+
+	err := errGroup.Wait()
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs   []error
+		failed []string
+	)
+
+	for _, job := range jobs {
+		if job.err != nil {
+			errs = append(errs, fmt.Errorf("matrix cell %s: %w", job.cell, job.err))
+
+			if !slices.Contains(failed, job.cell) {
+				failed = append(failed, job.cell)
+			}
+
+			continue
+		}
+
+		c.addBuiltTarget(job.mts, job.opt, job.propagateBuildArgs, buildCmd)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d matrix cells of %s failed (%s): %w",
+			len(failed), len(mb.Cells), fullTargetName, strings.Join(failed, ", "), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (c *Converter) executeMatrixJob(
+	ctx context.Context, sem semutil.Semaphore, target domain.Target, opt ConvertOpt,
+) (*states.MultiTarget, error) {
+	rel, err := sem.Acquire(ctx, 1)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring matrix parallelism semaphore: %w", err)
+	}
+	defer rel()
+
+	mts, err := Earthfile2LLB(ctx, target, opt, false)
+	if err != nil {
+		return nil, fmt.Errorf("earthfile2llb: %w", err)
+	}
+
+	err = c.forceExecution(ctx, mts.Final.MainState, mts.Final.PlatformResolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return mts, nil
+}
+
 // Workdir applies the WORKDIR command.
 func (c *Converter) Workdir(ctx context.Context, workdirPath string) error {
 	err := c.checkAllowed(workdirCmd)
@@ -1651,7 +1953,7 @@ func (c *Converter) Cmd(_ context.Context, cmdArgs []string, isWithShell bool) e
 	}
 
 	c.nonSaveCommand()
-	c.mts.Final.MainImage.Config.Cmd = withShell(cmdArgs, isWithShell)
+	c.mts.Final.MainImage.Config.Cmd = withShell(cmdArgs, isWithShell, c.shell())
 	c.cmdSet = true
 
 	return nil
@@ -1666,7 +1968,7 @@ func (c *Converter) Entrypoint(_ context.Context, entrypointArgs []string, isWit
 
 	c.nonSaveCommand()
 
-	c.mts.Final.MainImage.Config.Entrypoint = withShell(entrypointArgs, isWithShell)
+	c.mts.Final.MainImage.Config.Entrypoint = withShell(entrypointArgs, isWithShell, c.shell())
 	if !c.cmdSet {
 		c.mts.Final.MainImage.Config.Cmd = nil
 	}
@@ -1674,6 +1976,42 @@ func (c *Converter) Entrypoint(_ context.Context, entrypointArgs []string, isWit
 	return nil
 }
 
+// Shell applies the SHELL command.
+func (c *Converter) Shell(_ context.Context, shell []string) error {
+	err := c.checkAllowed(shellInstrCmd)
+	if err != nil {
+		return err
+	}
+
+	c.nonSaveCommand()
+	c.mts.Final.MainImage.Config.Shell = shell
+
+	return nil
+}
+
+// StopSignal applies the STOPSIGNAL command.
+func (c *Converter) StopSignal(_ context.Context, signal string) error {
+	err := c.checkAllowed(stopSignalCmd)
+	if err != nil {
+		return err
+	}
+
+	c.nonSaveCommand()
+	c.mts.Final.MainImage.Config.StopSignal = signal
+
+	return nil
+}
+
+// shell returns the shell set by SHELL, or inherited from the base image, for
+// the shell form of RUN, CMD and ENTRYPOINT. It is nil when /bin/sh -c is used.
+func (c *Converter) shell() []string {
+	if !c.ftrs.UseShellAndStopSignal {
+		return nil
+	}
+
+	return c.mts.Final.MainImage.Config.Shell
+}
+
 // Expose applies the EXPOSE command.
 func (c *Converter) Expose(_ context.Context, ports []string) error {
 	err := c.checkAllowed(exposeCmd)
@@ -1737,10 +2075,16 @@ func (c *Converter) Arg(ctx context.Context, argKey string, defaultArgValue stri
 		pncvf = c.processNonConstantBuildArgFunc(ctx)
 	}
 
+	constraint, err := variables.ParseConstraint(opts.Type, opts.Enum, opts.Pattern)
+	if err != nil {
+		return err
+	}
+
 	declOpts := []variables.DeclareOpt{
 		variables.AsArg(),
 		variables.WithValue(defaultArgValue),
 		variables.WithPNCVFunc(pncvf),
+		variables.WithConstraint(constraint),
 	}
 	if opts.Global {
 		declOpts = append(declOpts, variables.AsGlobal())
@@ -2361,12 +2705,15 @@ func (c *Converter) checkAutoSkip(
 		return false, nil, err
 	}
 
+	manifest := &inputgraph.Manifest{}
+
 	targetHash, _, err := inputgraph.HashTarget(ctx, inputgraph.HashOpt{
 		Target:         target,
 		Log:            c.opt.Log,
 		CI:             c.opt.IsCI,
 		BuiltinArgs:    c.opt.BuiltinArgs,
 		OverridingVars: overriding,
+		Manifest:       manifest,
 	})
 	if err != nil {
 		return false, nil, fmt.Errorf("auto-skip is unable to calculate hash for %s: %w", target, err)
@@ -2387,9 +2734,22 @@ func (c *Converter) checkAutoSkip(
 	}
 
 	return exists, func() {
-		err := c.opt.BuildkitSkipper.Add(ctx, target.StringCanonical(), targetHash)
+		entry := buildkitskipper.Entry{
+			Created:      time.Now().UTC(),
+			Target:       target.StringCanonical(),
+			Args:         overriding.BuildArgs(),
+			EarthVersion: c.opt.BuiltinArgs.EarthVersion,
+		}
+
+		err := c.opt.BuildkitSkipper.Add(ctx, entry, targetHash)
 		if err != nil {
 			console.Warnf("Failed to add target %s (hash %x) to the auto-skip DB.", target.String(), targetHash)
+			return
+		}
+
+		err = bk.AddManifest(ctx, c.opt.BuildkitSkipper, buildkitskipper.Record{Entry: entry, Hash: targetHash}, manifest)
+		if err != nil {
+			console.Warnf("Failed to record the input manifest of target %s: %s", target.String(), err)
 		}
 	}, nil
 }
@@ -2450,8 +2810,10 @@ func (c *Converter) prepBuildTarget(
 	opt.HasDangling = isDangling
 	opt.AllowPrivileged = allowPrivileged
 	opt.parentTargetID = c.mts.Final.ID
+	opt.referrer = c.varCollection.AbsRef()
 	opt.parentCommandID = parentCmdID
 	opt.OnExecutionSuccess = onExecutionSuccess
+	opt.matrixCell = ""
 
 	if cmdT == buildCmd {
 		// only BUILD commands get propagated
@@ -2499,9 +2861,19 @@ func (c *Converter) buildTarget(
 		return nil, fmt.Errorf("earthfile2llb for %s: %w", fullTargetName, err)
 	}
 
+	c.addBuiltTarget(mts, opt, propagateBuildArgs, cmdT)
+
+	return mts, nil
+}
+
+// addBuiltTarget records a target converted by buildTarget or BuildMatrix as
+// a dependency of the current target, and propagates its inputs upwards.
+func (c *Converter) addBuiltTarget(
+	mts *states.MultiTarget, opt ConvertOpt, propagateBuildArgs bool, cmdT cmdType,
+) {
 	c.directDeps = append(c.directDeps, mts.Final)
 	if !propagateBuildArgs {
-		return mts, nil
+		return
 	}
 
 	// Propagate build arg inputs upwards (a child target depending on a build arg means
@@ -2518,7 +2890,7 @@ func (c *Converter) buildTarget(
 	}
 
 	if cmdT != fromCmd {
-		return mts, nil
+		return
 	}
 
 	// Propagate globals.
@@ -2554,11 +2926,9 @@ func (c *Converter) buildTarget(
 	c.varCollection.Imports().SetGlobal(mts.Final.GlobalImports)
 	c.varCollection.SetProject(mts.Final.VarCollection.Project())
 	c.varCollection.SetOrg(mts.Final.VarCollection.Org())
-
-	return mts, nil
 }
 
-func getDebuggerSecretKey(saveFilesSettings []debuggercommon.SaveFilesSettings) string {
+func getDebuggerSecretKey(saveFilesSettings []debuggercommon.SaveFilesSettings, breakMode string) string {
 	h := sha1.New() // #nosec G401
 	b := make([]byte, 8)
 
@@ -2574,6 +2944,11 @@ func getDebuggerSecretKey(saveFilesSettings []debuggercommon.SaveFilesSettings)
 		addToHash(saveFile.Dst)
 	}
 
+	// The settings of a command with a breakpoint differ from the others'.
+	if breakMode != "" {
+		h.Write([]byte(breakMode))
+	}
+
 	return hex.EncodeToString(h.Sum(nil))
 }
 
@@ -2584,6 +2959,16 @@ func (c *Converter) internalRun(ctx context.Context, opts ConvertRunOpts) (pllb.
 		return pllb.State{}, err
 	}
 
+	if opts.Break != "" {
+		if !c.opt.AllowInteractive {
+			return pllb.State{}, errors.New("breakpoints are not allowed, when --strict is specified or otherwise implied")
+		}
+
+		if opts.Locally {
+			return pllb.State{}, errors.New("breakpoints are not supported with LOCALLY")
+		}
+	}
+
 	if opts.Locally {
 		switch {
 		case len(opts.Secrets) != 0:
@@ -2602,9 +2987,20 @@ func (c *Converter) internalRun(ctx context.Context, opts ConvertRunOpts) (pllb.
 			return pllb.State{}, errors.New("transient run not supported with LOCALLY")
 		case opts.NoNetwork:
 			return pllb.State{}, errors.New("--network=none is not supported with LOCALLY")
+		case len(opts.Services) != 0:
+			return pllb.State{}, errors.New("--service not supported with LOCALLY")
 		}
 	}
 
+	if len(opts.Services) != 0 {
+		serviceRunOpts, err := c.prepareRunServices(ctx, opts.Services)
+		if err != nil {
+			return pllb.State{}, err
+		}
+
+		opts.extraRunOpts = append(opts.extraRunOpts, serviceRunOpts...)
+	}
+
 	if opts.shellWrap == nil && opts.WithShell {
 		opts.shellWrap = withShellAndEnvVars
 	}
@@ -2651,13 +3047,19 @@ func (c *Converter) internalRun(ctx context.Context, opts ConvertRunOpts) (pllb.
 		strings.Join(opts.Args, " "),
 	)
 
-	prefix, _, err := c.newVertexMeta(ctx, opts.Locally, isInteractive, false, opts.Secrets)
+	prefix, _, err := c.newVertexMeta(ctx, opts.Locally, isInteractive || opts.Break != "", false, opts.Secrets)
 	if err != nil {
 		return pllb.State{}, err
 	}
 
 	runOpts = append(runOpts, llb.WithCustomNamef("%s%s", prefix, commandStr))
 
+	if opts.Break != "" {
+		// A command with a breakpoint always runs, so that the build pauses
+		// each time.
+		opts.NoCache = true
+	}
+
 	sorted := c.varCollection.SortedVariables(variables.WithActive())
 	extraEnvVars := make([]string, 0, len(sorted))
 
@@ -2710,7 +3112,7 @@ func (c *Converter) internalRun(ctx context.Context, opts ConvertRunOpts) (pllb.
 		err = c.opt.LLBCaps.Supports(solverpb.CapExecMountSock)
 		if err != nil {
 			if _, ok := errors.AsType[*apicaps.CapError](err); ok {
-				if c.opt.InteractiveDebuggerEnabled || isInteractive {
+				if c.opt.InteractiveDebuggerEnabled || isInteractive || opts.Break != "" {
 					return pllb.State{}, fmt.Errorf("interactive debugger requires a newer version of buildkit: %w", err)
 				}
 			} else {
@@ -2764,12 +3166,13 @@ func (c *Converter) internalRun(ctx context.Context, opts ConvertRunOpts) (pllb.
 		}
 
 		var (
-			debuggerSettingsSecretsKey = getDebuggerSecretKey(saveFiles)
+			debuggerSettingsSecretsKey = getDebuggerSecretKey(saveFiles, opts.Break)
 			debuggerSettings           = debuggercommon.DebuggerSettings{
 				DebugLevelLogging: c.opt.InteractiveDebuggerDebugLevelLogging,
 				Enabled:           c.opt.InteractiveDebuggerEnabled,
 				SocketPath:        debuggercommon.DebuggerDefaultSocketPath,
 				Term:              os.Getenv("TERM"),
+				Break:             opts.Break,
 				SaveFiles:         saveFiles,
 			}
 			debuggerSettingsData []byte
@@ -2802,7 +3205,13 @@ func (c *Converter) internalRun(ctx context.Context, opts ConvertRunOpts) (pllb.
 	prependDebugger := !opts.Locally
 
 	if opts.WithShell {
-		finalArgs = opts.shellWrap(finalArgs, extraEnvVars, opts.WithShell, prependDebugger, isInteractive)
+		finalArgs = opts.shellWrap(finalArgs, extraEnvVars, c.shell(), opts.WithShell, prependDebugger, isInteractive)
+	}
+
+	if len(opts.Services) != 0 {
+		// The services wrapper starts the services, and stops them once the
+		// command exits.
+		finalArgs = append([]string{servicesWrapperPath}, finalArgs...)
 	}
 
 	if opts.NoCache {
@@ -3200,9 +3609,8 @@ func (c *Converter) applyFromImage(state pllb.State, img *image.Image) (pllb.Sta
 	if img.Config.User != "" {
 		state = state.User(img.Config.User)
 	}
-	// No need to apply entrypoint, cmd, volumes and others.
+	// No need to apply entrypoint, cmd, volumes, shell and others.
 	// The fact that they exist in the image configuration is enough.
-	// TODO: Apply any other settings? Shell?
 	return state, img, ev
 }
 
@@ -97,3 +97,21 @@ func Test_parseSecretFlag(t *testing.T) {
 		})
 	}
 }
+
+func Test_addURLFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"https://example.com/releases/tool-1.2.3.tar.gz": "tool-1.2.3.tar.gz",
+		"https://example.com/download?file=tool.tar.gz":  "download",
+		"https://example.com/dir/":                       "dir",
+		"https://example.com":                            "__unnamed__",
+		"https://example.com/":                           "__unnamed__",
+	}
+
+	for url, want := range tests {
+		if got := addURLFilename(url); got != want {
+			t.Errorf("addURLFilename(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
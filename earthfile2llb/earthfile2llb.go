@@ -122,6 +122,12 @@ type ConvertOpt struct {
 	// parentTargetID is the Logbus target ID of the parent target, if any. It
 	// is used to link together targets.
 	parentTargetID string
+	// referrer is the reference of the Earthfile which references the target,
+	// if any. It is used to enforce the visibility of the target.
+	referrer domain.Reference
+	// matrixCell is the name of the cell of a BUILD matrix the target is built
+	// for, if any. It is reported in the Logbus manifest of the target.
+	matrixCell string
 	// The runner used to execute the target on. This is used only for metadata reporting purposes.
 	// May be one of the following:
 	// * "local:<hostname>" - local builds
@@ -131,6 +137,9 @@ type ConvertOpt struct {
 	FeatureFlagOverrides string
 	// LocalRegistryAddr is the address of the BuildKit-embedded registry.
 	LocalRegistryAddr string
+	// Breakpoints are the RUN commands at which the build pauses to open an
+	// interactive shell, set with the --break cli flag.
+	Breakpoints []Breakpoint
 	// The resolve mode for referenced images (force pull or prefer local).
 	ImageResolveMode llb.ResolveMode
 	// NoCache sets llb.IgnoreCache before calling StateToRef
@@ -240,6 +249,11 @@ func Earthfile2LLB(
 		return nil, fmt.Errorf("resolve build context for target %s: %w", target.String(), err)
 	}
 
+	err = bc.CheckVisibility(opt.referrer)
+	if err != nil {
+		return nil, err
+	}
+
 	if opt.Visited == nil {
 		if bc.Features.UseVisitedUpfrontHashCollection {
 			opt.Visited = states.NewVisitedUpfrontHashCollection()
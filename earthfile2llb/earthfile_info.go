@@ -10,16 +10,19 @@ import (
 	"github.com/EarthBuild/earthbuild/internal/earthfile"
 	"github.com/EarthBuild/earthbuild/util/flagutil"
 	"github.com/EarthBuild/earthbuild/util/platutil"
+	"github.com/EarthBuild/earthbuild/variables"
 	gwclient "github.com/moby/buildkit/frontend/gateway/client"
 )
 
 // These are functions that are used for getting information about an Earthfile,
 // most notably for `earth doc` and `earth ls` output.
 
-// GetTargets returns a list of targets from an Earthfile.
+// GetTargets returns a list of targets from an Earthfile. The internal and
+// private targets, which can't be invoked from the command line, are only
+// included if all is set.
 // Note that the passed in domain.Target's target name is ignored (only the reference to the Earthfile is used).
 func GetTargets(
-	ctx context.Context, resolver *buildcontext.Resolver, gwClient gwclient.Client, target domain.Target,
+	ctx context.Context, resolver *buildcontext.Resolver, gwClient gwclient.Client, target domain.Target, all bool,
 ) ([]string, error) {
 	platr := platutil.NewResolver(platutil.GetUserPlatform())
 
@@ -30,16 +33,31 @@ func GetTargets(
 
 	targets := make([]string, 0, len(bc.Earthfile.Targets))
 	for _, target := range bc.Earthfile.Targets {
+		if !all && bc.Features.TargetVisibility {
+			visibility, err := buildcontext.Visibility(target.Recipe)
+			if err == nil && visibility != buildcontext.VisibilityPublic {
+				continue
+			}
+		}
+
 		targets = append(targets, target.Name)
 	}
 
 	return targets, nil
 }
 
+// TargetArg is a build argument of a target.
+type TargetArg struct {
+	// Constraint restricts the values of the argument, as set by the --type,
+	// --enum and --pattern options of its ARG.
+	Constraint *variables.Constraint
+	Name       string
+}
+
 // GetTargetArgs returns a list of build arguments for a specified target.
 func GetTargetArgs(
 	ctx context.Context, resolver *buildcontext.Resolver, gwClient gwclient.Client, target domain.Target,
-) ([]string, error) {
+) ([]TargetArg, error) {
 	platr := platutil.NewResolver(platutil.GetUserPlatform())
 
 	bc, err := resolver.Resolve(ctx, gwClient, platr, target)
@@ -60,7 +78,7 @@ func GetTargetArgs(
 		return nil, fmt.Errorf("failed to find %s", target.String())
 	}
 
-	var args []string
+	var args []TargetArg
 
 	for _, stmt := range t.Recipe {
 		if stmt.Command != nil && stmt.Command.Name == "ARG" {
@@ -68,12 +86,17 @@ func GetTargetArgs(
 			// since Arg opts are ignored (and feature flags are not available) we set explicitGlobalArgFlag as false
 			explicitGlobal := false
 
-			_, argName, _, err := flagutil.ParseArgArgs(*stmt.Command, isBase, explicitGlobal)
+			opts, argName, _, err := flagutil.ParseArgArgs(*stmt.Command, isBase, explicitGlobal)
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse ARG arguments %v: %w", stmt.Command.Args, err)
 			}
 
-			args = append(args, argName)
+			constraint, err := variables.ParseConstraint(opts.Type, opts.Enum, opts.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ARG %s: %w", argName, err)
+			}
+
+			args = append(args, TargetArg{Name: argName, Constraint: constraint})
 		}
 	}
 
@@ -97,6 +120,19 @@ func ArgName(
 	return argName, dflt, opts.Required, opts.Global, nil
 }
 
+// ArgConstraint returns the constraint of the values of an ARG command, as set
+// by its --type, --enum and --pattern options.
+func ArgConstraint(cmd earthfile.Command) (*variables.Constraint, error) {
+	var opts cmdopts.Arg
+
+	_, err := flagutil.ParseArgsCleaned("ARG", &opts, flagutil.GetArgsCopy(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse opts for ARG [%v]: %w", cmd, err)
+	}
+
+	return variables.ParseConstraint(opts.Type, opts.Enum, opts.Pattern)
+}
+
 // ArtifactName returns the parsed name of a SAVE ARTIFACT command and its local
 // name (if any).
 func ArtifactName(cmd earthfile.Command) (string, *string, error) {
@@ -7,10 +7,12 @@ import (
 	"net"
 	"os"
 	"path"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/EarthBuild/earthbuild/buildcontext"
 	"github.com/EarthBuild/earthbuild/conslogging"
@@ -24,17 +26,23 @@ import (
 	"github.com/EarthBuild/earthbuild/util/oidcutil"
 	"github.com/EarthBuild/earthbuild/util/platutil"
 	"github.com/EarthBuild/earthbuild/util/shell"
+	"github.com/EarthBuild/earthbuild/util/stringutil"
 	"github.com/EarthBuild/earthbuild/variables"
 	"github.com/EarthBuild/earthbuild/variables/reserved"
 	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 	"github.com/jessevdk/go-flags"
+	"github.com/opencontainers/go-digest"
 )
 
 const maxCommandRenameWarnings = 3
 
 var errCannotAsync = errors.New("cannot run async operation")
 
+// stopSignalRE matches a signal name, e.g. SIGTERM, TERM or SIGRTMIN+3, or a
+// signal number.
+var stopSignalRE = regexp.MustCompile(`^(?:[0-9]+|(?:SIG)?[A-Z][A-Z0-9]*(?:[+-][0-9]+)?)$`)
+
 // use as default to differentiate between an un specified string flag and a specified flag with empty value.
 var defaultZeroStringFlag = uuid.NewString()
 
@@ -282,13 +290,13 @@ func (i *Interpreter) handleCommand(ctx context.Context, cmd earthfile.Command)
 	case earthfile.CmdHealthCheck:
 		return i.handleHealthcheck(ctx, cmd)
 	case earthfile.CmdAdd:
-		return i.handleAdd(cmd)
+		return i.handleAdd(ctx, cmd)
 	case earthfile.CmdStopSignal:
-		return i.handleStopsignal(cmd)
+		return i.handleStopsignal(ctx, cmd)
 	case earthfile.CmdOnBuild:
 		return i.handleOnbuild(cmd)
 	case earthfile.CmdShell:
-		return i.handleShell(cmd)
+		return i.handleShell(ctx, cmd)
 	case earthfile.CmdCommand:
 		return i.handleUserCommand(cmd)
 	case earthfile.CmdFunction:
@@ -303,6 +311,8 @@ func (i *Interpreter) handleCommand(ctx context.Context, cmd earthfile.Command)
 		return i.handleHost(ctx, cmd)
 	case earthfile.CmdProject:
 		return i.handleProject(ctx, cmd)
+	case earthfile.CmdVisibility:
+		return i.handleVisibility(cmd)
 	default:
 		return i.errorf(cmd.SourceLocation, "unexpected command %q", cmd.Name)
 	}
@@ -826,6 +836,31 @@ func (i *Interpreter) handleRun(ctx context.Context, cmd earthfile.Command) erro
 		return i.errorf(cmd.SourceLocation, "RUN --raw-output requires the --raw-output feature flag")
 	}
 
+	if len(opts.Services) != 0 && !i.converter.opt.Features.RunService {
+		return i.errorf(cmd.SourceLocation, "RUN --service requires the --run-service feature flag")
+	}
+
+	services := make([]RunServiceOpt, 0, len(opts.Services))
+
+	for _, s := range opts.Services {
+		var (
+			expanded string
+			service  RunServiceOpt
+		)
+
+		expanded, err = i.expandArgs(ctx, s, false, false)
+		if err != nil {
+			return i.errorf(cmd.SourceLocation, "failed to expand service arg in RUN: %s", s)
+		}
+
+		service, err = ParseRunService(expanded)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "invalid RUN --service %s", expanded)
+		}
+
+		services = append(services, service)
+	}
+
 	if i.withDocker == nil {
 		if opts.WithDocker {
 			return i.errorf(cmd.SourceLocation, "--with-docker is obsolete. Please use WITH DOCKER ... RUN ... END instead")
@@ -837,6 +872,7 @@ func (i *Interpreter) handleRun(ctx context.Context, cmd earthfile.Command) erro
 			Locally:              i.local,
 			Mounts:               opts.Mounts,
 			Secrets:              opts.Secrets,
+			Services:             services,
 			WithShell:            withShell,
 			WithEntrypoint:       opts.WithEntrypoint,
 			Privileged:           opts.Privileged,
@@ -850,6 +886,7 @@ func (i *Interpreter) handleRun(ctx context.Context, cmd earthfile.Command) erro
 			WithAWSCredentials:   opts.WithAWS,
 			OIDCInfo:             awsOIDCInfo,
 			RawOutput:            opts.RawOutput,
+			Break:                i.converter.breakpointMode(cmd.SourceLocation),
 		}
 
 		err = i.converter.Run(ctx, opts)
@@ -872,6 +909,10 @@ func (i *Interpreter) handleRun(ctx context.Context, cmd earthfile.Command) erro
 		return i.errorf(cmd.SourceLocation, "RUN --push not allowed in WITH DOCKER")
 	}
 
+	if len(services) != 0 {
+		return i.errorf(cmd.SourceLocation, "RUN --service not allowed in WITH DOCKER")
+	}
+
 	i.withDocker.Mounts = opts.Mounts
 	i.withDocker.Secrets = opts.Secrets
 	i.withDocker.WithShell = withShell
@@ -1518,8 +1559,16 @@ func (i *Interpreter) handleBuild(ctx context.Context, cmd earthfile.Command, as
 		platformsSlice = append(platformsSlice, platform)
 	}
 
+	matrixOpt := flagutil.MatrixOpt{Name: opts.MatrixName, Include: opts.MatrixInclude, Exclude: opts.MatrixExclude}
+
+	isMatrix := matrixOpt.IsSet() || opts.MatrixMaxParallel != 0 || opts.MatrixContinue
+	if isMatrix && !i.converter.ftrs.BuildMatrix {
+		return i.errorf(cmd.SourceLocation,
+			"the BUILD --matrix-* flags must be enabled with the VERSION --build-matrix feature flag.")
+	}
+
 	asyncSafeArgs := isSafeAsyncBuildArgsKVStyle(opts.BuildArgs) && isSafeAsyncBuildArgs(args[1:])
-	if async && (!asyncSafeArgs || opts.AutoSkip) {
+	if async && (!asyncSafeArgs || opts.AutoSkip || isMatrix) {
 		return errCannotAsync
 	}
 
@@ -1568,6 +1617,12 @@ func (i *Interpreter) handleBuild(ctx context.Context, cmd earthfile.Command, as
 			"the BUILD --auto-skip flag must be enabled with the VERSION --build-auto-skip feature flag.")
 	}
 
+	if isMatrix {
+		return i.handleBuildMatrix(
+			ctx, cmd, fullTargetName, opts, matrixOpt, allowPrivileged, expandedBuildArgs, platformsSlice,
+		)
+	}
+
 	for _, buildArgs := range crossProductBuildArgs {
 		saveHashFn := func() {}
 
@@ -1608,6 +1663,63 @@ func (i *Interpreter) handleBuild(ctx context.Context, cmd earthfile.Command, as
 	return nil
 }
 
+func (i *Interpreter) handleBuildMatrix(
+	ctx context.Context,
+	cmd earthfile.Command,
+	fullTargetName string,
+	opts cmdopts.Build,
+	matrixOpt flagutil.MatrixOpt,
+	allowPrivileged bool,
+	buildArgs []string,
+	platforms []platutil.Platform,
+) error {
+	if opts.MatrixMaxParallel < 0 {
+		return i.errorf(cmd.SourceLocation, "invalid BUILD --matrix-max-parallel %d", opts.MatrixMaxParallel)
+	}
+
+	if opts.AutoSkip && (opts.MatrixMaxParallel != 0 || opts.MatrixContinue) {
+		return i.errorf(cmd.SourceLocation,
+			"the BUILD --auto-skip flag cannot be used with --matrix-max-parallel or --matrix-continue")
+	}
+
+	var err error
+
+	matrixOpt.Name, err = i.expandArgs(ctx, matrixOpt.Name, false, false)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "failed to expand BUILD --matrix-name %s", matrixOpt.Name)
+	}
+
+	matrixOpt.Include, err = i.expandArgsSlice(ctx, matrixOpt.Include, false)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "failed to expand BUILD --matrix-include %v", matrixOpt.Include)
+	}
+
+	matrixOpt.Exclude, err = i.expandArgsSlice(ctx, matrixOpt.Exclude, false)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "failed to expand BUILD --matrix-exclude %v", matrixOpt.Exclude)
+	}
+
+	cells, err := flagutil.BuildMatrix(buildArgs, matrixOpt)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "build arg matrix")
+	}
+
+	err = i.converter.BuildMatrix(ctx, fullTargetName, MatrixBuild{
+		Cells:           cells,
+		Platforms:       platforms,
+		MaxParallel:     opts.MatrixMaxParallel,
+		AllowPrivileged: allowPrivileged,
+		PassArgs:        opts.PassArgs,
+		AutoSkip:        opts.AutoSkip,
+		Continue:        opts.MatrixContinue,
+	})
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "apply BUILD %s", fullTargetName)
+	}
+
+	return nil
+}
+
 func (i *Interpreter) handleWildcardBuilds(
 	ctx context.Context, fullTargetName string, cmd earthfile.Command, async bool,
 ) error {
@@ -1836,6 +1948,11 @@ func (i *Interpreter) handleArg(ctx context.Context, cmd earthfile.Command) erro
 		return i.wrapError(err, cmd.SourceLocation, "invalid ARG arguments %v", cmd.Args)
 	}
 
+	if (opts.Type != "" || opts.Enum != "" || opts.Pattern != "") && !i.converter.ftrs.TypedArgs {
+		return i.errorf(cmd.SourceLocation,
+			"the ARG --type, --enum and --pattern flags must be enabled with the VERSION --typed-args feature flag.")
+	}
+
 	if replacement, deprecated := reserved.DeprecatedBuiltin(key); deprecated {
 		i.log.Warnf("WARNING: the built-in ARG %s is deprecated. Use %s.", key, replacement)
 	}
@@ -2136,6 +2253,20 @@ func (i *Interpreter) handleWithDocker(ctx context.Context, cmd earthfile.Comman
 		}
 	}
 
+	for index, cp := range opts.ComposeProfiles {
+		opts.ComposeProfiles[index], err = i.expandArgs(ctx, cp, false, false)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to expand WITH DOCKER compose profile: %s", cp)
+		}
+	}
+
+	for index, ce := range opts.ComposeEnvFiles {
+		opts.ComposeEnvFiles[index], err = i.expandArgs(ctx, ce, false, false)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to expand WITH DOCKER compose env file: %s", ce)
+		}
+	}
+
 	for index, load := range opts.Loads {
 		opts.Loads[index], err = i.expandArgs(ctx, load, true, false)
 		if err != nil {
@@ -2214,23 +2345,207 @@ func (i *Interpreter) handleWithDocker(ctx context.Context, cmd earthfile.Comman
 		i.withDocker.CacheID = opts.CacheID
 	}
 
+	if len(opts.ComposeProfiles) > 0 || len(opts.ComposeEnvFiles) > 0 || opts.ComposeWait ||
+		opts.ComposeWaitTimeout != 0 {
+		if !i.converter.ftrs.DockerComposeOptions {
+			return i.errorf(cmd.SourceLocation,
+				"the WITH DOCKER --compose-profile, --compose-env-file, --compose-wait and --compose-wait-timeout "+
+					"flags must be enabled with the VERSION --docker-compose-options feature flag.")
+		}
+
+		if len(opts.ComposeFiles) == 0 {
+			return i.errorf(cmd.SourceLocation,
+				"the WITH DOCKER --compose-profile, --compose-env-file and --compose-wait flags require --compose")
+		}
+
+		if opts.ComposeWaitTimeout != 0 && !opts.ComposeWait {
+			return i.errorf(cmd.SourceLocation, "the WITH DOCKER --compose-wait-timeout flag requires --compose-wait")
+		}
+
+		timeout := opts.ComposeWaitTimeout
+		if timeout == 0 {
+			timeout = defaultComposeWaitTimeout
+		} else if timeout < time.Second {
+			return i.errorf(cmd.SourceLocation,
+				"invalid WITH DOCKER --compose-wait-timeout %s, expected at least 1s", timeout)
+		}
+
+		i.withDocker.ComposeProfiles = opts.ComposeProfiles
+		i.withDocker.ComposeEnvFiles = opts.ComposeEnvFiles
+		i.withDocker.ComposeWait = opts.ComposeWait
+		i.withDocker.ComposeWaitTimeout = timeout
+	}
+
 	return nil
 }
 
-func (i *Interpreter) handleAdd(cmd earthfile.Command) error {
-	return i.errorf(cmd.SourceLocation, "command ADD not yet supported")
+func (i *Interpreter) handleAdd(ctx context.Context, cmd earthfile.Command) error {
+	if !i.converter.ftrs.UseAddCommand {
+		return i.errorf(cmd.SourceLocation,
+			"the ADD command must be enabled with the VERSION --use-add-command feature flag.")
+	}
+
+	if i.pushOnlyAllowed {
+		return i.pushOnlyErr(cmd.SourceLocation)
+	}
+
+	if i.local {
+		return i.errorf(cmd.SourceLocation, "ADD is not supported in LOCALLY targets")
+	}
+
+	var opts cmdopts.Add
+
+	args, err := flagutil.ParseArgsCleaned("ADD", &opts, flagutil.GetArgsCopy(cmd))
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "invalid ADD arguments %v", cmd.Args)
+	}
+
+	if len(args) < 2 {
+		return i.errorf(cmd.SourceLocation, "not enough ADD arguments %v", cmd.Args)
+	}
+
+	dest, err := i.expandArgs(ctx, args[len(args)-1], false, false)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "failed to expand ADD args %v", args[len(args)-1])
+	}
+
+	srcs := args[:len(args)-1]
+	hasLocalSrcs := false
+
+	for index, src := range srcs {
+		srcs[index], err = i.expandArgs(ctx, src, false, false)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to expand ADD src %s", src)
+		}
+
+		if stringutil.IsHTTPURL(srcs[index]) {
+			continue
+		}
+
+		if _, parseErr := domain.ParseArtifact(srcs[index]); parseErr == nil {
+			return i.errorf(cmd.SourceLocation, "ADD does not support artifact sources, use COPY instead: %s", srcs[index])
+		}
+
+		if i.converter.opt.LocalArtifactWhiteList.Exists(srcs[index]) {
+			return i.errorf(cmd.SourceLocation,
+				"unable to add file %s, which has is outputted elsewhere by SAVE ARTIFACT AS LOCAL", srcs[index])
+		}
+
+		hasLocalSrcs = true
+	}
+
+	expandedChown, err := i.expandArgs(ctx, opts.Chown, false, false)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "failed to expand ADD chown: %v", opts.Chown)
+	}
+
+	var fileModeParsed *os.FileMode
+
+	if opts.Chmod != "" {
+		var expandedMode string
+
+		expandedMode, err = i.expandArgs(ctx, opts.Chmod, false, false)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to expand ADD chmod: %v", opts.Chmod)
+		}
+
+		var mask uint64
+
+		mask, err = strconv.ParseUint(expandedMode, 8, 32)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to parse ADD chmod: %v", expandedMode)
+		}
+
+		mode := os.FileMode(uint32(mask))
+		fileModeParsed = &mode
+	}
+
+	var checksum digest.Digest
+
+	if opts.Checksum != "" {
+		// A checksum pins the contents of a single download.
+		if len(srcs) != 1 || hasLocalSrcs {
+			return i.errorf(cmd.SourceLocation, "ADD --checksum requires exactly one source, which must be a URL")
+		}
+
+		var expandedChecksum string
+
+		expandedChecksum, err = i.expandArgs(ctx, opts.Checksum, false, false)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to expand ADD checksum: %v", opts.Checksum)
+		}
+
+		checksum, err = digest.Parse(expandedChecksum)
+		if err != nil {
+			return i.wrapError(err, cmd.SourceLocation, "failed to parse ADD checksum: %v", expandedChecksum)
+		}
+	}
+
+	err = i.converter.Add(ctx, srcs, dest, opts.KeepTs, opts.KeepOwn, expandedChown, fileModeParsed, checksum)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "add")
+	}
+
+	return nil
 }
 
-func (i *Interpreter) handleStopsignal(cmd earthfile.Command) error {
-	return i.errorf(cmd.SourceLocation, "command STOPSIGNAL not yet supported")
+func (i *Interpreter) handleStopsignal(ctx context.Context, cmd earthfile.Command) error {
+	if !i.converter.ftrs.UseShellAndStopSignal {
+		return i.errorf(cmd.SourceLocation,
+			"the STOPSIGNAL command must be enabled with the VERSION --use-shell-and-stopsignal feature flag.")
+	}
+
+	if i.pushOnlyAllowed {
+		return i.pushOnlyErr(cmd.SourceLocation)
+	}
+
+	if len(cmd.Args) != 1 {
+		return i.errorf(cmd.SourceLocation, "invalid number of arguments for STOPSIGNAL: %v", cmd.Args)
+	}
+
+	signal, err := i.expandArgs(ctx, cmd.Args[0], false, false)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "failed to expand STOPSIGNAL %s", cmd.Args[0])
+	}
+
+	if !stopSignalRE.MatchString(signal) {
+		return i.errorf(cmd.SourceLocation,
+			"invalid STOPSIGNAL %q, must be a signal name, such as SIGTERM, or number", signal)
+	}
+
+	err = i.converter.StopSignal(ctx, signal)
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "apply STOPSIGNAL")
+	}
+
+	return nil
 }
 
 func (i *Interpreter) handleOnbuild(cmd earthfile.Command) error {
 	return i.errorf(cmd.SourceLocation, "command ONBUILD not supported")
 }
 
-func (i *Interpreter) handleShell(cmd earthfile.Command) error {
-	return i.errorf(cmd.SourceLocation, "command SHELL not yet supported")
+func (i *Interpreter) handleShell(ctx context.Context, cmd earthfile.Command) error {
+	if !i.converter.ftrs.UseShellAndStopSignal {
+		return i.errorf(cmd.SourceLocation,
+			"the SHELL command must be enabled with the VERSION --use-shell-and-stopsignal feature flag.")
+	}
+
+	if i.pushOnlyAllowed {
+		return i.pushOnlyErr(cmd.SourceLocation)
+	}
+
+	if !cmd.ExecMode || len(cmd.Args) == 0 {
+		return i.errorf(cmd.SourceLocation,
+			`SHELL must be given as a JSON array, e.g. SHELL ["/bin/bash", "-o", "pipefail", "-c"]`)
+	}
+
+	err := i.converter.Shell(ctx, flagutil.GetArgsCopy(cmd))
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "apply SHELL")
+	}
+
+	return nil
 }
 
 func (i *Interpreter) handleUserCommand(cmd earthfile.Command) error {
@@ -2241,6 +2556,25 @@ func (i *Interpreter) handleFunction(cmd earthfile.Command) error {
 	return i.errorf(cmd.SourceLocation, "command FUNCTION not allowed in a target definition")
 }
 
+func (i *Interpreter) handleVisibility(cmd earthfile.Command) error {
+	if !i.converter.ftrs.TargetVisibility {
+		return i.errorf(cmd.SourceLocation,
+			"the VISIBILITY command must be enabled with the VERSION --target-visibility feature flag.")
+	}
+
+	if i.isBase {
+		return i.errorf(cmd.SourceLocation, "VISIBILITY is not supported in the base target")
+	}
+
+	// The visibility is enforced when the target or function is referenced.
+	_, err := buildcontext.Visibility(earthfile.Block{{Command: &cmd}})
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "invalid VISIBILITY")
+	}
+
+	return nil
+}
+
 func (i *Interpreter) handleDo(ctx context.Context, cmd earthfile.Command) error {
 	var opts cmdopts.Do
 
@@ -2290,6 +2624,11 @@ func (i *Interpreter) handleDo(ctx context.Context, cmd earthfile.Command) error
 		return i.errorf(cmd.SourceLocation, "want domain.Command, got %T", bc.Ref)
 	}
 
+	err = bc.CheckVisibility(i.converter.varCollection.AbsRef())
+	if err != nil {
+		return i.wrapError(err, cmd.SourceLocation, "unable to use function %s", ucName)
+	}
+
 	if resolvedAllowPrivilegedSet {
 		allowPrivileged = allowPrivileged && resolvedAllowPrivileged
 	}
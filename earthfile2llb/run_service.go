@@ -0,0 +1,288 @@
+package earthfile2llb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/states/image"
+	"github.com/EarthBuild/earthbuild/util/llbutil"
+	"github.com/EarthBuild/earthbuild/util/llbutil/pllb"
+	"github.com/moby/buildkit/client/llb"
+	dockerimage "github.com/moby/buildkit/exporter/containerimage/image"
+)
+
+const (
+	servicesWrapperPath = "/var/earthbuild/services-wrapper.sh"
+	servicesDir         = "/run/earthbuild/services"
+	servicesLogsDir     = "/run/earthbuild/services-logs"
+	servicesScriptPath  = "/run/earthbuild/services.sh"
+)
+
+var serviceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// RunServiceOpt holds parameters for the RUN --service parameter.
+type RunServiceOpt struct {
+	Name   string
+	Image  string
+	Target string
+	Env    []string
+	Port   int
+}
+
+// ParseRunService parses the value of a RUN --service parameter, of the form
+// name=<name>,image=<image>|target=<target-ref>[,port=<port>][,env=<key>=<value>...].
+func ParseRunService(s string) (RunServiceOpt, error) {
+	var opt RunServiceOpt
+
+	for kvPair := range strings.SplitSeq(s, ",") {
+		key, value, found := strings.Cut(kvPair, "=")
+		if !found {
+			return RunServiceOpt{}, fmt.Errorf("invalid service arg %s", kvPair)
+		}
+
+		switch key {
+		case "name":
+			if !serviceNameRegex.MatchString(value) {
+				return RunServiceOpt{}, fmt.Errorf("invalid service name %q", value)
+			}
+
+			opt.Name = value
+		case "image":
+			opt.Image = value
+		case "target":
+			_, err := domain.ParseTarget(value)
+			if err != nil {
+				return RunServiceOpt{}, fmt.Errorf("invalid service target %s: %w", value, err)
+			}
+
+			opt.Target = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return RunServiceOpt{}, fmt.Errorf("invalid service port %s", value)
+			}
+
+			opt.Port = port
+		case "env":
+			if !strings.Contains(value, "=") {
+				return RunServiceOpt{}, fmt.Errorf("invalid service env %s, expected <key>=<value>", value)
+			}
+
+			opt.Env = append(opt.Env, value)
+		default:
+			return RunServiceOpt{}, fmt.Errorf("invalid service arg %s", kvPair)
+		}
+	}
+
+	switch {
+	case opt.Name == "":
+		return RunServiceOpt{}, fmt.Errorf("service name not specified in %s", s)
+	case opt.Image == "" && opt.Target == "":
+		return RunServiceOpt{}, fmt.Errorf("service image or target not specified in %s", s)
+	case opt.Image != "" && opt.Target != "":
+		return RunServiceOpt{}, fmt.Errorf("both service image and target specified in %s", s)
+	}
+
+	return opt, nil
+}
+
+// runService is a service of a RUN, with the state and the image it runs from.
+type runService struct {
+	state pllb.State
+	img   *image.Image
+	opt   RunServiceOpt
+}
+
+// prepareRunServices pulls or builds the services of a RUN, and returns the
+// run options which make them available to the services wrapper.
+func (c *Converter) prepareRunServices(ctx context.Context, opts []RunServiceOpt) ([]llb.RunOption, error) {
+	names := make(map[string]struct{}, len(opts))
+	services := make([]runService, 0, len(opts))
+
+	for _, opt := range opts {
+		if _, ok := names[opt.Name]; ok {
+			return nil, fmt.Errorf("duplicate service name %s", opt.Name)
+		}
+
+		names[opt.Name] = struct{}{}
+
+		service, err := c.prepareRunService(ctx, opt)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", opt.Name, err)
+		}
+
+		services = append(services, service)
+	}
+
+	script, err := servicesScript(services)
+	if err != nil {
+		return nil, err
+	}
+
+	runOpts := []llb.RunOption{
+		pllb.AddMount(
+			servicesWrapperPath, pllb.Scratch(), llb.HostBind(), llb.SourcePath(servicesWrapperPath),
+		),
+		pllb.AddMount(
+			servicesScriptPath, pllb.Scratch().File(pllb.Mkfile("/services.sh", 0o644, script)),
+			llb.SourcePath("/services.sh"), llb.Readonly,
+		),
+		pllb.AddMount(servicesLogsDir, pllb.Scratch(), llb.Tmpfs()),
+	}
+
+	for _, service := range services {
+		root := path.Join(servicesDir, service.opt.Name)
+		runOpts = append(
+			runOpts,
+			// The changes made by the service are discarded with the mount.
+			pllb.AddMount(root, service.state),
+			pllb.AddMount(path.Join(root, "dev", "shm"), pllb.Scratch(), llb.Tmpfs()),
+			llb.AddExtraHost(service.opt.Name, net.IPv4(127, 0, 0, 1)),
+		)
+	}
+
+	return runOpts, nil
+}
+
+func (c *Converter) prepareRunService(ctx context.Context, opt RunServiceOpt) (runService, error) {
+	platform := c.platr.Current()
+
+	if opt.Image != "" {
+		state, img, _, err := c.internalFromClassical(
+			ctx, opt.Image, platform,
+			llb.WithCustomNamef("%sSERVICE %s", c.imageVertexPrefix(opt.Image, platform), opt.Image),
+		)
+		if err != nil {
+			return runService{}, err
+		}
+
+		return runService{opt: opt, state: state, img: img}, nil
+	}
+
+	mts, err := c.buildTarget(ctx, opt.Target, platform, false, false, nil, false, runCmd, "", nil)
+	if err != nil {
+		return runService{}, err
+	}
+
+	return runService{opt: opt, state: mts.Final.MainState, img: mts.Final.MainImage}, nil
+}
+
+// servicesScript returns the script sourced by the services wrapper, which
+// defines for each service <i> the variables service_<i>_name,
+// service_<i>_root and service_<i>_port, and the functions service_<i>_start
+// and service_<i>_healthcheck, run in the root of the service. The services run
+// as root: the USER of their image is ignored, as chroot can't switch to a user
+// of the root of the service portably.
+func servicesScript(services []runService) ([]byte, error) {
+	var (
+		b   strings.Builder
+		ids []string
+	)
+
+	b.WriteString("# Generated by earthbuild for RUN --service.\n")
+
+	for i, service := range services {
+		id := strconv.Itoa(i + 1)
+		ids = append(ids, id)
+
+		args := append(
+			append([]string{}, service.img.Config.Entrypoint...),
+			service.img.Config.Cmd...,
+		)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("service %s has no ENTRYPOINT or CMD", service.opt.Name)
+		}
+
+		envPrefix := serviceEnvPrefix(service)
+		workdir := service.img.Config.WorkingDir
+
+		fmt.Fprintf(&b, "service_%s_name=%s\n", id, shellQuote(service.opt.Name))
+		fmt.Fprintf(&b, "service_%s_root=%s\n", id, shellQuote(path.Join(servicesDir, service.opt.Name)))
+		fmt.Fprintf(&b, "service_%s_port=%d\n", id, service.opt.Port)
+		// The service replaces the shell of the function, so that it can be stopped
+		// by the pid of the function.
+		fmt.Fprintf(&b, "service_%s_start() {\n    exec %s\n}\n", id, serviceCmd(envPrefix, id, workdir, args))
+
+		test := healthcheckArgs(service.img.Config.Healthcheck)
+		if test == nil {
+			fmt.Fprintf(&b, "service_%s_healthcheck() {\n    return 0\n}\n", id)
+			continue
+		}
+
+		fmt.Fprintf(&b, "service_%s_healthcheck() {\n    %s\n}\n", id, serviceCmd(envPrefix, id, workdir, test))
+	}
+
+	fmt.Fprintf(&b, "earthbuild_services=%s\n", shellQuote(strings.Join(ids, " ")))
+
+	return []byte(b.String()), nil
+}
+
+// serviceEnvPrefix returns the env command which sets the environment of a
+// service, from its image and the env of its RUN --service parameter.
+func serviceEnvPrefix(service runService) string {
+	env := append(append([]string{}, service.img.Config.Env...), service.opt.Env...)
+	if !hasEnv(env, "PATH") {
+		env = append([]string{"PATH=" + llbutil.DefaultPathEnv}, env...)
+	}
+
+	parts := make([]string, 0, len(env)+2)
+	parts = append(parts, "env", "-i")
+
+	for _, kv := range env {
+		parts = append(parts, shellQuote(kv))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func hasEnv(env []string, key string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceCmd returns the command line which runs args in the root of service
+// id, in its working directory.
+func serviceCmd(envPrefix, id, workdir string, args []string) string {
+	parts := make([]string, 0, len(args)+8)
+	// The chroot command is resolved by the services wrapper, as it may not be
+	// in the PATH of the service.
+	parts = append(parts, envPrefix, `"$earthbuild_chroot"`, fmt.Sprintf("\"$service_%s_root\"", id))
+
+	if workdir != "" && workdir != "/" {
+		parts = append(parts, "/bin/sh", "-c", `'cd "$0" && exec "$@"'`, shellQuote(workdir))
+	}
+
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// healthcheckArgs returns the args of the test of a HEALTHCHECK, or nil if
+// there is none.
+func healthcheckArgs(hc *dockerimage.HealthConfig) []string {
+	if hc == nil || len(hc.Test) == 0 {
+		return nil
+	}
+
+	switch hc.Test[0] {
+	case "CMD":
+		return hc.Test[1:]
+	case "CMD-SHELL":
+		return []string{"/bin/sh", "-c", strings.Join(hc.Test[1:], " ")}
+	default: // NONE
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package earthfile2llb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/states/image"
+	dockerimage "github.com/moby/buildkit/exporter/containerimage/image"
+)
+
+func TestParseRunService(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want RunServiceOpt
+	}{
+		{
+			in:   "name=db,image=postgres:16",
+			want: RunServiceOpt{Name: "db", Image: "postgres:16"},
+		},
+		{
+			in:   "name=api,target=+api,port=8080,env=MODE=test,env=DEBUG=1",
+			want: RunServiceOpt{Name: "api", Target: "+api", Port: 8080, Env: []string{"MODE=test", "DEBUG=1"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseRunService(test.in)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRunServiceErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		"image=postgres",
+		"name=db",
+		"name=db,image=postgres,target=+db",
+		"name=-db,image=postgres",
+		"name=db,image=postgres,port=0",
+		"name=db,image=postgres,port=http",
+		"name=db,image=postgres,env=DEBUG",
+		"name=db,image=postgres,user=root",
+		"name=db,image",
+	}
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseRunService(in)
+			if err == nil {
+				t.Errorf("expected error for service %q", in)
+			}
+		})
+	}
+}
+
+func TestServicesScript(t *testing.T) {
+	t.Parallel()
+
+	db := runService{
+		opt: RunServiceOpt{Name: "db", Port: 5432, Env: []string{"POSTGRES_PASSWORD=it's"}},
+		img: &image.Image{Config: image.Config{
+			Healthcheck: &dockerimage.HealthConfig{Test: []string{"CMD-SHELL", "pg_isready", "-q"}},
+		}},
+	}
+	db.img.Config.Env = []string{"PATH=/usr/bin"}
+	db.img.Config.Entrypoint = []string{"docker-entrypoint.sh"}
+	db.img.Config.Cmd = []string{"postgres"}
+
+	api := runService{
+		opt: RunServiceOpt{Name: "api"},
+		img: &image.Image{},
+	}
+	api.img.Config.Cmd = []string{"/api", "--listen", ":80"}
+	api.img.Config.WorkingDir = "/srv"
+
+	script, err := servicesScript([]runService{db, api})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []string{
+		"service_1_name=db\n",
+		"service_1_root=/run/earthbuild/services/db\n",
+		"service_1_port=5432\n",
+		`exec env -i PATH=/usr/bin 'POSTGRES_PASSWORD=it'"'"'s' "$earthbuild_chroot" "$service_1_root" ` +
+			`docker-entrypoint.sh postgres` + "\n",
+		`    env -i PATH=/usr/bin 'POSTGRES_PASSWORD=it'"'"'s' "$earthbuild_chroot" "$service_1_root" ` +
+			`/bin/sh -c 'pg_isready -q'` + "\n",
+		"service_2_port=0\n",
+		`exec env -i PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin ` +
+			`"$earthbuild_chroot" "$service_2_root" /bin/sh -c 'cd "$0" && exec "$@"' /srv /api --listen :80` + "\n",
+		"service_2_healthcheck() {\n    return 0\n}\n",
+		"earthbuild_services='1 2'\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(string(script), w) {
+			t.Errorf("expected script to contain %q, got:\n%s", w, script)
+		}
+	}
+}
+
+func TestServicesScriptNoCommand(t *testing.T) {
+	t.Parallel()
+
+	_, err := servicesScript([]runService{{opt: RunServiceOpt{Name: "db"}, img: &image.Image{}}})
+	if err == nil {
+		t.Error("expected error for service without a command")
+	}
+}
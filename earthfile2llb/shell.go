@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -32,16 +34,22 @@ func splitWildcards(name string) (string, string) {
 	return path.Dir(name[:i]), base + name[i:]
 }
 
-func withShell(args []string, withShell bool) []string {
+// withShell wraps the args of a shell form CMD or ENTRYPOINT in the shell, or
+// in /bin/sh -c if there is none.
+func withShell(args []string, withShell bool, shell []string) []string {
 	if withShell {
-		return shellCmd(strings.Join(args, " "))
+		if len(shell) == 0 {
+			return shellCmd(strings.Join(args, " "))
+		}
+
+		return append(slices.Clone(shell), strings.Join(args, " "))
 	}
 
 	return args
 }
 
 func strWithEnvVarsAndDocker(
-	args, envVars []string,
+	args, envVars, shell []string,
 	withShell, withDebugger, forceDebugger, withDocker, isExpression bool,
 	exitCodeFile, outputFile string,
 ) string {
@@ -80,7 +88,7 @@ func strWithEnvVarsAndDocker(
 				fmt.Sprintf("; echo $? >'\"'\"%s\"'\"'", escapeShellSingleQuotes(exitCodeFile)))
 		}
 
-		cmdParts = append(cmdParts, shellCmd(fmt.Sprintf("'%s'", strings.Join(escapedArgs, " ")))...)
+		cmdParts = append(cmdParts, userShellCmd(shell, fmt.Sprintf("'%s'", strings.Join(escapedArgs, " ")))...)
 	} else {
 		cmdParts = append(cmdParts, args...)
 	}
@@ -88,48 +96,76 @@ func strWithEnvVarsAndDocker(
 	return strings.Join(cmdParts, " ")
 }
 
-type shellWrapFun func(args []string, envVars []string, withShell, withDebugger, forceDebugger bool) []string
+type shellWrapFun func(args, envVars, shell []string, withShell, withDebugger, forceDebugger bool) []string
 
 func shellCmd(cmd string) []string {
 	return []string{"/bin/sh", "-c", cmd}
 }
 
-func withShellAndEnvVars(args []string, envVars []string, withShell, withDebugger, forceDebugger bool) []string {
-	return shellCmd(strWithEnvVarsAndDocker(args, envVars, withShell, withDebugger, forceDebugger, false, false, "", ""))
+// userShellCmd returns the parts of a command line running the already quoted
+// cmd in the shell set by SHELL, or in /bin/sh -c if there is none. The parts
+// of the shell are quoted as needed.
+func userShellCmd(shell []string, cmd string) []string {
+	if len(shell) == 0 {
+		return shellCmd(cmd)
+	}
+
+	parts := make([]string, 0, len(shell)+1)
+	for _, part := range shell {
+		parts = append(parts, shellQuote(part))
+	}
+
+	return append(parts, cmd)
+}
+
+var shellSafeRE = regexp.MustCompile(`^[A-Za-z0-9_./=:,+-]+$`)
+
+func shellQuote(s string) string {
+	if shellSafeRE.MatchString(s) {
+		return s
+	}
+
+	return "'" + escapeShellSingleQuotes(s) + "'"
+}
+
+func withShellAndEnvVars(args, envVars, shell []string, withShell, withDebugger, forceDebugger bool) []string {
+	return shellCmd(
+		strWithEnvVarsAndDocker(args, envVars, shell, withShell, withDebugger, forceDebugger, false, false, "", ""),
+	)
 }
 
 func withShellAndEnvVarsExitCode(exitCodeFile string) shellWrapFun {
-	return func(args []string, envVars []string, withShell, withDebugger, _ bool) []string {
+	return func(args, envVars, shell []string, withShell, withDebugger, _ bool) []string {
 		if !withShell {
 			panic("unexpected exec mode")
 		}
 
 		return shellCmd(
-			strWithEnvVarsAndDocker(args, envVars, true, withDebugger, false, false, false, exitCodeFile, ""),
+			strWithEnvVarsAndDocker(args, envVars, shell, true, withDebugger, false, false, false, exitCodeFile, ""),
 		)
 	}
 }
 
 func withShellAndEnvVarsOutput(outputFile string) shellWrapFun {
-	return func(args []string, envVars []string, withShell, withDebugger, _ bool) []string {
+	return func(args, envVars, shell []string, withShell, withDebugger, _ bool) []string {
 		if !withShell {
 			panic("unexpected exec mode")
 		}
 
 		return shellCmd(
-			strWithEnvVarsAndDocker(args, envVars, true, withDebugger, false, false, false, "", outputFile),
+			strWithEnvVarsAndDocker(args, envVars, shell, true, withDebugger, false, false, false, "", outputFile),
 		)
 	}
 }
 
 func expressionWithShellAndEnvVarsOutput(outputFile string) shellWrapFun {
-	return func(args []string, envVars []string, withShell, withDebugger, _ bool) []string {
+	return func(args, envVars, shell []string, withShell, withDebugger, _ bool) []string {
 		if !withShell {
 			panic("unexpected exec mode")
 		}
 
 		return shellCmd(
-			strWithEnvVarsAndDocker(args, envVars, true, withDebugger, false, false, true, "", outputFile),
+			strWithEnvVarsAndDocker(args, envVars, shell, true, withDebugger, false, false, true, "", outputFile),
 		)
 	}
 }
@@ -0,0 +1,37 @@
+package earthfile2llb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withShell(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"echo", "hello"}
+
+	require.Equal(t, []string{"/bin/sh", "-c", "echo hello"}, withShell(args, true, nil))
+	require.Equal(t, []string{"pwsh", "-Command", "echo hello"}, withShell(args, true, []string{"pwsh", "-Command"}))
+	require.Equal(t, args, withShell(args, false, []string{"pwsh", "-Command"}))
+}
+
+func Test_withShellAndEnvVars(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"echo", "it's"}
+	envVars := []string{"A=1"}
+
+	require.Equal(t,
+		[]string{"/bin/sh", "-c", `A=1 /bin/sh -c 'echo it'"'"'s'`},
+		withShellAndEnvVars(args, envVars, nil, true, false, false))
+
+	require.Equal(t,
+		[]string{"/bin/sh", "-c", `A=1 /bin/bash -o pipefail -c 'echo it'"'"'s'`},
+		withShellAndEnvVars(args, envVars, []string{"/bin/bash", "-o", "pipefail", "-c"}, true, false, false))
+
+	// Parts of the shell are quoted as needed.
+	require.Equal(t,
+		[]string{"/bin/sh", "-c", `A=1 '/opt/my shell/sh' -c 'echo it'"'"'s'`},
+		withShellAndEnvVars(args, envVars, []string{"/opt/my shell/sh", "-c"}, true, false, false))
+}
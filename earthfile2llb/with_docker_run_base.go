@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"time"
 
 	debuggercommon "github.com/EarthBuild/earthbuild/debugger/common"
 	"github.com/EarthBuild/earthbuild/util/llbutil"
@@ -21,6 +22,7 @@ const (
 	dockerAutoInstallScriptPath = "/var/earthbuild/docker-auto-install.sh"
 	composeConfigFile           = "compose-config.yml"
 	suggestedDINDImage          = "earthbuild/dind:alpine-3.24-docker-29.5.3-r0"
+	defaultComposeWaitTimeout   = 2 * time.Minute
 )
 
 // DockerLoadOpt holds parameters for WITH DOCKER --load parameter.
@@ -50,7 +52,10 @@ type WithDockerOpt struct {
 	TryCatchSaveArtifacts []debuggercommon.SaveFilesSettings
 	ComposeServices       []string
 	ComposeFiles          []string
+	ComposeProfiles       []string
+	ComposeEnvFiles       []string
 	Loads                 []DockerLoadOpt
+	ComposeWaitTimeout    time.Duration
 	WithSSH               bool
 	WithAWSCredentials    bool
 	interactiveKeep       bool
@@ -58,6 +63,7 @@ type WithDockerOpt struct {
 	NoCache               bool
 	WithEntrypoint        bool
 	WithShell             bool
+	ComposeWait           bool
 }
 
 type withDockerRunBase struct {
@@ -205,10 +211,39 @@ func (w *withDockerRunBase) getComposeConfig(ctx context.Context, opt WithDocker
 	return composeConfigDt, nil
 }
 
+// dindID returns the ID of the data root of the inner dockerd. The data root
+// of a --cache-id, or of a WITH DOCKER command when the layers of its images
+// are reused, is kept across builds; any other data root is removed once the
+// command exits.
+func (w *withDockerRunBase) dindID(cacheID string) (string, error) {
+	if cacheID != "" {
+		// Note that the "cache_" prefix here is used to prevent auto-cleanup
+		return "cache_" + cacheID, nil
+	}
+
+	hash, err := w.c.mts.Final.TargetInput().Hash()
+	if err != nil {
+		return "", fmt.Errorf("make dind ID: %w", err)
+	}
+
+	if w.c.ftrs.ReuseDockerLayers {
+		// The "layers_" data roots are kept across builds, and cleaned up by the
+		// dockerd wrapper once they have not been used for a while. Each WITH
+		// DOCKER command of the target has its own, as the wrapper removes the
+		// images which the command doesn't load.
+		w.c.withDockerRuns++
+
+		return fmt.Sprintf("layers_%s_%d", hash, w.c.withDockerRuns), nil
+	}
+
+	return hash, nil
+}
+
 func makeWithDockerdWrapFun(dindID string, tarPaths, imgsWithDigests []string, opt WithDockerOpt) shellWrapFun {
-	cacheDataRoot := strings.HasPrefix(dindID, "cache_")
+	layerReuse := strings.HasPrefix(dindID, "layers_")
+	cacheDataRoot := layerReuse || strings.HasPrefix(dindID, "cache_")
 	dockerRoot := path.Join("/var/earthbuild/dind", dindID)
-	params := make([]string, 0, 7)
+	params := make([]string, 0, 8)
 	params = append(
 		params,
 		fmt.Sprintf("EARTHLY_DOCKERD_DATA_ROOT=\"%s\"", dockerRoot),
@@ -218,24 +253,45 @@ func makeWithDockerdWrapFun(dindID string, tarPaths, imgsWithDigests []string, o
 		// in case an image is updated.
 		fmt.Sprintf("EARTHLY_IMAGES_WITH_DIGESTS=\"%s\"", strings.Join(imgsWithDigests, " ")),
 	)
+	if layerReuse {
+		params = append(params, "EARTHLY_DOCKER_LAYER_REUSE=\"true\"")
+	}
 	params = append(params, composeParams(opt)...)
+	if opt.ComposeWait {
+		params = append(
+			params,
+			"EARTHLY_COMPOSE_WAIT=\"true\"",
+			fmt.Sprintf("EARTHLY_COMPOSE_WAIT_TIMEOUT=\"%d\"", int(opt.ComposeWaitTimeout.Seconds())),
+		)
+	}
 
-	return func(args []string, envVars []string, isWithShell, withDebugger, forceDebugger bool) []string {
+	return func(args, envVars, shell []string, isWithShell, withDebugger, forceDebugger bool) []string {
 		envVars2 := append(params, envVars...) //nolint:gocritic
 
 		return shellCmd(
-			strWithEnvVarsAndDocker(args, envVars2, isWithShell, withDebugger, forceDebugger, true, false, "", ""),
+			strWithEnvVarsAndDocker(args, envVars2, shell, isWithShell, withDebugger, forceDebugger, true, false, "", ""),
 		)
 	}
 }
 
 func composeParams(opt WithDockerOpt) []string {
-	return []string{
+	params := []string{
 		fmt.Sprintf("EARTHLY_START_COMPOSE=\"%t\"", (len(opt.ComposeFiles) > 0)),
 		fmt.Sprintf("EARTHLY_COMPOSE_FILES=\"%s\"", strings.Join(opt.ComposeFiles, " ")),
 		fmt.Sprintf("EARTHLY_COMPOSE_SERVICES=\"%s\"", strings.Join(opt.ComposeServices, " ")),
 		// fmt.Sprintf("EARTHLY_DEBUG=\"true\""),
 	}
+	// The profiles and env files are only passed when specified, so as not to
+	// bust the cache of existing WITH DOCKER commands.
+	if len(opt.ComposeProfiles) > 0 {
+		params = append(params, fmt.Sprintf("EARTHLY_COMPOSE_PROFILES=\"%s\"", strings.Join(opt.ComposeProfiles, " ")))
+	}
+
+	if len(opt.ComposeEnvFiles) > 0 {
+		params = append(params, fmt.Sprintf("EARTHLY_COMPOSE_ENV_FILES=\"%s\"", strings.Join(opt.ComposeEnvFiles, " ")))
+	}
+
+	return params
 }
 
 func platformIncompatMsg(platr *platutil.Resolver) string {
@@ -0,0 +1,51 @@
+package earthfile2llb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComposeParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want []string
+		opt  WithDockerOpt
+	}{
+		{
+			name: "compose files and services",
+			opt:  WithDockerOpt{ComposeFiles: []string{"compose.yml"}, ComposeServices: []string{"db", "api"}},
+			want: []string{
+				`EARTHLY_START_COMPOSE="true"`,
+				`EARTHLY_COMPOSE_FILES="compose.yml"`,
+				`EARTHLY_COMPOSE_SERVICES="db api"`,
+			},
+		},
+		{
+			name: "profiles and env files",
+			opt: WithDockerOpt{
+				ComposeFiles:    []string{"compose.yml"},
+				ComposeProfiles: []string{"test", "debug"},
+				ComposeEnvFiles: []string{".env.test"},
+			},
+			want: []string{
+				`EARTHLY_START_COMPOSE="true"`,
+				`EARTHLY_COMPOSE_FILES="compose.yml"`,
+				`EARTHLY_COMPOSE_SERVICES=""`,
+				`EARTHLY_COMPOSE_PROFILES="test debug"`,
+				`EARTHLY_COMPOSE_ENV_FILES=".env.test"`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := composeParams(test.opt)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
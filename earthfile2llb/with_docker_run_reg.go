@@ -225,15 +225,9 @@ func (w *withDockerRunRegistry) Run(ctx context.Context, args []string, opt With
 	))
 	crOpts.extraRunOpts = append(crOpts.extraRunOpts, opt.extraRunOpts...)
 
-	var dindID string
-	if opt.CacheID == "" {
-		dindID, err = w.c.mts.Final.TargetInput().Hash()
-		if err != nil {
-			return fmt.Errorf("make dind ID: %w", err)
-		}
-	} else {
-		// Note that the "cache_" prefix here is used to prevent auto-cleanup
-		dindID = "cache_" + opt.CacheID
+	dindID, err := w.dindID(opt.CacheID)
+	if err != nil {
+		return err
 	}
 	// We will pass along the variable EARTHLY_DOCKER_LOAD_REGISTRY via a secret
 	// to prevent busting the cache, as the intermediate image names are
@@ -198,9 +198,9 @@ func (w *withDockerRunTar) Run(ctx context.Context, args []string, opt WithDocke
 		tarPaths = append(tarPaths, path.Join(loadDir, "image.tar"))
 	}
 
-	dindID, err := w.c.mts.Final.TargetInput().Hash()
+	dindID, err := w.dindID("")
 	if err != nil {
-		return fmt.Errorf("make dind ID: %w", err)
+		return err
 	}
 
 	crOpts.shellWrap = makeWithDockerdWrapFun(dindID, tarPaths, nil, opt)
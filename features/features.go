@@ -77,6 +77,14 @@ type Features struct {
 	AllowWithoutEarthlyLabels     bool `description:"Allow the usage of --without-earthly-labels in SAVE IMAGE"                   long:"allow-without-earthly-labels"`     //nolint:lll
 	DockerCache                   bool `description:"enable the WITH DOCKER --cache-id option"                                    long:"docker-cache"`                     //nolint:lll
 	RunWithAWSOIDC                bool `description:"make AWS credentials via OIDC provider available to RUN commands"            long:"run-with-aws-oidc"`                //nolint:lll
+	UseAddCommand                 bool `description:"allow the use of the ADD command"                                            long:"use-add-command"`                  //nolint:lll
+	UseShellAndStopSignal         bool `description:"allow the use of the SHELL and STOPSIGNAL commands"                          long:"use-shell-and-stopsignal"`         //nolint:lll
+	TypedArgs                     bool `description:"allow the --type, --enum and --pattern options of ARG"                       long:"typed-args"`                       //nolint:lll
+	TargetVisibility              bool `description:"allow the use of the VISIBILITY command"                                     long:"target-visibility"`                //nolint:lll
+	BuildMatrix                   bool `description:"allow the --matrix-* options of BUILD"                                       long:"build-matrix"`                     //nolint:lll
+	RunService                    bool `description:"allow the --service option of RUN"                                           long:"run-service"`                      //nolint:lll
+	ReuseDockerLayers             bool `description:"reuse the layers of WITH DOCKER images across builds"                        long:"reuse-docker-layers"`              //nolint:lll
+	DockerComposeOptions          bool `description:"allow the --compose-* options of WITH DOCKER"                                long:"docker-compose-options"`           //nolint:lll
 
 	// version numbers
 	Major int
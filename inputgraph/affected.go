@@ -0,0 +1,313 @@
+package inputgraph
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/buildcontext"
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/variables"
+)
+
+// AffectedStatus is whether a target is affected by changed files.
+type AffectedStatus string
+
+const (
+	// StatusAffected is a target which, or one of whose local dependencies,
+	// copies a changed file or is defined in a changed Earthfile.
+	StatusAffected AffectedStatus = "affected"
+	// StatusUnaffected is a target none of whose inputs changed.
+	StatusUnaffected AffectedStatus = "unaffected"
+	// StatusUnknown is a target which can't be analyzed statically, because
+	// it or one of its dependencies references a target or a file through
+	// the output of a command, or whose graph failed to load.
+	StatusUnknown AffectedStatus = "unknown"
+)
+
+// AffectedTarget is the result of the analysis of a target by Affected.
+type AffectedTarget struct {
+	// Target is the target, or the directory of an Earthfile which failed to
+	// parse, whose targets are unknown.
+	Target string         `json:"target"`
+	Status AffectedStatus `json:"status"`
+	// Reason explains the status: which changed file affects the target, or
+	// which reference can't be resolved statically.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AffectedOpt contains the options of Affected.
+type AffectedOpt struct {
+	OverridingVars *variables.Scope
+	Log            *conslogging.ConsoleLogger
+	BuiltinArgs    variables.DefaultArgs
+	// Changed are the paths of the changed files, absolute or relative to the
+	// current directory.
+	Changed []string
+	Targets []domain.Target
+	// Invalid are the Earthfiles which FindTargets failed to parse.
+	Invalid []InvalidEarthfile
+	CI      bool
+}
+
+// InvalidEarthfile is an Earthfile which failed to parse.
+type InvalidEarthfile struct {
+	Err error
+	// Dir is the directory of the Earthfile, as the local path of its targets.
+	Dir string
+}
+
+// Affected analyzes whether each of the targets is affected by the changed
+// files. It walks the graph of each target as BuildGraph does, so a target is
+// affected by the changes to the files copied by the local targets and
+// functions it depends on, across Earthfiles, and by the changes to their
+// Earthfiles.
+func Affected(ctx context.Context, opt AffectedOpt) ([]AffectedTarget, error) {
+	changed := make([]string, 0, len(opt.Changed))
+
+	for _, p := range opt.Changed {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path of %s: %w", p, err)
+		}
+
+		changed = append(changed, abs)
+	}
+
+	slices.Sort(changed)
+
+	ret := make([]AffectedTarget, 0, len(opt.Targets))
+
+	for _, target := range opt.Targets {
+		if target.IsRemote() {
+			return nil, fmt.Errorf("remote target %s cannot be analyzed: %w", target, errCannotLoadRemoteTarget)
+		}
+
+		g, err := BuildGraph(ctx, GraphOpt{
+			Target:         target,
+			Log:            opt.Log,
+			CI:             opt.CI,
+			BuiltinArgs:    opt.BuiltinArgs,
+			OverridingVars: opt.OverridingVars,
+		})
+		if err != nil {
+			// The other targets may still be analyzed.
+			ret = append(ret, AffectedTarget{
+				Target: target.StringCanonical(),
+				Status: StatusUnknown,
+				Reason: fmt.Sprintf("failed to walk its graph: %v", err),
+			})
+
+			continue
+		}
+
+		at, err := g.affected(changed)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, at)
+	}
+
+	for _, inv := range opt.Invalid {
+		ef, err := filepath.Abs(filepath.Join(filepath.FromSlash(inv.Dir), buildcontext.Earthfile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path of the Earthfile in %s: %w", inv.Dir, err)
+		}
+
+		status := StatusUnknown
+		if _, found := slices.BinarySearch(changed, ef); found {
+			status = StatusAffected
+		}
+
+		ret = append(ret, AffectedTarget{
+			Target: inv.Dir,
+			Status: status,
+			Reason: fmt.Sprintf("its Earthfile failed to parse: %v", inv.Err),
+		})
+	}
+
+	return ret, nil
+}
+
+// affected analyzes whether the root of the graph is affected by the changed
+// files, given as sorted absolute paths.
+func (g *Graph) affected(changed []string) (AffectedTarget, error) {
+	var dynamic *Node
+
+	for i, n := range g.Nodes {
+		if n.Kind == NodeDynamic {
+			if dynamic == nil {
+				dynamic = &g.Nodes[i]
+			}
+
+			continue
+		}
+
+		dir, walked := g.dirs[n.ID]
+		if !walked {
+			continue
+		}
+
+		ef, err := filepath.Abs(filepath.Join(dir, buildcontext.Earthfile))
+		if err != nil {
+			return AffectedTarget{}, fmt.Errorf("failed to get absolute path of the Earthfile of %s: %w", n.ID, err)
+		}
+
+		if _, found := slices.BinarySearch(changed, ef); found {
+			return AffectedTarget{
+				Target: g.Root,
+				Status: StatusAffected,
+				Reason: fmt.Sprintf("%s changed, which defines %s", relPath(ef), n.ID),
+			}, nil
+		}
+
+		for _, src := range n.Sources {
+			abs, err := filepath.Abs(filepath.FromSlash(src))
+			if err != nil {
+				return AffectedTarget{}, fmt.Errorf("failed to get absolute path of %s: %w", src, err)
+			}
+
+			for _, c := range changed {
+				if sourceMatches(abs, c) {
+					return AffectedTarget{
+						Target: g.Root,
+						Status: StatusAffected,
+						Reason: fmt.Sprintf("%s changed, which is copied by %s", relPath(c), n.ID),
+					}, nil
+				}
+			}
+		}
+	}
+
+	if dynamic != nil {
+		from := g.Root
+
+		for _, e := range g.Edges {
+			if e.To == dynamic.ID {
+				from = e.From
+				break
+			}
+		}
+
+		return AffectedTarget{
+			Target: g.Root,
+			Status: StatusUnknown,
+			Reason: fmt.Sprintf("%q in %s cannot be resolved statically", dynamic.ID, from),
+		}, nil
+	}
+
+	return AffectedTarget{Target: g.Root, Status: StatusUnaffected}, nil
+}
+
+// sourceMatches returns whether the changed file is the source, is in the
+// source directory, or matches the source glob pattern, either itself or
+// through one of its parent directories.
+func sourceMatches(src, changed string) bool {
+	for p := changed; ; p = filepath.Dir(p) {
+		if p == src {
+			return true
+		}
+
+		if ok, _ := filepath.Match(src, p); ok {
+			return true
+		}
+
+		if filepath.Dir(p) == p {
+			return false
+		}
+	}
+}
+
+// relPath returns the path relative to the current directory, if it's in it.
+func relPath(p string) string {
+	wd, err := filepath.Abs(".")
+	if err != nil {
+		return p
+	}
+
+	rel, err := filepath.Rel(wd, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return p
+	}
+
+	return rel
+}
+
+// FindTargets returns the targets of the Earthfiles in dir and its
+// subdirectories which can be invoked from the command line, that is, the
+// public ones, and the Earthfiles which failed to parse. Hidden directories
+// are skipped.
+func FindTargets(dir string) ([]domain.Target, []InvalidEarthfile, error) {
+	var (
+		targets []domain.Target
+		invalid []InvalidEarthfile
+	)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.Name() != buildcontext.Earthfile {
+			return nil
+		}
+
+		localPath := filepath.ToSlash(filepath.Dir(path))
+		if !filepath.IsAbs(localPath) && !strings.HasPrefix(localPath, ".") {
+			localPath = "./" + localPath
+		}
+
+		found, err := publicTargets(path, localPath)
+		if err != nil {
+			// The other Earthfiles may still be walked.
+			invalid = append(invalid, InvalidEarthfile{Dir: localPath, Err: err})
+			return nil
+		}
+
+		targets = append(targets, found...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find the Earthfiles in %s: %w", dir, err)
+	}
+
+	return targets, invalid, nil
+}
+
+// publicTargets parses the Earthfile at path, and returns its public targets.
+func publicTargets(path, localPath string) ([]domain.Target, error) {
+	ef, err := earthfile.ParseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var targets []domain.Target
+
+	for _, t := range ef.Targets {
+		visibility, err := buildcontext.Visibility(t.Recipe)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if visibility == buildcontext.VisibilityPublic {
+			targets = append(targets, domain.Target{LocalPath: localPath, Target: t.Name})
+		}
+	}
+
+	return targets, nil
+}
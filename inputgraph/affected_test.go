@@ -0,0 +1,187 @@
+package inputgraph
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAffected(t *testing.T) {
+	t.Parallel()
+
+	cons := conslogging.New(os.Stderr, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	targets, invalid, err := FindTargets("./testdata/affected")
+	require.NoError(t, err)
+	require.Empty(t, invalid)
+	require.Equal(t, []domain.Target{
+		{LocalPath: "./testdata/affected", Target: "app"},
+		{LocalPath: "./testdata/affected", Target: "docs"},
+		{LocalPath: "./testdata/affected", Target: "gen"},
+		{LocalPath: "./testdata/affected/lib", Target: "dist"},
+	}, targets)
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []AffectedTarget
+	}{
+		{
+			name:    "dependency in imported Earthfile",
+			changed: []string{"testdata/affected/lib/src/main.go"},
+			want: []AffectedTarget{
+				{
+					Target: "./testdata/affected+app",
+					Status: StatusAffected,
+					Reason: "testdata/affected/lib/src/main.go changed, which is copied by ./testdata/affected/lib+dist",
+				},
+				{Target: "./testdata/affected+docs", Status: StatusUnaffected},
+				{
+					Target: "./testdata/affected+gen",
+					Status: StatusUnknown,
+					Reason: `"$(cat name.txt)" in ./testdata/affected+gen cannot be resolved statically`,
+				},
+				{
+					Target: "./testdata/affected/lib+dist",
+					Status: StatusAffected,
+					Reason: "testdata/affected/lib/src/main.go changed, which is copied by ./testdata/affected/lib+dist",
+				},
+			},
+		},
+		{
+			name:    "glob",
+			changed: []string{"testdata/affected/docs/index.md", "testdata/affected/docs/img/logo.png"},
+			want: []AffectedTarget{
+				{Target: "./testdata/affected+app", Status: StatusUnaffected},
+				{
+					Target: "./testdata/affected+docs",
+					Status: StatusAffected,
+					Reason: "testdata/affected/docs/index.md changed, which is copied by ./testdata/affected+docs",
+				},
+				{
+					Target: "./testdata/affected+gen",
+					Status: StatusUnknown,
+					Reason: `"$(cat name.txt)" in ./testdata/affected+gen cannot be resolved statically`,
+				},
+				{Target: "./testdata/affected/lib+dist", Status: StatusUnaffected},
+			},
+		},
+		{
+			name:    "Earthfile",
+			changed: []string{"testdata/affected/lib/Earthfile"},
+			want: []AffectedTarget{
+				{
+					Target: "./testdata/affected+app",
+					Status: StatusAffected,
+					Reason: "testdata/affected/lib/Earthfile changed, which defines ./testdata/affected/lib+dist",
+				},
+				{Target: "./testdata/affected+docs", Status: StatusUnaffected},
+				{
+					Target: "./testdata/affected+gen",
+					Status: StatusUnknown,
+					Reason: `"$(cat name.txt)" in ./testdata/affected+gen cannot be resolved statically`,
+				},
+				{
+					Target: "./testdata/affected/lib+dist",
+					Status: StatusAffected,
+					Reason: "testdata/affected/lib/Earthfile changed, which defines ./testdata/affected/lib+dist",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Affected(t.Context(), AffectedOpt{
+				Log:     cons,
+				Targets: targets,
+				Changed: tt.changed,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAffectedLoadError(t *testing.T) {
+	t.Parallel()
+
+	cons := conslogging.New(os.Stderr, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	got, err := Affected(t.Context(), AffectedOpt{
+		Log: cons,
+		Targets: []domain.Target{
+			{LocalPath: "./testdata/affected", Target: "missing"},
+			{LocalPath: "./testdata/affected", Target: "docs"},
+		},
+		Changed: []string{"testdata/affected/docs/index.md"},
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "./testdata/affected+missing", got[0].Target)
+	require.Equal(t, StatusUnknown, got[0].Status)
+	require.Contains(t, got[0].Reason, "failed to walk its graph")
+	require.Equal(t, StatusAffected, got[1].Status)
+}
+
+func TestAffectedParseError(t *testing.T) {
+	t.Parallel()
+
+	cons := conslogging.New(os.Stderr, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	targets, invalid, err := FindTargets("./testdata/affected-invalid")
+	require.NoError(t, err)
+	require.Equal(t, []domain.Target{{LocalPath: "./testdata/affected-invalid", Target: "build"}}, targets)
+	require.Len(t, invalid, 1)
+	require.Equal(t, "./testdata/affected-invalid/broken", invalid[0].Dir)
+	require.ErrorContains(t, invalid[0].Err, "testdata/affected-invalid/broken/Earthfile")
+	require.ErrorContains(t, invalid[0].Err, `duplicate target "duplicate"`)
+
+	for _, tt := range []struct {
+		changed string
+		want    AffectedStatus
+	}{
+		{changed: "testdata/affected-invalid/src/main.go", want: StatusUnknown},
+		{changed: "testdata/affected-invalid/broken/Earthfile", want: StatusAffected},
+	} {
+		got, err := Affected(t.Context(), AffectedOpt{
+			Log:     cons,
+			Targets: targets,
+			Invalid: invalid,
+			Changed: []string{tt.changed},
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		require.Equal(t, "./testdata/affected-invalid/broken", got[1].Target)
+		require.Equal(t, tt.want, got[1].Status)
+		require.Contains(t, got[1].Reason, "its Earthfile failed to parse")
+	}
+}
+
+func TestSourceMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		src     string
+		changed string
+		want    bool
+	}{
+		{src: "/a/b.txt", changed: "/a/b.txt", want: true},
+		{src: "/a/b", changed: "/a/b/c/d.txt", want: true},
+		{src: "/a/b", changed: "/a/bc.txt"},
+		{src: "/a/*.go", changed: "/a/main.go", want: true},
+		{src: "/a/*.go", changed: "/a/b/main.go"},
+		{src: "/a/*", changed: "/a/b/main.go", want: true},
+		{src: "/a/b.txt", changed: "/a"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, sourceMatches(tt.src, tt.changed), "%s %s", tt.src, tt.changed)
+	}
+}
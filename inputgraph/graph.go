@@ -0,0 +1,315 @@
+package inputgraph
+
+import (
+	"cmp"
+	"context"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/variables"
+)
+
+// NodeKind is the kind of a Node of a Graph.
+type NodeKind string
+
+const (
+	// NodeTarget is a local target.
+	NodeTarget NodeKind = "target"
+	// NodeFunction is a local function.
+	NodeFunction NodeKind = "function"
+	// NodeArtifact is an artifact saved by a target.
+	NodeArtifact NodeKind = "artifact"
+	// NodeRemote is a remote target or function, or a remote project when
+	// they are collapsed. Remote references are not walked.
+	NodeRemote NodeKind = "remote"
+	// NodeDynamic is a reference which depends on the output of a command,
+	// and so cannot be resolved statically.
+	NodeDynamic NodeKind = "dynamic"
+)
+
+// EdgeKind is the kind of an Edge of a Graph.
+type EdgeKind string
+
+const (
+	// EdgeFrom is a FROM of a target.
+	EdgeFrom EdgeKind = "from"
+	// EdgeBuild is a BUILD of a target.
+	EdgeBuild EdgeKind = "build"
+	// EdgeCopy is a COPY of an artifact, or its use by FROM DOCKERFILE.
+	EdgeCopy EdgeKind = "copy"
+	// EdgeArtifact links an artifact to the target which saves it.
+	EdgeArtifact EdgeKind = "artifact"
+	// EdgeDo is a DO of a function.
+	EdgeDo EdgeKind = "do"
+	// EdgeLoad is a WITH DOCKER --load of a target.
+	EdgeLoad EdgeKind = "load"
+	// EdgeService is a RUN --service of a target.
+	EdgeService EdgeKind = "service"
+)
+
+// Node is a target, function or artifact of a Graph.
+type Node struct {
+	// ID is the canonical reference of the node.
+	ID   string   `json:"id"`
+	Kind NodeKind `json:"kind"`
+	// Sources are the paths, or glob patterns, of the files and directories
+	// of the build context the node copies, relative to the current directory.
+	Sources []string `json:"sources,omitempty"`
+	// Truncated is set when the dependencies of the node were not walked
+	// because of the maximum depth.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Edge is a dependency of a node on another.
+type Edge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+	// Args are the build args passed to the target or function, as
+	// name=value, if GraphOpt.Args is set.
+	Args []string `json:"args,omitempty"`
+}
+
+// Graph is the static dependency graph of a target.
+type Graph struct {
+	nodes     map[string]NodeKind
+	truncated map[string]bool
+	edges     map[string]Edge
+	sources   map[string]map[string]struct{}
+	// dirs are the directories of the Earthfiles of the walked nodes.
+	dirs map[string]string
+	// Root is the ID of the target the graph was built for.
+	Root  string `json:"root"`
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	// maxDepth is the depth beyond which dependencies are not walked, or 0.
+	maxDepth       int
+	args           bool
+	collapseRemote bool
+}
+
+// GraphOpt contains the options of BuildGraph.
+type GraphOpt struct {
+	OverridingVars *variables.Scope
+	Log            *conslogging.ConsoleLogger
+	Target         domain.Target
+	BuiltinArgs    variables.DefaultArgs
+	// Depth limits how far dependencies are walked from the target; 0 means
+	// no limit.
+	Depth int
+	// CollapseRemote merges the remote references of a project into a single
+	// node.
+	CollapseRemote bool
+	// Args records the build args passed along the edges.
+	Args bool
+	CI   bool
+}
+
+// BuildGraph walks the FROM, BUILD, COPY, DO and WITH DOCKER --load
+// references of a target and returns its dependency graph, without executing
+// anything. References to remote Earthfiles are recorded but not walked.
+func BuildGraph(ctx context.Context, opt GraphOpt) (*Graph, error) {
+	g := &Graph{
+		nodes:          map[string]NodeKind{},
+		truncated:      map[string]bool{},
+		edges:          map[string]Edge{},
+		sources:        map[string]map[string]struct{}{},
+		dirs:           map[string]string{},
+		maxDepth:       opt.Depth,
+		args:           opt.Args,
+		collapseRemote: opt.CollapseRemote,
+	}
+
+	if opt.Target.IsRemote() {
+		g.Root = g.addRef(opt.Target)
+		g.finalize()
+
+		return g, nil
+	}
+
+	l := newLoader(HashOpt{
+		Target:         opt.Target,
+		Log:            opt.Log,
+		CI:             opt.CI,
+		BuiltinArgs:    opt.BuiltinArgs,
+		OverridingVars: opt.OverridingVars,
+	})
+	l.graph = g
+
+	g.Root = l.nodeID()
+	g.nodes[g.Root] = NodeTarget
+
+	_, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g.finalize()
+
+	return g, nil
+}
+
+// addRef adds the node of a target or function reference, returning its ID.
+func (g *Graph) addRef(ref domain.Reference) string {
+	id, kind := ref.StringCanonical(), NodeTarget
+
+	switch {
+	case ref.IsRemote():
+		kind = NodeRemote
+
+		if g.collapseRemote {
+			id = ref.ProjectCanonical()
+		}
+	case isFunction(ref):
+		kind = NodeFunction
+	}
+
+	g.nodes[id] = kind
+
+	return id
+}
+
+func (g *Graph) addEdge(e Edge) {
+	if !g.args || len(e.Args) == 0 {
+		e.Args = nil
+	}
+
+	key := strings.Join(append([]string{e.From, e.To, string(e.Kind)}, e.Args...), "\x00")
+	g.edges[key] = e
+}
+
+// finalize sorts the nodes and edges, so that the output is stable.
+func (g *Graph) finalize() {
+	g.Nodes = make([]Node, 0, len(g.nodes))
+	for id, kind := range g.nodes {
+		var sources []string
+		for src := range g.sources[id] {
+			sources = append(sources, src)
+		}
+
+		slices.Sort(sources)
+
+		g.Nodes = append(g.Nodes, Node{ID: id, Kind: kind, Sources: sources, Truncated: g.truncated[id]})
+	}
+
+	slices.SortFunc(g.Nodes, func(a, b Node) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	g.Edges = make([]Edge, 0, len(g.edges))
+	for _, e := range g.edges {
+		g.Edges = append(g.Edges, e)
+	}
+
+	slices.SortFunc(g.Edges, func(a, b Edge) int {
+		return cmp.Or(
+			cmp.Compare(a.From, b.From),
+			cmp.Compare(a.To, b.To),
+			cmp.Compare(a.Kind, b.Kind),
+			slices.Compare(a.Args, b.Args),
+		)
+	})
+}
+
+func isFunction(ref domain.Reference) bool {
+	_, ok := ref.(domain.Command)
+	return ok
+}
+
+// nodeID returns the ID of the target or function being loaded.
+func (l *loader) nodeID() string {
+	if l.function == "" {
+		return l.target.StringCanonical()
+	}
+
+	return domain.Command{
+		GitURL:    l.target.GitURL,
+		Tag:       l.target.Tag,
+		LocalPath: l.target.LocalPath,
+		Command:   l.function,
+	}.StringCanonical()
+}
+
+// recordDependency records in the graph, if one is being built, that the
+// current target or function depends on ref. An artifact gets a node of its
+// own, which depends on the target saving it. It returns whether the
+// dependencies of ref should be walked, given the maximum depth.
+func (l *loader) recordDependency(kind EdgeKind, ref domain.Reference, artifact string, args []string) bool {
+	g := l.graph
+	if g == nil {
+		return true
+	}
+
+	to := g.addRef(ref)
+
+	if artifact != "" && !(ref.IsRemote() && g.collapseRemote) {
+		target := to
+		to = ref.StringCanonical() + path.Join("/", artifact)
+		g.nodes[to] = NodeArtifact
+		g.addEdge(Edge{From: to, To: target, Kind: EdgeArtifact})
+	}
+
+	// The args of remote references are not parsed, and so are still flags.
+	for i, arg := range args {
+		args[i] = strings.TrimPrefix(arg, "--")
+	}
+
+	g.addEdge(Edge{From: l.nodeID(), To: to, Kind: kind, Args: args})
+
+	if ref.IsRemote() {
+		return false
+	}
+
+	if g.maxDepth > 0 && l.depth+1 >= g.maxDepth {
+		id := ref.StringCanonical()
+		if _, seen := g.truncated[id]; !seen {
+			g.truncated[id] = true
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// recordDynamic records in the graph, if one is being built, a reference
+// which cannot be resolved statically.
+func (l *loader) recordDynamic(kind EdgeKind, name string) {
+	if l.graph == nil {
+		return
+	}
+
+	l.graph.nodes[name] = NodeDynamic
+	l.graph.addEdge(Edge{From: l.nodeID(), To: name, Kind: kind})
+}
+
+// recordWalked marks the current target or function as walked, as it may
+// have been truncated when reached through a deeper path before.
+func (l *loader) recordWalked() {
+	if l.graph == nil {
+		return
+	}
+
+	id := l.nodeID()
+	l.graph.truncated[id] = false
+	l.graph.dirs[id] = l.target.GetLocalPath()
+}
+
+// recordSource records in the graph, if one is being built, that the current
+// target or function copies a path of the build context.
+func (l *loader) recordSource(path string) {
+	if l.graph == nil {
+		return
+	}
+
+	id := l.nodeID()
+	if l.graph.sources[id] == nil {
+		l.graph.sources[id] = map[string]struct{}{}
+	}
+
+	l.graph.sources[id][filepath.ToSlash(path)] = struct{}{}
+}
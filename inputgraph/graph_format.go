@@ -0,0 +1,123 @@
+package inputgraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats of a Graph.
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+)
+
+// Write writes the graph in the given format.
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatDOT:
+		return g.WriteDOT(w)
+	case FormatMermaid:
+		return g.WriteMermaid(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(g)
+	default:
+		return fmt.Errorf("unknown graph format %q, expected one of %s, %s or %s",
+			format, FormatDOT, FormatMermaid, FormatJSON)
+	}
+}
+
+var dotShapes = map[NodeKind]string{
+	NodeTarget:   "box",
+	NodeFunction: "component",
+	NodeArtifact: "note",
+	NodeRemote:   "box3d",
+	NodeDynamic:  "diamond",
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph earth {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+
+	for _, n := range g.Nodes {
+		attrs := "shape=" + dotShapes[n.Kind]
+		if n.ID == g.Root {
+			attrs += ", penwidth=2"
+		}
+
+		if n.Truncated || n.Kind == NodeRemote || n.Kind == NodeDynamic {
+			attrs += ", style=dashed"
+		}
+
+		fmt.Fprintf(bw, "  %s [%s];\n", dotQuote(n.ID), attrs)
+	}
+
+	for _, e := range g.Edges {
+		label := strings.Join(append([]string{string(e.Kind)}, e.Args...), "\n")
+		fmt.Fprintf(bw, "  %s -> %s [label=%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(label))
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "flowchart LR")
+
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+
+		label := mermaidQuote(n.ID)
+
+		switch n.Kind {
+		case NodeFunction:
+			fmt.Fprintf(bw, "    %s[[%s]]\n", ids[n.ID], label)
+		case NodeArtifact:
+			fmt.Fprintf(bw, "    %s[/%s/]\n", ids[n.ID], label)
+		case NodeRemote:
+			fmt.Fprintf(bw, "    %s([%s])\n", ids[n.ID], label)
+		case NodeDynamic:
+			fmt.Fprintf(bw, "    %s{%s}\n", ids[n.ID], label)
+		case NodeTarget:
+			fmt.Fprintf(bw, "    %s[%s]\n", ids[n.ID], label)
+		}
+
+		if n.ID == g.Root {
+			fmt.Fprintf(bw, "    style %s stroke-width:3px\n", ids[n.ID])
+		}
+
+		if n.Truncated {
+			fmt.Fprintf(bw, "    style %s stroke-dasharray: 5 5\n", ids[n.ID])
+		}
+	}
+
+	for _, e := range g.Edges {
+		label := strings.Join(append([]string{string(e.Kind)}, e.Args...), "<br>")
+		fmt.Fprintf(bw, "    %s -->|%s| %s\n", ids[e.From], mermaidQuote(label), ids[e.To])
+	}
+
+	return bw.Flush()
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
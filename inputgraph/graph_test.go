@@ -0,0 +1,140 @@
+package inputgraph
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildGraph(t *testing.T) {
+	t.Parallel()
+
+	cons := conslogging.New(os.Stderr, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	g, err := BuildGraph(t.Context(), GraphOpt{
+		Log:    cons,
+		Target: domain.Target{LocalPath: "./testdata/graph", Target: "build"},
+		Args:   true,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "./testdata/graph+build", g.Root)
+	require.Equal(t, []Node{
+		{ID: "$(echo +other)", Kind: NodeDynamic},
+		{ID: "./testdata/graph+build", Kind: NodeTarget},
+		{ID: "./testdata/graph+deps", Kind: NodeTarget, Sources: []string{"testdata/graph/src"}},
+		{ID: "./testdata/graph+lint", Kind: NodeTarget},
+		{ID: "./testdata/graph+test", Kind: NodeTarget},
+		{ID: "./testdata/graph/lib+HELPER", Kind: NodeFunction},
+		{ID: "./testdata/graph/lib+artifact", Kind: NodeTarget},
+		{ID: "./testdata/graph/lib+artifact/out.txt", Kind: NodeArtifact},
+		{ID: "github.com/EarthBuild/hello-world:main+hello", Kind: NodeRemote},
+	}, g.Nodes)
+	require.Equal(t, []Edge{
+		{From: "./testdata/graph+build", To: "./testdata/graph+deps", Kind: EdgeFrom},
+		{From: "./testdata/graph+build", To: "./testdata/graph+test", Kind: EdgeBuild, Args: []string{"mode=ci"}},
+		{From: "./testdata/graph+build", To: "./testdata/graph/lib+HELPER", Kind: EdgeDo, Args: []string{"name=app"}},
+		{From: "./testdata/graph+build", To: "./testdata/graph/lib+artifact/out.txt", Kind: EdgeCopy},
+		{From: "./testdata/graph+build", To: "github.com/EarthBuild/hello-world:main+hello", Kind: EdgeBuild},
+		{From: "./testdata/graph+lint", To: "./testdata/graph+deps", Kind: EdgeFrom},
+		{From: "./testdata/graph+test", To: "$(echo +other)", Kind: EdgeBuild},
+		{From: "./testdata/graph+test", To: "./testdata/graph+deps", Kind: EdgeFrom},
+		{From: "./testdata/graph+test", To: "./testdata/graph+lint", Kind: EdgeBuild},
+		{From: "./testdata/graph/lib+HELPER", To: "./testdata/graph/lib+artifact", Kind: EdgeBuild},
+		{From: "./testdata/graph/lib+artifact/out.txt", To: "./testdata/graph/lib+artifact", Kind: EdgeArtifact},
+	}, g.Edges)
+}
+
+func TestBuildGraph_DepthAndCollapse(t *testing.T) {
+	t.Parallel()
+
+	cons := conslogging.New(os.Stderr, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	g, err := BuildGraph(t.Context(), GraphOpt{
+		Log:            cons,
+		Target:         domain.Target{LocalPath: "./testdata/graph", Target: "build"},
+		Depth:          1,
+		CollapseRemote: true,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []Node{
+		{ID: "./testdata/graph+build", Kind: NodeTarget},
+		{ID: "./testdata/graph+deps", Kind: NodeTarget, Truncated: true},
+		{ID: "./testdata/graph+test", Kind: NodeTarget, Truncated: true},
+		{ID: "./testdata/graph/lib+HELPER", Kind: NodeFunction, Truncated: true},
+		{ID: "./testdata/graph/lib+artifact", Kind: NodeTarget, Truncated: true},
+		{ID: "./testdata/graph/lib+artifact/out.txt", Kind: NodeArtifact},
+		{ID: "github.com/EarthBuild/hello-world:main", Kind: NodeRemote},
+	}, g.Nodes)
+
+	for _, e := range g.Edges {
+		require.Empty(t, e.Args)
+	}
+}
+
+func TestGraph_Write(t *testing.T) {
+	t.Parallel()
+
+	g := &Graph{
+		Root: "+build",
+		Nodes: []Node{
+			{ID: "+HELPER", Kind: NodeFunction},
+			{ID: "+build", Kind: NodeTarget},
+			{ID: "+deps", Kind: NodeTarget, Truncated: true},
+		},
+		Edges: []Edge{
+			{From: "+build", To: "+HELPER", Kind: EdgeDo, Args: []string{`msg="hi"`}},
+			{From: "+build", To: "+deps", Kind: EdgeFrom},
+		},
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, g.Write(&buf, FormatDOT))
+	require.Equal(t, `digraph earth {
+  rankdir=LR;
+  "+HELPER" [shape=component];
+  "+build" [shape=box, penwidth=2];
+  "+deps" [shape=box, style=dashed];
+  "+build" -> "+HELPER" [label="do\nmsg=\"hi\""];
+  "+build" -> "+deps" [label="from"];
+}
+`, buf.String())
+
+	buf.Reset()
+
+	require.NoError(t, g.Write(&buf, FormatMermaid))
+	require.Equal(t, `flowchart LR
+    n0[["+HELPER"]]
+    n1["+build"]
+    style n1 stroke-width:3px
+    n2["+deps"]
+    style n2 stroke-dasharray: 5 5
+    n1 -->|"do<br>msg=#quot;hi#quot;"| n0
+    n1 -->|"from"| n2
+`, buf.String())
+
+	buf.Reset()
+
+	require.NoError(t, g.Write(&buf, FormatJSON))
+	require.JSONEq(t, `{
+  "root": "+build",
+  "nodes": [
+    {"id": "+HELPER", "kind": "function"},
+    {"id": "+build", "kind": "target"},
+    {"id": "+deps", "kind": "target", "truncated": true}
+  ],
+  "edges": [
+    {"from": "+build", "to": "+HELPER", "kind": "do", "args": ["msg=\"hi\""]},
+    {"from": "+build", "to": "+deps", "kind": "from"}
+  ]
+}`, buf.String())
+
+	require.Error(t, g.Write(&buf, "svg"))
+}
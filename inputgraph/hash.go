@@ -9,10 +9,12 @@ import (
 	"github.com/EarthBuild/earthbuild/variables"
 )
 
-// HashOpt contains all of the options available to the hasher.
+// HashOpt contains all of the options available to the hasher. When Manifest
+// is set, the inputs which went into the hash are recorded in it.
 type HashOpt struct {
 	OverridingVars *variables.Scope
 	Log            *conslogging.ConsoleLogger
+	Manifest       *Manifest
 	Target         domain.Target
 	BuiltinArgs    variables.DefaultArgs
 	CI             bool
@@ -26,6 +28,7 @@ func HashTarget(ctx context.Context, opt HashOpt) ([]byte, Stats, error) {
 		if supportedRemoteTarget(t) {
 			h := hasher.New()
 			h.HashString(t.StringCanonical())
+			opt.Manifest.add(Input{Kind: InputRemoteTarget, Name: t.StringCanonical()})
 
 			return h.GetHash(), Stats{}, nil
 		}
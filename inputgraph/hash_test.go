@@ -12,6 +12,7 @@ import (
 
 	"github.com/EarthBuild/earthbuild/conslogging"
 	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/variables"
 	"github.com/stretchr/testify/require"
 )
 
@@ -192,3 +193,136 @@ func TestHashTargetCache(t *testing.T) {
 	hex := hex.EncodeToString(hash)
 	r.NotEmpty(hex)
 }
+
+func TestHashTargetManifest(t *testing.T) {
+	t.Parallel()
+
+	r := require.New(t)
+	ctx := t.Context()
+	cons := conslogging.New(io.Discard, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	dir := t.TempDir()
+	earthfile := filepath.Join(dir, "Earthfile")
+	r.NoError(os.WriteFile(earthfile, []byte(`VERSION 0.8
+FROM alpine
+build:
+    ARG VERSION=1
+    BUILD +dep
+    RUN echo $VERSION
+dep:
+    COPY a.txt .
+`), 0o600))
+	r.NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o600))
+
+	target := domain.Target{LocalPath: dir, Target: "build"}
+
+	hash, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target})
+	r.NoError(err)
+
+	prev := &Manifest{}
+	withManifest, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target, Manifest: prev})
+	r.NoError(err)
+	r.Equal(hash, withManifest, "recording a manifest must not change the hash")
+
+	r.Contains(prev.Inputs, Input{
+		Kind:   InputFile,
+		Target: strings.TrimSuffix(target.StringCanonical(), "build") + "dep",
+		Name:   filepath.ToSlash(filepath.Join(dir, "a.txt")),
+		Value:  "sha256:ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+	})
+	r.Contains(prev.Inputs, Input{Kind: InputArg, Target: target.StringCanonical(), Name: "VERSION", Value: "1"})
+	r.Contains(prev.Inputs, Input{Kind: InputCommand, Target: target.StringCanonical(), Name: "RUN echo $VERSION"})
+
+	r.Empty(Diff(prev, prev))
+
+	r.NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("b"), 0o600))
+	r.NoError(replaceInFile(earthfile, "ARG VERSION=1", "ARG VERSION=2"))
+
+	cur := &Manifest{}
+	_, _, err = HashTarget(ctx, HashOpt{Log: cons, Target: target, Manifest: cur})
+	r.NoError(err)
+
+	var got []string
+	for _, c := range Diff(prev, cur) {
+		got = append(got, string(c.Kind)+" "+string(c.Input().Kind)+" "+c.Input().Name)
+	}
+
+	r.Equal([]string{
+		"changed arg VERSION",
+		"removed command ARG VERSION=1",
+		"added command ARG VERSION=2",
+		"changed file " + filepath.ToSlash(filepath.Join(dir, "a.txt")),
+		"changed target " + strings.TrimSuffix(target.StringCanonical(), "build") + "dep",
+	}, got)
+}
+
+func TestHashTargetAdd(t *testing.T) {
+	t.Parallel()
+
+	r := require.New(t)
+	ctx := t.Context()
+	cons := conslogging.New(io.Discard, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	const url = "https://example.com/app.tar.gz"
+
+	checksum := "sha256:" + strings.Repeat("a", 64)
+
+	dir := t.TempDir()
+	earthfile := filepath.Join(dir, "Earthfile")
+	r.NoError(os.WriteFile(earthfile, []byte(`VERSION --use-add-command 0.8
+FROM alpine
+build:
+    ARG CHECKSUM=`+checksum+`
+    ADD a.tar.gz ./
+    ADD --checksum=$CHECKSUM `+url+` ./
+unpinned:
+    ADD `+url+` ./
+several:
+    ADD --checksum=`+checksum+` `+url+` `+url+`.sig ./
+local:
+    ADD --checksum=`+checksum+` a.tar.gz ./
+`), 0o600))
+	r.NoError(os.WriteFile(filepath.Join(dir, "a.tar.gz"), []byte("a"), 0o600))
+
+	target := domain.Target{LocalPath: dir, Target: "build"}
+
+	m := &Manifest{}
+	first, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target, Manifest: m})
+	r.NoError(err)
+	r.Contains(m.Inputs, Input{Kind: InputURL, Target: target.StringCanonical(), Name: url, Value: checksum})
+
+	r.NoError(os.WriteFile(filepath.Join(dir, "a.tar.gz"), []byte("b"), 0o600))
+
+	second, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target})
+	r.NoError(err)
+	r.NotEqual(first, second, "a changed local source must change the hash")
+
+	override, err := variables.ParseCommandLineArgs([]string{"CHECKSUM=sha256:" + strings.Repeat("b", 64)})
+	r.NoError(err)
+
+	third, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target, OverridingVars: override})
+	r.NoError(err)
+	r.NotEqual(second, third, "a changed checksum must change the hash")
+
+	_, _, err = HashTarget(ctx, HashOpt{Log: cons, Target: domain.Target{LocalPath: dir, Target: "unpinned"}})
+	r.ErrorContains(err, "without --checksum cannot be hashed")
+
+	for _, name := range []string{"several", "local"} {
+		_, _, err = HashTarget(ctx, HashOpt{Log: cons, Target: domain.Target{LocalPath: dir, Target: name}})
+		r.ErrorContains(err, "ADD --checksum requires exactly one source, which must be a URL", name)
+	}
+}
+
+func TestDiffCommands(t *testing.T) {
+	t.Parallel()
+
+	cmd := func(name string) Input { return Input{Kind: InputCommand, Target: "+t", Name: name} }
+
+	prev := &Manifest{Inputs: []Input{cmd("RUN a"), cmd("RUN a"), cmd("RUN b")}}
+	cur := &Manifest{Inputs: []Input{cmd("RUN a"), cmd("RUN c"), cmd("RUN b")}}
+
+	changes := Diff(prev, cur)
+	require.Len(t, changes, 2)
+	require.Equal(t, "removed command RUN a in +t", changes[0].String())
+	require.Equal(t, "added command RUN c in +t", changes[1].String())
+}
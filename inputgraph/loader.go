@@ -22,7 +22,9 @@ import (
 	"github.com/EarthBuild/earthbuild/internal/earthfile"
 	"github.com/EarthBuild/earthbuild/util/buildkitskipper/hasher"
 	"github.com/EarthBuild/earthbuild/util/flagutil"
+	"github.com/EarthBuild/earthbuild/util/stringutil"
 	"github.com/EarthBuild/earthbuild/variables"
+	arg "github.com/EarthBuild/earthbuild/variables/reserved"
 )
 
 var (
@@ -50,22 +52,31 @@ type loader struct {
 	stats          *Stats
 	globalImports  map[string]domain.ImportTrackerVal
 	hasher         *hasher.Hasher
+	manifest       *Manifest
+	graph          *Graph
 	log            *conslogging.ConsoleLogger
 	visited        map[string]struct{}
 	overridingVars *variables.Scope
 	target         domain.Target
-	builtinArgs    variables.DefaultArgs
-	baseProcessed  bool
-	ci             bool
-	primaryTarget  bool
+	// function is the name of the function being loaded, if any, in the
+	// Earthfile of target.
+	function      string
+	builtinArgs   variables.DefaultArgs
+	depth         int
+	baseProcessed bool
+	ci            bool
+	primaryTarget bool
 }
 
 func newLoader(opt HashOpt) *loader {
 	h := hasher.New()
 	h.HashJSONMarshalled(opt.BuiltinArgs)
+	opt.Manifest.add(Input{Kind: InputBuiltin, Name: arg.EarthVersion, Value: opt.BuiltinArgs.EarthVersion})
+	opt.Manifest.add(Input{Kind: InputBuiltin, Name: arg.EarthBuildSha, Value: opt.BuiltinArgs.EarthBuildSha})
 	// Other important values are set by load().
 	return &loader{
 		log:            opt.Log,
+		manifest:       opt.Manifest,
 		target:         opt.Target,
 		visited:        map[string]struct{}{},
 		hasher:         h,
@@ -97,7 +108,7 @@ func (l *loader) handleFrom(ctx context.Context, cmd earthfile.Command) error {
 		return nil
 	}
 
-	return l.loadTargetFromString(ctx, fromTarget, args[1:], false, cmd.SourceLocation)
+	return l.loadTargetFromString(ctx, fromTarget, args[1:], false, cmd.SourceLocation, EdgeFrom, "")
 }
 
 func (l *loader) handleBuild(ctx context.Context, cmd earthfile.Command) error {
@@ -120,7 +131,7 @@ func (l *loader) handleBuild(ctx context.Context, cmd earthfile.Command) error {
 	}
 
 	for _, args := range argCombos {
-		err := l.loadTargetFromString(ctx, targetName, args[1:], opts.PassArgs, cmd.SourceLocation)
+		err := l.loadTargetFromString(ctx, targetName, args[1:], opts.PassArgs, cmd.SourceLocation, EdgeBuild, "")
 		if err != nil {
 			return err
 		}
@@ -135,14 +146,9 @@ func (l *loader) derefedTarget(targetName string) (domain.Target, error) {
 		return domain.Target{}, fmt.Errorf("failed to parse target %s: %w", targetName, err)
 	}
 
-	derefed, _, _, err := l.varCollection.Imports().Deref(target)
+	targetRef, err := l.deref(target)
 	if err != nil {
-		return domain.Target{}, fmt.Errorf("failed to deref target %s: %w", target, err)
-	}
-
-	targetRef, err := domain.JoinReferences(l.varCollection.AbsRef(), derefed)
-	if err != nil {
-		return domain.Target{}, fmt.Errorf("failed to join %s and %s: %w", l.target, target, err)
+		return domain.Target{}, err
 	}
 
 	target, ok := targetRef.(domain.Target)
@@ -153,6 +159,41 @@ func (l *loader) derefedTarget(targetName string) (domain.Target, error) {
 	return target, nil
 }
 
+func (l *loader) derefedCommand(commandName string) (domain.Command, error) {
+	command, err := domain.ParseCommand(commandName)
+	if err != nil {
+		return domain.Command{}, fmt.Errorf("failed to parse function %s: %w", commandName, err)
+	}
+
+	commandRef, err := l.deref(command)
+	if err != nil {
+		return domain.Command{}, err
+	}
+
+	command, ok := commandRef.(domain.Command)
+	if !ok {
+		return domain.Command{}, fmt.Errorf("want domain.Command, got %T", commandRef)
+	}
+
+	return command, nil
+}
+
+// deref resolves the import of ref, if any, and makes it relative to the
+// current Earthfile.
+func (l *loader) deref(ref domain.Reference) (domain.Reference, error) {
+	derefed, _, _, err := l.varCollection.Imports().Deref(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deref %s: %w", ref, err)
+	}
+
+	joined, err := domain.JoinReferences(l.varCollection.AbsRef(), derefed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join %s and %s: %w", l.target, ref, err)
+	}
+
+	return joined, nil
+}
+
 func (l *loader) handleCopy(ctx context.Context, cmd earthfile.Command) error {
 	var opts cmdopts.Copy
 
@@ -162,6 +203,11 @@ func (l *loader) handleCopy(ctx context.Context, cmd earthfile.Command) error {
 	}
 
 	if opts.From != "" {
+		if l.graph != nil {
+			// Images are not part of the graph.
+			return nil
+		}
+
 		return newError(cmd.SourceLocation, "COPY --from is not supported")
 	}
 
@@ -182,6 +228,91 @@ func (l *loader) handleCopy(ctx context.Context, cmd earthfile.Command) error {
 	return nil
 }
 
+func (l *loader) handleAdd(ctx context.Context, cmd earthfile.Command) error {
+	var opts cmdopts.Add
+
+	args, err := flagutil.ParseArgsCleaned(string(earthfile.CmdAdd), &opts, flagutil.GetArgsCopy(cmd))
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		return newError(cmd.SourceLocation, "ADD must include a source and destination")
+	}
+
+	checksum, err := l.expandArgs(opts.Checksum)
+	if err != nil {
+		return wrapError(err, cmd.SourceLocation, "failed to expand ADD checksum")
+	}
+
+	srcs := args[:len(args)-1]
+	if checksum != "" && len(srcs) != 1 {
+		return newError(cmd.SourceLocation, "ADD --checksum requires exactly one source, which must be a URL")
+	}
+
+	for _, src := range srcs {
+		expandedSrc, err := l.expandArgs(src)
+		if err != nil {
+			return wrapError(err, cmd.SourceLocation, "failed to expand ADD source")
+		}
+
+		if !stringutil.IsHTTPURL(expandedSrc) {
+			if checksum != "" {
+				return newError(cmd.SourceLocation, "ADD --checksum requires exactly one source, which must be a URL")
+			}
+
+			err = l.handleCopySrc(ctx, cmd, src, true)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if l.graph != nil {
+			continue
+		}
+
+		// The contents of a URL may change at any time, unless they're pinned
+		// by a checksum.
+		if checksum == "" {
+			return newError(cmd.SourceLocation, "ADD source %q without --checksum cannot be hashed", expandedSrc)
+		}
+
+		l.hasher.HashString(fmt.Sprintf("ADD %s=%s", expandedSrc, checksum))
+		l.record(InputURL, expandedSrc, checksum)
+	}
+
+	return nil
+}
+
+func (l *loader) handleRun(ctx context.Context, cmd earthfile.Command) error {
+	var opts cmdopts.Run
+
+	_, err := flagutil.ParseArgsCleaned(string(earthfile.CmdRun), &opts, flagutil.GetArgsCopy(cmd))
+	if err != nil {
+		return wrapError(err, cmd.SourceLocation, "failed to parse RUN flags")
+	}
+
+	// The image of a service may be built from a target, e.g.
+	// --service name=api,target=+api.
+	for _, service := range opts.Services {
+		for kv := range strings.SplitSeq(service, ",") {
+			target, ok := strings.CutPrefix(kv, "target=")
+			if !ok {
+				continue
+			}
+
+			err = l.loadTargetFromString(ctx, target, nil, false, cmd.SourceLocation, EdgeService, "")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func containsShellExpr(s string) bool {
 	var (
 		last    string
@@ -266,7 +397,9 @@ func (l *loader) handleCopySrc(ctx context.Context, cmd earthfile.Command, src s
 
 		targetName := artifactSrc.Target.String()
 
-		err = l.loadTargetFromString(ctx, targetName, extraArgs, false, cmd.SourceLocation)
+		err = l.loadTargetFromString(
+			ctx, targetName, extraArgs, false, cmd.SourceLocation, EdgeCopy, artifactSrc.Artifact,
+		)
 		if err != nil {
 			return err
 		}
@@ -283,6 +416,11 @@ func (l *loader) handleCopySrc(ctx context.Context, cmd earthfile.Command, src s
 	}
 
 	if containsShellExpr(src) {
+		if l.graph != nil {
+			l.recordDynamic(EdgeCopy, src)
+			return nil
+		}
+
 		return newError(cmd.SourceLocation, "dynamic COPY source %q cannot be resolved", src)
 	}
 
@@ -291,6 +429,11 @@ func (l *loader) handleCopySrc(ctx context.Context, cmd earthfile.Command, src s
 		path  = filepath.Join(l.target.GetLocalPath(), src)
 	)
 
+	if l.graph != nil {
+		l.recordSource(path)
+		return nil
+	}
+
 	files, err = l.expandCopyFiles(path, mustExist)
 	if err != nil {
 		return addErrorSrc(err, cmd.SourceLocation)
@@ -299,7 +442,7 @@ func (l *loader) handleCopySrc(ctx context.Context, cmd earthfile.Command, src s
 	sort.Strings(files)
 
 	for _, file := range files {
-		err = l.hasher.HashFile(ctx, file)
+		err = l.hashFile(ctx, file)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) && !mustExist {
 				continue
@@ -428,6 +571,10 @@ func (l *loader) handleCommand(ctx context.Context, cmd earthfile.Command) error
 		return l.handleBuild(ctx, cmd)
 	case earthfile.CmdCopy:
 		return l.handleCopy(ctx, cmd)
+	case earthfile.CmdAdd:
+		return l.handleAdd(ctx, cmd)
+	case earthfile.CmdRun:
+		return l.handleRun(ctx, cmd)
 	case earthfile.CmdArg:
 		return l.handleArg(cmd, false)
 	case earthfile.CmdLet:
@@ -438,6 +585,8 @@ func (l *loader) handleCommand(ctx context.Context, cmd earthfile.Command) error
 		return l.handleFromDockerfile(ctx, cmd)
 	case earthfile.CmdImport:
 		return l.handleImport(cmd, false)
+	case earthfile.CmdDo:
+		return l.handleDo(ctx, cmd)
 	default:
 		// By default, no special handling is required. The raw command has been
 		// hashed above and all argument values have been hashed independently.
@@ -506,6 +655,7 @@ func (l *loader) handleArg(cmd earthfile.Command, isBase bool) error {
 	}
 
 	l.hasher.HashString(fmt.Sprintf("ARG %s=%s", key, expanded))
+	l.record(InputArg, key, expanded)
 
 	if opts.Global {
 		declOpts = append(declOpts, variables.AsGlobal())
@@ -542,6 +692,7 @@ func (l *loader) handleLet(cmd earthfile.Command) error {
 	}
 
 	l.hasher.HashString(fmt.Sprintf("LET %s=%s", key, val))
+	l.record(InputVar, key, val)
 
 	_, _, err = l.varCollection.DeclareVar(key, variables.WithValue(val))
 	if err != nil {
@@ -574,6 +725,7 @@ func (l *loader) handleSet(cmd earthfile.Command) error {
 	}
 
 	l.hasher.HashString(fmt.Sprintf("SET %s=%s", key, val))
+	l.record(InputVar, key, val)
 
 	err = l.varCollection.UpdateVar(key, val, nil)
 	if err != nil {
@@ -620,7 +772,7 @@ func (l *loader) handleWithDocker(ctx context.Context, cmd earthfile.Command) er
 			return wrapError(err, cmd.SourceLocation, "failed to parse --load value")
 		}
 
-		err = l.loadTargetFromString(ctx, target, extraArgs, false, cmd.SourceLocation)
+		err = l.loadTargetFromString(ctx, target, extraArgs, false, cmd.SourceLocation, EdgeLoad, "")
 		if err != nil {
 			return err
 		}
@@ -855,6 +1007,7 @@ func (l *loader) handleFor(ctx context.Context, forStmt earthfile.ForStatement)
 
 	for _, val := range vals {
 		l.hasher.HashString(fmt.Sprintf("FOR %s=%s", name, val))
+		l.record(InputVar, name, val)
 		l.varCollection.SetArg(name, val)
 
 		err := l.loadBlock(ctx, forStmt.Body)
@@ -991,6 +1144,9 @@ func (l *loader) forTarget(target domain.Target, args []string, passArgs bool) (
 		target:         target,
 		visited:        visited,
 		hasher:         hasher.New(),
+		manifest:       l.manifest,
+		graph:          l.graph,
+		depth:          l.depth + 1,
 		ci:             l.ci,
 		builtinArgs:    l.builtinArgs,
 		overridingVars: overriding,
@@ -1010,6 +1166,7 @@ func (l *loader) forTarget(target domain.Target, args []string, passArgs bool) (
 
 func (l *loader) loadTargetFromString(
 	ctx context.Context, targetName string, args []string, passArgs bool, srcLoc *earthfile.SourceLocation,
+	kind EdgeKind, artifact string,
 ) error {
 	targetName, err := l.expandArgs(targetName)
 	if err != nil {
@@ -1024,6 +1181,11 @@ func (l *loader) loadTargetFromString(
 	// If the target name contains a variable that hasn't been expanded, we
 	// won't be able to explore the rest of the graph and generate a valid hash.
 	if containsShellExpr(targetName) {
+		if l.graph != nil {
+			l.recordDynamic(kind, targetName)
+			return nil
+		}
+
 		return newError(srcLoc, "dynamic target %q cannot be resolved", targetName)
 	}
 
@@ -1033,8 +1195,15 @@ func (l *loader) loadTargetFromString(
 	}
 
 	if target.IsRemote() {
+		if l.graph != nil {
+			l.recordDependency(kind, target, artifact, args)
+			return nil
+		}
+
 		if supportedRemoteTarget(target) {
 			l.hasher.HashString(target.StringCanonical())
+			l.record(InputRemoteTarget, target.StringCanonical(), "")
+
 			return nil
 		}
 
@@ -1057,12 +1226,137 @@ func (l *loader) loadTargetFromString(
 		return wrapError(err, srcLoc, "failed to create loader for target %q", targetName)
 	}
 
+	if !l.recordDependency(kind, target, artifact, newLoader.buildArgs()) {
+		return nil
+	}
+
 	hash, err := newLoader.load(ctx)
 	if err != nil {
 		return err
 	}
 
 	l.hasher.HashBytes(hash)
+	l.record(InputTarget, newLoader.targetRef(), hex.EncodeToString(hash))
+
+	return nil
+}
+
+// handleDo walks the function of a DO command. It is only done when building
+// a graph: auto-skip hashes the DO command itself.
+func (l *loader) handleDo(ctx context.Context, cmd earthfile.Command) error {
+	if l.graph == nil {
+		return nil
+	}
+
+	var opts cmdopts.Do
+
+	args, err := flagutil.ParseArgsCleaned(string(earthfile.CmdDo), &opts, flagutil.GetArgsCopy(cmd))
+	if err != nil {
+		return wrapError(err, cmd.SourceLocation, "failed to parse DO args")
+	}
+
+	if len(args) < 1 {
+		return newError(cmd.SourceLocation, "missing DO arg")
+	}
+
+	commandName, err := l.expandArgs(args[0])
+	if err != nil {
+		return wrapError(err, cmd.SourceLocation, "failed to expand args")
+	}
+
+	if containsShellExpr(commandName) {
+		l.recordDynamic(EdgeDo, commandName)
+		return nil
+	}
+
+	args, err = l.expandArgsSlice(args[1:])
+	if err != nil {
+		return wrapError(err, cmd.SourceLocation, "failed to expand args")
+	}
+
+	command, err := l.derefedCommand(commandName)
+	if err != nil {
+		return addErrorSrc(err, cmd.SourceLocation)
+	}
+
+	if command.IsRemote() {
+		l.recordDependency(EdgeDo, command, "", args)
+		return nil
+	}
+
+	if _, exists := l.visited[command.String()]; exists {
+		return newError(cmd.SourceLocation, "circular dependency detected; %s already called", command.String())
+	}
+
+	base := domain.Target{
+		GitURL:    command.GitURL,
+		Tag:       command.Tag,
+		LocalPath: command.LocalPath,
+		Target:    earthfile.TargetBase,
+	}
+
+	newLoader, err := l.forTarget(base, args, opts.PassArgs)
+	if err != nil {
+		return wrapError(err, cmd.SourceLocation, "failed to create loader for function %q", commandName)
+	}
+
+	newLoader.function = command.Command
+	newLoader.visited[command.String()] = struct{}{}
+
+	if !l.recordDependency(EdgeDo, command, "", newLoader.buildArgs()) {
+		return nil
+	}
+
+	_, err = newLoader.load(ctx)
+
+	return err
+}
+
+// buildArgs returns the build args the target is loaded with, as name=value.
+func (l *loader) buildArgs() []string {
+	if l.overridingVars == nil {
+		return nil
+	}
+
+	return l.overridingVars.BuildArgs()
+}
+
+// record adds an input of the target to the manifest, if one is being
+// recorded.
+func (l *loader) record(kind InputKind, name, value string) {
+	if l.manifest == nil {
+		return
+	}
+
+	l.manifest.add(Input{Kind: kind, Target: l.target.StringCanonical(), Name: name, Value: value})
+}
+
+// targetRef returns the canonical name of the target along with the args it
+// is loaded with, as in a BUILD command.
+func (l *loader) targetRef() string {
+	ref := l.target.StringCanonical()
+
+	if l.overridingVars != nil {
+		for _, val := range l.overridingVars.BuildArgs() {
+			ref += " --" + val
+		}
+	}
+
+	return ref
+}
+
+// hashFile hashes a file, recording its digest in the manifest.
+func (l *loader) hashFile(ctx context.Context, file string) error {
+	if l.manifest == nil {
+		return l.hasher.HashFile(ctx, file)
+	}
+
+	digest, err := l.hasher.HashFileDigest(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	l.record(InputFile, filepath.ToSlash(file), "sha256:"+hex.EncodeToString(digest))
 
 	return nil
 }
@@ -1071,6 +1365,10 @@ func (l *loader) targetCacheKey() string {
 	h := hasher.New()
 	h.HashString(l.target.StringCanonical())
 
+	if l.function != "" {
+		h.HashString("FUNCTION " + l.function)
+	}
+
 	if l.overridingVars != nil {
 		for _, val := range l.overridingVars.BuildArgs() {
 			h.HashString("VAR " + val)
@@ -1086,6 +1384,7 @@ func (l *loader) load(ctx context.Context) ([]byte, error) {
 	}
 
 	l.stats.TargetsVisited++
+	l.recordWalked()
 
 	// We can avoid reprocessing this target if it's already been hashed. This
 	// hash key is computed using the canonical target name & the provided
@@ -1120,6 +1419,9 @@ func (l *loader) load(ctx context.Context) ([]byte, error) {
 	if l.overridingVars != nil {
 		for _, val := range l.overridingVars.BuildArgs() {
 			l.hasher.HashString("VAR " + val)
+
+			name, value, _ := strings.Cut(val, "=")
+			l.record(InputArg, name, value)
 		}
 	}
 
@@ -1137,7 +1439,8 @@ func (l *loader) load(ctx context.Context) ([]byte, error) {
 				err = l.handleImport(*stmt.Command, true)
 			case stmt.Command.Name == earthfile.CmdArg:
 				err = l.handleArg(*stmt.Command, true)
-			case stmt.Command.Name == earthfile.CmdFrom:
+			case stmt.Command.Name == earthfile.CmdFrom && l.function == "":
+				// Functions run in the environment of their caller.
 				err = l.handleFrom(ctx, *stmt.Command)
 			}
 
@@ -1147,21 +1450,15 @@ func (l *loader) load(ctx context.Context) ([]byte, error) {
 		}
 	}
 
-	isBase := l.target.Target == earthfile.TargetBase
+	isBase := l.target.Target == earthfile.TargetBase && l.function == ""
 
 	// Since "base" is always processed above, there's not need to revisit it here.
 	if !isBase {
 		var block earthfile.Block
 
-		for _, t := range ef.Targets {
-			if t.Name == l.target.Target {
-				block = t.Recipe
-				break
-			}
-		}
-
-		if block == nil {
-			return nil, fmt.Errorf("target %q not found", l.target.Target)
+		block, err = l.recipe(ef)
+		if err != nil {
+			return nil, err
 		}
 
 		err = l.loadBlock(ctx, block)
@@ -1180,3 +1477,24 @@ func (l *loader) load(ctx context.Context) ([]byte, error) {
 
 	return v, nil
 }
+
+// recipe returns the recipe of the target or function being loaded.
+func (l *loader) recipe(ef earthfile.Tree) (earthfile.Block, error) {
+	if l.function != "" {
+		for _, f := range ef.Functions {
+			if f.Name == l.function {
+				return f.Recipe, nil
+			}
+		}
+
+		return nil, fmt.Errorf("function %q not found", l.function)
+	}
+
+	for _, t := range ef.Targets {
+		if t.Name == l.target.Target {
+			return t.Recipe, nil
+		}
+	}
+
+	return nil, fmt.Errorf("target %q not found", l.target.Target)
+}
@@ -1,12 +1,15 @@
 package inputgraph
 
 import (
+	"strings"
+
 	"github.com/EarthBuild/earthbuild/internal/earthfile"
 )
 
 func (l *loader) hashIfStatement(s earthfile.IfStatement) {
 	l.hasher.HashString("IF")
 	l.hasher.HashJSONMarshalled(s.Expression)
+	l.recordStatement("IF", s.Expression)
 	l.hasher.HashBool(s.ExecMode)
 	l.hasher.HashInt(len(s.IfBody))
 	l.hasher.HashInt(len(s.ElseIf))
@@ -19,6 +22,7 @@ func (l *loader) hashIfStatement(s earthfile.IfStatement) {
 func (l *loader) hashElseIf(e earthfile.ElseIfStatement) {
 	l.hasher.HashString("ELSE IF")
 	l.hasher.HashJSONMarshalled(e.Expression)
+	l.recordStatement("ELSE IF", e.Expression)
 	l.hasher.HashBool(e.ExecMode)
 	l.hasher.HashInt(len(e.Body))
 }
@@ -27,24 +31,48 @@ func (l *loader) hashWaitStatement(w earthfile.WaitStatement) {
 	l.hasher.HashString("WAIT")
 	l.hasher.HashInt(len(w.Body))
 	l.hasher.HashJSONMarshalled(w.Args)
+	l.recordStatement("WAIT", w.Args)
 }
 
 func (l *loader) hashVersion(v earthfile.Version) {
 	l.hasher.HashString("VERSION")
 	l.hasher.HashJSONMarshalled(v.Args)
+	l.recordStatement("VERSION", v.Args)
 }
 
 func (l *loader) hashCommand(c earthfile.Command) {
 	l.hasher.HashString(string(c.Name))
 	l.hasher.HashJSONMarshalled(c.Args)
 	l.hasher.HashBool(c.ExecMode)
+	l.recordStatement(string(c.Name), c.Args)
 }
 
 func (l *loader) hashForStatement(f earthfile.ForStatement) {
 	l.hasher.HashString("FOR")
 	l.hasher.HashJSONMarshalled(f.Args)
+	l.recordStatement("FOR", f.Args)
 }
 
 func (l *loader) hashTryStatement() {
 	l.hasher.HashString("TRY")
 }
+
+// recordStatement records a statement in the manifest, with its unexpanded
+// args. It is recorded as a whole, as a statement has no identity that would
+// survive an edit.
+func (l *loader) recordStatement(name string, args []string) {
+	if l.manifest == nil {
+		return
+	}
+
+	text := strings.Join(args, " ")
+
+	// The lexer splits declarations as in "ARG NAME = value"; print them as
+	// written.
+	switch earthfile.Cmd(name) { //nolint:exhaustive // Only declarations are joined.
+	case earthfile.CmdArg, earthfile.CmdEnv, earthfile.CmdLet, earthfile.CmdSet:
+		text = strings.Replace(text, " = ", "=", 1)
+	}
+
+	l.record(InputCommand, strings.TrimSpace(name+" "+text), "")
+}
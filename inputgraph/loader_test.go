@@ -1,12 +1,53 @@
 package inputgraph
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/EarthBuild/earthbuild/conslogging"
+	"github.com/EarthBuild/earthbuild/domain"
 	"github.com/stretchr/testify/require"
 )
 
+func TestLoaderRunService(t *testing.T) {
+	t.Parallel()
+
+	r := require.New(t)
+	ctx := t.Context()
+	cons := conslogging.New(io.Discard, &sync.Mutex{}, 0, conslogging.Info, false)
+
+	dir := t.TempDir()
+	r.NoError(os.WriteFile(filepath.Join(dir, "Earthfile"), []byte(`VERSION --run-service 0.8
+FROM alpine
+test:
+    ARG API=+api
+    RUN --service name=db,image=postgres:16 --service name=api,target=$API,port=8080 ./test.sh
+api:
+    COPY api.txt ./
+    ENTRYPOINT ["cat", "api.txt"]
+`), 0o600))
+	r.NoError(os.WriteFile(filepath.Join(dir, "api.txt"), []byte("a"), 0o600))
+
+	target := domain.Target{LocalPath: dir, Target: "test"}
+
+	first, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target})
+	r.NoError(err)
+
+	r.NoError(os.WriteFile(filepath.Join(dir, "api.txt"), []byte("b"), 0o600))
+
+	second, _, err := HashTarget(ctx, HashOpt{Log: cons, Target: target})
+	r.NoError(err)
+	r.NotEqual(first, second, "a change to the target of a service must change the hash")
+
+	g, err := BuildGraph(ctx, GraphOpt{Log: cons, Target: target})
+	r.NoError(err)
+	r.Equal([]Edge{{From: g.Root, To: strings.TrimSuffix(g.Root, "test") + "api", Kind: EdgeService}}, g.Edges)
+}
+
 func Test_containsShellExpr(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,212 @@
+package inputgraph
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// InputKind is the kind of an input recorded in a Manifest.
+type InputKind string
+
+const (
+	// InputBuiltin is the set of built-in args, such as the earth version.
+	InputBuiltin InputKind = "builtin"
+	// InputArg is an ARG, or a build arg passed to a target.
+	InputArg InputKind = "arg"
+	// InputVar is a LET, SET or FOR variable.
+	InputVar InputKind = "var"
+	// InputFile is a file copied from the build context, with the SHA-256
+	// digest of its contents as value.
+	InputFile InputKind = "file"
+	// InputCommand is a command or statement of a recipe.
+	InputCommand InputKind = "command"
+	// InputTarget is a local target the target depends on, with its hash as
+	// value.
+	InputTarget InputKind = "target"
+	// InputRemoteTarget is a remote target, pinned by a Git SHA or tag.
+	InputRemoteTarget InputKind = "remote-target"
+	// InputURL is a URL downloaded by ADD, with the checksum pinning its
+	// contents as value.
+	InputURL InputKind = "url"
+)
+
+// Input is a single input which went into a hash.
+type Input struct {
+	// Kind is the kind of the input.
+	Kind InputKind `json:"kind"`
+	// Target is the canonical name of the target the input belongs to.
+	Target string `json:"target"`
+	// Name identifies the input within the target: a file path, an arg name,
+	// a command or a target reference.
+	Name string `json:"name"`
+	// Value is the value of the input, if it has one, such as an arg value or
+	// a file digest.
+	Value string `json:"value,omitempty"`
+}
+
+func (in Input) key() string {
+	return string(in.Kind) + "\x00" + in.Target + "\x00" + in.Name
+}
+
+func compareInputs(a, b Input) int {
+	return cmp.Or(
+		cmp.Compare(a.Target, b.Target),
+		cmp.Compare(a.Kind, b.Kind),
+		cmp.Compare(a.Name, b.Name),
+		cmp.Compare(a.Value, b.Value),
+	)
+}
+
+// Manifest lists the inputs which went into the hash of a target. Set
+// HashOpt.Manifest to record one.
+type Manifest struct {
+	Inputs []Input `json:"inputs"`
+}
+
+func (m *Manifest) add(in Input) {
+	if m == nil {
+		return
+	}
+
+	m.Inputs = append(m.Inputs, in)
+}
+
+// ChangeKind is the kind of a Change.
+type ChangeKind string
+
+const (
+	// ChangeAdded is an input which is new in the current manifest.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is an input which is gone from the current manifest.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified is an input whose value changed.
+	ChangeModified ChangeKind = "changed"
+)
+
+// Change is a difference between two manifests.
+type Change struct {
+	// Old is the input in the previous manifest; zero when it was added.
+	Old Input
+	// New is the input in the current manifest; zero when it was removed.
+	New Input
+	// Kind is the kind of change.
+	Kind ChangeKind
+}
+
+// Input returns the input the change is about.
+func (c Change) Input() Input {
+	if c.Kind == ChangeRemoved {
+		return c.Old
+	}
+
+	return c.New
+}
+
+// String describes the change in a single line.
+func (c Change) String() string {
+	in := c.Input()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s %s", c.Kind, in.Kind, in.Name)
+
+	if in.Target != "" {
+		fmt.Fprintf(&b, " in %s", in.Target)
+	}
+
+	switch c.Kind {
+	case ChangeModified:
+		fmt.Fprintf(&b, ": %s -> %s", quoteValue(c.Old.Value), quoteValue(c.New.Value))
+	case ChangeAdded, ChangeRemoved:
+		if in.Value != "" {
+			fmt.Fprintf(&b, " (%s)", quoteValue(in.Value))
+		}
+	}
+
+	return b.String()
+}
+
+func quoteValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+
+	return v
+}
+
+// Diff returns the differences between a previous and the current manifest.
+// Changes to the hash of a dependency are listed after the other changes, as
+// they are a consequence of them.
+func Diff(prev, cur *Manifest) []Change {
+	// Inputs such as commands may repeat, so compare them as multisets.
+	count := map[Input]int{}
+
+	for _, in := range prev.Inputs {
+		count[in]++
+	}
+
+	var added []Input
+
+	for _, in := range cur.Inputs {
+		if count[in] > 0 {
+			count[in]--
+			continue
+		}
+
+		added = append(added, in)
+	}
+
+	var removed []Input
+
+	for _, in := range prev.Inputs {
+		if count[in] > 0 {
+			count[in]--
+			removed = append(removed, in)
+		}
+	}
+
+	// Pair up the inputs with the same identity as modifications.
+	removedByKey := map[string][]Input{}
+	for _, in := range removed {
+		removedByKey[in.key()] = append(removedByKey[in.key()], in)
+	}
+
+	var changes []Change
+
+	for _, in := range added {
+		if olds := removedByKey[in.key()]; len(olds) > 0 && in.Kind != InputCommand {
+			changes = append(changes, Change{Kind: ChangeModified, Old: olds[0], New: in})
+			removedByKey[in.key()] = olds[1:]
+
+			continue
+		}
+
+		changes = append(changes, Change{Kind: ChangeAdded, New: in})
+	}
+
+	for _, olds := range removedByKey {
+		for _, in := range olds {
+			changes = append(changes, Change{Kind: ChangeRemoved, Old: in})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		return cmp.Or(
+			cmp.Compare(boolInt(a.Input().Kind == InputTarget), boolInt(b.Input().Kind == InputTarget)),
+			compareInputs(a.Input(), b.Input()),
+			cmp.Compare(a.Kind, b.Kind),
+		)
+	})
+
+	return changes
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,42 @@
+package earthfile
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Comment is a comment found in the Earthfile source.
+type Comment struct {
+	// Text is the comment including the leading '#'.
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// EndOfLine is true when the comment follows other tokens on its line.
+	EndOfLine bool `json:"endOfLine,omitempty"`
+}
+
+// Comments returns the comments in the Earthfile text, in source order.
+// Comments inside a line continuation are not reported.
+func Comments(name, text string) ([]Comment, error) {
+	var comments []Comment
+
+	l := lex(name, text)
+
+	for {
+		it := l.nextItem()
+
+		switch it.Typ { //nolint:exhaustive // Only comments are of interest.
+		case itemEOF:
+			return comments, nil
+		case itemError:
+			return nil, fmt.Errorf("%s line %d:%d: %w", name, it.Line, it.Col, errors.New(it.Val))
+		case itemComment, itemEOLComment:
+			comments = append(comments, Comment{
+				Text:      it.Val,
+				Line:      it.Line,
+				Column:    it.Col,
+				EndOfLine: it.Typ == itemEOLComment,
+			})
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package earthfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComments(t *testing.T) {
+	t.Parallel()
+
+	input := `VERSION 0.8 # version
+# top level
+build:
+    # doc
+    RUN echo hi \
+        there # end of command
+`
+
+	comments, err := Comments("Earthfile", input)
+	require.NoError(t, err)
+	require.Equal(t, []Comment{
+		{Text: "# version", Line: 1, Column: 13, EndOfLine: true},
+		{Text: "# top level", Line: 2, Column: 1},
+		{Text: "# doc", Line: 4, Column: 5},
+		{Text: "# end of command", Line: 6, Column: 15, EndOfLine: true},
+	}, comments)
+}
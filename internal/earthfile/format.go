@@ -0,0 +1,903 @@
+package earthfile
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// formatIndent is the indentation used for each nesting level of a formatted
+// Earthfile. Continuation lines are indented one additional level.
+const formatIndent = "    "
+
+// errFormatChangesAST is returned when the formatted output would not parse
+// back into the same AST as the input. It indicates a formatter bug and is
+// never expected in practice; it exists so that a bug cannot silently change
+// the meaning of a user's Earthfile.
+var errFormatChangesAST = errors.New("formatting would change the meaning of the Earthfile")
+
+// Format re-emits the Earthfile text in the canonical layout: four-space
+// indentation per block, one space between arguments, continuation lines
+// indented one level deeper than their command, at most one blank line
+// between statements, and exactly one blank line around targets and
+// functions. Comments (including doc comments) are preserved, as are the
+// points at which the author chose to break long commands with a line
+// continuation.
+//
+// Formatting is idempotent, and the returned text always parses into the same
+// AST as the input.
+func Format(name, text string) (string, error) {
+	ef, err := Parse(name, text, WithSourceMap())
+	if err != nil {
+		return "", err
+	}
+
+	f := newFormatter(text)
+
+	err = f.earthfile(ef)
+	if err != nil {
+		return "", fmt.Errorf("failed to format %s: %w", name, err)
+	}
+
+	out := f.out.String()
+
+	err = sameAST(name, text, out)
+	if err != nil {
+		return "", fmt.Errorf("failed to format %s: %w", name, err)
+	}
+
+	return out, nil
+}
+
+// sameAST verifies that both texts parse into identical ASTs, ignoring source
+// locations.
+func sameAST(name, before, after string) error {
+	want, err := Parse(name, before)
+	if err != nil {
+		return err
+	}
+
+	got, err := Parse(name, after)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errFormatChangesAST, err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return errFormatChangesAST
+	}
+
+	return nil
+}
+
+// fmtLine is a single logical line of the source (a command, a block keyword
+// or a target header), possibly spanning several physical lines through line
+// continuations.
+type fmtLine struct {
+	eol   *item
+	atoms []item
+	kw    item
+}
+
+// formatter holds the state needed to re-emit a parsed Earthfile. Comments
+// are not part of the AST, so the formatter re-lexes the source and tracks
+// which comments have been written as it walks the AST in source order.
+type formatter struct {
+	byLoc      map[[2]int]int
+	text       string
+	lineStarts []int
+	items      []item
+	comments   []item
+	out        strings.Builder
+	cursor     int
+	next       int
+	eolTotal   int
+	eolUsed    int
+	lastLine   int
+	blockStart bool
+	forceBlank bool
+}
+
+func newFormatter(text string) *formatter {
+	f := &formatter{
+		text:       text,
+		lineStarts: []int{0},
+		byLoc:      map[[2]int]int{},
+		blockStart: true,
+	}
+
+	for i := range len(text) {
+		if text[i] == '\n' {
+			f.lineStarts = append(f.lineStarts, i+1)
+		}
+	}
+
+	l := lex("", text)
+
+	for {
+		it := l.nextItem()
+		if it.Typ == itemEOF || it.Typ == itemError {
+			f.items = append(f.items, it)
+			break
+		}
+
+		switch it.Typ { //nolint:exhaustive // Only comments and whitespace are treated specially.
+		case itemWS:
+			continue
+		case itemComment:
+			f.comments = append(f.comments, it)
+			continue
+		case itemEOLComment:
+			f.eolTotal++
+		}
+
+		f.byLoc[[2]int{it.Line, it.Col}] = len(f.items)
+		f.items = append(f.items, it)
+	}
+
+	return f
+}
+
+// lineOf returns the 1-based source line containing the byte offset.
+func (f *formatter) lineOf(offset int) int {
+	return sort.Search(len(f.lineStarts), func(i int) bool {
+		return f.lineStarts[i] > offset
+	})
+}
+
+// rawLine returns the text of the 1-based source line, without its line ending.
+func (f *formatter) rawLine(line int) string {
+	start := f.lineStarts[line-1]
+
+	end := len(f.text)
+	if line < len(f.lineStarts) {
+		end = f.lineStarts[line] - 1
+	}
+
+	return strings.TrimRight(f.text[start:end], "\r")
+}
+
+func (f *formatter) endLine(it item) int {
+	return f.lineOf(int(it.pos) + len(it.Val) - 1)
+}
+
+// hasBlank reports whether any line strictly between from and to is empty.
+func (f *formatter) hasBlank(from, to int) bool {
+	for l := from + 1; l < to; l++ {
+		if strings.TrimSpace(f.rawLine(l)) == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// indexOf returns the index of the token at the start of the source location.
+func (f *formatter) indexOf(sl *SourceLocation) (int, error) {
+	if sl == nil {
+		return 0, errors.New("missing source location")
+	}
+
+	idx, ok := f.byLoc[[2]int{sl.StartLine, sl.StartColumn}]
+	if !ok {
+		return 0, fmt.Errorf("no token found at line %d:%d", sl.StartLine, sl.StartColumn)
+	}
+
+	return idx, nil
+}
+
+// expect returns the index of the next significant token after the cursor,
+// which must be one of the given types.
+func (f *formatter) expect(typs ...itemType) (int, error) {
+	for i := f.cursor + 1; i < len(f.items); i++ {
+		switch f.items[i].Typ { //nolint:exhaustive // Layout tokens are skipped, everything else is checked.
+		case itemNL, itemIndent, itemDedent:
+			continue
+		}
+
+		if !slices.Contains(typs, f.items[i].Typ) {
+			return 0, fmt.Errorf("unexpected token %s at line %d", f.items[i], f.items[i].Line)
+		}
+
+		return i, nil
+	}
+
+	return 0, errors.New("unexpected end of file")
+}
+
+// line collects the tokens of the logical line starting at the given index and
+// advances the cursor past it.
+func (f *formatter) line(idx int) fmtLine {
+	ln := fmtLine{kw: f.items[idx]}
+	f.cursor = idx
+
+	for i := idx + 1; i < len(f.items); i++ {
+		it := f.items[i]
+
+		switch it.Typ { //nolint:exhaustive // Everything up to the line terminator belongs to the line.
+		case itemNL, itemEOF, itemDedent, itemIndent, itemError:
+			return ln
+		case itemEOLComment:
+			ln.eol = &f.items[i]
+			f.cursor = i
+
+			return ln
+		}
+
+		ln.atoms = append(ln.atoms, it)
+		f.cursor = i
+	}
+
+	return ln
+}
+
+// lastLineOf returns the last physical source line occupied by the logical line.
+func (f *formatter) lastLineOf(ln fmtLine) int {
+	if len(ln.atoms) == 0 {
+		return f.endLine(ln.kw)
+	}
+
+	return f.endLine(ln.atoms[len(ln.atoms)-1])
+}
+
+// continuationComments returns the comments hidden inside the line
+// continuation between prev and the token starting on line next: the comment
+// trailing the backslash itself (if any), followed by full-line comments.
+func (f *formatter) continuationComments(prev item, next int) (string, []string) {
+	var (
+		eol      string
+		comments []string
+	)
+
+	prevLine := f.endLine(prev)
+	rest := f.rawLine(prevLine)[int(prev.pos)+len(prev.Val)-f.lineStarts[prevLine-1]:]
+
+	if after, ok := strings.CutPrefix(strings.TrimSpace(rest), `\`); ok {
+		after = strings.TrimSpace(after)
+		if strings.HasPrefix(after, "#") {
+			eol = after
+		}
+	}
+
+	for l := prevLine + 1; l < next; l++ {
+		raw := strings.TrimSpace(f.rawLine(l))
+		if strings.HasPrefix(raw, "#") {
+			comments = append(comments, raw)
+		}
+	}
+
+	return eol, comments
+}
+
+// write emits a single output line and records the source line it came from.
+func (f *formatter) write(indent, text string, srcLine int) {
+	f.out.WriteString(indent)
+	f.out.WriteString(text)
+	f.out.WriteByte('\n')
+
+	f.lastLine = srcLine
+	f.blockStart = false
+}
+
+// gap emits a blank line before the element starting at srcLine if the source
+// had one there, or if one is forced (e.g. around targets). Blank lines are
+// never emitted at the start of a block.
+func (f *formatter) gap(srcLine int) {
+	switch {
+	case f.out.Len() == 0:
+	case f.forceBlank:
+		f.out.WriteByte('\n')
+	case !f.blockStart && f.hasBlank(f.lastLine, srcLine):
+		f.out.WriteByte('\n')
+	}
+
+	f.forceBlank = false
+}
+
+// flushComments writes all standalone comments located before the given
+// offset. If tail is set, only comments that were indented in the source are
+// written, which keeps trailing comments inside the target they belong to.
+func (f *formatter) flushComments(before pos, indent string, tail bool) {
+	for f.next < len(f.comments) {
+		c := f.comments[f.next]
+		if c.pos >= before || (tail && c.Col == 1) {
+			return
+		}
+
+		f.gap(c.Line)
+		f.write(indent, c.Val, c.Line)
+		f.next++
+	}
+}
+
+// writeLine emits a logical line made of a head (the keyword) and its
+// arguments. Arguments that started a new physical line in the source start a
+// new continuation line in the output, preceded by any comments that sat
+// inside the continuation. atoms holds the source tokens of args; when the two
+// do not line up one to one, the line is emitted without continuations.
+//
+// Continuation lines are indented one level deeper than the command. Any
+// additional indentation the author used to nest continuation lines relative
+// to the first one (e.g. the body of a shell "if") is kept.
+func (f *formatter) writeLine(indent, head string, args []string, atoms []item, ln fmtLine) {
+	var (
+		breaks    = len(args) == len(atoms)
+		cur       = head
+		curIndent = indent
+		prev      = ln.kw
+		base      = -1
+	)
+
+	contIndent := func(line int) string {
+		width := indentWidth(f.rawLine(line))
+		if base < 0 {
+			base = width
+		}
+
+		return indent + formatIndent + strings.Repeat(" ", max(width-base, 0))
+	}
+
+	for i, arg := range args {
+		if !breaks {
+			cur = joinArg(cur, arg)
+			continue
+		}
+
+		if atoms[i].Line > f.endLine(prev) {
+			eol, comments := f.continuationComments(prev, atoms[i].Line)
+
+			cur += ` \`
+			if eol != "" {
+				cur += " " + eol
+			}
+
+			f.write(curIndent, cur, f.endLine(prev))
+
+			curIndent = contIndent(atoms[i].Line)
+			for _, c := range comments {
+				f.write(curIndent, c, f.endLine(prev))
+			}
+
+			cur = ""
+		}
+
+		// The parser resolves continuations inside an argument, but the
+		// author's line break (and any comment in it) is worth keeping.
+		if arg != atoms[i].Val && hasContinuation(atoms[i].Val) {
+			arg = f.reflowArg(atoms[i], contIndent)
+		}
+
+		cur = joinArg(cur, arg)
+		prev = atoms[i]
+	}
+
+	if ln.eol != nil {
+		cur += " " + ln.eol.Val
+		f.eolUsed++
+	}
+
+	f.write(curIndent, cur, f.lastLineOf(ln))
+}
+
+// reflowArg re-indents the line continuations inside a single argument.
+func (f *formatter) reflowArg(atom item, contIndent func(int) string) string {
+	var (
+		sb  strings.Builder
+		raw = atom.Val
+	)
+
+	for i := 0; i < len(raw); i++ {
+		n := 0
+		if raw[i] == '\\' {
+			n = matchLineContinuationFrom(raw, i)
+		}
+
+		if n == 0 {
+			sb.WriteByte(raw[i])
+
+			if raw[i] == '\\' && i+1 < len(raw) {
+				i++
+				sb.WriteByte(raw[i])
+			}
+
+			continue
+		}
+
+		lines := strings.Split(strings.ReplaceAll(raw[i+1:i+n], "\r", ""), "\n")
+		nextIndent := contIndent(f.lineOf(int(atom.pos) + i + n))
+
+		sb.WriteByte('\\')
+
+		if c := strings.TrimSpace(lines[0]); c != "" {
+			sb.WriteString(" " + c)
+		}
+
+		for _, l := range lines[1:] {
+			if c := strings.TrimSpace(l); c != "" {
+				sb.WriteString("\n" + nextIndent + c)
+			}
+		}
+
+		sb.WriteString("\n" + nextIndent)
+
+		i += n - 1
+	}
+
+	return sb.String()
+}
+
+// indentWidth returns the width of the leading whitespace of a line, counting
+// tabs as one indentation level.
+func indentWidth(line string) int {
+	width := 0
+
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += len(formatIndent)
+		default:
+			return width
+		}
+	}
+
+	return width
+}
+
+func joinArg(cur, arg string) string {
+	if cur == "" {
+		return arg
+	}
+
+	return cur + " " + arg
+}
+
+// hasContinuation reports whether the raw token contains a line continuation.
+func hasContinuation(raw string) bool {
+	if !strings.Contains(raw, "\n") {
+		return false
+	}
+
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+
+		if matchLineContinuationFrom(raw, i) > 0 {
+			return true
+		}
+
+		i++
+	}
+
+	return false
+}
+
+// topLevel is a top-level element of an Earthfile, in source order.
+type topLevel struct {
+	version *Version
+	stmt    *Statement
+	loc     *SourceLocation
+	name    string
+	recipe  Block
+	pos     pos
+}
+
+func (f *formatter) earthfile(ef Tree) error {
+	var elems []topLevel
+
+	if ef.Version != nil {
+		elems = append(elems, topLevel{version: ef.Version, loc: ef.Version.SourceLocation})
+	}
+
+	for i := range ef.BaseRecipe {
+		elems = append(elems, topLevel{stmt: &ef.BaseRecipe[i], loc: statementLocation(ef.BaseRecipe[i])})
+	}
+
+	for _, t := range ef.Targets {
+		elems = append(elems, topLevel{name: t.Name, recipe: t.Recipe, loc: t.SourceLocation})
+	}
+
+	for _, fn := range ef.Functions {
+		elems = append(elems, topLevel{name: fn.Name, recipe: fn.Recipe, loc: fn.SourceLocation})
+	}
+
+	for i := range elems {
+		idx, err := f.indexOf(elems[i].loc)
+		if err != nil {
+			return err
+		}
+
+		elems[i].pos = f.items[idx].pos
+	}
+
+	slices.SortFunc(elems, func(a, b topLevel) int {
+		return cmp.Compare(a.pos, b.pos)
+	})
+
+	for i, el := range elems {
+		next := pos(len(f.text))
+		if i+1 < len(elems) {
+			next = elems[i+1].pos
+		}
+
+		var err error
+
+		switch {
+		case el.version != nil:
+			err = f.version(el.version)
+		case el.stmt != nil:
+			err = f.statement(*el.stmt, "")
+		default:
+			err = f.target(el, next)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	f.flushComments(pos(len(f.text)), "", false)
+
+	if f.next < len(f.comments) || f.eolUsed != f.eolTotal {
+		return errors.New("unable to place all comments")
+	}
+
+	return nil
+}
+
+func (f *formatter) version(v *Version) error {
+	idx, err := f.indexOf(v.SourceLocation)
+	if err != nil {
+		return err
+	}
+
+	f.flushComments(f.items[idx].pos, "", false)
+	f.gap(f.items[idx].Line)
+
+	ln := f.line(idx)
+	f.writeLine("", string(CmdVersion), v.Args, ln.atoms, ln)
+	f.forceBlank = true
+
+	return nil
+}
+
+func (f *formatter) target(el topLevel, next pos) error {
+	idx, err := f.indexOf(el.loc)
+	if err != nil {
+		return err
+	}
+
+	// Targets and functions are always surrounded by blank lines. Comments
+	// directly above them (doc comments) stay attached.
+	f.forceBlank = f.out.Len() > 0
+	f.flushComments(f.items[idx].pos, "", false)
+	f.gap(f.items[idx].Line)
+
+	header := el.name
+	if !strings.HasSuffix(header, ":") {
+		header += ":"
+	}
+
+	ln := f.line(idx)
+	f.writeLine("", header, nil, nil, ln)
+
+	f.blockStart = true
+
+	err = f.block(el.recipe, formatIndent)
+	if err != nil {
+		return err
+	}
+
+	f.flushComments(next, formatIndent, true)
+	f.forceBlank = true
+
+	return nil
+}
+
+func (f *formatter) block(b Block, indent string) error {
+	for _, stmt := range b {
+		err := f.statement(stmt, indent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func statementLocation(s Statement) *SourceLocation {
+	switch {
+	case s.Command != nil:
+		return s.Command.SourceLocation
+	case s.With != nil:
+		return s.With.SourceLocation
+	case s.If != nil:
+		return s.If.SourceLocation
+	case s.Try != nil:
+		return s.Try.SourceLocation
+	case s.For != nil:
+		return s.For.SourceLocation
+	case s.Wait != nil:
+		return s.Wait.SourceLocation
+	}
+
+	return s.SourceLocation
+}
+
+func (f *formatter) statement(s Statement, indent string) error {
+	idx, err := f.indexOf(statementLocation(s))
+	if err != nil {
+		return err
+	}
+
+	f.flushComments(f.items[idx].pos, indent, false)
+	f.gap(f.items[idx].Line)
+
+	ln := f.line(idx)
+
+	switch {
+	case s.Command != nil:
+		return f.command(*s.Command, indent, ln)
+	case s.With != nil:
+		var atoms []item
+		if len(ln.atoms) > 0 {
+			atoms = ln.atoms[1:]
+		}
+
+		head := string(CmdWith) + " " + string(s.With.Command.Name)
+		f.writeLine(indent, head, s.With.Command.Args, atoms, ln)
+
+		return f.body(s.With.Body, indent, itemEnd)
+	case s.If != nil:
+		return f.ifStatement(*s.If, indent, ln)
+	case s.Try != nil:
+		return f.tryStatement(*s.Try, indent, ln)
+	case s.For != nil:
+		f.writeLine(indent, string(CmdFor), s.For.Args, ln.atoms, ln)
+		return f.body(s.For.Body, indent, itemEnd)
+	case s.Wait != nil:
+		f.writeRaw(indent, string(CmdWait), ln)
+		return f.body(s.Wait.Body, indent, itemEnd)
+	}
+
+	return errors.New("empty statement")
+}
+
+// writeRaw emits a keyword line whose arguments are not part of the AST,
+// keeping whatever arguments the source had.
+func (f *formatter) writeRaw(indent, head string, ln fmtLine) {
+	args := make([]string, len(ln.atoms))
+	for i, a := range ln.atoms {
+		args[i] = a.Val
+	}
+
+	f.writeLine(indent, head, args, ln.atoms, ln)
+}
+
+// bodyUntil writes a nested block and returns the index of the keyword that
+// closes it, which must be one of closers. Comments between the last
+// statement and the closing keyword stay inside the block.
+func (f *formatter) bodyUntil(b Block, indent string, closers ...itemType) (int, error) {
+	f.blockStart = true
+
+	err := f.block(b, indent+formatIndent)
+	if err != nil {
+		return 0, err
+	}
+
+	idx, err := f.expect(closers...)
+	if err != nil {
+		return 0, err
+	}
+
+	f.flushComments(f.items[idx].pos, indent+formatIndent, false)
+
+	return idx, nil
+}
+
+// body writes a nested block terminated by the given keyword.
+func (f *formatter) body(b Block, indent string, closer itemType) error {
+	idx, err := f.bodyUntil(b, indent, closer)
+	if err != nil {
+		return err
+	}
+
+	f.writeRaw(indent, f.items[idx].Val, f.line(idx))
+
+	return nil
+}
+
+func (f *formatter) ifStatement(s IfStatement, indent string, ln fmtLine) error {
+	f.writeExpr(indent, string(CmdIf), s.Expression, s.ExecMode, ln)
+
+	idx, err := f.bodyUntil(s.IfBody, indent, itemElseIf, itemElse, itemEnd)
+	if err != nil {
+		return err
+	}
+
+	for _, ei := range s.ElseIf {
+		if f.items[idx].Typ != itemElseIf {
+			return fmt.Errorf("expected ELSE IF at line %d", f.items[idx].Line)
+		}
+
+		f.writeExpr(indent, string(CmdElseIf), ei.Expression, ei.ExecMode, f.line(idx))
+
+		idx, err = f.bodyUntil(ei.Body, indent, itemElseIf, itemElse, itemEnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.ElseBody != nil {
+		f.writeRaw(indent, string(CmdElse), f.line(idx))
+
+		idx, err = f.bodyUntil(*s.ElseBody, indent, itemEnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.writeRaw(indent, string(CmdEnd), f.line(idx))
+
+	return nil
+}
+
+func (f *formatter) tryStatement(s TryStatement, indent string, ln fmtLine) error {
+	f.writeRaw(indent, string(CmdTry), ln)
+
+	idx, err := f.bodyUntil(s.TryBody, indent, itemCatch, itemFinally, itemEnd)
+	if err != nil {
+		return err
+	}
+
+	if s.CatchBody != nil {
+		f.writeRaw(indent, string(CmdCatch), f.line(idx))
+
+		idx, err = f.bodyUntil(*s.CatchBody, indent, itemFinally, itemEnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.FinallyBody != nil {
+		f.writeRaw(indent, string(CmdFinally), f.line(idx))
+
+		idx, err = f.bodyUntil(*s.FinallyBody, indent, itemEnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.writeRaw(indent, string(CmdEnd), f.line(idx))
+
+	return nil
+}
+
+func (f *formatter) writeExpr(indent, head string, expr []string, execMode bool, ln fmtLine) {
+	if execMode {
+		f.writeLine(indent, head, []string{execForm(expr)}, nil, ln)
+		return
+	}
+
+	f.writeLine(indent, head, expr, ln.atoms, ln)
+}
+
+func (f *formatter) command(c Command, indent string, ln fmtLine) error {
+	head := string(c.Name)
+
+	switch {
+	case c.ExecMode:
+		return f.writeSingle(indent, head, []string{execForm(c.Args)}, ln)
+	case c.Name == CmdEnv || c.Name == CmdArg || c.Name == CmdSet || c.Name == CmdLet:
+		return f.writeSingle(indent, head, keyValueArgs(c.Args), ln)
+	case c.Name == CmdLabel:
+		return f.writeSingle(indent, head, labelArgs(c.Args), ln)
+	}
+
+	f.writeLine(indent, head, c.Args, ln.atoms, ln)
+
+	return nil
+}
+
+// writeSingle emits a command whose arguments are rewritten rather than kept
+// token by token, and which is therefore always joined onto a single line.
+func (f *formatter) writeSingle(indent, head string, args []string, ln fmtLine) error {
+	prev := ln.kw
+	for _, a := range ln.atoms {
+		if a.Line > f.endLine(prev) {
+			eol, comments := f.continuationComments(prev, a.Line)
+			if eol != "" || len(comments) > 0 {
+				return fmt.Errorf("unable to keep comments inside the %s command at line %d", head, ln.kw.Line)
+			}
+		}
+
+		prev = a
+	}
+
+	f.writeLine(indent, head, args, nil, ln)
+
+	return nil
+}
+
+// keyValueArgs renders the arguments of ENV, ARG, SET and LET as
+// "[flags] KEY[=value]".
+func keyValueArgs(args []string) []string {
+	eq := slices.Index(args, "=")
+	if eq < 1 {
+		return args
+	}
+
+	out := slices.Clone(args[:eq-1])
+	kv := args[eq-1] + "="
+
+	if eq+1 < len(args) {
+		kv += args[eq+1]
+	}
+
+	return append(out, kv)
+}
+
+// labelArgs renders the arguments of LABEL as "key=value" pairs.
+func labelArgs(args []string) []string {
+	var out []string
+
+	for i := 0; i < len(args); i++ {
+		if i+2 < len(args) && args[i+1] == "=" {
+			out = append(out, args[i]+"="+args[i+2])
+			i += 2
+
+			continue
+		}
+
+		out = append(out, args[i])
+	}
+
+	return out
+}
+
+// execForm renders arguments in the JSON-like exec form, e.g. ["a", "b"],
+// using only the escapes understood by parseExecForm.
+func execForm(args []string) string {
+	var sb strings.Builder
+
+	sb.WriteByte('[')
+
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteByte('"')
+
+		for j := range len(arg) {
+			switch c := arg[j]; c {
+			case '"', '\\':
+				sb.WriteByte('\\')
+				sb.WriteByte(c)
+			case '\b':
+				sb.WriteString(`\b`)
+			case '\f':
+				sb.WriteString(`\f`)
+			case '\n':
+				sb.WriteString(`\n`)
+			case '\r':
+				sb.WriteString(`\r`)
+			case '\t':
+				sb.WriteString(`\t`)
+			default:
+				sb.WriteByte(c)
+			}
+		}
+
+		sb.WriteByte('"')
+	}
+
+	sb.WriteByte(']')
+
+	return sb.String()
+}
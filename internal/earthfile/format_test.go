@@ -0,0 +1,281 @@
+package earthfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "normalizes indentation and spacing",
+			input: `VERSION  0.8
+FROM   alpine:3.18
+build:
+  RUN   echo    hello
+  SAVE ARTIFACT  out AS LOCAL  out
+`,
+			want: `VERSION 0.8
+
+FROM alpine:3.18
+
+build:
+    RUN echo hello
+    SAVE ARTIFACT out AS LOCAL out
+`,
+		},
+		{
+			name: "collapses blank lines and separates targets",
+			input: `VERSION 0.8
+a:
+
+
+    FROM alpine
+
+    RUN true
+
+
+b:
+    FROM alpine
+c:
+`,
+			want: `VERSION 0.8
+
+a:
+    FROM alpine
+
+    RUN true
+
+b:
+    FROM alpine
+
+c:
+`,
+		},
+		{
+			name: "keeps doc comments attached",
+			input: `VERSION 0.8
+
+# build builds the thing.
+# It has two lines of docs.
+build:
+    # FOO is an argument.
+    ARG FOO = bar
+    # not a doc comment
+
+    RUN echo $FOO
+`,
+			want: `VERSION 0.8
+
+# build builds the thing.
+# It has two lines of docs.
+build:
+    # FOO is an argument.
+    ARG FOO=bar
+    # not a doc comment
+
+    RUN echo $FOO
+`,
+		},
+		{
+			name: "keeps end of line and trailing comments",
+			input: `VERSION 0.8
+build: # the target
+  RUN echo hi   # says hi
+  # trailing inside build
+# trailing at top level
+`,
+			want: `VERSION 0.8
+
+build: # the target
+    RUN echo hi # says hi
+    # trailing inside build
+
+# trailing at top level
+`,
+		},
+		{
+			name: "re-indents line continuations",
+			input: `VERSION 0.8
+build:
+  RUN --mount=type=cache,target=/root/.cache \
+   go build \
+          -o out \ # output
+   # the package
+   ./cmd
+`,
+			want: `VERSION 0.8
+
+build:
+    RUN --mount=type=cache,target=/root/.cache \
+        go build \
+               -o out \ # output
+        # the package
+        ./cmd
+`,
+		},
+		{
+			name: "formats block statements",
+			input: `VERSION 0.8
+build:
+  IF [ "$A" = "1" ]
+   RUN echo one
+  ELSE IF [ "$A" = "2" ]
+   RUN echo two
+   # before else
+  ELSE
+   RUN echo other
+
+  END
+  FOR x IN a b
+   TRY
+    RUN echo $x
+   FINALLY
+    SAVE ARTIFACT out
+   END
+  END
+  WAIT
+   BUILD +other
+  END
+  WITH DOCKER --load img=+image
+   RUN docker run img
+  END
+`,
+			want: `VERSION 0.8
+
+build:
+    IF [ "$A" = "1" ]
+        RUN echo one
+    ELSE IF [ "$A" = "2" ]
+        RUN echo two
+        # before else
+    ELSE
+        RUN echo other
+    END
+    FOR x IN a b
+        TRY
+            RUN echo $x
+        FINALLY
+            SAVE ARTIFACT out
+        END
+    END
+    WAIT
+        BUILD +other
+    END
+    WITH DOCKER --load img=+image
+        RUN docker run img
+    END
+`,
+		},
+		{
+			name: "normalizes exec form, key-value and label arguments",
+			input: `VERSION 0.8
+build:
+  ENTRYPOINT [ "/bin/sh","-c" ,  "echo \"hi\"" ]
+  ENV  A  hello world
+  LET b  =  "x y"
+  LABEL one = 1  two="2"
+`,
+			want: `VERSION 0.8
+
+build:
+    ENTRYPOINT ["/bin/sh", "-c", "echo \"hi\""]
+    ENV A=hello world
+    LET b="x y"
+    LABEL one=1 two="2"
+`,
+		},
+		{
+			name: "formats functions",
+			input: `VERSION 0.8
+MY_FUNC:
+ FUNCTION
+ ARG name
+ RUN echo $name
+build:
+ DO +MY_FUNC --name=x
+`,
+			want: `VERSION 0.8
+
+MY_FUNC:
+    FUNCTION
+    ARG name
+    RUN echo $name
+
+build:
+    DO +MY_FUNC --name=x
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := require.New(t)
+
+			got, err := Format("Earthfile", tt.input)
+			r.NoError(err)
+			r.Equal(tt.want, got)
+
+			again, err := Format("Earthfile", got)
+			r.NoError(err)
+			r.Equal(got, again, "formatting is not idempotent")
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Format("Earthfile", "VERSION 0.8\nbuild:\n    IF true\n        RUN x\n")
+	require.ErrorContains(t, err, "expected END to close IF statement")
+
+	_, err = Format("Earthfile", "VERSION 0.8\nbuild:\n    ENV A=b \\\n        # comment\n        c\n")
+	require.ErrorContains(t, err, "unable to keep comments inside the ENV command")
+}
+
+// TestFormatRoundTrip formats every Earthfile fixture and checks that the
+// output is stable and parses into the same AST.
+func TestFormatRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob(filepath.Join("tests", "*.earth"))
+	require.NoError(t, err)
+
+	paths = append(paths, filepath.Join("tests", "Earthfile"))
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Parallel()
+
+			r := require.New(t)
+
+			b, err := os.ReadFile(path) // #nosec G304
+			r.NoError(err)
+
+			want, err := Parse(path, string(b))
+			r.NoError(err)
+
+			formatted, err := Format(path, string(b))
+			r.NoError(err)
+
+			got, err := Parse(path, formatted)
+			r.NoError(err)
+			r.Equal(want, got)
+
+			again, err := Format(path, formatted)
+			r.NoError(err)
+			r.Equal(formatted, again)
+		})
+	}
+}
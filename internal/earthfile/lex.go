@@ -61,6 +61,7 @@ const (
 	itemHost
 	itemProject
 	itemUser
+	itemVisibility
 
 	// Block keywords.
 
@@ -127,6 +128,7 @@ const (
 	CmdTry            Cmd = "TRY"
 	CmdUser           Cmd = "USER"
 	CmdVersion        Cmd = "VERSION"
+	CmdVisibility     Cmd = "VISIBILITY"
 	CmdVolume         Cmd = "VOLUME"
 	CmdWait           Cmd = "WAIT"
 	CmdWith           Cmd = "WITH"
@@ -160,7 +162,7 @@ func (i item) String() string {
 		itemElseIf, itemElse, itemEnd, itemCmd, itemEntrypoint, itemGitClone,
 		itemAdd, itemStopSignal, itemOnBuild, itemHealthCheck, itemShell,
 		itemDo, itemCommand, itemFunctionKW, itemImport, itemVersion, itemCache,
-		itemHost, itemProject, itemUser, itemWith, itemDocker, itemTry,
+		itemHost, itemProject, itemUser, itemVisibility, itemWith, itemDocker, itemTry,
 		itemCatch, itemFinally, itemFor, itemWait, itemTarget, itemUserCommand,
 		itemFunction, itemAtom, itemEquals:
 		return fmt.Sprintf("%q", i.Val)
@@ -719,6 +721,8 @@ func lexCommandKeyword(l *lexer) stateFn {
 		typ = itemWith
 	case CmdWait:
 		typ = itemWait
+	case CmdVisibility:
+		typ = itemVisibility
 	default:
 		return l.errorf("unknown command keyword: %q", val)
 	}
@@ -884,6 +888,12 @@ func lexKeyValueCommandArgs(l *lexer) stateFn {
 			l.next()
 		}
 
+		if l.peek() == '=' {
+			// The flag has a value, e.g. ARG --type=int.
+			l.next()
+			lexFlagValue(l)
+		}
+
 		l.emit(itemAtom)
 
 		// After the flag, continue in lexKeyValueCommandArgs to expect more flags or the key
@@ -954,6 +964,33 @@ func lexKeyValueCommandArgs(l *lexer) stateFn {
 	return lexRecipeCommandArgs
 }
 
+// lexFlagValue consumes the value of a flag up to the next unquoted space,
+// newline or comment.
+func lexFlagValue(l *lexer) {
+	var quote rune
+
+	for {
+		r := l.peek()
+		if r == eof || isEndOfLine(r) || (quote == 0 && (isSpace(r) || r == '#')) {
+			return
+		}
+
+		l.next()
+
+		switch {
+		case r == '\\' && quote != '\'':
+			// The escaped character can't end the value.
+			if next := l.peek(); next != eof && !isEndOfLine(next) {
+				l.next()
+			}
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case r == quote:
+			quote = 0
+		}
+	}
+}
+
 // lexGlobalCommandArgs parses arguments for global scope commands such as VERSION or PROJECT.
 func lexGlobalCommandArgs(l *lexer) stateFn {
 	for {
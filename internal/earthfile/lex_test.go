@@ -54,6 +54,23 @@ func TestLex(t *testing.T) {
 				makeItemEOF(),
 			},
 		},
+		{
+			name:  "arg with flag values",
+			input: "ARG --type=int --pattern='[a-z ]+' KEY=VALUE\n",
+			want: []item{
+				makeItemArg(),
+				makeItemSpace(),
+				makeItemAtom("--type=int"),
+				makeItemSpace(),
+				makeItemAtom("--pattern='[a-z ]+'"),
+				makeItemSpace(),
+				makeItemAtom("KEY"),
+				makeItemAtom("="),
+				makeItemAtom("VALUE"),
+				makeItemNL(),
+				makeItemEOF(),
+			},
+		},
 		{
 			name:  "env with flags",
 			input: "ENV --local KEY=VALUE\n",
@@ -465,7 +465,7 @@ func isCommandToken(t itemType) bool {
 		itemCmd, itemEntrypoint, itemGitClone, itemAdd, itemStopSignal,
 		itemOnBuild, itemHealthCheck, itemShell, itemDo, itemCommand,
 		itemFunctionKW, itemImport, itemCache, itemHost, itemProject,
-		itemWith, itemIf, itemFor, itemWait, itemTry:
+		itemVisibility, itemWith, itemIf, itemFor, itemWait, itemTry:
 		return true
 	case itemError, itemEOF, itemNL, itemIndent, itemDedent, itemWS, itemComment,
 		itemEOLComment, itemElseIf, itemElse, itemEnd, itemVersion, itemDocker,
@@ -635,7 +635,7 @@ func (p *parser) parseStmts() (Block, error) {
 			itemCmd, itemEntrypoint, itemGitClone, itemAdd, itemStopSignal,
 			itemOnBuild, itemHealthCheck, itemShell, itemDo, itemCommand,
 			itemFunctionKW, itemImport, itemVersion, itemCache, itemHost,
-			itemProject:
+			itemProject, itemVisibility:
 			cmd, err := p.parseCommand()
 			if err != nil {
 				return block, err
@@ -797,7 +797,7 @@ func (p *parser) parseBlock() (Block, error) {
 			itemCmd, itemEntrypoint, itemGitClone, itemAdd, itemStopSignal,
 			itemOnBuild, itemHealthCheck, itemShell, itemDo, itemCommand,
 			itemFunctionKW, itemImport, itemVersion, itemCache, itemHost,
-			itemProject:
+			itemProject, itemVisibility:
 			sawNL = false
 
 			cmd, err := p.parseCommand()
@@ -855,7 +855,7 @@ func (p *parser) parseCommand() (Command, error) {
 	if len(cmd.Args) > 0 {
 		//nolint:exhaustive // Only commands supporting list/exec form or split args (LABEL) require post-processing.
 		switch cmd.Name {
-		case CmdRun, CmdCmd, CmdEntrypoint, CmdVolume:
+		case CmdRun, CmdCmd, CmdEntrypoint, CmdVolume, CmdShell:
 			if execArgs, ok := parseExecForm(cmd.Args); ok {
 				cmd.Args = execArgs
 				cmd.ExecMode = true
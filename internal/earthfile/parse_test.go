@@ -692,6 +692,64 @@ build:
 				},
 			},
 		},
+		{
+			name: "shell exec mode",
+			input: `VERSION 0.8
+build:
+  SHELL ["/bin/bash", "-o", "pipefail", "-c"]
+  STOPSIGNAL SIGINT
+`,
+			want: Tree{
+				Version: &Version{
+					Args: []string{"0.8"},
+				},
+				Targets: []Target{
+					{
+						Name: "build",
+						Recipe: Block{
+							{
+								Command: &Command{
+									Name:     "SHELL",
+									Args:     []string{"/bin/bash", "-o", "pipefail", "-c"},
+									ExecMode: true,
+								},
+							},
+							{
+								Command: &Command{
+									Name: "STOPSIGNAL",
+									Args: []string{"SIGINT"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "visibility",
+			input: `VERSION 0.8
+deps:
+  VISIBILITY private
+`,
+			want: Tree{
+				Version: &Version{
+					Args: []string{"0.8"},
+				},
+				Targets: []Target{
+					{
+						Name: "deps",
+						Recipe: Block{
+							{
+								Command: &Command{
+									Name: "VISIBILITY",
+									Args: []string{"private"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "if block",
 			input: `VERSION 0.8
@@ -0,0 +1,234 @@
+// Package lint checks Earthfiles against a registry of rules that catch likely mistakes which the parser accepts.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/features"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+// Severity levels, from least to most severe. SeverityOff disables a rule.
+const (
+	SeverityOff Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityOff:     "off",
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity as its name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name such as "warning".
+func ParseSeverity(s string) (Severity, error) {
+	for sev, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return sev, nil
+		}
+	}
+
+	return SeverityOff, fmt.Errorf("invalid severity %q; should be one of off, info, warning or error", s)
+}
+
+// Rule is a single lint check.
+type Rule struct {
+	// Check inspects the Earthfile and reports any problems via [File.Reportf].
+	Check       func(f *File)
+	ID          string
+	Description string
+	// Severity is the default severity of the diagnostics the rule reports.
+	Severity Severity
+}
+
+var registry = map[string]Rule{}
+
+// Register adds a rule to the registry. It panics if the rule is incomplete or
+// a rule with the same ID is already registered.
+func Register(r Rule) {
+	if r.ID == "" || r.Check == nil {
+		panic("lint: rule must have an ID and a Check function")
+	}
+
+	if _, ok := registry[r.ID]; ok {
+		panic("lint: duplicate rule " + r.ID)
+	}
+
+	registry[r.ID] = r
+}
+
+// Rules returns all registered rules, sorted by ID.
+func Rules() []Rule {
+	rules := make([]Rule, 0, len(registry))
+	for _, r := range registry {
+		rules = append(rules, r)
+	}
+
+	slices.SortFunc(rules, func(a, b Rule) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return rules
+}
+
+// Diagnostic is a problem reported by a rule.
+type Diagnostic struct {
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+
+	// endLine is the last line of the reported statement, used to match
+	// end-of-line suppression comments on continued commands.
+	endLine int
+}
+
+// Config customizes a lint run.
+type Config struct {
+	// Severities overrides the default severity of rules, by rule ID.
+	Severities map[string]Severity
+}
+
+// Validate checks that the config only refers to registered rules.
+func (c Config) Validate() error {
+	var err error
+
+	for id := range c.Severities {
+		if _, ok := registry[id]; !ok {
+			err = errors.Join(err, fmt.Errorf("unknown lint rule %q", id))
+		}
+	}
+
+	return err
+}
+
+func (c Config) severity(r Rule) Severity {
+	if sev, ok := c.Severities[r.ID]; ok {
+		return sev
+	}
+
+	return r.Severity
+}
+
+// File is the Earthfile being linted, as seen by a rule.
+type File struct {
+	// Features are the feature flags enabled by the VERSION command.
+	Features *features.Features
+	Tree     earthfile.Tree
+	Path     string
+
+	rule        Rule
+	diagnostics []Diagnostic
+	severity    Severity
+}
+
+// Reportf reports a problem at the given source location.
+func (f *File) Reportf(loc *earthfile.SourceLocation, format string, args ...any) {
+	d := Diagnostic{
+		Rule:     f.rule.ID,
+		Severity: f.severity,
+		Message:  fmt.Sprintf(format, args...),
+		File:     f.Path,
+	}
+
+	if loc != nil {
+		d.Line = loc.StartLine
+		d.Column = loc.StartColumn
+		d.endLine = loc.EndLine
+	}
+
+	f.diagnostics = append(f.diagnostics, d)
+}
+
+// LintFile reads and lints the Earthfile at path.
+func LintFile(path string, cfg Config) ([]Diagnostic, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return Lint(path, string(b), cfg)
+}
+
+// Lint checks the Earthfile text against all registered rules. Diagnostics are
+// sorted by position; those suppressed by an ignore comment are dropped.
+func Lint(path, text string, cfg Config) ([]Diagnostic, error) {
+	tree, err := earthfile.Parse(path, text, earthfile.WithSourceMap())
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := earthfile.Comments(path, text)
+	if err != nil {
+		return nil, err
+	}
+
+	ftrs, _, err := features.Get(tree.Version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to parse VERSION: %w", path, err)
+	}
+
+	_, err = ftrs.ProcessFlags()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	f := &File{
+		Features: ftrs,
+		Path:     path,
+		Tree:     tree,
+	}
+
+	for _, r := range Rules() {
+		f.rule = r
+
+		f.severity = cfg.severity(r)
+		if f.severity == SeverityOff {
+			continue
+		}
+
+		r.Check(f)
+	}
+
+	diags := suppress(f.diagnostics, comments)
+
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+
+		if a.Column != b.Column {
+			return a.Column - b.Column
+		}
+
+		return strings.Compare(a.Rule, b.Rule)
+	})
+
+	return diags, nil
+}
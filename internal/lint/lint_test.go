@@ -0,0 +1,415 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// summary formats diagnostics compactly for comparison in tests.
+func summary(diags []Diagnostic) []string {
+	out := make([]string, 0, len(diags))
+	for _, d := range diags {
+		out = append(out, fmt.Sprintf("%d:%s:%s", d.Line, d.Severity, d.Rule))
+	}
+
+	return out
+}
+
+func TestLintRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "clean Earthfile",
+			input: `VERSION 0.8
+ARG --global REGISTRY=docker.io
+
+build:
+    FROM $REGISTRY/golang:1.22
+    ARG GOOS=linux
+    CACHE /root/.cache/go-build
+    COPY go.mod go.sum ./
+    RUN GOOS=$GOOS go build ./...
+    SAVE ARTIFACT out
+`,
+			want: []string{},
+		},
+		{
+			name: "unused ARGs",
+			input: `VERSION 0.8
+ARG --global UNUSED_GLOBAL=x
+ARG --global USED_GLOBAL=y
+
+build:
+    FROM alpine
+    ARG USED
+    ARG IN_RUN_ENV
+    RUN ./script-reading-env.sh
+    BUILD +other --X=${USED} --Y=$USED_GLOBAL
+    ARG UNUSED
+    SAVE ARTIFACT out
+
+pass:
+    ARG FORWARDED
+    BUILD --pass-args +build
+`,
+			want: []string{"2:warning:unused-arg", "11:warning:unused-arg"},
+		},
+		{
+			name: "ARG referenced before declaration only",
+			input: `VERSION 0.8
+build:
+    RUN echo $LATE
+    ARG LATE
+`,
+			want: []string{"4:warning:unused-arg"},
+		},
+		{
+			name: "dependency installs without cache",
+			input: `VERSION 0.8
+deps:
+    FROM node:20
+    RUN npm ci
+    RUN --mount type=cache,target=/root/.npm npm install
+    RUN apk add --no-cache git
+    RUN echo apt-get
+
+cached:
+    FROM golang
+    CACHE /go/pkg/mod
+    RUN go mod download
+`,
+			want: []string{"4:info:run-without-cache"},
+		},
+		{
+			name: "push outside WAIT",
+			input: `VERSION 0.8
+image:
+    FROM alpine
+    SAVE IMAGE --push example/a
+    SAVE IMAGE example/b
+    WAIT
+        SAVE IMAGE --push example/c
+    END
+`,
+			want: []string{"4:info:push-outside-wait"},
+		},
+		{
+			name: "push outside WAIT needs WAIT blocks",
+			input: `VERSION 0.6
+image:
+    FROM alpine
+    SAVE IMAGE --push example/a
+`,
+			want: []string{},
+		},
+		{
+			name: "COPY of the whole context",
+			input: `VERSION 0.8
+build:
+    FROM alpine
+    COPY . /src
+    COPY --dir ./ /src
+    COPY +other/. /src
+    COPY src /src
+`,
+			want: []string{"4:warning:copy-whole-context", "5:warning:copy-whole-context"},
+		},
+		{
+			name: "pass-args without feature flag",
+			input: `VERSION 0.7
+build:
+    FROM --pass-args +base
+    BUILD --pass-args +other
+    WITH DOCKER --pass-args --load=+img
+        RUN true
+    END
+`,
+			want: []string{
+				"3:error:undeclared-pass-args",
+				"4:error:undeclared-pass-args",
+				"5:error:undeclared-pass-args",
+			},
+		},
+		{
+			name: "pass-args with feature flag",
+			input: `VERSION --pass-args 0.7
+build:
+    BUILD --pass-args +other
+`,
+			want: []string{},
+		},
+		{
+			name: "missing feature flags",
+			input: `VERSION 0.6
+build:
+    FROM alpine
+    CACHE /cache
+    FOR x IN a b
+        RUN --raw-output --network=none echo $x
+    END
+    TRY
+        RUN true
+    FINALLY
+        RUN true
+    END
+    COPY --chmod 0755 a b
+    BUILD --auto-skip +other
+    WITH DOCKER --compose compose.yml --compose-profile test --compose-wait --compose-wait-timeout 1m
+        RUN true
+    END
+`,
+			want: []string{
+				"4:error:missing-feature-flag",
+				"6:error:missing-feature-flag",
+				"6:error:missing-feature-flag",
+				"8:error:missing-feature-flag",
+				"13:error:missing-feature-flag",
+				"14:error:missing-feature-flag",
+				"15:error:missing-feature-flag",
+				"15:error:missing-feature-flag",
+				"15:error:missing-feature-flag",
+			},
+		},
+		{
+			name: "missing feature flags of unreleased commands",
+			input: `VERSION 0.8
+build:
+    VISIBILITY private
+    ARG --type=int N=1
+    FROM alpine
+    SHELL ["/bin/bash", "-c"]
+    STOPSIGNAL SIGINT
+    ADD https://example.com/a.tar.gz /src
+    RUN --service name=db,image=postgres true
+    BUILD --matrix-exclude A=1 +other --A=1 --A=2
+`,
+			want: []string{
+				"3:error:missing-feature-flag",
+				"4:error:missing-feature-flag",
+				"6:error:missing-feature-flag",
+				"7:error:missing-feature-flag",
+				"8:error:missing-feature-flag",
+				"9:error:missing-feature-flag",
+				"10:error:missing-feature-flag",
+			},
+		},
+		{
+			name: "unreleased feature flags enabled by version",
+			input: `VERSION --target-visibility --typed-args --use-shell-and-stopsignal --use-add-command --run-service --build-matrix 0.8
+build:
+    VISIBILITY private
+    ARG --type=int N=1
+    FROM alpine
+    SHELL ["/bin/bash", "-c"]
+    STOPSIGNAL SIGINT
+    ADD https://example.com/a.tar.gz /src
+    RUN --service name=db,image=postgres true
+    BUILD --matrix-exclude A=1 +other --A=1 --A=2
+`,
+			want: []string{},
+		},
+		{
+			name: "feature flags enabled by version",
+			input: `VERSION --raw-output --try 0.8
+build:
+    FROM alpine
+    CACHE /cache
+    FOR x IN a b
+        RUN --raw-output --network=none echo $x
+    END
+    TRY
+        RUN true
+    FINALLY
+        RUN true
+    END
+`,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			diags, err := Lint("Earthfile", tt.input, Config{})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, summary(diags))
+		})
+	}
+}
+
+func TestLintMessages(t *testing.T) {
+	t.Parallel()
+
+	diags, err := Lint("Earthfile", "VERSION 0.7\nbuild:\n    BUILD --pass-args +other\n", Config{})
+	require.NoError(t, err)
+	require.Equal(t, []Diagnostic{{
+		Rule:     "undeclared-pass-args",
+		Severity: SeverityError,
+		Message:  "BUILD --pass-args requires the VERSION --pass-args feature flag (or VERSION 0.8)",
+		File:     "Earthfile",
+		Line:     3,
+		Column:   5,
+		endLine:  3,
+	}}, diags)
+}
+
+func TestLintSuppression(t *testing.T) {
+	t.Parallel()
+
+	input := `VERSION 0.8
+build:
+    FROM alpine
+    # earth-lint:ignore unused-arg
+    # a comment between the directive and the command
+    ARG A
+    ARG B # earth-lint:ignore
+    ARG C # earth-lint:ignore copy-whole-context
+    COPY . \
+        /src # earth-lint:ignore copy-whole-context
+    ARG D
+    # earth-lint:ignored is not a directive
+    ARG E
+`
+
+	diags, err := Lint("Earthfile", input, Config{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"8:warning:unused-arg", "11:warning:unused-arg", "13:warning:unused-arg"}, summary(diags))
+
+	diags, err = Lint("Earthfile", "# earth-lint:ignore-file unused-arg\n"+input, Config{})
+	require.NoError(t, err)
+	require.Empty(t, diags)
+}
+
+func TestLintConfig(t *testing.T) {
+	t.Parallel()
+
+	input := "VERSION 0.8\nbuild:\n    FROM alpine\n    ARG A\n    COPY . /src\n"
+
+	diags, err := Lint("Earthfile", input, Config{Severities: map[string]Severity{
+		"unused-arg":         SeverityError,
+		"copy-whole-context": SeverityOff,
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"4:error:unused-arg"}, summary(diags))
+
+	err = Config{Severities: map[string]Severity{"no-such-rule": SeverityError}}.Validate()
+	require.ErrorContains(t, err, `unknown lint rule "no-such-rule"`)
+}
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	for _, sev := range []Severity{SeverityOff, SeverityInfo, SeverityWarning, SeverityError} {
+		got, err := ParseSeverity(sev.String())
+		require.NoError(t, err)
+		require.Equal(t, sev, got)
+	}
+
+	_, err := ParseSeverity("fatal")
+	require.ErrorContains(t, err, `invalid severity "fatal"`)
+}
+
+func TestRulesRegistry(t *testing.T) {
+	t.Parallel()
+
+	rules := Rules()
+	require.NotEmpty(t, rules)
+
+	for i, r := range rules {
+		require.NotEmpty(t, r.Description, r.ID)
+
+		if i > 0 {
+			require.Less(t, rules[i-1].ID, r.ID)
+		}
+	}
+
+	require.Panics(t, func() { Register(rules[0]) })
+
+	for _, ffs := range flagFeatures {
+		for _, ff := range ffs {
+			require.NotPanics(t, func() { featureFlagHint(ff.feature) }, ff.feature)
+		}
+	}
+
+	for _, feature := range commandFeatures {
+		require.NotPanics(t, func() { featureFlagHint(feature) }, feature)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	diags := []Diagnostic{{
+		Rule:     "unused-arg",
+		Severity: SeverityWarning,
+		Message:  "ARG A is declared in build but never used",
+		File:     "sub/Earthfile",
+		Line:     4,
+		Column:   5,
+	}}
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, FormatText, diags))
+		require.Equal(t, "sub/Earthfile:4:5: warning: ARG A is declared in build but never used (unused-arg)\n", buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, FormatJSON, diags))
+		require.JSONEq(t, `[{
+			"rule": "unused-arg",
+			"severity": "warning",
+			"message": "ARG A is declared in build but never used",
+			"file": "sub/Earthfile",
+			"line": 4,
+			"column": 5
+		}]`, buf.String())
+
+		buf.Reset()
+		require.NoError(t, Write(&buf, FormatJSON, nil))
+		require.JSONEq(t, `[]`, buf.String())
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, FormatSARIF, diags))
+
+		var log sarifLog
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+		require.Equal(t, "2.1.0", log.Version)
+		require.Len(t, log.Runs, 1)
+		require.Len(t, log.Runs[0].Tool.Driver.Rules, len(Rules()))
+		require.Equal(t, []sarifResult{{
+			RuleID:  "unused-arg",
+			Level:   "warning",
+			Message: sarifMessage{Text: "ARG A is declared in build but never used"},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "sub/Earthfile"},
+				Region:           sarifRegion{StartLine: 4, StartColumn: 5},
+			}}},
+		}}, log.Runs[0].Results)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		t.Parallel()
+
+		require.ErrorContains(t, Write(new(bytes.Buffer), "xml", diags), `invalid lint output format "xml"`)
+	})
+}
@@ -0,0 +1,454 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/earthfile2llb/cmdopts"
+	"github.com/EarthBuild/earthbuild/features"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/util/flagutil"
+)
+
+func init() {
+	Register(Rule{
+		ID:          "unused-arg",
+		Description: "ARG is declared but never referenced, nor visible to a later RUN as an environment variable",
+		Severity:    SeverityWarning,
+		Check:       checkUnusedArgs,
+	})
+	Register(Rule{
+		ID:          "run-without-cache",
+		Description: "RUN installs or builds dependencies without a cache mount or CACHE command",
+		Severity:    SeverityInfo,
+		Check:       checkRunWithoutCache,
+	})
+	Register(Rule{
+		ID:          "push-outside-wait",
+		Description: "SAVE IMAGE --push outside a WAIT block is only pushed once the whole build has finished",
+		Severity:    SeverityInfo,
+		Check:       checkPushOutsideWait,
+	})
+	Register(Rule{
+		ID:          "copy-whole-context",
+		Description: "COPY of the whole build context invalidates the cache on any change",
+		Severity:    SeverityWarning,
+		Check:       checkCopyWholeContext,
+	})
+	Register(Rule{
+		ID:          "undeclared-pass-args",
+		Description: "--pass-args is used without the VERSION --pass-args feature flag",
+		Severity:    SeverityError,
+		Check:       checkUndeclaredPassArgs,
+	})
+	Register(Rule{
+		ID:          "missing-feature-flag",
+		Description: "command or flag requires a VERSION feature flag which is not enabled",
+		Severity:    SeverityError,
+		Check:       checkMissingFeatureFlags,
+	})
+}
+
+// checkUnusedArgs reports ARGs which are not referenced by any later statement
+// of their recipe. ARGs of the base recipe may be referenced anywhere in the
+// file. ARGs are also set in the environment of RUN commands (and of the shell
+// expressions of IF and FOR), so any such later statement counts as a use. A
+// recipe which passes its arguments on with --pass-args uses them all.
+func checkUnusedArgs(f *File) {
+	fileRefs := map[string]bool{}
+
+	for _, r := range recipes(f.Tree) {
+		visit(r.block, nil, func(s earthfile.Statement, _ []earthfile.Statement) {
+			varRefs(fileRefs, statementArgs(s))
+		})
+	}
+
+	for _, r := range recipes(f.Tree) {
+		var stmts []earthfile.Statement
+
+		visit(r.block, nil, func(s earthfile.Statement, _ []earthfile.Statement) {
+			stmts = append(stmts, s)
+		})
+
+		if slices.ContainsFunc(stmts, func(s earthfile.Statement) bool {
+			return s.Command != nil && hasPassArgs(*s.Command) ||
+				s.With != nil && hasPassArgs(s.With.Command)
+		}) {
+			continue
+		}
+
+		for i, s := range stmts {
+			if s.Command == nil || s.Command.Name != earthfile.CmdArg {
+				continue
+			}
+
+			_, name, _, err := flagutil.ParseArgArgs(*s.Command, r.isBase, f.Features.ExplicitGlobal)
+			if err != nil {
+				continue
+			}
+
+			var used bool
+
+			if r.isBase {
+				used = fileRefs[name]
+			} else {
+				refs := map[string]bool{}
+				for _, later := range stmts[i+1:] {
+					varRefs(refs, statementArgs(later))
+
+					used = used || readsEnv(later)
+				}
+
+				used = used || refs[name]
+			}
+
+			if !used {
+				f.Reportf(s.Command.SourceLocation, "ARG %s is declared in %s but never used", name, r.name)
+			}
+		}
+	}
+}
+
+// readsEnv reports whether the statement runs a process which can read ARGs
+// from its environment.
+func readsEnv(s earthfile.Statement) bool {
+	return s.If != nil || s.For != nil || s.With != nil ||
+		s.Command != nil && s.Command.Name == earthfile.CmdRun
+}
+
+// dependencyCommands are commands which download or build dependencies and
+// benefit from a persistent cache between builds.
+var dependencyCommands = [][]string{
+	{"apt-get", "install"},
+	{"apt", "install"},
+	{"apk", "add"},
+	{"dnf", "install"},
+	{"yum", "install"},
+	{"npm", "ci"},
+	{"npm", "install"},
+	{"yarn", "install"},
+	{"pnpm", "install"},
+	{"pip", "install"},
+	{"pip3", "install"},
+	{"go", "build"},
+	{"go", "mod", "download"},
+	{"cargo", "build"},
+	{"cargo", "fetch"},
+	{"mvn", "package"},
+	{"mvn", "install"},
+	{"gradle", "build"},
+}
+
+// dependencyCommand returns the dependency command run by the shell words, if any.
+func dependencyCommand(words []string) string {
+	if slices.ContainsFunc(words, func(w string) bool {
+		// Explicitly opting out of the package manager's cache.
+		return w == "--no-cache" || w == "--no-cache-dir"
+	}) {
+		return ""
+	}
+
+	for i := range words {
+		for _, dc := range dependencyCommands {
+			if i+len(dc) <= len(words) && slices.Equal(words[i:i+len(dc)], dc) {
+				return strings.Join(dc, " ")
+			}
+		}
+	}
+
+	return ""
+}
+
+// checkRunWithoutCache reports RUN commands which install or build
+// dependencies without a cache mount, in recipes that have no CACHE command.
+func checkRunWithoutCache(f *File) {
+	for _, r := range recipes(f.Tree) {
+		var (
+			runs     []earthfile.Command
+			hasCache bool
+		)
+
+		visitCommands(r.block, func(cmd earthfile.Command, _ []earthfile.Statement) {
+			switch cmd.Name { //nolint:exhaustive // Only RUN and CACHE are of interest.
+			case earthfile.CmdCache:
+				hasCache = true
+			case earthfile.CmdRun:
+				runs = append(runs, cmd)
+			}
+		})
+
+		if hasCache {
+			continue
+		}
+
+		for _, cmd := range runs {
+			var opts cmdopts.Run
+
+			args, ok := parseFlags(cmd, &opts)
+			if !ok {
+				continue
+			}
+
+			if slices.ContainsFunc(opts.Mounts, func(m string) bool {
+				return strings.Contains(m, "type=cache")
+			}) {
+				continue
+			}
+
+			var words []string
+			for _, arg := range args {
+				words = append(words, strings.Fields(arg)...)
+			}
+
+			if dc := dependencyCommand(words); dc != "" {
+				f.Reportf(cmd.SourceLocation,
+					"RUN %s without --mount type=cache or a CACHE command re-downloads dependencies on every change", dc)
+			}
+		}
+	}
+}
+
+// checkPushOutsideWait reports SAVE IMAGE --push commands which are not inside
+// a WAIT (or WITH) block. It only applies when WAIT blocks are available.
+func checkPushOutsideWait(f *File) {
+	if !f.Features.WaitBlock {
+		return
+	}
+
+	for _, r := range recipes(f.Tree) {
+		visitCommands(r.block, func(cmd earthfile.Command, parents []earthfile.Statement) {
+			if cmd.Name != earthfile.CmdSaveImage {
+				return
+			}
+
+			var opts cmdopts.SaveImage
+
+			_, ok := parseFlags(cmd, &opts)
+			if !ok || !opts.Push {
+				return
+			}
+
+			if slices.ContainsFunc(parents, func(s earthfile.Statement) bool {
+				return s.Wait != nil || s.With != nil
+			}) {
+				return
+			}
+
+			f.Reportf(cmd.SourceLocation,
+				"SAVE IMAGE --push outside a WAIT block is deferred until the whole build has finished")
+		})
+	}
+}
+
+// checkCopyWholeContext reports COPY commands which copy the entire build
+// context.
+func checkCopyWholeContext(f *File) {
+	for _, r := range recipes(f.Tree) {
+		visitCommands(r.block, func(cmd earthfile.Command, _ []earthfile.Statement) {
+			if cmd.Name != earthfile.CmdCopy {
+				return
+			}
+
+			var opts cmdopts.Copy
+
+			args, ok := parseFlags(cmd, &opts)
+			if !ok || len(args) < 2 {
+				return
+			}
+
+			for _, src := range args[:len(args)-1] {
+				if src == "." || src == "./" {
+					f.Reportf(cmd.SourceLocation,
+						"COPY %s copies the whole build context; copy only the files the target needs", src)
+
+					return
+				}
+			}
+		})
+	}
+}
+
+// passArgsFlags lists the commands which accept the --pass-args flag.
+var passArgsFlags = map[earthfile.Cmd]flagFeature{
+	earthfile.CmdFrom:   gated("--pass-args", "PassArgs", func(o *cmdopts.From) bool { return o.PassArgs }),
+	earthfile.CmdBuild:  gated("--pass-args", "PassArgs", func(o *cmdopts.Build) bool { return o.PassArgs }),
+	earthfile.CmdCopy:   gated("--pass-args", "PassArgs", func(o *cmdopts.Copy) bool { return o.PassArgs }),
+	earthfile.CmdDocker: gated("--pass-args", "PassArgs", func(o *cmdopts.WithDocker) bool { return o.PassArgs }),
+	earthfile.CmdDo:     gated("--pass-args", "PassArgs", func(o *cmdopts.Do) bool { return o.PassArgs }),
+}
+
+// hasPassArgs reports whether cmd uses the --pass-args flag.
+func hasPassArgs(cmd earthfile.Command) bool {
+	ff, ok := passArgsFlags[cmd.Name]
+	return ok && ff.used(cmd)
+}
+
+// commandName returns the name of the command as written in the Earthfile.
+func commandName(cmd earthfile.Command) string {
+	if cmd.Name == earthfile.CmdDocker {
+		return "WITH DOCKER"
+	}
+
+	return string(cmd.Name)
+}
+
+// checkUndeclaredPassArgs reports uses of --pass-args when the feature is not
+// enabled by the VERSION command.
+func checkUndeclaredPassArgs(f *File) {
+	if f.Features.PassArgs {
+		return
+	}
+
+	for _, r := range recipes(f.Tree) {
+		visitCommands(r.block, func(cmd earthfile.Command, _ []earthfile.Statement) {
+			if hasPassArgs(cmd) {
+				f.Reportf(cmd.SourceLocation,
+					"%s --pass-args requires %s", commandName(cmd), featureFlagHint("PassArgs"))
+			}
+		})
+	}
+}
+
+// flagFeature is a command flag which is gated by a feature flag.
+type flagFeature struct {
+	// used reports whether the command sets the flag.
+	used    func(cmd earthfile.Command) bool
+	flag    string
+	feature string
+}
+
+// gated returns a flagFeature for a flag of the command options T, which is
+// set when isSet returns true for the parsed options.
+func gated[T any](flag, feature string, isSet func(opts *T) bool) flagFeature {
+	return flagFeature{
+		flag:    flag,
+		feature: feature,
+		used: func(cmd earthfile.Command) bool {
+			var opts T
+
+			_, ok := parseFlags(cmd, &opts)
+
+			return ok && isSet(&opts)
+		},
+	}
+}
+
+// flagFeatures lists the gated flags of each command, mirroring the checks of
+// the interpreter.
+var flagFeatures = map[earthfile.Cmd][]flagFeature{
+	earthfile.CmdRun: {
+		gated("--network=none", "NoNetwork", func(o *cmdopts.Run) bool { return o.Network == "none" }),
+		gated("--aws", "RunWithAWS", func(o *cmdopts.Run) bool { return o.WithAWS }),
+		gated("--oidc", "RunWithAWSOIDC", func(o *cmdopts.Run) bool { return o.OIDC != "" }),
+		gated("--raw-output", "RawOutput", func(o *cmdopts.Run) bool { return o.RawOutput }),
+		gated("--service", "RunService", func(o *cmdopts.Run) bool { return len(o.Services) > 0 }),
+	},
+	earthfile.CmdArg: {
+		gated("--type", "TypedArgs", func(o *cmdopts.Arg) bool { return o.Type != "" }),
+		gated("--enum", "TypedArgs", func(o *cmdopts.Arg) bool { return o.Enum != "" }),
+		gated("--pattern", "TypedArgs", func(o *cmdopts.Arg) bool { return o.Pattern != "" }),
+	},
+	earthfile.CmdCopy: {
+		gated("--chmod", "UseChmod", func(o *cmdopts.Copy) bool { return o.Chmod != "" }),
+	},
+	earthfile.CmdSaveImage: {
+		gated("--no-manifest-list", "UseNoManifestList", func(o *cmdopts.SaveImage) bool { return o.NoManifestList }),
+		gated("--without-earthly-labels", "AllowWithoutEarthlyLabels",
+			func(o *cmdopts.SaveImage) bool { return o.WithoutEarthLabels }),
+	},
+	earthfile.CmdFromDockerfile: {
+		gated("--allow-privileged", "AllowPrivilegedFromDockerfile",
+			func(o *cmdopts.FromDockerfile) bool { return o.AllowPrivileged }),
+	},
+	earthfile.CmdBuild: {
+		gated("--auto-skip", "BuildAutoSkip", func(o *cmdopts.Build) bool { return o.AutoSkip }),
+		gated("--matrix-name", "BuildMatrix", func(o *cmdopts.Build) bool { return o.MatrixName != "" }),
+		gated("--matrix-include", "BuildMatrix", func(o *cmdopts.Build) bool { return len(o.MatrixInclude) > 0 }),
+		gated("--matrix-exclude", "BuildMatrix", func(o *cmdopts.Build) bool { return len(o.MatrixExclude) > 0 }),
+		gated("--matrix-max-parallel", "BuildMatrix", func(o *cmdopts.Build) bool { return o.MatrixMaxParallel != 0 }),
+		gated("--matrix-continue", "BuildMatrix", func(o *cmdopts.Build) bool { return o.MatrixContinue }),
+	},
+	earthfile.CmdDocker: {
+		gated("--cache-id", "DockerCache", func(o *cmdopts.WithDocker) bool { return o.CacheID != "" }),
+		gated("--compose-profile", "DockerComposeOptions",
+			func(o *cmdopts.WithDocker) bool { return len(o.ComposeProfiles) > 0 }),
+		gated("--compose-env-file", "DockerComposeOptions",
+			func(o *cmdopts.WithDocker) bool { return len(o.ComposeEnvFiles) > 0 }),
+		gated("--compose-wait", "DockerComposeOptions", func(o *cmdopts.WithDocker) bool { return o.ComposeWait }),
+		gated("--compose-wait-timeout", "DockerComposeOptions",
+			func(o *cmdopts.WithDocker) bool { return o.ComposeWaitTimeout != 0 }),
+	},
+	earthfile.CmdCache: {
+		gated("--persist", "CachePersistOption", func(o *cmdopts.Cache) bool { return o.Persist }),
+	},
+}
+
+// commandFeatures lists the commands which are gated by a feature flag.
+var commandFeatures = map[earthfile.Cmd]string{
+	earthfile.CmdCache:      "UseCacheCommand",
+	earthfile.CmdHost:       "UseHostCommand",
+	earthfile.CmdSet:        "ArgScopeSet",
+	earthfile.CmdFunction:   "UseFunctionKeyword",
+	earthfile.CmdAdd:        "UseAddCommand",
+	earthfile.CmdShell:      "UseShellAndStopSignal",
+	earthfile.CmdStopSignal: "UseShellAndStopSignal",
+	earthfile.CmdVisibility: "TargetVisibility",
+}
+
+// enabled reports whether the named feature is enabled.
+func enabled(ftrs *features.Features, feature string) bool {
+	return reflect.ValueOf(ftrs).Elem().FieldByName(feature).Bool()
+}
+
+// featureFlagHint describes how to enable the named feature, e.g.
+// "the VERSION --pass-args feature flag (or VERSION 0.8)".
+func featureFlagHint(feature string) string {
+	field, ok := reflect.TypeFor[features.Features]().FieldByName(feature)
+	if !ok {
+		panic("lint: unknown feature " + feature)
+	}
+
+	hint := fmt.Sprintf("the VERSION --%s feature flag", field.Tag.Get("long"))
+	if v := field.Tag.Get("enabled_in_version"); v != "" {
+		hint += fmt.Sprintf(" (or VERSION %s)", v)
+	}
+
+	return hint
+}
+
+// checkMissingFeatureFlags reports commands and flags which are not enabled by
+// the VERSION command.
+func checkMissingFeatureFlags(f *File) {
+	report := func(loc *earthfile.SourceLocation, what, feature string) {
+		if !enabled(f.Features, feature) {
+			f.Reportf(loc, "%s requires %s", what, featureFlagHint(feature))
+		}
+	}
+
+	for _, r := range recipes(f.Tree) {
+		visit(r.block, nil, func(s earthfile.Statement, _ []earthfile.Statement) {
+			switch {
+			case s.For != nil:
+				report(s.For.SourceLocation, "FOR", "ForIn")
+			case s.Wait != nil:
+				report(s.Wait.SourceLocation, "WAIT", "WaitBlock")
+			case s.Try != nil:
+				report(s.Try.SourceLocation, "TRY", "TryFinally")
+			}
+		})
+
+		visitCommands(r.block, func(cmd earthfile.Command, _ []earthfile.Statement) {
+			if feature, ok := commandFeatures[cmd.Name]; ok {
+				report(cmd.SourceLocation, commandName(cmd), feature)
+			}
+
+			for _, ff := range flagFeatures[cmd.Name] {
+				if ff.used(cmd) {
+					report(cmd.SourceLocation, commandName(cmd)+" "+ff.flag, ff.feature)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+)
+
+const (
+	// ignoreDirective suppresses diagnostics on the line it ends, or on the
+	// next line when it stands on a line of its own.
+	ignoreDirective = "earth-lint:ignore"
+	// ignoreFileDirective suppresses diagnostics in the whole file.
+	ignoreFileDirective = "earth-lint:ignore-file"
+)
+
+// suppression is a parsed ignore comment. A nil rules set matches all rules.
+type suppression struct {
+	rules     map[string]bool
+	line      int
+	wholeFile bool
+	endOfLine bool
+}
+
+func (s suppression) matches(d Diagnostic) bool {
+	if s.rules != nil && !s.rules[d.Rule] {
+		return false
+	}
+
+	if s.wholeFile {
+		return true
+	}
+
+	if s.endOfLine {
+		return d.Line <= s.line && s.line <= max(d.Line, d.endLine)
+	}
+
+	return d.Line == s.line
+}
+
+// parseSuppressions finds the ignore comments in the file. A standalone ignore
+// comment applies to the first line after it which is not itself a comment.
+func parseSuppressions(comments []earthfile.Comment) []suppression {
+	commentLines := make(map[int]bool, len(comments))
+
+	for _, c := range comments {
+		if !c.EndOfLine {
+			commentLines[c.Line] = true
+		}
+	}
+
+	var sups []suppression
+
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "#"))
+
+		var s suppression
+
+		switch {
+		case strings.HasPrefix(text, ignoreFileDirective):
+			text = strings.TrimPrefix(text, ignoreFileDirective)
+			s.wholeFile = true
+		case strings.HasPrefix(text, ignoreDirective):
+			text = strings.TrimPrefix(text, ignoreDirective)
+		default:
+			continue
+		}
+
+		if text != "" && !strings.HasPrefix(text, " ") && !strings.HasPrefix(text, "\t") {
+			// Some other directive that happens to share the prefix.
+			continue
+		}
+
+		for id := range strings.FieldsFuncSeq(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			if s.rules == nil {
+				s.rules = map[string]bool{}
+			}
+
+			s.rules[id] = true
+		}
+
+		s.endOfLine = c.EndOfLine
+
+		s.line = c.Line
+		if !c.EndOfLine {
+			for s.line++; commentLines[s.line]; s.line++ {
+			}
+		}
+
+		sups = append(sups, s)
+	}
+
+	return sups
+}
+
+// suppress drops the diagnostics matched by an ignore comment.
+func suppress(diags []Diagnostic, comments []earthfile.Comment) []Diagnostic {
+	sups := parseSuppressions(comments)
+	if len(sups) == 0 {
+		return diags
+	}
+
+	kept := diags[:0]
+
+	for _, d := range diags {
+		ignored := false
+
+		for _, s := range sups {
+			if s.matches(d) {
+				ignored = true
+				break
+			}
+		}
+
+		if !ignored {
+			kept = append(kept, d)
+		}
+	}
+
+	return kept
+}
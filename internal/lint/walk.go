@@ -0,0 +1,138 @@
+package lint
+
+import (
+	"regexp"
+
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/util/flagutil"
+	"github.com/EarthBuild/earthbuild/util/stringutil"
+	"github.com/jessevdk/go-flags"
+)
+
+// recipe is a target, function or the base recipe of an Earthfile.
+type recipe struct {
+	name   string
+	block  earthfile.Block
+	isBase bool
+}
+
+// recipes returns every recipe in the tree, starting with the base recipe.
+func recipes(tree earthfile.Tree) []recipe {
+	rs := []recipe{{name: earthfile.TargetBase, block: tree.BaseRecipe, isBase: true}}
+
+	for _, t := range tree.Targets {
+		rs = append(rs, recipe{name: t.Name, block: t.Recipe})
+	}
+
+	for _, fn := range tree.Functions {
+		rs = append(rs, recipe{name: fn.Name, block: fn.Recipe})
+	}
+
+	return rs
+}
+
+// visit calls fn for every statement in the block in source order, including
+// statements nested in blocks. parents holds the enclosing block statements,
+// innermost last.
+func visit(
+	b earthfile.Block, parents []earthfile.Statement, fn func(s earthfile.Statement, parents []earthfile.Statement),
+) {
+	for _, s := range b {
+		fn(s, parents)
+
+		inner := append(parents[:len(parents):len(parents)], s)
+
+		switch {
+		case s.With != nil:
+			visit(s.With.Body, inner, fn)
+		case s.If != nil:
+			visit(s.If.IfBody, inner, fn)
+
+			for _, elseIf := range s.If.ElseIf {
+				visit(elseIf.Body, inner, fn)
+			}
+
+			if s.If.ElseBody != nil {
+				visit(*s.If.ElseBody, inner, fn)
+			}
+		case s.Try != nil:
+			visit(s.Try.TryBody, inner, fn)
+
+			if s.Try.CatchBody != nil {
+				visit(*s.Try.CatchBody, inner, fn)
+			}
+
+			if s.Try.FinallyBody != nil {
+				visit(*s.Try.FinallyBody, inner, fn)
+			}
+		case s.For != nil:
+			visit(s.For.Body, inner, fn)
+		case s.Wait != nil:
+			visit(s.Wait.Body, inner, fn)
+		}
+	}
+}
+
+// visitCommands calls fn for every command in the block, including the
+// commands that open WITH blocks.
+func visitCommands(b earthfile.Block, fn func(cmd earthfile.Command, parents []earthfile.Statement)) {
+	visit(b, nil, func(s earthfile.Statement, parents []earthfile.Statement) {
+		switch {
+		case s.Command != nil:
+			fn(*s.Command, parents)
+		case s.With != nil:
+			fn(s.With.Command, parents)
+		}
+	})
+}
+
+// statementArgs returns the arguments of the statement itself, excluding any
+// nested statements.
+func statementArgs(s earthfile.Statement) []string {
+	switch {
+	case s.Command != nil:
+		return s.Command.Args
+	case s.With != nil:
+		return s.With.Command.Args
+	case s.If != nil:
+		args := s.If.Expression
+		for _, elseIf := range s.If.ElseIf {
+			args = append(args[:len(args):len(args)], elseIf.Expression...)
+		}
+
+		return args
+	case s.For != nil:
+		return s.For.Args
+	case s.Wait != nil:
+		return s.Wait.Args
+	}
+
+	return nil
+}
+
+// parseFlags parses the flags of cmd into opts and returns the remaining
+// arguments. It reports false if the flags are not valid, in which case the
+// build itself will report the problem.
+func parseFlags(cmd earthfile.Command, opts any) ([]string, bool) {
+	args := stringutil.ProcessParamsAndQuotes(flagutil.GetArgsCopy(cmd))
+
+	args, err := flagutil.ParseArgsWithValueModifierAndOptions(
+		string(cmd.Name), opts, args, nil, flags.PassDoubleDash|flags.PassAfterNonOption|flags.AllowBoolValues,
+	)
+	if err != nil {
+		return nil, false
+	}
+
+	return args, true
+}
+
+var varRefRe = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// varRefs adds the names of the variables referenced in args to refs.
+func varRefs(refs map[string]bool, args []string) {
+	for _, arg := range args {
+		for _, m := range varRefRe.FindAllStringSubmatch(arg, -1) {
+			refs[m[1]] = true
+		}
+	}
+}
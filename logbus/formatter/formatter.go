@@ -9,6 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -46,6 +49,10 @@ var (
 	ansiEraseRestLine = fmt.Appendf(nil, "%c[K", esc)
 	ansiSupported     = os.Getenv("TERM") != "dumb" &&
 		(isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()))
+
+	// dockerLayersRegex matches the line printed by the dockerd wrapper once the
+	// images of a WITH DOCKER command are loaded, when their layers are reused.
+	dockerLayersRegex = regexp.MustCompile(`^Docker layers already present: (\d+) of (\d+) bytes\r?$`)
 )
 
 // TODO(vladaionescu): What to do with interactive mode? We need a way for an external
@@ -54,8 +61,14 @@ var (
 type command struct {
 	lastProgress time.Time
 	// openLine is the line of output that has not yet been terminated with a \n.
-	openLine       []byte
-	lastPercentage int32
+	openLine []byte
+	// dockerLayersLine is the line of output that has not yet been terminated
+	// with a \n, as scanned by observeDockerLayers.
+	dockerLayersLine []byte
+	lastPercentage   int32
+	// dockerLayersDone is set once the docker layers of the command were
+	// observed.
+	dockerLayersDone bool
 }
 
 // Formatter is a delta to console logger.
@@ -283,6 +296,56 @@ func (f *Formatter) getCommand(commandID string) *command {
 	return cmd
 }
 
+// observeDockerLayers records the bytes of the docker layers which were already
+// present when a WITH DOCKER command loaded its images, as printed by the
+// dockerd wrapper. Only the first such line of the command is considered,
+// since the wrapper prints it before the command of the user runs.
+func (f *Formatter) observeDockerLayers(cmd *command, targetID, commandID string, output []byte) {
+	if cmd.dockerLayersDone || !strings.HasPrefix(f.commandName(commandID), "WITH DOCKER RUN") {
+		return
+	}
+
+	data := slices.Concat(cmd.dockerLayersLine, output)
+
+	for {
+		line, rest, found := bytes.Cut(data, []byte{'\n'})
+		if !found {
+			break
+		}
+
+		data = rest
+
+		m := dockerLayersRegex.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		cmd.dockerLayersDone = true
+		cmd.dockerLayersLine = nil
+
+		present, err := strconv.ParseUint(string(m[1]), 10, 64)
+		if err != nil {
+			return
+		}
+
+		total, err := strconv.ParseUint(string(m[2]), 10, 64)
+		if err != nil {
+			return
+		}
+
+		f.execStatsTracker.ObserveDockerLayers(f.targetName(targetID), f.commandName(commandID), present, total)
+
+		return
+	}
+
+	// The line of the wrapper is short; longer ones are output of the user.
+	if len(data) > 256 {
+		data = nil
+	}
+
+	cmd.dockerLayersLine = slices.Clone(data)
+}
+
 func (f *Formatter) handleDeltaLog(dl *logstream.DeltaLog) error {
 	commandID := dl.GetCommandId()
 	targetID := dl.GetTargetId()
@@ -297,13 +360,17 @@ func (f *Formatter) handleDeltaLog(dl *logstream.DeltaLog) error {
 		rawOutput = strings.Contains(cm.GetName(), "RUN --raw-output")
 	}
 
+	cmd := f.getCommand(dl.GetCommandId())
+
+	if f.execStatsTracker != nil && dl.GetStream() != BuildkitStatsStream {
+		f.observeDockerLayers(cmd, targetID, commandID, dl.GetData())
+	}
+
 	c, verboseOnly := f.targetConsole(targetID, commandID, rawOutput)
 	if verboseOnly && !f.verbose {
 		return nil
 	}
 
-	cmd := f.getCommand(dl.GetCommandId())
-
 	sameAsLast := (!f.lastOutputWasOngoingUpdate &&
 		!f.lastOutputWasProgress &&
 		f.lastCommandOutput == cmd)
@@ -403,6 +470,10 @@ func (f *Formatter) printHeader(
 		metaParts = append(metaParts, cm.GetPlatform())
 	}
 
+	if tm.GetMatrixCell() != "" {
+		metaParts = append(metaParts, "matrix "+tm.GetMatrixCell())
+	}
+
 	if tm != nil && tm.GetOverrideArgs() != nil {
 		metaParts = append(metaParts, strings.Join(tm.GetOverrideArgs(), " "))
 	}
@@ -0,0 +1,360 @@
+// Package htmlsub implements a logbus subscriber which writes the build as a self-contained HTML report.
+package htmlsub
+
+import (
+	"bytes"
+	"cmp"
+	_ "embed" // For the report template.
+	"errors"
+	"fmt"
+	"html/template"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/internal/circbuf"
+	"github.com/EarthBuild/earthbuild/logbus/formatter"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/deltautil"
+	"github.com/EarthBuild/earthbuild/util/stringutil"
+)
+
+// maxLogBytes bounds the output kept for each command; the tail is kept.
+const maxLogBytes = 256 * 1024
+
+//go:embed report.html.tmpl
+var reportTmpl string
+
+var tmpl = template.Must(template.New("report").Parse(reportTmpl))
+
+// HTMLSub is a bus subscriber which tracks the manifest and the raw output of
+// the build, and writes them as a single HTML file when closed.
+type HTMLSub struct {
+	manifest *logstream.RunManifest
+	logs     map[string]*circbuf.Buffer // command ID -> output tail
+	err      error
+	path     string
+	mu       sync.Mutex
+}
+
+// New creates a new HTMLSub which writes its report to path.
+func New(path string) *HTMLSub {
+	return &HTMLSub{
+		path:     path,
+		manifest: &logstream.RunManifest{},
+		logs:     map[string]*circbuf.Buffer{},
+	}
+}
+
+// Write applies the given delta to the manifest of the build, and records the
+// output of its commands.
+func (hs *HTMLSub) Write(delta *logstream.Delta) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	err := deltautil.ApplyDelta(hs.manifest, delta)
+	if err != nil {
+		hs.err = errors.Join(hs.err, fmt.Errorf("failed to apply delta: %w", err))
+		return
+	}
+
+	dl := delta.GetDeltaLog()
+	if dl == nil || dl.GetStream() == formatter.BuildkitStatsStream {
+		return
+	}
+
+	buf, ok := hs.logs[dl.GetCommandId()]
+	if !ok {
+		buf, err = circbuf.NewBuffer(maxLogBytes)
+		if err != nil {
+			hs.err = errors.Join(hs.err, err)
+			return
+		}
+
+		hs.logs[dl.GetCommandId()] = buf
+	}
+
+	_, err = buf.Write(dl.GetData())
+	if err != nil {
+		hs.err = errors.Join(hs.err, err)
+	}
+}
+
+// Close writes the report and returns any error that occurred while tracking
+// the build or writing the report.
+func (hs *HTMLSub) Close() error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	err := tmpl.Execute(&buf, newReport(hs.manifest, hs.logs))
+	if err != nil {
+		return errors.Join(hs.err, fmt.Errorf("failed to render html report: %w", err))
+	}
+
+	err = os.WriteFile(hs.path, buf.Bytes(), 0o644) // #nosec G306
+	if err != nil {
+		return errors.Join(hs.err, fmt.Errorf("failed to write html report %s: %w", hs.path, err))
+	}
+
+	return hs.err
+}
+
+type report struct {
+	Started  string
+	Status   string
+	Duration string
+	Failure  string
+	Tree     []*treeNode
+	Targets  []*targetView
+}
+
+type treeNode struct {
+	Target   *targetView
+	Children []*treeNode
+	// Repeat marks a target already shown elsewhere in the tree.
+	Repeat bool
+}
+
+type targetView struct {
+	Name     string
+	Status   string
+	Duration string
+	Commands []*commandView
+	Cached   bool
+	started  uint64
+}
+
+type commandView struct {
+	Anchor   string
+	Name     string
+	Status   string
+	Duration string
+	Error    string
+	Source   string
+	Log      string
+	Left     float64 // percent of the build
+	Width    float64 // percent of the build
+	Cached   bool
+	Failed   bool
+	started  uint64
+}
+
+func newReport(m *logstream.RunManifest, logs map[string]*circbuf.Buffer) *report {
+	start, end := m.GetStartedAtUnixNanos(), m.GetEndedAtUnixNanos()
+
+	// The build may have been cut short; span every recorded command.
+	for _, cm := range m.GetCommands() {
+		if start == 0 || (cm.GetStartedAtUnixNanos() != 0 && cm.GetStartedAtUnixNanos() < start) {
+			start = cm.GetStartedAtUnixNanos()
+		}
+
+		end = max(end, cm.GetEndedAtUnixNanos(), cm.GetStartedAtUnixNanos())
+	}
+
+	r := &report{
+		Status:   status(m.GetStatus()),
+		Duration: duration(start, end),
+		Failure:  m.GetFailure().GetErrorMessage(),
+	}
+
+	if start != 0 {
+		r.Started = time.Unix(0, int64(start)).UTC().Format(time.RFC3339) // #nosec G115
+	}
+
+	targets := map[string]*targetView{}
+
+	for id, tm := range m.GetTargets() {
+		name := cmp.Or(tm.GetCanonicalName(), tm.GetName())
+		if tm.GetMatrixCell() != "" {
+			name += " [" + tm.GetMatrixCell() + "]"
+		}
+
+		if tm.GetFinalPlatform() != "" {
+			name += " (" + tm.GetFinalPlatform() + ")"
+		}
+
+		targets[id] = &targetView{
+			Name:     name,
+			Status:   status(tm.GetStatus()),
+			Duration: duration(tm.GetStartedAtUnixNanos(), tm.GetEndedAtUnixNanos()),
+			started:  tm.GetStartedAtUnixNanos(),
+		}
+	}
+
+	other := &targetView{Name: "earth"}
+
+	for id, cm := range m.GetCommands() {
+		tv, ok := targets[cm.GetTargetId()]
+		if !ok {
+			tv = other
+		}
+
+		cv := &commandView{
+			Name:     cm.GetName(),
+			Status:   status(cm.GetStatus()),
+			Duration: duration(cm.GetStartedAtUnixNanos(), cm.GetEndedAtUnixNanos()),
+			Error:    cm.GetErrorMessage(),
+			Cached:   cm.GetIsCached(),
+			Failed:   cm.GetStatus() == logstream.RunStatus_RUN_STATUS_FAILURE || m.GetFailure().GetCommandId() == id,
+			started:  cm.GetStartedAtUnixNanos(),
+		}
+
+		if sl := cm.GetSourceLocation(); sl != nil {
+			cv.Source = fmt.Sprintf("%s:%d", sl.GetFile(), sl.GetStartLine())
+		}
+
+		if buf, ok := logs[id]; ok {
+			cv.Log = stringutil.ScrubANSICodes(string(buf.Bytes()))
+		}
+
+		if end > start && cm.GetStartedAtUnixNanos() != 0 {
+			cmdEnd := cmp.Or(cm.GetEndedAtUnixNanos(), end)
+			cv.Left = percent(cm.GetStartedAtUnixNanos()-start, end-start)
+			cv.Width = max(percent(cmdEnd-cm.GetStartedAtUnixNanos(), end-start), 0.2)
+		}
+
+		tv.Commands = append(tv.Commands, cv)
+	}
+
+	for _, tv := range targets {
+		r.Targets = append(r.Targets, tv)
+	}
+
+	if len(other.Commands) > 0 {
+		r.Targets = append(r.Targets, other)
+	}
+
+	slices.SortFunc(r.Targets, func(a, b *targetView) int {
+		return cmp.Or(cmp.Compare(a.started, b.started), cmp.Compare(a.Name, b.Name))
+	})
+
+	n := 0
+
+	for _, tv := range r.Targets {
+		slices.SortFunc(tv.Commands, func(a, b *commandView) int {
+			return cmp.Or(cmp.Compare(a.started, b.started), cmp.Compare(a.Name, b.Name))
+		})
+
+		cached := 0
+
+		for _, cv := range tv.Commands {
+			n++
+			cv.Anchor = fmt.Sprintf("cmd-%d", n)
+
+			if cv.Cached {
+				cached++
+			}
+		}
+
+		tv.Cached = len(tv.Commands) > 0 && cached == len(tv.Commands)
+	}
+
+	r.Tree = dependencyTree(m, targets)
+
+	return r
+}
+
+// dependencyTree returns the tree of targets, rooted at the main target,
+// following their depends_on. Targets no other target depends on are roots
+// too.
+func dependencyTree(m *logstream.RunManifest, targets map[string]*targetView) []*treeNode {
+	dependedOn := map[string]bool{}
+
+	for _, tm := range m.GetTargets() {
+		for _, dep := range tm.GetDependsOn() {
+			dependedOn[dep] = true
+		}
+	}
+
+	var roots []string
+
+	if _, ok := targets[m.GetMainTargetId()]; ok {
+		roots = append(roots, m.GetMainTargetId())
+	}
+
+	var others []string
+
+	for id := range targets {
+		if !dependedOn[id] && id != m.GetMainTargetId() {
+			others = append(others, id)
+		}
+	}
+
+	slices.SortFunc(others, func(a, b string) int {
+		return cmp.Or(cmp.Compare(targets[a].started, targets[b].started), cmp.Compare(a, b))
+	})
+
+	roots = append(roots, others...)
+
+	shown := map[string]bool{}
+
+	var build func(id string) *treeNode
+
+	build = func(id string) *treeNode {
+		node := &treeNode{Target: targets[id]}
+		if shown[id] {
+			node.Repeat = true
+			return node
+		}
+
+		shown[id] = true
+
+		for _, dep := range m.GetTargets()[id].GetDependsOn() {
+			if _, ok := targets[dep]; ok {
+				node.Children = append(node.Children, build(dep))
+			}
+		}
+
+		return node
+	}
+
+	var tree []*treeNode
+
+	for _, id := range roots {
+		if !shown[id] {
+			tree = append(tree, build(id))
+		}
+	}
+
+	// Targets only reachable through a cycle.
+	for _, id := range slices.Sorted(maps.Keys(targets)) {
+		if !shown[id] {
+			tree = append(tree, build(id))
+		}
+	}
+
+	return tree
+}
+
+func status(s logstream.RunStatus) string {
+	switch s { //nolint:exhaustive // The others are all unknown.
+	case logstream.RunStatus_RUN_STATUS_SUCCESS:
+		return "success"
+	case logstream.RunStatus_RUN_STATUS_FAILURE:
+		return "failure"
+	case logstream.RunStatus_RUN_STATUS_CANCELED:
+		return "canceled"
+	case logstream.RunStatus_RUN_STATUS_IN_PROGRESS:
+		return "in progress"
+	case logstream.RunStatus_RUN_STATUS_NOT_STARTED:
+		return "not started"
+	default:
+		return "unknown"
+	}
+}
+
+func duration(start, end uint64) string {
+	if start == 0 || end < start {
+		return ""
+	}
+
+	return time.Duration(end - start).Round(time.Millisecond).String() // #nosec G115
+}
+
+func percent(part, total uint64) float64 {
+	return float64(part) * 100 / float64(total)
+}
@@ -0,0 +1,106 @@
+package htmlsub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/logbus"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLSub(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "report.html")
+	bus := logbus.New()
+	run := bus.Run()
+	start := bus.CreatedAt()
+
+	run.SetStart(start)
+
+	main, err := run.NewTarget("t1", domain.Target{Target: "all"}, nil, "", "")
+	require.NoError(t, err)
+	main.SetStart(start)
+	main.AddDependsOn("t2")
+
+	dep, err := run.NewTarget("t2", domain.Target{Target: "test"}, nil, "", "")
+	require.NoError(t, err)
+	dep.SetStart(start)
+
+	cached, err := run.NewCommand("c1", "COPY . .", "t2", "", "", true, false, false, nil, "", "", "")
+	require.NoError(t, err)
+	cached.SetEnd(start, logstream.RunStatus_RUN_STATUS_SUCCESS, "")
+
+	sl := &earthfile.SourceLocation{File: "Earthfile", StartLine: 4}
+
+	failed, err := run.NewCommand("c2", "RUN go test", "t2", "", "", false, false, false, sl, "", "", "")
+	require.NoError(t, err)
+	failed.SetStart(start.Add(time.Second))
+
+	// Subscribers added late receive the whole history.
+	sub := New(path)
+	bus.AddRawSubscriber(sub)
+
+	_, err = failed.Write([]byte("\x1b[31m--- FAIL: TestX <script>\x1b[0m\n"), start.Add(2*time.Second), 1)
+	require.NoError(t, err)
+
+	failed.SetEnd(start.Add(2*time.Second), logstream.RunStatus_RUN_STATUS_FAILURE, "exit code 1")
+	dep.SetEnd(start.Add(3*time.Second), logstream.RunStatus_RUN_STATUS_FAILURE, "linux/amd64")
+	main.SetEnd(start.Add(4*time.Second), logstream.RunStatus_RUN_STATUS_FAILURE, "linux/amd64")
+	run.SetEnd(start.Add(4*time.Second), logstream.RunStatus_RUN_STATUS_FAILURE)
+
+	require.NoError(t, sub.Close())
+
+	dt, err := os.ReadFile(path) // #nosec G304
+	require.NoError(t, err)
+
+	html := string(dt)
+	require.Contains(t, html, "&#43;all (linux/amd64)")
+	require.Contains(t, html, "&#43;test (linux/amd64)")
+	require.Contains(t, html, `<span class="cached">cached</span>`)
+	require.Contains(t, html, `<details class="command failed" id="cmd-2" open>`)
+	require.Contains(t, html, "Earthfile:4")
+	require.Contains(t, html, "exit code 1")
+	require.Contains(t, html, "--- FAIL: TestX &lt;script&gt;")
+	require.Contains(t, html, "left: 25.000%; width: 25.000%")
+	require.NotContains(t, html, "<script>")
+	require.NotContains(t, html, "ZgotmplZ")
+}
+
+func TestDependencyTree(t *testing.T) {
+	t.Parallel()
+
+	m := &logstream.RunManifest{
+		MainTargetId: "a",
+		Targets: map[string]*logstream.TargetManifest{
+			"a": {Name: "+a", DependsOn: []string{"b", "c"}},
+			"b": {Name: "+b", DependsOn: []string{"c"}},
+			"c": {Name: "+c"},
+			"d": {Name: "+d", DependsOn: []string{"e"}},
+			"e": {Name: "+e", DependsOn: []string{"d"}},
+		},
+	}
+
+	r := newReport(m, nil)
+
+	require.Len(t, r.Tree, 2)
+
+	a := r.Tree[0]
+	require.Equal(t, "+a", a.Target.Name)
+	require.Len(t, a.Children, 2)
+	require.Equal(t, "+b", a.Children[0].Target.Name)
+	require.Equal(t, "+c", a.Children[0].Children[0].Target.Name)
+	require.False(t, a.Children[0].Children[0].Repeat)
+	require.Equal(t, "+c", a.Children[1].Target.Name)
+	require.True(t, a.Children[1].Repeat)
+
+	// The cycle is only reachable from itself.
+	require.Equal(t, "+d", r.Tree[1].Target.Name)
+	require.Equal(t, "+e", r.Tree[1].Children[0].Target.Name)
+	require.True(t, r.Tree[1].Children[0].Children[0].Repeat)
+}
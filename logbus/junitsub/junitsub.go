@@ -0,0 +1,271 @@
+// Package junitsub implements a logbus subscriber which writes the build as a JUnit XML report.
+package junitsub
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/logbus"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/buildresult"
+	"github.com/EarthBuild/earthbuild/util/deltautil"
+	"github.com/EarthBuild/earthbuild/util/stringutil"
+)
+
+// buildSuite is the name of the suite of the commands and failures which don't
+// belong to a target.
+const buildSuite = "earth"
+
+// JUnitSub is a bus subscriber which tracks the manifest of the build, and
+// writes it as a JUnit XML report when closed. Each target is a testsuite,
+// and each of its commands a testcase.
+type JUnitSub struct {
+	bus      *logbus.Bus
+	manifest *logstream.RunManifest
+	err      error
+	path     string
+	mu       sync.Mutex
+}
+
+// New creates a new JUnitSub which writes its report to path. The bus is used
+// to fetch the tail output of failed commands.
+func New(bus *logbus.Bus, path string) *JUnitSub {
+	return &JUnitSub{
+		bus:      bus,
+		path:     path,
+		manifest: &logstream.RunManifest{},
+	}
+}
+
+// Write applies the given delta to the manifest of the build.
+func (js *JUnitSub) Write(delta *logstream.Delta) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	err := deltautil.ApplyDelta(js.manifest, delta)
+	if err != nil {
+		js.err = errors.Join(js.err, fmt.Errorf("failed to apply delta: %w", err))
+	}
+}
+
+// Close writes the report and returns any error that occurred while tracking
+// the build or writing the report.
+func (js *JUnitSub) Close() error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	report := newReport(js.manifest, js.tailOutput)
+
+	dt, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Join(js.err, fmt.Errorf("failed to marshal junit report: %w", err))
+	}
+
+	dt = append([]byte(xml.Header), append(dt, '\n')...)
+
+	err = os.WriteFile(js.path, dt, 0o644) // #nosec G306
+	if err != nil {
+		return errors.Join(js.err, fmt.Errorf("failed to write junit report %s: %w", js.path, err))
+	}
+
+	return js.err
+}
+
+func (js *JUnitSub) tailOutput(commandID string) []byte {
+	c, ok := js.bus.Run().Command(commandID)
+	if !ok {
+		return nil
+	}
+
+	return c.TailOutput()
+}
+
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     seconds     `xml:"time,attr"`
+}
+
+type testSuite struct {
+	Name      string     `xml:"name,attr"`
+	Timestamp string     `xml:"timestamp,attr,omitempty"`
+	Cases     []testCase `xml:"testcase"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	Time      seconds    `xml:"time,attr"`
+	started   uint64
+}
+
+type testCase struct {
+	Failure   *failure `xml:"failure,omitempty"`
+	Skipped   *skipped `xml:"skipped,omitempty"`
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	File      string   `xml:"file,attr,omitempty"`
+	Line      int32    `xml:"line,attr,omitempty"`
+	Time      seconds  `xml:"time,attr"`
+	started   uint64
+}
+
+type failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Output  string `xml:",chardata"`
+}
+
+type skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// seconds is a duration in nanoseconds, formatted as seconds.
+type seconds uint64
+
+func (s seconds) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: strconv.FormatFloat(time.Duration(s).Seconds(), 'f', 3, 64)}, nil // #nosec G115
+}
+
+func newReport(m *logstream.RunManifest, tailOutput func(commandID string) []byte) *testSuites {
+	suites := map[string]*testSuite{}
+
+	suite := func(targetID string) *testSuite {
+		s, ok := suites[targetID]
+		if ok {
+			return s
+		}
+
+		s = &testSuite{Name: buildSuite}
+
+		if tm, ok := m.GetTargets()[targetID]; ok {
+			s.Name = cmp.Or(tm.GetCanonicalName(), tm.GetName())
+			if tm.GetMatrixCell() != "" {
+				s.Name += " [" + tm.GetMatrixCell() + "]"
+			}
+
+			if tm.GetFinalPlatform() != "" {
+				s.Name += " (" + tm.GetFinalPlatform() + ")"
+			}
+
+			s.started = tm.GetStartedAtUnixNanos()
+			s.Time = duration(tm.GetStartedAtUnixNanos(), tm.GetEndedAtUnixNanos())
+		}
+
+		if s.started != 0 {
+			s.Timestamp = time.Unix(0, int64(s.started)).UTC().Format(time.RFC3339) // #nosec G115
+		}
+
+		suites[targetID] = s
+
+		return s
+	}
+
+	fatal := m.GetFailure()
+	fatalReported := false
+
+	for id, cm := range m.GetCommands() {
+		s := suite(cm.GetTargetId())
+
+		tc := testCase{
+			Name:      cm.GetName(),
+			ClassName: s.Name,
+			Time:      duration(cm.GetStartedAtUnixNanos(), cm.GetEndedAtUnixNanos()),
+			started:   cm.GetStartedAtUnixNanos(),
+		}
+
+		if sl := cm.GetSourceLocation(); sl != nil {
+			tc.File = sl.GetFile()
+			tc.Line = sl.GetStartLine()
+		}
+
+		isFatal := fatal != nil && fatal.GetCommandId() == id
+
+		switch {
+		case cm.GetStatus() == logstream.RunStatus_RUN_STATUS_FAILURE || isFatal:
+			tc.Failure = &failure{
+				Message: cm.GetErrorMessage(),
+				Output:  stringutil.ScrubANSICodes(string(tailOutput(id))),
+			}
+
+			if isFatal {
+				tc.Failure.Message = cmp.Or(fatal.GetErrorMessage(), tc.Failure.Message)
+				tc.Failure.Type = buildresult.FailureType(fatal.GetType())
+				fatalReported = true
+			}
+		case cm.GetIsCached():
+			tc.Skipped = &skipped{Message: "cached"}
+		case cm.GetStatus() == logstream.RunStatus_RUN_STATUS_CANCELED:
+			tc.Skipped = &skipped{Message: "canceled"}
+		case cm.GetStatus() == logstream.RunStatus_RUN_STATUS_NOT_STARTED,
+			cm.GetStatus() == logstream.RunStatus_RUN_STATUS_UNKNOWN:
+			tc.Skipped = &skipped{Message: "not run"}
+		}
+
+		s.Cases = append(s.Cases, tc)
+	}
+
+	if fatal != nil && !fatalReported {
+		s := suite(fatal.GetTargetId())
+		s.Cases = append(s.Cases, testCase{
+			Name:      "build",
+			ClassName: s.Name,
+			Failure: &failure{
+				Message: fatal.GetErrorMessage(),
+				Type:    buildresult.FailureType(fatal.GetType()),
+				Output:  stringutil.ScrubANSICodes(string(fatal.GetOutput())),
+			},
+		})
+	}
+
+	report := &testSuites{
+		Name: buildSuite,
+		Time: duration(m.GetStartedAtUnixNanos(), m.GetEndedAtUnixNanos()),
+	}
+
+	for _, s := range suites {
+		slices.SortFunc(s.Cases, func(a, b testCase) int {
+			return cmp.Or(cmp.Compare(a.started, b.started), cmp.Compare(a.Name, b.Name))
+		})
+
+		for _, tc := range s.Cases {
+			s.Tests++
+
+			if tc.Failure != nil {
+				s.Failures++
+			}
+
+			if tc.Skipped != nil {
+				s.Skipped++
+			}
+		}
+
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Skipped += s.Skipped
+		report.Suites = append(report.Suites, *s)
+	}
+
+	slices.SortFunc(report.Suites, func(a, b testSuite) int {
+		return cmp.Or(cmp.Compare(a.started, b.started), cmp.Compare(a.Name, b.Name))
+	})
+
+	return report
+}
+
+func duration(start, end uint64) seconds {
+	if start == 0 || end < start {
+		return 0
+	}
+
+	return seconds(end - start)
+}
@@ -0,0 +1,92 @@
+package junitsub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/logbus"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJUnitSub(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	bus := logbus.New()
+	run := bus.Run()
+	start := bus.CreatedAt()
+
+	run.SetStart(start)
+
+	target, err := run.NewTarget("t1", domain.Target{Target: "test"}, nil, "", "")
+	require.NoError(t, err)
+	target.SetStart(start)
+	target.SetMatrixCell("go1.22")
+
+	cached, err := run.NewCommand("c1", "COPY . .", "t1", "", "", true, false, false, nil, "", "", "")
+	require.NoError(t, err)
+	cached.SetEnd(start, logstream.RunStatus_RUN_STATUS_SUCCESS, "")
+
+	sl := &earthfile.SourceLocation{File: "Earthfile", StartLine: 4}
+
+	failed, err := run.NewCommand("c2", "RUN go test", "t1", "", "", false, false, false, sl, "", "", "")
+	require.NoError(t, err)
+	failed.SetStart(start.Add(time.Second))
+
+	_, err = failed.Write([]byte("\x1b[31m--- FAIL: TestX\x1b[0m\n"), start.Add(2*time.Second), 1)
+	require.NoError(t, err)
+
+	failed.SetEnd(start.Add(2500*time.Millisecond), logstream.RunStatus_RUN_STATUS_FAILURE, "exit code 1")
+
+	// Subscribers added late receive the whole history.
+	sub := New(bus, path)
+	bus.AddRawSubscriber(sub)
+
+	target.SetEnd(start.Add(3*time.Second), logstream.RunStatus_RUN_STATUS_FAILURE, "linux/amd64")
+	run.SetFatalError(start.Add(3*time.Second), "t1", "c2", logstream.FailureType_FAILURE_TYPE_NONZERO_EXIT, "",
+		"the command go test did not complete successfully")
+
+	require.NoError(t, sub.Close())
+
+	dt, err := os.ReadFile(path) // #nosec G304
+	require.NoError(t, err)
+
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="earth" tests="2" failures="1" skipped="1" time="3.000">
+  <testsuite name="+test [go1.22] (linux/amd64)" timestamp="`+start.UTC().Format(time.RFC3339)+
+		`" tests="2" failures="1" skipped="1" time="3.000">
+    <testcase name="COPY . ." classname="+test [go1.22] (linux/amd64)" time="0.000">
+      <skipped message="cached"></skipped>
+    </testcase>
+    <testcase name="RUN go test" classname="+test [go1.22] (linux/amd64)" file="Earthfile" line="4" time="1.500">
+      <failure message="the command go test did not complete successfully" `+
+		`type="nonzero-exit">--- FAIL: TestX&#xA;</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(dt))
+}
+
+func TestNewReportFatalWithoutCommand(t *testing.T) {
+	t.Parallel()
+
+	report := newReport(&logstream.RunManifest{
+		Failure: &logstream.Failure{
+			Type:         logstream.FailureType_FAILURE_TYPE_SYNTAX,
+			CommandId:    logbus.GenericDefault,
+			ErrorMessage: "syntax error",
+		},
+	}, func(string) []byte { return nil })
+
+	require.Equal(t, 1, report.Failures)
+	require.Len(t, report.Suites, 1)
+	require.Equal(t, "earth", report.Suites[0].Name)
+	require.Equal(t, "build", report.Suites[0].Cases[0].Name)
+	require.Equal(t, "syntax error", report.Suites[0].Cases[0].Failure.Message)
+	require.Equal(t, "syntax", report.Suites[0].Cases[0].Failure.Type)
+}
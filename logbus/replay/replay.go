@@ -0,0 +1,154 @@
+// Package replay replays the deltas recorded by --logstream-debug-file as the console output of the build.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/logbus"
+	"github.com/EarthBuild/earthbuild/logbus/formatter"
+	"github.com/EarthBuild/earthbuild/logbus/writersub"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/deltautil"
+)
+
+// fullTargetID is the ID under which formatted logs hold the whole output.
+const fullTargetID = "_full"
+
+// Opt holds the options of a replay.
+type Opt struct {
+	// Out receives the console output.
+	Out io.Writer
+	// Target, if set, only replays the output of the targets with this name or
+	// canonical name.
+	Target string
+	// Speed is the replay speed relative to the original build, e.g. 1 for real
+	// time or 2 for twice as fast. Zero replays as fast as possible.
+	Speed float64
+	// Verbose replays the output of verbose-only commands.
+	Verbose bool
+}
+
+// Replay formats the given raw deltas, as recorded by a
+// writersub.RawWriterSub, into console output. Recorded formatted logs are
+// ignored; the output is reformatted from the raw deltas.
+func Replay(ctx context.Context, deltas []*logstream.Delta, opt Opt) error {
+	if opt.Speed < 0 {
+		return errors.New("speed must not be negative")
+	}
+
+	targetIDs := []string{fullTargetID}
+
+	if opt.Target != "" {
+		var err error
+
+		targetIDs, err = findTargetIDs(deltas, opt.Target)
+		if err != nil {
+			return err
+		}
+	}
+
+	bus := logbus.New()
+	f := formatter.New(ctx, bus, false, opt.Verbose, false, true, nil, false)
+
+	var subs []*writersub.WriterSub
+
+	for _, id := range targetIDs {
+		ws := writersub.New(opt.Out, id)
+		bus.AddFormattedSubscriber(ws)
+		subs = append(subs, ws)
+	}
+
+	var last uint64
+
+	for _, delta := range deltas {
+		if delta.GetDeltaFormattedLog() != nil {
+			continue
+		}
+
+		ts := deltaTime(delta)
+		if opt.Speed > 0 && last != 0 && ts > last {
+			err := sleep(ctx, time.Duration(float64(ts-last)/opt.Speed))
+			if err != nil {
+				return err
+			}
+		}
+
+		if ts > last {
+			last = ts
+		}
+
+		f.Write(delta)
+	}
+
+	err := f.Close()
+
+	for _, ws := range subs {
+		err = errors.Join(err, ws.Err())
+	}
+
+	return err
+}
+
+// findTargetIDs returns the IDs of the targets with the given name or
+// canonical name, one for each platform they were built for.
+func findTargetIDs(deltas []*logstream.Delta, name string) ([]string, error) {
+	m := &logstream.RunManifest{}
+
+	for _, delta := range deltas {
+		err := deltautil.ApplyDelta(m, delta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply delta: %w", err)
+		}
+	}
+
+	var ids []string
+
+	for id, tm := range m.GetTargets() {
+		if tm.GetName() == name || tm.GetCanonicalName() == name {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("target %s not found in the recorded build", name)
+	}
+
+	return ids, nil
+}
+
+// deltaTime returns the latest time recorded in the delta, in nanoseconds
+// since the epoch, or zero if it has none.
+func deltaTime(delta *logstream.Delta) uint64 {
+	if dl := delta.GetDeltaLog(); dl != nil {
+		return dl.GetTimestampUnixNanos()
+	}
+
+	fields := delta.GetDeltaManifest().GetFields()
+	ts := max(fields.GetStartedAtUnixNanos(), fields.GetEndedAtUnixNanos())
+
+	for _, tm := range fields.GetTargets() {
+		ts = max(ts, tm.GetStartedAtUnixNanos(), tm.GetEndedAtUnixNanos())
+	}
+
+	for _, cm := range fields.GetCommands() {
+		ts = max(ts, cm.GetStartedAtUnixNanos(), cm.GetEndedAtUnixNanos())
+	}
+
+	return ts
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package replay
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/domain"
+	"github.com/EarthBuild/earthbuild/logbus"
+	"github.com/EarthBuild/earthbuild/logbus/writersub"
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/stretchr/testify/require"
+)
+
+func record(t *testing.T, json bool) []*logstream.Delta {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	bus := logbus.New()
+	rec := writersub.NewRaw(&buf, json)
+	bus.AddSubscriber(rec)
+
+	run := bus.Run()
+	start := bus.CreatedAt()
+	run.SetStart(start)
+
+	for i, name := range []string{"build", "test"} {
+		id := "t-" + name
+
+		_, err := run.NewTarget(id, domain.Target{Target: name}, nil, "", "")
+		require.NoError(t, err)
+
+		c, err := run.NewCommand("c-"+name, "RUN ./"+name+".sh", id, "", "", false, false, false, nil, "", "", "")
+		require.NoError(t, err)
+
+		c.SetStart(start.Add(time.Duration(i) * time.Second))
+
+		_, err = c.Write([]byte("hello from "+name+"\n"), start.Add(time.Duration(i)*time.Second), 1)
+		require.NoError(t, err)
+
+		c.SetEnd(start.Add(time.Duration(i+1)*time.Second), logstream.RunStatus_RUN_STATUS_SUCCESS, "")
+	}
+
+	run.SetEnd(start.Add(2*time.Second), logstream.RunStatus_RUN_STATUS_SUCCESS)
+	require.NoError(t, rec.Err())
+
+	deltas, err := writersub.ReadRaw(&buf, json)
+	require.NoError(t, err)
+
+	return deltas
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	for _, json := range []bool{false, true} {
+		deltas := record(t, json)
+
+		var out bytes.Buffer
+		require.NoError(t, Replay(t.Context(), deltas, Opt{Out: &out}))
+		require.Contains(t, out.String(), "RUN ./build.sh")
+		require.Contains(t, out.String(), "hello from build")
+		require.Contains(t, out.String(), "hello from test")
+
+		out.Reset()
+		require.NoError(t, Replay(t.Context(), deltas, Opt{Out: &out, Target: "+test"}))
+		require.NotContains(t, out.String(), "hello from build")
+		require.Contains(t, out.String(), "hello from test")
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	t.Parallel()
+
+	deltas := record(t, false)
+
+	var out bytes.Buffer
+
+	start := time.Now()
+	require.NoError(t, Replay(t.Context(), deltas, Opt{Out: &out, Speed: 10}))
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestReplayUnknownTarget(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	err := Replay(t.Context(), record(t, false), Opt{Out: &out, Target: "+nope"})
+	require.ErrorContains(t, err, "target +nope not found")
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/EarthBuild/earthbuild/logbus"
 	"github.com/EarthBuild/earthbuild/logbus/formatter"
+	"github.com/EarthBuild/earthbuild/logbus/htmlsub"
+	"github.com/EarthBuild/earthbuild/logbus/junitsub"
 	"github.com/EarthBuild/earthbuild/logbus/solvermon"
 	"github.com/EarthBuild/earthbuild/logbus/writersub"
 	"github.com/EarthBuild/earthbuild/logstream"
@@ -26,6 +28,8 @@ type BusSetup struct {
 	Formatter        *formatter.Formatter
 	SolverMonitor    *solvermon.SolverMonitor
 	BusDebugWriter   *writersub.RawWriterSub
+	JUnitWriter      *junitsub.JUnitSub
+	HTMLWriter       *htmlsub.HTMLSub
 	InitialManifest  *logstream.RunManifest
 	execStatsTracker *execstatssummary.Tracker
 }
@@ -84,16 +88,37 @@ func (bs *BusSetup) SetGitAuthor(gitAuthor, gitCommitEmail string) {
 	bs.InitialManifest.GitConfigEmail = gitCommitEmail
 }
 
+// SetJUnitReport enables writing a JUnit XML report of the build to the given
+// file when the bus setup is closed.
+func (bs *BusSetup) SetJUnitReport(path string) {
+	bs.JUnitWriter = junitsub.New(bs.Bus, path)
+	bs.Bus.AddRawSubscriber(bs.JUnitWriter)
+}
+
+// SetHTMLReport enables writing an HTML report of the build to the given file
+// when the bus setup is closed.
+func (bs *BusSetup) SetHTMLReport(path string) {
+	bs.HTMLWriter = htmlsub.New(path)
+	bs.Bus.AddRawSubscriber(bs.HTMLWriter)
+}
+
 // SetCI tracks whether this build is being run in a CI environment.
 func (bs *BusSetup) SetCI(isCI bool) {
 	bs.InitialManifest.IsCi = isCI
 }
 
-// DumpManifestToFile dumps the manifest to the given file.
-func (bs *BusSetup) DumpManifestToFile(path string) error {
+// Manifest returns a copy of the manifest of the run.
+func (bs *BusSetup) Manifest() *logstream.RunManifest {
 	m := bs.Formatter.Manifest()
 	proto.Merge(m, bs.InitialManifest)
 
+	return m
+}
+
+// DumpManifestToFile dumps the manifest to the given file.
+func (bs *BusSetup) DumpManifestToFile(path string) error {
+	m := bs.Manifest()
+
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644) // #nosec G302, G304
 	if err != nil {
 		return fmt.Errorf("failed to open bus manifest debug file %s: %w", path, err)
@@ -132,6 +157,8 @@ func (bs *BusSetup) Close() error {
 	var err error
 
 	if bs.execStatsTracker != nil {
+		bs.execStatsTracker.SetManifest(bs.Manifest())
+
 		trackerErr := bs.execStatsTracker.Close()
 		if trackerErr != nil {
 			err = errors.Join(err, fmt.Errorf("exec stats summary: %w", trackerErr))
@@ -155,5 +182,19 @@ func (bs *BusSetup) Close() error {
 		}
 	}
 
+	if bs.JUnitWriter != nil {
+		junitErr := bs.JUnitWriter.Close()
+		if junitErr != nil {
+			err = errors.Join(err, fmt.Errorf("junit writer: %w", junitErr))
+		}
+	}
+
+	if bs.HTMLWriter != nil {
+		htmlErr := bs.HTMLWriter.Close()
+		if htmlErr != nil {
+			err = errors.Join(err, fmt.Errorf("html writer: %w", htmlErr))
+		}
+	}
+
 	return err
 }
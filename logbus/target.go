@@ -40,6 +40,14 @@ func (t *Target) SetEnd(end time.Time, status logstream.RunStatus, finalPlatform
 	})
 }
 
+// SetMatrixCell sets the name of the cell of a BUILD matrix the target is
+// built for.
+func (t *Target) SetMatrixCell(name string) {
+	t.targetDelta(&logstream.DeltaTargetManifest{
+		MatrixCell: name,
+	})
+}
+
 // AddDependsOn creates a delta that will be used to merge the specified target
 // ID into the current target's list of targets on which it depends.
 func (t *Target) AddDependsOn(targetID string) {
@@ -1,13 +1,16 @@
 package writersub
 
 import (
+	"bufio"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 
 	"github.com/EarthBuild/earthbuild/logstream"
+	"google.golang.org/protobuf/encoding/protodelim"
 	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/proto"
 )
 
 // RawWriterSub is a bus subscriber that can print formatted logs to a writer.
@@ -26,29 +29,28 @@ func NewRaw(w io.Writer, json bool) *RawWriterSub {
 	}
 }
 
-// Write writes the given delta to the writer, if it is a formatted log delta.
+// Write writes the given delta to the writer: as a line of JSON, or as a
+// size-delimited protobuf message. ReadRaw reads them back.
 func (rws *RawWriterSub) Write(delta *logstream.Delta) {
 	rws.mu.Lock()
 	defer rws.mu.Unlock()
 
-	var (
-		dt  []byte
-		err error
-	)
+	if !rws.json {
+		_, err := protodelim.MarshalTo(rws.w, delta)
+		if err != nil {
+			rws.err = errors.Join(rws.err, err)
+		}
 
-	if rws.json {
-		dt, err = protojson.Marshal(delta)
-		dt = append(dt, '\n')
-	} else {
-		dt, err = proto.Marshal(delta)
+		return
 	}
 
+	dt, err := protojson.Marshal(delta)
 	if err != nil {
 		rws.err = errors.Join(rws.err, err)
 		return
 	}
 
-	_, err = rws.w.Write(dt)
+	_, err = rws.w.Write(append(dt, '\n'))
 	if err != nil {
 		rws.err = errors.Join(rws.err, err)
 		return
@@ -62,3 +64,57 @@ func (rws *RawWriterSub) Err() error {
 
 	return rws.err
 }
+
+// ReadRaw reads back the deltas written by a RawWriterSub.
+func ReadRaw(r io.Reader, json bool) ([]*logstream.Delta, error) {
+	if json {
+		return readRawJSON(r)
+	}
+
+	br := bufio.NewReader(r)
+
+	var deltas []*logstream.Delta
+
+	for {
+		delta := &logstream.Delta{}
+
+		err := protodelim.UnmarshalFrom(br, delta)
+		if errors.Is(err, io.EOF) {
+			return deltas, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delta %d: %w", len(deltas)+1, err)
+		}
+
+		deltas = append(deltas, delta)
+	}
+}
+
+func readRawJSON(r io.Reader) ([]*logstream.Delta, error) {
+	dec := json.NewDecoder(r)
+
+	var deltas []*logstream.Delta
+
+	for {
+		var raw json.RawMessage
+
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return deltas, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delta %d: %w", len(deltas)+1, err)
+		}
+
+		delta := &logstream.Delta{}
+
+		err = protojson.Unmarshal(raw, delta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode delta %d: %w", len(deltas)+1, err)
+		}
+
+		deltas = append(deltas, delta)
+	}
+}
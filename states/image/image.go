@@ -4,6 +4,7 @@ package image
 
 import (
 	"maps"
+	"slices"
 
 	"github.com/EarthBuild/earthbuild/util/llbutil"
 	"github.com/moby/buildkit/exporter/containerimage/image"
@@ -43,6 +44,7 @@ func (img *Image) Clone() *Image {
 		Architecture: img.Architecture,
 		OS:           img.OS,
 		Config: Config{
+			Shell: slices.Clone(img.Config.Shell),
 			ImageConfig: specs.ImageConfig{
 				User:         img.Config.User,
 				Env:          make([]string, len(img.Config.Env)),
@@ -83,5 +85,7 @@ func (img *Image) Clone() *Image {
 //nolint:embeddedstructfieldcheck // fieldalignment takes precedence over embeddedstructfieldcheck
 type Config struct {
 	Healthcheck *image.HealthConfig `json:",omitempty"`
+	// Shell is the shell used for the shell form of RUN, CMD and ENTRYPOINT.
+	Shell []string `json:",omitempty"`
 	specs.ImageConfig
 }
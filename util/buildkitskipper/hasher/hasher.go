@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"context"
 	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -68,6 +69,24 @@ func (h *Hasher) HashBytes(b []byte) {
 
 // HashFile hashes a file.
 func (h *Hasher) HashFile(ctx context.Context, src string) error {
+	return h.hashFile(ctx, src, h.h)
+}
+
+// HashFileDigest hashes a file like HashFile, and also returns the SHA-256
+// digest of its contents, without reading it twice.
+func (h *Hasher) HashFileDigest(ctx context.Context, src string) ([]byte, error) {
+	d := sha256.New()
+
+	err := h.hashFile(ctx, src, io.MultiWriter(h.h, d))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Sum(nil), nil
+}
+
+// hashFile hashes a file, writing its contents to w.
+func (h *Hasher) hashFile(ctx context.Context, src string, w io.Writer) error {
 	stat, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -104,7 +123,7 @@ func (h *Hasher) HashFile(ctx context.Context, src string) error {
 			case err != nil:
 				return err
 			default:
-				h.h.Write(buf[:n])
+				_, _ = w.Write(buf[:n])
 			}
 		case <-ctx.Done():
 			return ctx.Err()
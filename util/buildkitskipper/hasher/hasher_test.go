@@ -2,7 +2,9 @@ package hasher_test
 
 import (
 	"context"
+	"encoding/hex"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/EarthBuild/earthbuild/util/buildkitskipper/hasher"
@@ -76,3 +78,21 @@ func TestHashFile(t *testing.T) {
 	NotNil(t, hash)
 	NotEqual(t, hash, emptyHash)
 }
+
+func TestHashFileDigest(t *testing.T) {
+	t.Parallel()
+
+	name := filepath.Join(t.TempDir(), "file-to-hash")
+	NoError(t, os.WriteFile(name, []byte("hello"), 0o600))
+
+	h1 := hasher.New()
+	NoError(t, h1.HashFile(t.Context(), name))
+
+	h2 := hasher.New()
+	digest, err := h2.HashFileDigest(t.Context(), name)
+	NoError(t, err)
+
+	// Recording the digest must not change the hash.
+	Equal(t, h1.GetHash(), h2.GetHash())
+	Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hex.EncodeToString(digest))
+}
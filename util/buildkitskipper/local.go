@@ -1,10 +1,14 @@
 package buildkitskipper
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1" // #nosec G505
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -12,17 +16,83 @@ import (
 
 var errInvalidHash = errors.New("invalid sha1 hash")
 
+var (
+	buildsBucket    = []byte("builds")
+	manifestsBucket = []byte("manifests")
+)
+
+// localCacheOpenTimeout bounds how long NewLocalCache waits for another
+// process to release the database.
+const localCacheOpenTimeout = time.Second
+
+// DefaultTTL is how long an auto-skip entry is trusted by default. After that,
+// the target runs again, so that a stale entry can't mask a broken build
+// indefinitely.
+const DefaultTTL = 7 * 24 * time.Hour
+
+// legacyTimeLayout is the layout of time.Time.String(), which is how entries
+// were stored before they were structured.
+const legacyTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Entry describes a build recorded in the auto-skip database.
+type Entry struct {
+	// Created is when the build completed.
+	Created time.Time `json:"created"`
+	// Target is the canonical name of the target.
+	Target string `json:"target"`
+	// EarthVersion is the version of earth which ran the build.
+	EarthVersion string `json:"earthVersion,omitempty"`
+	// Args are the build args the target was run with, as NAME=value.
+	Args []string `json:"args,omitempty"`
+}
+
+// Expired reports whether the entry is older than ttl at now. A zero ttl never
+// expires.
+func (e Entry) Expired(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(e.Created) > ttl
+}
+
+// Record is an entry along with the hash it is stored against.
+type Record struct {
+	Entry
+
+	Hash []byte `json:"hash"`
+}
+
+// ManifestRecord is the input manifest of the last recorded build of a target
+// with given args, along with that build.
+type ManifestRecord struct {
+	Record
+
+	// Manifest is the JSON-encoded input manifest.
+	Manifest json.RawMessage `json:"manifest"`
+}
+
 // NewLocal creates and returns a BoltDB implementation of the auto-skip client.
 func NewLocal(path string) (*LocalBuildkitSkipper, error) {
-	db, err := bolt.Open(path, 0o600, nil)
+	return newLocal(path, nil)
+}
+
+// NewLocalCache is like NewLocal, but gives up rather than waiting when
+// another process holds the database. It is meant for optional caches, such as
+// the read-through cache of a RemoteBuildkitSkipper, and for maintenance which
+// should not block on a running build.
+func NewLocalCache(path string) (*LocalBuildkitSkipper, error) {
+	return newLocal(path, &bolt.Options{Timeout: localCacheOpenTimeout})
+}
+
+func newLocal(path string, opts *bolt.Options) (*LocalBuildkitSkipper, error) {
+	db, err := bolt.Open(path, 0o600, opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not open db, %w", err)
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte("builds"))
-		if err != nil {
-			return fmt.Errorf("could not create builds bucket: %w", err)
+		for _, name := range [][]byte{buildsBucket, manifestsBucket} {
+			_, err = tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return fmt.Errorf("could not create %s bucket: %w", name, err)
+			}
 		}
 
 		return nil
@@ -38,20 +108,39 @@ func NewLocal(path string) (*LocalBuildkitSkipper, error) {
 
 // LocalBuildkitSkipper uses BoltDB to store & retrieve auto-skip hashes.
 type LocalBuildkitSkipper struct {
-	db *bolt.DB
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+// SetTTL sets how long entries are trusted by Exists and Get; zero (the
+// default) means forever. Expired entries are kept until they are pruned or
+// overwritten.
+func (l *LocalBuildkitSkipper) SetTTL(ttl time.Duration) {
+	l.ttl = ttl
 }
 
-// Add a new hash value (org & target are ignored in this implementation).
-func (l *LocalBuildkitSkipper) Add(_ context.Context, _ string, data []byte) error {
+// Close closes the database.
+func (l *LocalBuildkitSkipper) Close() error {
+	return l.db.Close()
+}
+
+// Add records a new hash value. A zero Created time is set to now.
+func (l *LocalBuildkitSkipper) Add(_ context.Context, entry Entry, data []byte) error {
 	if len(data) != sha1.Size {
 		return errInvalidHash
 	}
 
-	return l.db.Update(func(tx *bolt.Tx) error {
-		// could be serialized into a structure; however LocalBuildkitSkipper is only meant for dev/testing
-		payload := []byte(time.Now().String())
+	if entry.Created.IsZero() {
+		entry.Created = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
 
-		err := tx.Bucket([]byte("builds")).Put(data, payload)
+	return l.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(buildsBucket).Put(data, payload)
 		if err != nil {
 			return fmt.Errorf("could not set config: %w", err)
 		}
@@ -60,25 +149,218 @@ func (l *LocalBuildkitSkipper) Add(_ context.Context, _ string, data []byte) err
 	})
 }
 
-// Exists checks if the hash exists.
-func (l *LocalBuildkitSkipper) Exists(_ context.Context, data []byte) (bool, error) {
+// Exists checks if the hash exists and has not expired.
+func (l *LocalBuildkitSkipper) Exists(ctx context.Context, data []byte) (bool, error) {
+	_, found, err := l.Get(ctx, data)
+	return found, err
+}
+
+// Get returns the entry stored against the hash, if it exists and has not
+// expired.
+func (l *LocalBuildkitSkipper) Get(_ context.Context, data []byte) (Entry, bool, error) {
 	if len(data) != sha1.Size {
-		return false, errInvalidHash
+		return Entry{}, false, errInvalidHash
 	}
 
-	var found bool
+	var (
+		entry Entry
+		found bool
+	)
 
 	err := l.db.View(func(tx *bolt.Tx) error {
-		payload := tx.Bucket([]byte("builds")).Get(data)
+		payload := tx.Bucket(buildsBucket).Get(data)
 		if payload != nil {
+			entry = decodeEntry(payload)
 			found = true
 		}
 
 		return nil
 	})
 	if err != nil {
-		return false, err
+		return Entry{}, false, err
+	}
+
+	if !found || entry.Expired(l.ttl, time.Now()) {
+		return Entry{}, false, nil
 	}
 
-	return found, nil
+	return entry, true, nil
+}
+
+// List returns every record in the database, expired or not, newest first.
+func (l *LocalBuildkitSkipper) List(_ context.Context) ([]Record, error) {
+	var records []Record
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(buildsBucket).ForEach(func(k, v []byte) error {
+			records = append(records, Record{Entry: decodeEntry(v), Hash: bytes.Clone(k)})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(records, func(a, b Record) int {
+		return b.Created.Compare(a.Created)
+	})
+
+	return records, nil
+}
+
+// Prune deletes the records created before the given time, and returns how
+// many were deleted.
+func (l *LocalBuildkitSkipper) Prune(_ context.Context, before time.Time) (int, error) {
+	return l.deleteFunc(func(e Entry) bool {
+		return e.Created.Before(before)
+	})
+}
+
+// Forget deletes the records of the given targets, and returns how many were
+// deleted. Their manifests are deleted too.
+func (l *LocalBuildkitSkipper) Forget(_ context.Context, targets ...string) (int, error) {
+	n, err := l.deleteFunc(func(e Entry) bool {
+		return slices.Contains(targets, e.Target)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(manifestsBucket)
+
+		var keys [][]byte
+
+		err := b.ForEach(func(k, _ []byte) error {
+			var key []string
+			if json.Unmarshal(k, &key) == nil && len(key) > 0 && slices.Contains(targets, key[0]) {
+				keys = append(keys, bytes.Clone(k))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			err = b.Delete(k)
+			if err != nil {
+				return fmt.Errorf("could not delete manifest: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// AddManifest stores the input manifest of a recorded build, replacing the
+// one of the previous build of the same target with the same args.
+func (l *LocalBuildkitSkipper) AddManifest(_ context.Context, rec Record, manifest []byte) error {
+	if rec.Created.IsZero() {
+		rec.Created = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(ManifestRecord{Record: rec, Manifest: manifest})
+	if err != nil {
+		return err
+	}
+
+	return l.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(manifestsBucket).Put(manifestKey(rec.Target, rec.Args), payload)
+		if err != nil {
+			return fmt.Errorf("could not store manifest: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetManifest returns the input manifest of the last recorded build of the
+// target with the given args, or nil if there is none.
+func (l *LocalBuildkitSkipper) GetManifest(_ context.Context, target string, args []string) (*ManifestRecord, error) {
+	var rec *ManifestRecord
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		payload := tx.Bucket(manifestsBucket).Get(manifestKey(target, args))
+		if payload == nil {
+			return nil
+		}
+
+		rec = &ManifestRecord{}
+
+		return json.Unmarshal(payload, rec)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	return rec, nil
+}
+
+// manifestKey identifies a target run with given args.
+func manifestKey(target string, args []string) []byte {
+	key, _ := json.Marshal(append([]string{target}, args...)) //nolint:errchkjson // Strings always marshal.
+	return key
+}
+
+func (l *LocalBuildkitSkipper) deleteFunc(del func(Entry) bool) (int, error) {
+	var n int
+
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(buildsBucket)
+
+		// Deleting while iterating with ForEach is not supported.
+		var keys [][]byte
+
+		err := b.ForEach(func(k, v []byte) error {
+			if del(decodeEntry(v)) {
+				keys = append(keys, bytes.Clone(k))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			err = b.Delete(k)
+			if err != nil {
+				return fmt.Errorf("could not delete entry: %w", err)
+			}
+		}
+
+		n = len(keys)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// decodeEntry decodes a stored entry. Entries written before they were
+// structured only hold their creation time; if even that can't be parsed, the
+// entry has a zero Created time, so that it is treated as expired.
+func decodeEntry(payload []byte) Entry {
+	var entry Entry
+
+	err := json.Unmarshal(payload, &entry)
+	if err == nil {
+		return entry
+	}
+
+	// Strip the monotonic clock reading, e.g. " m=+0.012345".
+	s, _, _ := strings.Cut(string(payload), " m=")
+	entry.Created, _ = time.Parse(legacyTimeLayout, s)
+
+	return entry
 }
@@ -0,0 +1,93 @@
+package buildkitskipper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestLocalEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	l := newTestLocal(t)
+
+	require.ErrorIs(t, l.Add(ctx, Entry{}, []byte("short")), errInvalidHash)
+
+	require.NoError(t, l.Add(ctx, Entry{Target: "+new", EarthVersion: "v1.0.0", Args: []string{"A=1"}}, hashOf("a")))
+	require.NoError(t, l.Add(ctx, Entry{Target: "+old", Created: time.Now().Add(-48 * time.Hour)}, hashOf("b")))
+
+	entry, found, err := l.Get(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "+new", entry.Target)
+	require.Equal(t, "v1.0.0", entry.EarthVersion)
+	require.Equal(t, []string{"A=1"}, entry.Args)
+	require.WithinDuration(t, time.Now(), entry.Created, time.Minute)
+
+	records, err := l.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "+new", records[0].Target)
+	require.Equal(t, hashOf("a"), records[0].Hash)
+	require.Equal(t, "+old", records[1].Target)
+
+	l.SetTTL(24 * time.Hour)
+
+	found, err = l.Exists(ctx, hashOf("b"))
+	require.NoError(t, err)
+	require.False(t, found, "entries past the TTL should be treated as missing")
+
+	found, err = l.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	n, err := l.Prune(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	n, err = l.Forget(ctx, "+other", "+new")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	records, err = l.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
+
+func TestLocalLegacyEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	l := newTestLocal(t)
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Entries used to hold only time.Now().String().
+	require.NoError(t, l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(buildsBucket)
+
+		err := b.Put(hashOf("a"), []byte(created.String()+" m=+0.012345"))
+		if err != nil {
+			return err
+		}
+
+		return b.Put(hashOf("b"), []byte("garbage"))
+	}))
+
+	entry, found, err := l.Get(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, entry.Created.Equal(created))
+	require.Empty(t, entry.Target)
+
+	l.SetTTL(time.Hour)
+
+	for _, h := range []string{"a", "b"} {
+		found, err = l.Exists(ctx, hashOf(h))
+		require.NoError(t, err)
+		require.False(t, found)
+	}
+}
@@ -0,0 +1,347 @@
+package buildkitskipper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// DefaultRemoteTimeout bounds each request to the remote auto-skip store.
+const DefaultRemoteTimeout = 5 * time.Second
+
+// RemoteOpt configures a RemoteBuildkitSkipper.
+type RemoteOpt struct {
+	// Cache is an optional local read-through cache. Hashes found remotely are
+	// recorded in it, and it is consulted before (and instead of, once the
+	// remote is unavailable) the remote store.
+	Cache *LocalBuildkitSkipper
+	// Client is the HTTP client to use; nil means a client with Timeout.
+	Client *http.Client
+	// OnUnavailable is called once, the first time the remote store fails.
+	OnUnavailable func(err error)
+	// URL is the address of the remote store: either http(s)://host/path for
+	// the HTTP protocol, or s3://bucket/prefix for an S3-compatible store, with
+	// optional endpoint and region query parameters.
+	URL string
+	// Token is sent as a bearer token to HTTP stores.
+	Token string
+	// Timeout bounds each request; zero means DefaultRemoteTimeout.
+	Timeout time.Duration
+	// TTL is how long entries are trusted; zero means forever. Servers may
+	// also expire entries on their own.
+	TTL time.Duration
+}
+
+// RemoteBuildkitSkipper stores auto-skip hashes in a remote key/value store
+// shared between machines. A hash is a key, stored at <base URL>/<hex hash>:
+// GET returns its JSON-encoded Entry (200) or reports that it does not exist
+// (404), and PUT records it with a JSON-encoded Entry as the body. The same protocol is spoken by the
+// reference server (see [NewHandler]) and, with request signing, by S3.
+//
+// When the remote store fails, the skipper logs once via OnUnavailable and
+// carries on with the local cache only, so auto-skip never fails a build.
+type RemoteBuildkitSkipper struct {
+	cache         *LocalBuildkitSkipper
+	client        *http.Client
+	sign          func(ctx context.Context, req *http.Request, body []byte) error
+	onUnavailable func(err error)
+	base          string
+	token         string
+	ttl           time.Duration
+	unavailable   atomic.Bool
+	// forbiddenIsMiss is set for S3, which answers 403 rather than 404 for a
+	// missing key when the credentials may not list the bucket.
+	forbiddenIsMiss bool
+	reportOnce      sync.Once
+}
+
+// NewRemote creates and returns a remote implementation of the auto-skip client.
+func NewRemote(ctx context.Context, opt RemoteOpt) (*RemoteBuildkitSkipper, error) {
+	u, err := url.Parse(opt.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auto-skip remote URL %q: %w", opt.URL, err)
+	}
+
+	client := opt.Client
+	if client == nil {
+		timeout := opt.Timeout
+		if timeout == 0 {
+			timeout = DefaultRemoteTimeout
+		}
+
+		client = &http.Client{Timeout: timeout}
+	}
+
+	r := &RemoteBuildkitSkipper{
+		cache:         opt.Cache,
+		client:        client,
+		onUnavailable: opt.OnUnavailable,
+		token:         opt.Token,
+		ttl:           opt.TTL,
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		u.RawQuery = ""
+		r.base = strings.TrimSuffix(u.String(), "/")
+	case "s3":
+		err = r.initS3(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid auto-skip remote URL %q: scheme must be http, https or s3", opt.URL)
+	}
+
+	return r, nil
+}
+
+// initS3 configures the skipper for an S3-compatible store addressed as
+// s3://bucket/prefix?endpoint=...&region=..., using path-style requests
+// signed with the default AWS credentials chain.
+func (r *RemoteBuildkitSkipper) initS3(ctx context.Context, u *url.URL) error {
+	if u.Host == "" {
+		return fmt.Errorf("invalid auto-skip remote URL %q: missing bucket", u.String())
+	}
+
+	q := u.Query()
+
+	var opts []func(*config.LoadOptions) error
+	if region := q.Get("region"); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config for auto-skip remote: %w", err)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	r.base = strings.TrimSuffix(endpoint, "/") + "/" + u.Host
+	if prefix := strings.Trim(u.Path, "/"); prefix != "" {
+		r.base += "/" + prefix
+	}
+
+	r.forbiddenIsMiss = true
+
+	signer := v4.NewSigner()
+	creds := cfg.Credentials
+
+	r.sign = func(ctx context.Context, req *http.Request, body []byte) error {
+		if creds == nil {
+			return errors.New("no AWS credentials available")
+		}
+
+		c, err := creds.Retrieve(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+		}
+
+		sum := sha256.Sum256(body)
+		payloadHash := hex.EncodeToString(sum[:])
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+		return signer.SignHTTP(ctx, c, req, payloadHash, "s3", region, time.Now())
+	}
+
+	return nil
+}
+
+// Add records the hash in the local cache and the remote store.
+func (r *RemoteBuildkitSkipper) Add(ctx context.Context, entry Entry, data []byte) error {
+	if len(data) != sha1.Size {
+		return errInvalidHash
+	}
+
+	if entry.Created.IsZero() {
+		entry.Created = time.Now().UTC()
+	}
+
+	if r.cache != nil {
+		err := r.cache.Add(ctx, entry, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.unavailable.Load() {
+		return nil
+	}
+
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	status, _, err := r.do(ctx, http.MethodPut, data, body)
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("unexpected status %d", status)
+	}
+
+	if err != nil {
+		r.fail(fmt.Errorf("failed to record hash: %w", err))
+	}
+
+	return nil
+}
+
+// Exists checks if the hash exists in the local cache or the remote store.
+func (r *RemoteBuildkitSkipper) Exists(ctx context.Context, data []byte) (bool, error) {
+	if len(data) != sha1.Size {
+		return false, errInvalidHash
+	}
+
+	if r.cache != nil {
+		found, err := r.cache.Exists(ctx, data)
+		if err != nil {
+			return false, err
+		}
+
+		if found {
+			return true, nil
+		}
+	}
+
+	if r.unavailable.Load() {
+		return false, nil
+	}
+
+	entry, found, err := r.Get(ctx, data)
+	if err != nil {
+		r.fail(fmt.Errorf("failed to check hash: %w", err))
+		return false, nil
+	}
+
+	// Stores which predate structured entries have a zero Created time, which
+	// is expired when a TTL is set, as it is in the local database, so that
+	// legacy entries are not refreshed forever by caching.
+	if !found || entry.Expired(r.ttl, time.Now()) {
+		return false, nil
+	}
+
+	if r.cache != nil {
+		err = r.cache.Add(ctx, entry, data)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Get returns the entry stored against the hash in the remote store, expired
+// or not, without consulting or updating the local cache. Unlike Exists, it
+// returns the failures of the remote store.
+func (r *RemoteBuildkitSkipper) Get(ctx context.Context, data []byte) (Entry, bool, error) {
+	if len(data) != sha1.Size {
+		return Entry{}, false, errInvalidHash
+	}
+
+	status, respBody, err := r.do(ctx, http.MethodGet, data, nil)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	if status == http.StatusNotFound || (r.forbiddenIsMiss && status == http.StatusForbidden) {
+		return Entry{}, false, nil
+	}
+
+	if status != http.StatusOK {
+		return Entry{}, false, fmt.Errorf("unexpected status %d", status)
+	}
+
+	// Stores which predate structured entries have an empty body.
+	var entry Entry
+	if json.Unmarshal(respBody, &entry) != nil {
+		entry = Entry{}
+	}
+
+	return entry, true, nil
+}
+
+// AddManifest stores the input manifest of a recorded build in the local
+// cache; manifests are not shared through the remote store.
+func (r *RemoteBuildkitSkipper) AddManifest(ctx context.Context, rec Record, manifest []byte) error {
+	if r.cache == nil {
+		return nil
+	}
+
+	return r.cache.AddManifest(ctx, rec, manifest)
+}
+
+// do sends a request for the hash to the remote store and returns the
+// response status and body.
+func (r *RemoteBuildkitSkipper) do(ctx context.Context, method string, data, body []byte) (int, []byte, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.base+"/"+hex.EncodeToString(data), rd)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	if r.sign != nil {
+		err = r.sign(ctx, req, body)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close() // #nosec G104
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxRecordSize))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, respBody, nil
+}
+
+// fail marks the remote store as unavailable and reports the first failure.
+func (r *RemoteBuildkitSkipper) fail(err error) {
+	r.unavailable.Store(true)
+
+	r.reportOnce.Do(func() {
+		if r.onUnavailable != nil {
+			r.onUnavailable(err)
+		}
+	})
+}
@@ -0,0 +1,335 @@
+package buildkitskipper
+
+import (
+	"crypto/sha1" // #nosec G505
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocal(t *testing.T) *LocalBuildkitSkipper {
+	t.Helper()
+
+	l, err := NewLocal(filepath.Join(t.TempDir(), "skip.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	return l
+}
+
+func hashOf(s string) []byte {
+	sum := sha1.Sum([]byte(s)) // #nosec G401
+	return sum[:]
+}
+
+func TestRemoteRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	srv := httptest.NewServer(NewHandler(newTestLocal(t), "secret"))
+	t.Cleanup(srv.Close)
+
+	r, err := NewRemote(ctx, RemoteOpt{URL: srv.URL + "/", Token: "secret", OnUnavailable: func(err error) {
+		t.Errorf("unexpected unavailability: %v", err)
+	}})
+	require.NoError(t, err)
+
+	found, err := r.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, r.Add(ctx, Entry{Target: "+build"}, hashOf("a")))
+
+	found, err = r.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	_, err = r.Exists(ctx, []byte("short"))
+	require.ErrorIs(t, err, errInvalidHash)
+}
+
+func TestRemoteReadThroughCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := newTestLocal(t)
+	require.NoError(t, store.Add(ctx, Entry{Target: "+build"}, hashOf("a")))
+
+	var (
+		mu   sync.Mutex
+		hits int
+	)
+
+	handler := NewHandler(store, "")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		handler.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := newTestLocal(t)
+
+	r, err := NewRemote(ctx, RemoteOpt{URL: srv.URL, Cache: cache})
+	require.NoError(t, err)
+
+	for range 2 {
+		found, err := r.Exists(ctx, hashOf("a"))
+		require.NoError(t, err)
+		require.True(t, found)
+	}
+
+	require.Equal(t, 1, hits, "second lookup should be served by the local cache")
+
+	found, err := cache.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestRemoteKeepsEntry(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := newTestLocal(t)
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	entry := Entry{Created: created, Target: "+build", EarthVersion: "v1.0.0", Args: []string{"A=1"}}
+	require.NoError(t, store.Add(ctx, entry, hashOf("a")))
+	require.NoError(t, store.Add(ctx, Entry{Created: created, Target: "+old"}, hashOf("b")))
+
+	srv := httptest.NewServer(NewHandler(store, ""))
+	t.Cleanup(srv.Close)
+
+	cache := newTestLocal(t)
+
+	r, err := NewRemote(ctx, RemoteOpt{URL: srv.URL, Cache: cache, TTL: 2 * time.Hour})
+	require.NoError(t, err)
+
+	found, err := r.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	got, found, err := cache.Get(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, got.Created.Equal(created), "the cache should keep the original creation time")
+	got.Created = created
+	require.Equal(t, entry, got)
+
+	// The server trusts entries for longer than the client does.
+	r, err = NewRemote(ctx, RemoteOpt{URL: srv.URL, TTL: time.Minute})
+	require.NoError(t, err)
+
+	found, err = r.Exists(ctx, hashOf("b"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRemoteLegacyEntry(t *testing.T) {
+	t.Parallel()
+
+	// Stores which predate structured entries answer with an empty body.
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	ctx := t.Context()
+	cache := newTestLocal(t)
+
+	r, err := NewRemote(ctx, RemoteOpt{URL: srv.URL, Cache: cache, TTL: time.Hour})
+	require.NoError(t, err)
+
+	found, err := r.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.False(t, found, "a legacy entry has no creation time, so it is expired")
+
+	_, found, err = cache.Get(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.False(t, found, "an expired entry should not be cached")
+
+	r, err = NewRemote(ctx, RemoteOpt{URL: srv.URL, Cache: cache})
+	require.NoError(t, err)
+
+	found, err = r.Exists(ctx, hashOf("a"))
+	require.NoError(t, err)
+	require.True(t, found, "entries never expire without a TTL")
+}
+
+func TestRemoteUnavailable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    string
+	}{
+		{
+			name:    "unauthorized",
+			handler: NewHandler(nil, "secret"),
+			want:    "unexpected status 401",
+		},
+		{
+			name: "server error",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}),
+			want: "unexpected status 502",
+		},
+		{
+			name: "unreachable",
+			want: "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+
+			srv := httptest.NewServer(tt.handler)
+			if tt.handler == nil {
+				srv.Close()
+			} else {
+				t.Cleanup(srv.Close)
+			}
+
+			var errs []error
+
+			cache := newTestLocal(t)
+
+			r, err := NewRemote(ctx, RemoteOpt{URL: srv.URL, Cache: cache, OnUnavailable: func(err error) {
+				errs = append(errs, err)
+			}})
+			require.NoError(t, err)
+
+			found, err := r.Exists(ctx, hashOf("a"))
+			require.NoError(t, err)
+			require.False(t, found)
+
+			// Recording still works locally, and later lookups are served by
+			// the cache without reporting the failure again.
+			require.NoError(t, r.Add(ctx, Entry{Target: "+build"}, hashOf("a")))
+
+			found, err = r.Exists(ctx, hashOf("a"))
+			require.NoError(t, err)
+			require.True(t, found)
+
+			require.Len(t, errs, 1)
+			require.ErrorContains(t, errs[0], tt.want)
+		})
+	}
+}
+
+//nolint:paralleltest // t.Setenv modifies process-wide state.
+func TestRemoteS3(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	tests := []struct {
+		name          string
+		missingStatus int
+	}{
+		{name: "not found", missingStatus: http.StatusNotFound},
+		// S3 answers 403 for missing keys when the bucket may not be listed.
+		{name: "forbidden", missingStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := t.Context()
+			objects := map[string][]byte{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+					!strings.Contains(req.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request") ||
+					req.Header.Get("X-Amz-Content-Sha256") == "" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				switch req.Method {
+				case http.MethodPut:
+					objects[req.URL.Path], _ = io.ReadAll(req.Body)
+				case http.MethodGet:
+					body, ok := objects[req.URL.Path]
+					if !ok {
+						w.WriteHeader(tt.missingStatus)
+						return
+					}
+
+					_, _ = w.Write(body)
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			r, err := NewRemote(ctx, RemoteOpt{
+				URL: "s3://bucket/auto-skip/?region=eu-west-1&endpoint=" + srv.URL,
+				OnUnavailable: func(err error) {
+					t.Errorf("unexpected unavailability: %v", err)
+				},
+			})
+			require.NoError(t, err)
+
+			found, err := r.Exists(ctx, hashOf("a"))
+			require.NoError(t, err)
+			require.False(t, found)
+
+			require.NoError(t, r.Add(ctx, Entry{Target: "+build"}, hashOf("a")))
+			object := objects["/bucket/auto-skip/86f7e437faa5a7fce15d1ddcb9eaeaea377667b8"]
+			require.Contains(t, string(object), `"target":"+build"`)
+
+			found, err = r.Exists(ctx, hashOf("a"))
+			require.NoError(t, err)
+			require.True(t, found)
+		})
+	}
+}
+
+func TestNewRemoteInvalidURL(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRemote(t.Context(), RemoteOpt{URL: "ftp://example.com"})
+	require.ErrorContains(t, err, "scheme must be http, https or s3")
+
+	_, err = NewRemote(t.Context(), RemoteOpt{URL: "s3:///prefix"})
+	require.ErrorContains(t, err, "missing bucket")
+}
+
+func TestHandlerRejectsInvalidHash(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(NewHandler(newTestLocal(t), ""))
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodHead, srv.URL+"/not-a-hash", nil)
+	require.NoError(t, err)
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestNewLocalCacheLocked(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "skip.db")
+
+	l, err := NewLocal(path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	_, err = NewLocalCache(path)
+	require.ErrorContains(t, err, "timeout")
+}
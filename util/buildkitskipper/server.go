@@ -0,0 +1,112 @@
+package buildkitskipper
+
+import (
+	"context"
+	"crypto/sha1" // #nosec G505
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxRecordSize bounds the body of a PUT request to the reference server.
+const maxRecordSize = 64 << 10
+
+// Store adds auto-skip entries to a datastore & allows us to retrieve them.
+type Store interface {
+	Add(ctx context.Context, entry Entry, data []byte) error
+	Get(ctx context.Context, data []byte) (Entry, bool, error)
+}
+
+// NewHandler returns the reference server for the remote auto-skip protocol
+// spoken by RemoteBuildkitSkipper, backed by the given store. When token is
+// not empty, requests must carry it as a bearer token.
+func NewHandler(store Store, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	// GET patterns also match HEAD requests.
+	mux.HandleFunc("GET /{hash}", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := parseHash(w, r)
+		if !ok {
+			return
+		}
+
+		entry, found, err := store.Get(r.Context(), data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(entry)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("PUT /{hash}", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := parseHash(w, r)
+		if !ok {
+			return
+		}
+
+		var entry Entry
+
+		b, err := io.ReadAll(io.LimitReader(r.Body, maxRecordSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(b) > 0 {
+			err = json.Unmarshal(b, &entry)
+			if err != nil {
+				http.Error(w, "invalid record: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		err = store.Add(r.Context(), entry, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if token == "" {
+		return mux
+	}
+
+	want := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// parseHash decodes the hex hash from the request path, writing an error
+// response if it is not valid.
+func parseHash(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := hex.DecodeString(strings.ToLower(r.PathValue("hash")))
+	if err != nil || len(data) != sha1.Size {
+		http.Error(w, errInvalidHash.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return data, true
+}
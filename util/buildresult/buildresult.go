@@ -0,0 +1,350 @@
+// Package buildresult produces the machine-readable summary of a build written by --result-file.
+package buildresult
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/gatewaycrafter"
+)
+
+// Version is the version of the result document. It is bumped whenever a
+// field is removed or changes meaning; fields may be added without bumping it.
+const Version = 1
+
+// Result is the summary of a build.
+type Result struct {
+	StartedAt  time.Time  `json:"startedAt,omitzero"`
+	EndedAt    time.Time  `json:"endedAt,omitzero"`
+	BuildID    string     `json:"buildId"`
+	Status     string     `json:"status"`
+	MainTarget string     `json:"mainTarget,omitempty"`
+	Targets    []Target   `json:"targets"`
+	Artifacts  []Artifact `json:"artifacts"`
+	Images     []Image    `json:"images"`
+	Failures   []Failure  `json:"failures"`
+	Version    int        `json:"version"`
+	DurationMS int64      `json:"durationMs"`
+}
+
+// Target is the outcome of a single target.
+type Target struct {
+	StartedAt     time.Time `json:"startedAt,omitzero"`
+	EndedAt       time.Time `json:"endedAt,omitzero"`
+	Name          string    `json:"name"`
+	CanonicalName string    `json:"canonicalName"`
+	Platform      string    `json:"platform,omitempty"`
+	// MatrixCell is the name of the cell of a BUILD matrix the target was
+	// built for, if any.
+	MatrixCell     string   `json:"matrixCell,omitempty"`
+	Status         string   `json:"status"`
+	OverrideArgs   []string `json:"overrideArgs,omitempty"`
+	DurationMS     int64    `json:"durationMs"`
+	Commands       int      `json:"commands"`
+	CachedCommands int      `json:"cachedCommands"`
+	// Cached is whether every command of the target was cached.
+	Cached bool `json:"cached"`
+}
+
+// Artifact is an artifact saved locally.
+type Artifact struct {
+	// Artifact is the canonical artifact reference, such as +build/out.bin.
+	Artifact string `json:"artifact"`
+	Path     string `json:"path"`
+	// Digest is the SHA-256 digest of the file; it is empty for directories.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Image is an image loaded into the local container runtime, pushed, or both.
+type Image struct {
+	Target string `json:"target"`
+	Name   string `json:"name"`
+	// Digest is the manifest digest of the image, when buildkit reported it.
+	Digest string `json:"digest,omitempty"`
+	Loaded bool   `json:"loaded"`
+	Pushed bool   `json:"pushed"`
+}
+
+// Failure is an error which failed a command or the build.
+type Failure struct {
+	Source  *SourceLocation `json:"source,omitempty"`
+	Target  string          `json:"target,omitempty"`
+	Command string          `json:"command,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Message string          `json:"message"`
+	Help    string          `json:"help,omitempty"`
+}
+
+// SourceLocation is the location of an Earthfile statement.
+type SourceLocation struct {
+	RepositoryURL  string `json:"repositoryUrl,omitempty"`
+	RepositoryHash string `json:"repositoryHash,omitempty"`
+	File           string `json:"file"`
+	StartLine      int32  `json:"startLine"`
+	StartColumn    int32  `json:"startColumn"`
+	EndLine        int32  `json:"endLine"`
+	EndColumn      int32  `json:"endColumn"`
+}
+
+// New builds the result of a build from its manifest and the outputs recorded
+// by its export coordinator, which may be nil.
+func New(m *logstream.RunManifest, exports *gatewaycrafter.ExportCoordinator) *Result {
+	r := &Result{
+		Version:    Version,
+		BuildID:    m.GetBuildId(),
+		Status:     status(m.GetStatus()),
+		StartedAt:  unixNanos(m.GetStartedAtUnixNanos()),
+		EndedAt:    unixNanos(m.GetEndedAtUnixNanos()),
+		DurationMS: durationMS(m.GetStartedAtUnixNanos(), m.GetEndedAtUnixNanos()),
+		Targets:    []Target{},
+		Artifacts:  []Artifact{},
+		Images:     []Image{},
+		Failures:   []Failure{},
+	}
+
+	if tm, ok := m.GetTargets()[m.GetMainTargetId()]; ok {
+		r.MainTarget = tm.GetCanonicalName()
+	}
+
+	r.addTargets(m)
+	r.addFailures(m)
+
+	if exports != nil {
+		r.addArtifacts(exports)
+		r.addImages(exports)
+	}
+
+	return r
+}
+
+// WriteFile writes the result as indented JSON to path.
+func (r *Result) WriteFile(path string) error {
+	dt, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	err = os.WriteFile(path, append(dt, '\n'), 0o644) // #nosec G306
+	if err != nil {
+		return fmt.Errorf("failed to write result file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (r *Result) addTargets(m *logstream.RunManifest) {
+	type counts struct{ commands, cached int }
+
+	byTarget := map[string]*counts{}
+
+	for _, cm := range m.GetCommands() {
+		c, ok := byTarget[cm.GetTargetId()]
+		if !ok {
+			c = &counts{}
+			byTarget[cm.GetTargetId()] = c
+		}
+
+		c.commands++
+
+		if cm.GetIsCached() {
+			c.cached++
+		}
+	}
+
+	for id, tm := range m.GetTargets() {
+		c := byTarget[id]
+		if c == nil {
+			c = &counts{}
+		}
+
+		r.Targets = append(r.Targets, Target{
+			Name:           tm.GetName(),
+			CanonicalName:  tm.GetCanonicalName(),
+			Platform:       tm.GetFinalPlatform(),
+			OverrideArgs:   tm.GetOverrideArgs(),
+			MatrixCell:     tm.GetMatrixCell(),
+			Status:         status(tm.GetStatus()),
+			StartedAt:      unixNanos(tm.GetStartedAtUnixNanos()),
+			EndedAt:        unixNanos(tm.GetEndedAtUnixNanos()),
+			DurationMS:     durationMS(tm.GetStartedAtUnixNanos(), tm.GetEndedAtUnixNanos()),
+			Commands:       c.commands,
+			CachedCommands: c.cached,
+			Cached:         c.commands > 0 && c.cached == c.commands,
+		})
+	}
+
+	slices.SortFunc(r.Targets, func(a, b Target) int {
+		return cmp.Or(
+			a.StartedAt.Compare(b.StartedAt),
+			cmp.Compare(a.CanonicalName, b.CanonicalName),
+			cmp.Compare(a.Platform, b.Platform),
+		)
+	})
+}
+
+func (r *Result) addFailures(m *logstream.RunManifest) {
+	fatal := m.GetFailure()
+
+	var failures []*logstream.CommandManifest
+
+	for id, cm := range m.GetCommands() {
+		if cm.GetStatus() == logstream.RunStatus_RUN_STATUS_FAILURE || (fatal != nil && id == fatal.GetCommandId()) {
+			failures = append(failures, cm)
+		}
+	}
+
+	slices.SortFunc(failures, func(a, b *logstream.CommandManifest) int {
+		return cmp.Or(
+			cmp.Compare(a.GetEndedAtUnixNanos(), b.GetEndedAtUnixNanos()),
+			cmp.Compare(a.GetName(), b.GetName()),
+		)
+	})
+
+	fatalReported := false
+
+	for _, cm := range failures {
+		f := Failure{
+			Target:  m.GetTargets()[cm.GetTargetId()].GetCanonicalName(),
+			Command: cm.GetName(),
+			Message: cm.GetErrorMessage(),
+			Source:  sourceLocation(cm.GetSourceLocation()),
+		}
+
+		if fatal != nil && m.GetCommands()[fatal.GetCommandId()] == cm {
+			f.Type = FailureType(fatal.GetType())
+			f.Help = fatal.GetHelpMessage()
+			f.Message = cmp.Or(fatal.GetErrorMessage(), f.Message)
+			fatalReported = true
+		}
+
+		r.Failures = append(r.Failures, f)
+	}
+
+	if fatal != nil && !fatalReported {
+		r.Failures = append(r.Failures, Failure{
+			Target:  m.GetTargets()[fatal.GetTargetId()].GetCanonicalName(),
+			Type:    FailureType(fatal.GetType()),
+			Message: fatal.GetErrorMessage(),
+			Help:    fatal.GetHelpMessage(),
+		})
+	}
+}
+
+func (r *Result) addArtifacts(exports *gatewaycrafter.ExportCoordinator) {
+	for _, e := range exports.GetArtifactSummary() {
+		r.Artifacts = append(r.Artifacts, Artifact{
+			Artifact: e.Target,
+			Path:     e.Path,
+			Digest:   fileDigest(e.Path),
+		})
+	}
+}
+
+func (r *Result) addImages(exports *gatewaycrafter.ExportCoordinator) {
+	index := map[[2]string]int{}
+
+	image := func(target, name, digest string) *Image {
+		k := [2]string{target, name}
+
+		i, ok := index[k]
+		if !ok {
+			i = len(r.Images)
+			index[k] = i
+			r.Images = append(r.Images, Image{Target: target, Name: name})
+		}
+
+		img := &r.Images[i]
+		img.Digest = cmp.Or(img.Digest, digest)
+
+		return img
+	}
+
+	for _, e := range exports.GetLocalOutputSummary() {
+		image(e.Target, e.DockerTag, e.Digest).Loaded = true
+	}
+
+	for _, e := range exports.GetPushedImageSummary() {
+		img := image(e.Target, e.DockerTag, e.Digest)
+		img.Pushed = img.Pushed || e.Pushed
+	}
+}
+
+// fileDigest returns the SHA-256 digest of a regular file, or an empty string
+// if it is not one or can't be read.
+func fileDigest(path string) string {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return ""
+	}
+	defer f.Close() // #nosec G307
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return ""
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func sourceLocation(sl *logstream.SourceLocation) *SourceLocation {
+	if sl == nil {
+		return nil
+	}
+
+	return &SourceLocation{
+		RepositoryURL:  sl.GetRepositoryUrl(),
+		RepositoryHash: sl.GetRepositoryHash(),
+		File:           sl.GetFile(),
+		StartLine:      sl.GetStartLine(),
+		StartColumn:    sl.GetStartColumn(),
+		EndLine:        sl.GetEndLine(),
+		EndColumn:      sl.GetEndColumn(),
+	}
+}
+
+// status turns e.g. RUN_STATUS_IN_PROGRESS into in-progress.
+func status(s logstream.RunStatus) string {
+	return enumString(s.String(), "RUN_STATUS_")
+}
+
+// FailureType names a failure type as the build reports do, turning e.g.
+// FAILURE_TYPE_NONZERO_EXIT into nonzero-exit.
+func FailureType(t logstream.FailureType) string {
+	return enumString(t.String(), "FAILURE_TYPE_")
+}
+
+func enumString(s, prefix string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(s, prefix)), "_", "-")
+}
+
+func unixNanos(n uint64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(n)).UTC() // #nosec G115
+}
+
+func durationMS(start, end uint64) int64 {
+	if start == 0 || end < start {
+		return 0
+	}
+
+	return int64(end-start) / int64(time.Millisecond) // #nosec G115
+}
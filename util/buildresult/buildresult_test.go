@@ -0,0 +1,174 @@
+package buildresult
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/gatewaycrafter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) uint64 {
+		return uint64(start.Add(d).UnixNano()) // #nosec G115
+	}
+
+	m := &logstream.RunManifest{
+		BuildId:            "build-1",
+		Status:             logstream.RunStatus_RUN_STATUS_FAILURE,
+		StartedAtUnixNanos: at(0),
+		EndedAtUnixNanos:   at(3 * time.Second),
+		MainTargetId:       "t1",
+		Targets: map[string]*logstream.TargetManifest{
+			"t1": {
+				Name:               "+test",
+				CanonicalName:      "+test",
+				Status:             logstream.RunStatus_RUN_STATUS_FAILURE,
+				StartedAtUnixNanos: at(time.Second),
+				EndedAtUnixNanos:   at(3 * time.Second),
+			},
+			"t2": {
+				Name:               "+build",
+				CanonicalName:      "+build",
+				FinalPlatform:      "linux/amd64",
+				Status:             logstream.RunStatus_RUN_STATUS_SUCCESS,
+				StartedAtUnixNanos: at(0),
+				EndedAtUnixNanos:   at(1500 * time.Millisecond),
+			},
+		},
+		Commands: map[string]*logstream.CommandManifest{
+			"c1": {Name: "RUN go build", TargetId: "t2", IsCached: true, Status: logstream.RunStatus_RUN_STATUS_SUCCESS},
+			"c2": {
+				Name:             "RUN go test",
+				TargetId:         "t1",
+				Status:           logstream.RunStatus_RUN_STATUS_FAILURE,
+				EndedAtUnixNanos: at(3 * time.Second),
+				ErrorMessage:     "exit code 1",
+				SourceLocation:   &logstream.SourceLocation{File: "Earthfile", StartLine: 12, EndLine: 12, EndColumn: 15},
+			},
+		},
+		Failure: &logstream.Failure{
+			Type:         logstream.FailureType_FAILURE_TYPE_NONZERO_EXIT,
+			TargetId:     "t1",
+			CommandId:    "c2",
+			ErrorMessage: "the command go test did not complete successfully. Exit code 1",
+		},
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.bin")
+	require.NoError(t, os.WriteFile(out, []byte("hello"), 0o600))
+
+	ec := gatewaycrafter.NewExportCoordinator()
+	ec.AddArtifactSummary("+build/out.bin", out, "salt")
+	ec.AddArtifactSummary("+build/dist", dir, "salt")
+	ec.AddLocalOutputSummary("+build", "app:latest", "salt")
+	ec.AddPushedImageSummary("+build", "app:latest", "salt", true)
+	ec.AddImageDigests(map[string]string{
+		"app:latest|containerimage.descriptor": base64.StdEncoding.EncodeToString(
+			[]byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:abc","size":1}`)),
+	})
+
+	r := New(m, ec)
+
+	require.Equal(t, Version, r.Version)
+	require.Equal(t, "failure", r.Status)
+	require.Equal(t, "+test", r.MainTarget)
+	require.Equal(t, int64(3000), r.DurationMS)
+
+	require.Equal(t, []Target{
+		{
+			Name:           "+build",
+			CanonicalName:  "+build",
+			Platform:       "linux/amd64",
+			Status:         "success",
+			StartedAt:      start,
+			EndedAt:        start.Add(1500 * time.Millisecond),
+			DurationMS:     1500,
+			Commands:       1,
+			CachedCommands: 1,
+			Cached:         true,
+		},
+		{
+			Name:          "+test",
+			CanonicalName: "+test",
+			Status:        "failure",
+			StartedAt:     start.Add(time.Second),
+			EndedAt:       start.Add(3 * time.Second),
+			DurationMS:    2000,
+			Commands:      1,
+		},
+	}, r.Targets)
+
+	require.Equal(t, []Artifact{
+		{Artifact: "+build/dist", Path: dir},
+		{
+			Artifact: "+build/out.bin",
+			Path:     out,
+			Digest:   "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+	}, r.Artifacts)
+
+	require.Equal(t, []Image{
+		{Target: "+build", Name: "app:latest", Digest: "sha256:abc", Loaded: true, Pushed: true},
+	}, r.Images)
+
+	require.Equal(t, []Failure{
+		{
+			Target:  "+test",
+			Command: "RUN go test",
+			Type:    "nonzero-exit",
+			Message: "the command go test did not complete successfully. Exit code 1",
+			Source:  &SourceLocation{File: "Earthfile", StartLine: 12, EndLine: 12, EndColumn: 15},
+		},
+	}, r.Failures)
+}
+
+func TestNewFailureWithoutCommand(t *testing.T) {
+	t.Parallel()
+
+	r := New(&logstream.RunManifest{
+		Status: logstream.RunStatus_RUN_STATUS_FAILURE,
+		Failure: &logstream.Failure{
+			Type:         logstream.FailureType_FAILURE_TYPE_SYNTAX,
+			CommandId:    "_generic:default",
+			ErrorMessage: "syntax error",
+		},
+	}, nil)
+
+	require.Equal(t, []Failure{{Type: "syntax", Message: "syntax error"}}, r.Failures)
+	require.Empty(t, r.Targets)
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	r := New(&logstream.RunManifest{BuildId: "b", Status: logstream.RunStatus_RUN_STATUS_SUCCESS}, nil)
+	require.NoError(t, r.WriteFile(path))
+
+	dt, err := os.ReadFile(path) // #nosec G304
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(dt, &doc))
+	require.Equal(t, map[string]any{
+		"version":    float64(1),
+		"buildId":    "b",
+		"status":     "success",
+		"durationMs": float64(0),
+		"targets":    []any{},
+		"artifacts":  []any{},
+		"images":     []any{},
+		"failures":   []any{},
+	}, doc)
+}
@@ -0,0 +1,292 @@
+// Package critpath analyzes where the time of a finished build went: its critical path, the wall and
+// cumulative time of each target, and its slowest commands.
+package critpath
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/logstream"
+)
+
+// DefaultTop is the default number of slowest commands reported.
+const DefaultTop = 10
+
+// Report is the analysis of a build.
+type Report struct {
+	// Path is the critical path of the build, from its first target to its
+	// last.
+	Path []Step
+	// Targets holds the time spent in each target, slowest first.
+	Targets []Target
+	// Slowest holds the slowest commands which were not cached, slowest first.
+	Slowest []Command
+	// Length is the length of the critical path.
+	Length time.Duration
+	// Wall is the wall time of the build.
+	Wall time.Duration
+}
+
+// Step is a target on the critical path.
+type Step struct {
+	Target string
+	// Wall is the wall time of the target.
+	Wall time.Duration
+	// Self is the time the target added to the path, after the dependency
+	// before it on the path ended.
+	Self time.Duration
+}
+
+// Target is the time spent in a target.
+type Target struct {
+	Target string
+	// Wall is the time between the start and the end of the target.
+	Wall time.Duration
+	// Cumulative is the sum of the durations of the commands of the target
+	// which were not cached. It exceeds Wall when commands ran in parallel.
+	Cumulative time.Duration
+	Commands   int
+	Cached     int
+}
+
+// Command is the time spent in a command.
+type Command struct {
+	Target  string
+	Command string
+	// Source is the Earthfile location of the command, if any.
+	Source   string
+	Duration time.Duration
+}
+
+type span struct {
+	start, end uint64
+}
+
+func (s span) duration() time.Duration {
+	if s.start == 0 || s.end < s.start {
+		return 0
+	}
+
+	return time.Duration(s.end - s.start) // #nosec G115
+}
+
+func (s span) union(o span) span {
+	if s.start == 0 || (o.start != 0 && o.start < s.start) {
+		s.start = o.start
+	}
+
+	s.end = max(s.end, o.end)
+
+	return s
+}
+
+// Analyze analyzes the build recorded in the manifest, reporting its top
+// slowest commands; a negative top reports none.
+func Analyze(m *logstream.RunManifest, top int) *Report {
+	r := &Report{}
+
+	spans := targetSpans(m)
+	names := map[string]string{}
+
+	for id, tm := range m.GetTargets() {
+		names[id] = targetName(tm)
+	}
+
+	targets := map[string]*Target{}
+
+	for id := range m.GetTargets() {
+		targets[id] = &Target{Target: names[id], Wall: spans[id].duration()}
+	}
+
+	for _, cm := range m.GetCommands() {
+		s := span{cm.GetStartedAtUnixNanos(), cm.GetEndedAtUnixNanos()}
+
+		t, ok := targets[cm.GetTargetId()]
+		if !ok {
+			continue
+		}
+
+		t.Commands++
+
+		if cm.GetIsCached() {
+			t.Cached++
+			continue
+		}
+
+		t.Cumulative += s.duration()
+
+		if s.duration() == 0 {
+			continue
+		}
+
+		c := Command{Target: t.Target, Command: cm.GetName(), Duration: s.duration()}
+		if sl := cm.GetSourceLocation(); sl != nil {
+			c.Source = fmt.Sprintf("%s:%d", sl.GetFile(), sl.GetStartLine())
+		}
+
+		r.Slowest = append(r.Slowest, c)
+	}
+
+	// The run may have been cut short before it recorded its end.
+	runSpan := span{m.GetStartedAtUnixNanos(), m.GetEndedAtUnixNanos()}
+	for _, s := range spans {
+		runSpan = runSpan.union(s)
+	}
+
+	r.Wall = runSpan.duration()
+
+	for _, t := range targets {
+		r.Targets = append(r.Targets, *t)
+	}
+
+	slices.SortFunc(r.Targets, func(a, b Target) int {
+		return cmp.Or(cmp.Compare(b.Wall, a.Wall), cmp.Compare(a.Target, b.Target))
+	})
+
+	slices.SortFunc(r.Slowest, func(a, b Command) int {
+		return cmp.Or(cmp.Compare(b.Duration, a.Duration), cmp.Compare(a.Target, b.Target),
+			cmp.Compare(a.Command, b.Command))
+	})
+
+	if len(r.Slowest) > top {
+		r.Slowest = r.Slowest[:max(top, 0)]
+	}
+
+	r.Path = criticalPath(m, spans, names)
+	for _, s := range r.Path {
+		r.Length += s.Self
+	}
+
+	return r
+}
+
+// criticalPath walks back from the target which ended last, through the
+// dependency of each target which ended last, and returns the path in the
+// order it ran.
+func criticalPath(m *logstream.RunManifest, spans map[string]span, names map[string]string) []Step {
+	cur := ""
+
+	for _, id := range slices.Sorted(maps.Keys(spans)) {
+		if spans[id].end != 0 && (cur == "" || spans[id].end > spans[cur].end) {
+			cur = id
+		}
+	}
+
+	if main, ok := spans[m.GetMainTargetId()]; ok && cur != "" && main.end == spans[cur].end {
+		cur = m.GetMainTargetId()
+	}
+
+	var path []Step
+
+	visited := map[string]bool{}
+
+	for cur != "" {
+		visited[cur] = true
+		s := spans[cur]
+
+		next := ""
+
+		for _, dep := range slices.Sorted(slices.Values(m.GetTargets()[cur].GetDependsOn())) {
+			ds, ok := spans[dep]
+			if !ok || visited[dep] || ds.end == 0 {
+				continue
+			}
+
+			if next == "" || ds.end > spans[next].end {
+				next = dep
+			}
+		}
+
+		step := Step{Target: names[cur], Wall: s.duration(), Self: s.duration()}
+		if next != "" {
+			step.Self = span{start: max(s.start, spans[next].end), end: s.end}.duration()
+		}
+
+		path = append(path, step)
+		cur = next
+	}
+
+	slices.Reverse(path)
+
+	return path
+}
+
+// targetSpans returns the start and end of each target. Targets which never
+// ended, such as those canceled by a failure, end with their last command.
+func targetSpans(m *logstream.RunManifest) map[string]span {
+	spans := map[string]span{}
+
+	for id, tm := range m.GetTargets() {
+		spans[id] = span{tm.GetStartedAtUnixNanos(), tm.GetEndedAtUnixNanos()}
+	}
+
+	for _, cm := range m.GetCommands() {
+		s, ok := spans[cm.GetTargetId()]
+		if !ok || m.GetTargets()[cm.GetTargetId()].GetEndedAtUnixNanos() != 0 {
+			continue
+		}
+
+		s.end = max(s.end, cm.GetEndedAtUnixNanos(), cm.GetStartedAtUnixNanos())
+		spans[cm.GetTargetId()] = s
+	}
+
+	return spans
+}
+
+func targetName(tm *logstream.TargetManifest) string {
+	name := cmp.Or(tm.GetCanonicalName(), tm.GetName())
+	if tm.GetFinalPlatform() != "" {
+		name += " (" + tm.GetFinalPlatform() + ")"
+	}
+
+	return name
+}
+
+// String implements [fmt.Stringer]. It returns the report as tables.
+func (r *Report) String() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "critical path: %v of %v\n", round(r.Length), round(r.Wall))
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "target\twall\ton path\n")
+
+	for _, s := range r.Path {
+		fmt.Fprintf(w, "%s\t%v\t%v\n", s.Target, round(s.Wall), round(s.Self))
+	}
+
+	w.Flush() // #nosec G104
+
+	buf.WriteString("\ntargets:\n")
+
+	w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "target\twall\tcumulative\tcommands\tcached\n")
+
+	for _, t := range r.Targets {
+		fmt.Fprintf(w, "%s\t%v\t%v\t%d\t%d\n", t.Target, round(t.Wall), round(t.Cumulative), t.Commands, t.Cached)
+	}
+
+	w.Flush() // #nosec G104
+
+	buf.WriteString("\nslowest uncached commands:\n")
+
+	w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "target\tcommand\tduration\tsource\n")
+
+	for _, c := range r.Slowest {
+		fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", c.Target, c.Command, round(c.Duration), c.Source)
+	}
+
+	w.Flush() // #nosec G104
+
+	return buf.String()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,137 @@
+package critpath
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	const start = uint64(1_000_000_000_000)
+
+	at := func(d time.Duration) uint64 {
+		return start + uint64(d)
+	}
+
+	m := &logstream.RunManifest{
+		MainTargetId:       "all",
+		StartedAtUnixNanos: start,
+		EndedAtUnixNanos:   at(10 * time.Second),
+		Targets: map[string]*logstream.TargetManifest{
+			"all": {
+				Name: "+all", DependsOn: []string{"lint", "build"},
+				StartedAtUnixNanos: start, EndedAtUnixNanos: at(9 * time.Second),
+			},
+			"build": {
+				Name: "+build", DependsOn: []string{"deps"}, FinalPlatform: "linux/amd64",
+				StartedAtUnixNanos: start, EndedAtUnixNanos: at(8 * time.Second),
+			},
+			"deps": {
+				Name:               "+deps",
+				StartedAtUnixNanos: start, EndedAtUnixNanos: at(3 * time.Second),
+			},
+			"lint": {
+				Name:               "+lint",
+				StartedAtUnixNanos: start, EndedAtUnixNanos: at(5 * time.Second),
+			},
+		},
+		Commands: map[string]*logstream.CommandManifest{
+			"deps/1": {
+				Name: "RUN go mod download", TargetId: "deps",
+				StartedAtUnixNanos: at(time.Second), EndedAtUnixNanos: at(3 * time.Second),
+			},
+			"build/1": {
+				Name: "COPY . .", TargetId: "build", IsCached: true,
+			},
+			"build/2": {
+				Name: "RUN go build", TargetId: "build",
+				SourceLocation:     &logstream.SourceLocation{File: "Earthfile", StartLine: 7},
+				StartedAtUnixNanos: at(3 * time.Second), EndedAtUnixNanos: at(8 * time.Second),
+			},
+			"lint/1": {
+				Name: "RUN golangci-lint run", TargetId: "lint",
+				StartedAtUnixNanos: at(time.Second), EndedAtUnixNanos: at(4 * time.Second),
+			},
+			"lint/2": {
+				Name: "RUN go vet", TargetId: "lint",
+				StartedAtUnixNanos: at(time.Second), EndedAtUnixNanos: at(5 * time.Second),
+			},
+		},
+	}
+
+	r := Analyze(m, 3)
+
+	require.Equal(t, 10*time.Second, r.Wall)
+	require.Equal(t, 9*time.Second, r.Length)
+	require.Equal(t, []Step{
+		{Target: "+deps", Wall: 3 * time.Second, Self: 3 * time.Second},
+		{Target: "+build (linux/amd64)", Wall: 8 * time.Second, Self: 5 * time.Second},
+		{Target: "+all", Wall: 9 * time.Second, Self: time.Second},
+	}, r.Path)
+
+	require.Equal(t, Target{
+		Target: "+lint", Wall: 5 * time.Second, Cumulative: 7 * time.Second, Commands: 2,
+	}, r.Targets[2])
+	require.Equal(t, Target{
+		Target: "+build (linux/amd64)", Wall: 8 * time.Second, Cumulative: 5 * time.Second, Commands: 2, Cached: 1,
+	}, r.Targets[1])
+
+	require.Equal(t, []Command{
+		{Target: "+build (linux/amd64)", Command: "RUN go build", Source: "Earthfile:7", Duration: 5 * time.Second},
+		{Target: "+lint", Command: "RUN go vet", Duration: 4 * time.Second},
+		{Target: "+lint", Command: "RUN golangci-lint run", Duration: 3 * time.Second},
+	}, r.Slowest)
+
+	require.Equal(t, `critical path: 9s of 10s
+target                wall  on path
++deps                 3s    3s
++build (linux/amd64)  8s    5s
++all                  9s    1s
+
+targets:
+target                wall  cumulative  commands  cached
++all                  9s    0s          0         0
++build (linux/amd64)  8s    5s          2         1
++lint                 5s    7s          2         0
++deps                 3s    2s          1         0
+
+slowest uncached commands:
+target                command                duration  source
++build (linux/amd64)  RUN go build           5s        Earthfile:7
+`+"+lint                 RUN go vet             4s        \n"+
+		"+lint                 RUN golangci-lint run  3s        \n", r.String())
+
+	require.Empty(t, Analyze(m, -1).Slowest)
+}
+
+func TestAnalyzeUnfinished(t *testing.T) {
+	t.Parallel()
+
+	m := &logstream.RunManifest{
+		Targets: map[string]*logstream.TargetManifest{
+			"a": {Name: "+a", StartedAtUnixNanos: 100},
+		},
+		Commands: map[string]*logstream.CommandManifest{
+			"a/1": {Name: "RUN false", TargetId: "a", StartedAtUnixNanos: 200, EndedAtUnixNanos: 300},
+		},
+	}
+
+	r := Analyze(m, DefaultTop)
+
+	require.Equal(t, time.Duration(200), r.Wall)
+	require.Equal(t, []Step{{Target: "+a", Wall: 200, Self: 200}}, r.Path)
+	require.Len(t, r.Slowest, 1)
+}
+
+func TestAnalyzeEmpty(t *testing.T) {
+	t.Parallel()
+
+	r := Analyze(&logstream.RunManifest{}, DefaultTop)
+
+	require.Empty(t, r.Path)
+	require.Zero(t, r.Wall)
+}
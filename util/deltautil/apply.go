@@ -136,6 +136,10 @@ func setManifestFields(dm *pb.DeltaManifest, ret *pb.RunManifest) {
 		if len(t2.GetDependsOn()) > 0 {
 			t.DependsOn = append(t.DependsOn, t2.GetDependsOn()...)
 		}
+
+		if t2.GetMatrixCell() != "" {
+			t.MatrixCell = t2.GetMatrixCell()
+		}
 	}
 
 	for commandID, c2 := range f.GetCommands() {
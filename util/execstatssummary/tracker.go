@@ -11,21 +11,26 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/EarthBuild/earthbuild/logstream"
+	"github.com/EarthBuild/earthbuild/util/critpath"
 	"github.com/dustin/go-humanize"
 )
 
 // Tracker is used for tracking exec stats summary for each RUN command.
 type Tracker struct {
-	stats map[string]*stats
-	path  string
-	mu    sync.Mutex
+	stats        map[string]*stats
+	dockerLayers map[string]*dockerLayerStats
+	manifest     *logstream.RunManifest
+	path         string
+	mu           sync.Mutex
 }
 
 // NewTracker creates a new exec stats summary tracker.
 func NewTracker(path string) *Tracker {
 	return &Tracker{
-		stats: map[string]*stats{},
-		path:  path,
+		stats:        map[string]*stats{},
+		dockerLayers: map[string]*dockerLayerStats{},
+		path:         path,
 	}
 }
 
@@ -54,6 +59,37 @@ func (t *Tracker) Observe(target, command string, memory uint64, cpu time.Durati
 	}
 }
 
+// ObserveDockerLayers records the bytes of the layers of the images loaded by
+// a WITH DOCKER command, and how many of them were already present in its
+// docker data root, from a previous build.
+func (t *Tracker) ObserveDockerLayers(target, command string, present, total uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := target + "|" + command
+
+	stat, ok := t.dockerLayers[k]
+	if !ok {
+		stat = &dockerLayerStats{
+			target:  target,
+			command: command,
+		}
+		t.dockerLayers[k] = stat
+	}
+
+	stat.present += present
+	stat.total += total
+}
+
+// SetManifest sets the manifest of the finished build, whose critical path
+// and slowest commands are added to the summary.
+func (t *Tracker) SetManifest(m *logstream.RunManifest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.manifest = m
+}
+
 // String implements [fmt.Stringer]. It returns a summarized table.
 func (t *Tracker) String() string {
 	t.mu.Lock()
@@ -76,9 +112,40 @@ func (t *Tracker) String() string {
 
 	w.Flush() // #nosec G104
 
+	if len(t.dockerLayers) > 0 {
+		buf.WriteString("\n")
+		t.writeDockerLayers(&buf)
+	}
+
+	if t.manifest != nil {
+		buf.WriteString("\n")
+		buf.WriteString(critpath.Analyze(t.manifest, critpath.DefaultTop).String())
+	}
+
 	return buf.String()
 }
 
+func (t *Tracker) writeDockerLayers(buf *bytes.Buffer) {
+	keys := slices.Sorted(maps.Keys(t.dockerLayers))
+
+	var present, total uint64
+
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "target\tcommand\tdocker layers\talready present\n")
+
+	for _, k := range keys {
+		v := t.dockerLayers[k]
+		fmt.Fprintf(w, "%s\t%s\t%v\t%v\n", v.target, v.command, humanize.Bytes(v.total), humanize.Bytes(v.present))
+
+		present += v.present
+		total += v.total
+	}
+
+	w.Flush() // #nosec G104
+
+	fmt.Fprintf(buf, "docker layers already present: %s of %s\n", humanize.Bytes(present), humanize.Bytes(total))
+}
+
 // Close closes the tracker, and writes the summary to disk (or stdout).
 func (t *Tracker) Close() error {
 	summary := t.String()
@@ -96,3 +163,10 @@ type stats struct {
 	memory  uint64
 	cpu     time.Duration
 }
+
+type dockerLayerStats struct {
+	target  string
+	command string
+	present uint64
+	total   uint64
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/EarthBuild/earthbuild/logstream"
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,4 +41,45 @@ target1  cmd1     1.0 kB  100ms
 
 		require.Equal(t, want, summary)
 	})
+
+	t.Run("docker layers", func(t *testing.T) {
+		t.Parallel()
+
+		tracker := NewTracker("-")
+		tracker.Observe("+test", "WITH DOCKER RUN", 1024, 100*time.Millisecond)
+		tracker.ObserveDockerLayers("+test", "WITH DOCKER RUN", 1500, 2000)
+		tracker.ObserveDockerLayers("+test", "WITH DOCKER RUN", 500, 1000)
+		tracker.ObserveDockerLayers("+other", "WITH DOCKER RUN", 0, 1000)
+
+		summary := tracker.String()
+		want := `target  command          memory  cpu
++test   WITH DOCKER RUN  1.0 kB  100ms
+
+target  command          docker layers  already present
++other  WITH DOCKER RUN  1.0 kB         0 B
++test   WITH DOCKER RUN  3.0 kB         2.0 kB
+docker layers already present: 2.0 kB of 4.0 kB
+`
+
+		require.Equal(t, want, summary)
+	})
+
+	t.Run("with manifest adds the critical path", func(t *testing.T) {
+		t.Parallel()
+
+		tracker := NewTracker("-")
+		tracker.Observe("+target1", "cmd1", 1024, 100*time.Millisecond)
+		tracker.SetManifest(&logstream.RunManifest{
+			Targets: map[string]*logstream.TargetManifest{
+				"t1": {Name: "+target1", StartedAtUnixNanos: 1, EndedAtUnixNanos: 1 + uint64(time.Second)},
+			},
+		})
+
+		summary := tracker.String()
+		require.Contains(t, summary, `target    command  memory  cpu
++target1  cmd1     1.0 kB  100ms
+
+critical path: 1s of 1s
+`)
+	})
 }
@@ -1,7 +1,10 @@
 package flagutil
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -42,6 +45,167 @@ func BuildArgMatrix(args []string) ([][]string, error) {
 	return crossProduct(groupedArgs, nil), nil
 }
 
+// MatrixOpt contains the options of a build arg matrix, as given by the
+// --matrix-* flags of BUILD and of the command line.
+type MatrixOpt struct {
+	// Name is a template of the names of the cells, in which {KEY} is
+	// replaced by the value of the build arg KEY. By default, a cell is named
+	// after its build args.
+	Name string
+	// Include are extra cells, each given as a comma-separated list of
+	// KEY=VALUE build args.
+	Include []string
+	// Exclude are combinations of build args, each given as a comma-separated
+	// list of KEY=VALUE build args. A cell is excluded when it has all the
+	// build args of one of them.
+	Exclude []string
+}
+
+// IsSet returns whether any option of the matrix is set.
+func (opt MatrixOpt) IsSet() bool {
+	return opt.Name != "" || len(opt.Include) > 0 || len(opt.Exclude) > 0
+}
+
+// MatrixCell is a combination of build args of a matrix.
+type MatrixCell struct {
+	Name string
+	Args []string
+}
+
+var matrixNameKeyRE = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// BuildMatrix builds the cells of a build arg matrix: the combinations of
+// BuildArgMatrix which aren't excluded, followed by the included ones which
+// aren't already part of the matrix.
+func BuildMatrix(args []string, opt MatrixOpt) ([]MatrixCell, error) {
+	combos, err := BuildArgMatrix(args)
+	if err != nil {
+		return nil, err
+	}
+
+	excludes := make([][]string, 0, len(opt.Exclude))
+
+	for _, entry := range opt.Exclude {
+		exclude, err := parseMatrixEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matrix exclude: %w", err)
+		}
+
+		excludes = append(excludes, exclude)
+	}
+
+	var cells []MatrixCell
+
+	for _, combo := range combos {
+		excluded := slices.ContainsFunc(excludes, func(exclude []string) bool {
+			return containsAll(combo, exclude)
+		})
+		if !excluded {
+			cells = append(cells, MatrixCell{Args: combo})
+		}
+	}
+
+	for _, entry := range opt.Include {
+		include, err := parseMatrixEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matrix include: %w", err)
+		}
+
+		exists := slices.ContainsFunc(cells, func(cell MatrixCell) bool {
+			return len(cell.Args) == len(include) && containsAll(cell.Args, include)
+		})
+		if !exists {
+			cells = append(cells, MatrixCell{Args: include})
+		}
+	}
+
+	names := make(map[string]struct{}, len(cells))
+
+	for i, cell := range cells {
+		name, err := matrixCellName(opt.Name, cell.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, found := names[name]; found {
+			return nil, fmt.Errorf("duplicate matrix cell name %q", name)
+		}
+
+		names[name] = struct{}{}
+		cells[i].Name = name
+	}
+
+	return cells, nil
+}
+
+// parseMatrixEntry parses a comma-separated list of KEY=VALUE build args.
+func parseMatrixEntry(entry string) ([]string, error) {
+	var args []string
+
+	for arg := range strings.SplitSeq(entry, ",") {
+		arg = strings.TrimSpace(arg)
+
+		key, _, err := parseKeyValue(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "" {
+			return nil, fmt.Errorf("invalid build arg %q in %q", arg, entry)
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+func containsAll(args, subset []string) bool {
+	for _, arg := range subset {
+		if !slices.Contains(args, arg) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matrixCellName(template string, args []string) (string, error) {
+	if template == "" {
+		return strings.Join(args, ","), nil
+	}
+
+	values := make(map[string]string, len(args))
+
+	for _, arg := range args {
+		key, value, err := parseKeyValue(arg)
+		if err != nil {
+			return "", err
+		}
+
+		values[key] = ""
+		if value != nil {
+			values[key] = *value
+		}
+	}
+
+	var errs []error
+
+	name := matrixNameKeyRE.ReplaceAllStringFunc(template, func(m string) string {
+		key := m[1 : len(m)-1]
+
+		value, found := values[key]
+		if !found {
+			errs = append(errs, fmt.Errorf("matrix name %q refers to %s, which isn't a build arg of the cell %s",
+				template, key, strings.Join(args, ",")))
+		}
+
+		return value
+	})
+
+	return name, errors.Join(errs...)
+}
+
 func crossProduct(ga []argGroup, prefix []string) [][]string {
 	if len(ga) == 0 {
 		return [][]string{prefix}
@@ -50,8 +214,6 @@ func crossProduct(ga []argGroup, prefix []string) [][]string {
 	var ret [][]string
 
 	for _, v := range ga[0].values {
-		newPrefix := prefix
-
 		var kv string
 		if v == nil {
 			kv = ga[0].key
@@ -59,9 +221,9 @@ func crossProduct(ga []argGroup, prefix []string) [][]string {
 			kv = fmt.Sprintf("%s=%s", ga[0].key, *v)
 		}
 
-		newPrefix = append(newPrefix, kv)
-
-		cp := crossProduct(ga[1:], newPrefix)
+		// The prefix is cloned, so that the cells don't share its backing
+		// array and overwrite each other's args.
+		cp := crossProduct(ga[1:], append(slices.Clone(prefix), kv))
 		ret = append(ret, cp...)
 	}
 
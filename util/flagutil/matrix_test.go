@@ -42,6 +42,18 @@ func TestBuildArgMatrix(t *testing.T) {
 	}, {
 		[]string{"a=1", "a=3", "a=7", "c=10"},
 		[][]string{{"a=1", "c=10"}, {"a=3", "c=10"}, {"a=7", "c=10"}},
+	}, {
+		[]string{"a=1", "a=2", "b=1", "b=2", "c=1", "c=2", "d=1", "d=2"},
+		[][]string{
+			{"a=1", "b=1", "c=1", "d=1"}, {"a=1", "b=1", "c=1", "d=2"},
+			{"a=1", "b=1", "c=2", "d=1"}, {"a=1", "b=1", "c=2", "d=2"},
+			{"a=1", "b=2", "c=1", "d=1"}, {"a=1", "b=2", "c=1", "d=2"},
+			{"a=1", "b=2", "c=2", "d=1"}, {"a=1", "b=2", "c=2", "d=2"},
+			{"a=2", "b=1", "c=1", "d=1"}, {"a=2", "b=1", "c=1", "d=2"},
+			{"a=2", "b=1", "c=2", "d=1"}, {"a=2", "b=1", "c=2", "d=2"},
+			{"a=2", "b=2", "c=1", "d=1"}, {"a=2", "b=2", "c=1", "d=2"},
+			{"a=2", "b=2", "c=2", "d=1"}, {"a=2", "b=2", "c=2", "d=2"},
+		},
 	}}
 
 	for _, tt := range tests {
@@ -50,3 +62,88 @@ func TestBuildArgMatrix(t *testing.T) {
 		r.Equal(tt.out, ans)
 	}
 }
+
+func TestBuildMatrix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		opt  MatrixOpt
+		out  []MatrixCell
+	}{{
+		name: "default names",
+		args: []string{"a=1", "a=2", "b=x"},
+		out: []MatrixCell{
+			{Name: "a=1,b=x", Args: []string{"a=1", "b=x"}},
+			{Name: "a=2,b=x", Args: []string{"a=2", "b=x"}},
+		},
+	}, {
+		name: "exclude",
+		args: []string{"a=1", "a=2", "b=x", "b=y"},
+		opt:  MatrixOpt{Exclude: []string{"a=1,b=y", "a=2, b=x"}},
+		out: []MatrixCell{
+			{Name: "a=1,b=x", Args: []string{"a=1", "b=x"}},
+			{Name: "a=2,b=y", Args: []string{"a=2", "b=y"}},
+		},
+	}, {
+		name: "exclude partial",
+		args: []string{"a=1", "a=2", "b=x", "b=y"},
+		opt:  MatrixOpt{Exclude: []string{"b=y"}},
+		out: []MatrixCell{
+			{Name: "a=1,b=x", Args: []string{"a=1", "b=x"}},
+			{Name: "a=2,b=x", Args: []string{"a=2", "b=x"}},
+		},
+	}, {
+		name: "include",
+		args: []string{"a=1", "a=2"},
+		opt:  MatrixOpt{Include: []string{"a=2", "a=3,b=z"}},
+		out: []MatrixCell{
+			{Name: "a=1", Args: []string{"a=1"}},
+			{Name: "a=2", Args: []string{"a=2"}},
+			{Name: "a=3,b=z", Args: []string{"a=3", "b=z"}},
+		},
+	}, {
+		name: "name",
+		args: []string{"go=1.22", "go=1.23", "os=linux"},
+		opt:  MatrixOpt{Name: "go{go}-{os}"},
+		out: []MatrixCell{
+			{Name: "go1.22-linux", Args: []string{"go=1.22", "os=linux"}},
+			{Name: "go1.23-linux", Args: []string{"go=1.23", "os=linux"}},
+		},
+	}, {
+		name: "four dimensions",
+		args: []string{"A=1", "B=1", "C=1", "C=2", "D=1", "D=2"},
+		out: []MatrixCell{
+			{Name: "A=1,B=1,C=1,D=1", Args: []string{"A=1", "B=1", "C=1", "D=1"}},
+			{Name: "A=1,B=1,C=1,D=2", Args: []string{"A=1", "B=1", "C=1", "D=2"}},
+			{Name: "A=1,B=1,C=2,D=1", Args: []string{"A=1", "B=1", "C=2", "D=1"}},
+			{Name: "A=1,B=1,C=2,D=2", Args: []string{"A=1", "B=1", "C=2", "D=2"}},
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cells, err := BuildMatrix(tt.args, tt.opt)
+			require.NoError(t, err)
+			require.Equal(t, tt.out, cells)
+		})
+	}
+}
+
+func TestBuildMatrixErrors(t *testing.T) {
+	t.Parallel()
+
+	r := require.New(t)
+
+	_, err := BuildMatrix([]string{"a=1", "a=2", "b=x"}, MatrixOpt{Name: "{b}"})
+	r.ErrorContains(err, `duplicate matrix cell name "x"`)
+
+	_, err = BuildMatrix([]string{"a=1"}, MatrixOpt{Name: "{c}"})
+	r.ErrorContains(err, "isn't a build arg of the cell a=1")
+
+	_, err = BuildMatrix([]string{"a=1"}, MatrixOpt{Include: []string{"a=2,,b=3"}})
+	r.ErrorContains(err, "invalid matrix include")
+}
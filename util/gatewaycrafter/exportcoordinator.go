@@ -1,17 +1,23 @@
 package gatewaycrafter
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/EarthBuild/earthbuild/util/dockerutil"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // ExportCoordinator is a thread-safe data-store used for coordinating the export
 // of images, and artifacts (e.g. OnPull, OnImage, and Artifact summaries).
 type ExportCoordinator struct {
 	imageEntries          map[string]imageEntry
+	imageDigests          map[string]string
 	localOutputSummary    []LocalOutputSummaryEntry
 	artifactOutputSummary []ArtifactOutputSummaryEntry
 	pushedImageSummary    []PushedImageSummaryEntry
@@ -29,6 +35,7 @@ type LocalOutputSummaryEntry struct {
 	Target    string
 	DockerTag string
 	Salt      string
+	Digest    string // empty when buildkit did not report it
 }
 
 // PushedImageSummaryEntry contains a summary of images which were pushed.
@@ -36,6 +43,7 @@ type PushedImageSummaryEntry struct {
 	Target    string
 	DockerTag string
 	Salt      string
+	Digest    string // empty when buildkit did not report it
 	Pushed    bool
 }
 
@@ -50,6 +58,7 @@ type ArtifactOutputSummaryEntry struct {
 func NewExportCoordinator() *ExportCoordinator {
 	return &ExportCoordinator{
 		imageEntries: map[string]imageEntry{},
+		imageDigests: map[string]string{},
 	}
 }
 
@@ -124,6 +133,10 @@ func (ec *ExportCoordinator) GetLocalOutputSummary() []LocalOutputSummaryEntry {
 	entries := append([]LocalOutputSummaryEntry{}, ec.localOutputSummary...)
 	ec.m.Unlock()
 
+	for i := range entries {
+		entries[i].Digest = ec.ImageDigest(entries[i].DockerTag)
+	}
+
 	sort.SliceStable(entries, func(i, j int) bool {
 		return entries[i].Target < entries[j].Target
 	})
@@ -151,9 +164,57 @@ func (ec *ExportCoordinator) GetPushedImageSummary() []PushedImageSummaryEntry {
 	entries := append([]PushedImageSummaryEntry{}, ec.pushedImageSummary...)
 	ec.m.Unlock()
 
+	for i := range entries {
+		entries[i].Digest = ec.ImageDigest(entries[i].DockerTag)
+	}
+
 	sort.SliceStable(entries, func(i, j int) bool {
 		return entries[i].Target < entries[j].Target
 	})
 
 	return entries
 }
+
+// AddImageDigests records the manifest digests of the images exported by a
+// solve, as reported in its exporter response. Images exported under an
+// intermediate name (see AddImage) are recorded under their local name.
+func (ec *ExportCoordinator) AddImageDigests(exporterResponse map[string]string) {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	for k, v := range exporterResponse {
+		names, ok := strings.CutSuffix(k, "|"+exptypes.ExporterImageDescriptorKey)
+		if !ok {
+			continue
+		}
+
+		dt, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			continue
+		}
+
+		var desc ocispecs.Descriptor
+
+		err = json.Unmarshal(dt, &desc)
+		if err != nil || desc.Digest == "" {
+			continue
+		}
+
+		for name := range strings.SplitSeq(names, ",") {
+			if entry, ok := ec.imageEntries[name]; ok {
+				name = entry.localImage
+			}
+
+			ec.imageDigests[name] = desc.Digest.String()
+		}
+	}
+}
+
+// ImageDigest returns the manifest digest of an exported image, or an empty
+// string if it is unknown.
+func (ec *ExportCoordinator) ImageDigest(dockerTag string) string {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	return ec.imageDigests[dockerTag]
+}
@@ -0,0 +1,79 @@
+package gitutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrCouldNotDetectChanges is an error returned when the changed files could not be detected.
+var ErrCouldNotDetectChanges = errors.New("could not detect the changed files")
+
+// ChangedFiles returns the absolute paths of the files which changed in the
+// git repository of dir since ref. The changes are relative to the merge base
+// of ref and HEAD, so that the changes made on ref since the branch was
+// created are not included, and include uncommitted and untracked files. A
+// renamed file is listed under both its old and new names.
+func ChangedFiles(ctx context.Context, dir, ref string) ([]string, error) {
+	err := detectGitBinary(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir, err := detectGitBaseDir(ctx, dir)
+	if err != nil {
+		return nil, ErrNotAGitDir
+	}
+
+	// The ref comes from the user, and must not be taken for an option.
+	mergeBase, err := gitOutput(ctx, dir, "merge-base", "--end-of-options", ref, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("no merge base of %s and HEAD: %w", ref, err)
+	}
+
+	mergeBase = strings.TrimSpace(mergeBase)
+
+	diff, err := gitOutput(ctx, baseDir, "diff", "--name-only", "--no-renames", "-z", mergeBase, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := gitOutput(ctx, baseDir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for name := range strings.SplitSeq(diff+untracked, "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(baseDir, filepath.FromSlash(name)))
+		}
+	}
+
+	slices.Sort(files)
+
+	return slices.Compact(files), nil
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...) // #nosec G204
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		var stderr string
+		if exitErr, ok := errors.AsType[*exec.ExitError](err); ok {
+			stderr = strings.TrimSpace(string(exitErr.Stderr))
+		}
+
+		return "", fmt.Errorf("git %s returned error %w: %s: %w",
+			strings.Join(args, " "), err, stderr, ErrCouldNotDetectChanges)
+	}
+
+	return string(out), nil
+}
@@ -0,0 +1,92 @@
+package gitutil
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// The temp dir may be behind a symlink, as on macOS, while git reports
+	// the resolved path of the repository.
+	dir, err := filepath.EvalSymlinks(dir)
+	NoError(t, err)
+
+	run := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.CommandContext(context.Background(), args[0], args[1:]...) //nolint:gosec // test helper
+		cmd.Dir = dir
+
+		cmd.Env = append(
+			os.Environ(),
+			"GIT_AUTHOR_NAME=test",
+			"GIT_AUTHOR_EMAIL=test@test.com",
+			"GIT_COMMITTER_NAME=test",
+			"GIT_COMMITTER_EMAIL=test@test.com",
+		)
+
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %s\n%s", args, err, out)
+		}
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+
+		path := filepath.Join(dir, name)
+		NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	run("git", "init")
+	run("git", "checkout", "-b", "main")
+	run("git", "config", "commit.gpgsign", "false")
+
+	write("a/kept.txt", "kept\n")
+	write("a/old.txt", "old\n")
+	write("b/changed.txt", "before\n")
+	write(".gitignore", "*.log\n")
+	run("git", "add", ".")
+	run("git", "commit", "--no-verify", "-m", "first")
+
+	run("git", "checkout", "-b", "feature")
+	run("git", "mv", "a/old.txt", "a/new.txt")
+	run("git", "commit", "--no-verify", "-m", "rename")
+
+	// A change on main after the branch was created isn't a change of the
+	// branch.
+	run("git", "checkout", "main")
+	write("main.txt", "main\n")
+	run("git", "add", ".")
+	run("git", "commit", "--no-verify", "-m", "main")
+	run("git", "checkout", "feature")
+
+	write("b/changed.txt", "after\n")
+	write("c/untracked.txt", "untracked\n")
+	write("c/ignored.log", "ignored\n")
+
+	files, err := ChangedFiles(context.Background(), filepath.Join(dir, "a"), "main")
+	NoError(t, err)
+	Equal(t, []string{
+		filepath.Join(dir, "a", "new.txt"),
+		filepath.Join(dir, "a", "old.txt"),
+		filepath.Join(dir, "b", "changed.txt"),
+		filepath.Join(dir, "c", "untracked.txt"),
+	}, files)
+
+	_, err = ChangedFiles(context.Background(), dir, "no-such-ref")
+	Error(t, err)
+
+	// A ref is never taken for an option of git: git merge-base --independent
+	// HEAD would succeed.
+	_, err = ChangedFiles(context.Background(), dir, "--independent")
+	Error(t, err)
+}
@@ -27,23 +27,11 @@ func CopyOp(
 	ifExists, symlinkNoFollow, merge bool,
 	opts ...llb.ConstraintsOpt,
 ) (pllb.State, error) {
-	destAdjusted := dest
-	if dest == "." || dest == "" || len(srcs) > 1 {
-		// TODO: needs to be the containers platform, not the earth hosts platform. For now, this is always Linux.
-		destAdjusted += string("/")
-	}
-
-	var baseCopyOpts []llb.CopyOption
-	if chown != "" {
-		baseCopyOpts = append(baseCopyOpts, llb.WithUser(chown))
-	}
+	destAdjusted := adjustDest(dest, len(srcs))
+	baseCopyOpts := baseCopyOptions(keepTs, chown)
 
 	var fa *pllb.FileAction
 
-	if !keepTs {
-		baseCopyOpts = append(baseCopyOpts, llb.WithCreatedTime(*defaultTs()))
-	}
-
 	for _, src := range srcs {
 		if ifExists && len(src) != 0 {
 			// Strip ./ and / prefixes as to make paths relative to top-level.
@@ -89,6 +77,79 @@ func CopyOp(
 	return destState.File(fa, opts...), nil
 }
 
+// AddSrc is a source of an ADD operation.
+type AddSrc struct {
+	State pllb.State
+	Path  string
+	// Remote is set for a file downloaded from a URL, which is neither
+	// unpacked nor expanded as a wildcard.
+	Remote bool
+}
+
+// AddOp is a simplified llb copy operation for the ADD command. Unlike CopyOp,
+// the sources may come from different states, and local tar archives are
+// unpacked into the destination.
+func AddOp(
+	srcs []AddSrc,
+	destState pllb.State,
+	dest string,
+	keepTs bool,
+	chown string,
+	chmod *fs.FileMode,
+	opts ...llb.ConstraintsOpt,
+) pllb.State {
+	destAdjusted := adjustDest(dest, len(srcs))
+	baseCopyOpts := baseCopyOptions(keepTs, chown)
+
+	var fa *pllb.FileAction
+
+	for _, src := range srcs {
+		copyOpts := append([]llb.CopyOption{
+			&llb.CopyInfo{
+				Mode:                chmod,
+				FollowSymlinks:      true,
+				CopyDirContentsOnly: true,
+				AttemptUnpack:       !src.Remote,
+				CreateDestPath:      true,
+				AllowWildcard:       !src.Remote,
+			},
+		}, baseCopyOpts...)
+		if fa == nil {
+			fa = pllb.Copy(src.State, src.Path, destAdjusted, copyOpts...)
+		} else {
+			fa = fa.Copy(src.State, src.Path, destAdjusted, copyOpts...)
+		}
+	}
+
+	if fa == nil {
+		return destState
+	}
+
+	return destState.File(fa, opts...)
+}
+
+func adjustDest(dest string, numSrcs int) string {
+	if dest == "." || dest == "" || numSrcs > 1 {
+		// TODO: needs to be the containers platform, not the earth hosts platform. For now, this is always Linux.
+		return dest + string("/")
+	}
+
+	return dest
+}
+
+func baseCopyOptions(keepTs bool, chown string) []llb.CopyOption {
+	var opts []llb.CopyOption
+	if chown != "" {
+		opts = append(opts, llb.WithUser(chown))
+	}
+
+	if !keepTs {
+		opts = append(opts, llb.WithCreatedTime(*defaultTs()))
+	}
+
+	return opts
+}
+
 // CopyWithRunOptions copies from `src` to `dest` and returns the result in a separate LLB State.
 // This operation is similar llb.Copy, however, it can apply llb.RunOptions (such as a mount)
 // Internally, the operation runs on the internal COPY image used by Dockerfile.
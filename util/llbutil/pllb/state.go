@@ -61,6 +61,14 @@ func Git(remote, ref string, opts ...llb.GitOption) State {
 	return State{st: llb.Git(remote, ref, opts...)}
 }
 
+// HTTP is a wrapper around llb.HTTP.
+func HTTP(url string, opts ...llb.HTTPOption) State {
+	gmu.Lock()
+	defer gmu.Unlock()
+
+	return State{st: llb.HTTP(url, opts...)}
+}
+
 // Merge is a wrapper around llb.Merge.
 func Merge(sts []State, opts ...llb.ConstraintsOpt) State {
 	sts2 := make([]llb.State, len(sts))
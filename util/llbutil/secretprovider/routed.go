@@ -0,0 +1,101 @@
+package secretprovider
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/EarthBuild/earthbuild/config"
+	"github.com/EarthBuild/earthbuild/debugger/common"
+	"github.com/moby/buildkit/session/secrets"
+)
+
+type route struct {
+	store  secrets.SecretStore
+	prefix string
+}
+
+type routedStore struct {
+	routes []route
+}
+
+// NewRoutedStore returns a SecretStore which looks up the secrets whose names
+// start with one of the given prefixes in the store of the longest such
+// prefix. The prefix is removed from the name passed to the store.
+func NewRoutedStore(stores map[string]secrets.SecretStore) secrets.SecretStore {
+	r := &routedStore{}
+	for prefix, store := range stores {
+		r.routes = append(r.routes, route{prefix: prefix, store: store})
+	}
+
+	slices.SortFunc(r.routes, func(a, b route) int {
+		return cmp.Or(cmp.Compare(len(b.prefix), len(a.prefix)), cmp.Compare(a.prefix, b.prefix))
+	})
+
+	return r
+}
+
+// NewConfiguredStore returns a SecretStore serving the secret stores of the
+// config, routed by their prefix.
+func NewConfiguredStore(cfgs map[string]config.SecretStoreConfig) (secrets.SecretStore, error) {
+	stores := make(map[string]secrets.SecretStore, len(cfgs))
+
+	for prefix, cfg := range cfgs {
+		var (
+			store secrets.SecretStore
+			err   error
+		)
+
+		switch cfg.Type {
+		case "vault":
+			store, err = NewVaultStore(cfg, nil)
+		case "sops":
+			store, err = NewSOPSStore(cfg)
+		default:
+			err = fmt.Errorf("unknown type %q, valid types are vault and sops", cfg.Type)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("secret store %q: %w", prefix, err)
+		}
+
+		stores[prefix] = store
+	}
+
+	return NewRoutedStore(stores), nil
+}
+
+// GetSecret gets a secret from the store routed to by its name.
+func (r *routedStore) GetSecret(ctx context.Context, id string) ([]byte, error) {
+	q, err := url.ParseQuery(id)
+	if err != nil {
+		return nil, errors.New("failed to parse secret ID")
+	}
+
+	name := q.Get("name")
+	if name == common.DebuggerSettingsSecretsKey {
+		return nil, secrets.ErrNotFound
+	}
+
+	for _, rt := range r.routes {
+		rest, ok := strings.CutPrefix(name, rt.prefix)
+		if !ok {
+			continue
+		}
+
+		q.Set("name", rest)
+
+		data, err := rt.store.GetSecret(ctx, q.Encode())
+		if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+			return nil, fmt.Errorf("secret %s: %w", name, err)
+		}
+
+		return data, err
+	}
+
+	return nil, secrets.ErrNotFound
+}
@@ -0,0 +1,48 @@
+package secretprovider
+
+import (
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/config"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutedStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewRoutedStore(map[string]secrets.SecretStore{
+		"vault/":      NewMapStore(map[string][]byte{"db/password": []byte("vault")}),
+		"vault/prod/": NewMapStore(map[string][]byte{"db/password": []byte("vault prod")}),
+	})
+
+	dt, err := store.GetSecret(t.Context(), secretID("vault/db/password"))
+	require.NoError(t, err)
+	require.Equal(t, "vault", string(dt))
+
+	// The longest prefix wins.
+	dt, err = store.GetSecret(t.Context(), secretID("vault/prod/db/password"))
+	require.NoError(t, err)
+	require.Equal(t, "vault prod", string(dt))
+
+	_, err = store.GetSecret(t.Context(), secretID("vault/db/user"))
+	require.ErrorIs(t, err, secrets.ErrNotFound)
+
+	_, err = store.GetSecret(t.Context(), secretID("db/password"))
+	require.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestNewConfiguredStore(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewConfiguredStore(map[string]config.SecretStoreConfig{
+		"vault/": {Type: "vault", Address: "https://vault.example.com", Token: "s.token"},
+		"sops/":  {Type: "sops", File: "secrets.enc.yaml"},
+	})
+	require.NoError(t, err)
+
+	_, err = NewConfiguredStore(map[string]config.SecretStoreConfig{
+		"kms/": {Type: "kms"},
+	})
+	require.ErrorContains(t, err, `secret store "kms/": unknown type "kms"`)
+}
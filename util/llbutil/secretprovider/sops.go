@@ -0,0 +1,533 @@
+package secretprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/EarthBuild/earthbuild/config"
+	"github.com/moby/buildkit/session/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// sopsValueRE matches a value encrypted by SOPS.
+var sopsValueRE = regexp.MustCompile(
+	`^ENC\[AES256_GCM,data:([^,]*),iv:([^,]+),tag:([^,]+),type:(str|int|float|bool|bytes|time)\]$`,
+)
+
+// SOPSStore is a SecretStore which reads secrets from a YAML file encrypted
+// by SOPS with age. A secret name is the path of a value in the file, with the
+// keys separated by slashes, e.g. db/password. The file is decrypted once, the
+// first time a secret is read.
+type SOPSStore struct {
+	values     map[string][]byte
+	err        error
+	file       string
+	ageKeyFile string
+	once       sync.Once
+}
+
+// NewSOPSStore returns a SOPSStore for the given config.
+func NewSOPSStore(cfg config.SecretStoreConfig) (*SOPSStore, error) {
+	if cfg.File == "" {
+		return nil, errors.New("no sops file configured")
+	}
+
+	return &SOPSStore{
+		file:       cfg.File,
+		ageKeyFile: cfg.AgeKeyFile,
+	}, nil
+}
+
+// GetSecret gets a secret from the SOPS file.
+func (ss *SOPSStore) GetSecret(_ context.Context, id string) ([]byte, error) {
+	q, err := url.ParseQuery(id)
+	if err != nil {
+		return nil, errors.New("failed to parse secret ID")
+	}
+
+	ss.once.Do(func() {
+		ss.values, ss.err = ss.decrypt()
+	})
+
+	if ss.err != nil {
+		return nil, ss.err
+	}
+
+	v, ok := ss.values[q.Get("name")]
+	if !ok {
+		return nil, secrets.ErrNotFound
+	}
+
+	return v, nil
+}
+
+// sopsMetadata holds the fields of the sops metadata of a file which are
+// needed to decrypt and verify it.
+type sopsMetadata struct {
+	LastModified            string `yaml:"lastmodified"`
+	MAC                     string `yaml:"mac"`
+	UnencryptedSuffix       string `yaml:"unencrypted_suffix"`
+	EncryptedSuffix         string `yaml:"encrypted_suffix"`
+	UnencryptedRegex        string `yaml:"unencrypted_regex"`
+	EncryptedRegex          string `yaml:"encrypted_regex"`
+	UnencryptedCommentRegex string `yaml:"unencrypted_comment_regex"`
+	EncryptedCommentRegex   string `yaml:"encrypted_comment_regex"`
+	Age                     []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	MACOnlyEncrypted bool `yaml:"mac_only_encrypted"`
+}
+
+func (ss *SOPSStore) decrypt() (map[string][]byte, error) {
+	dt, err := os.ReadFile(ss.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sops file: %w", err)
+	}
+
+	tree, meta, err := parseSOPSFile(dt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sops file %s: %w", ss.file, err)
+	}
+
+	if len(meta.Age) == 0 {
+		return nil, fmt.Errorf("sops file %s has no age recipients, only age encrypted files are supported", ss.file)
+	}
+
+	if meta.UnencryptedCommentRegex != "" || meta.EncryptedCommentRegex != "" {
+		return nil, fmt.Errorf("sops file %s uses comment regexes, which are not supported", ss.file)
+	}
+
+	identities, err := ss.identities()
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+
+	for _, recipient := range meta.Age {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(recipient.Enc)), identities...)
+		if err != nil {
+			continue
+		}
+
+		key, err = io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt sops data key: %w", err)
+		}
+
+		break
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("none of the age identities can decrypt sops file %s", ss.file)
+	}
+
+	w, err := newSOPSWalker(meta, key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sops metadata in %s: %w", ss.file, err)
+	}
+
+	err = w.walk(tree, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops file %s: %w", ss.file, err)
+	}
+
+	err = w.verify()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify sops file %s: %w", ss.file, err)
+	}
+
+	return w.values, nil
+}
+
+// parseSOPSFile returns the tree of a sops file, without its metadata, and
+// its metadata.
+func parseSOPSFile(dt []byte) (*yaml.Node, *sopsMetadata, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(dt))
+
+	var doc yaml.Node
+
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dec.Decode(&yaml.Node{}) != io.EOF {
+		return nil, nil, errors.New("files with several YAML documents are not supported")
+	}
+
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("the document is not a mapping")
+	}
+
+	root := doc.Content[0]
+	tree := &yaml.Node{Kind: yaml.MappingNode}
+	meta := &sopsMetadata{}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "sops" {
+			tree.Content = append(tree.Content, root.Content[i], root.Content[i+1])
+			continue
+		}
+
+		err = root.Content[i+1].Decode(meta)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tree, meta, nil
+}
+
+// sopsMACOnlyEncryptedInit is written first to the MAC of files with
+// mac_only_encrypted set, as sops does.
+var sopsMACOnlyEncryptedInit = []byte{
+	0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0x0b,
+	0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69,
+}
+
+// sopsWalker decrypts the values of a sops tree and computes its MAC, like
+// sops does: the MAC is a SHA-512 of the (decrypted) values, in the order of
+// the file, and is itself encrypted in the metadata.
+type sopsWalker struct {
+	mac              hash.Hash
+	meta             *sopsMetadata
+	values           map[string][]byte
+	unencryptedRegex *regexp.Regexp
+	encryptedRegex   *regexp.Regexp
+	key              []byte
+}
+
+func newSOPSWalker(meta *sopsMetadata, key []byte) (*sopsWalker, error) {
+	w := &sopsWalker{
+		mac:    sha512.New(),
+		meta:   meta,
+		values: map[string][]byte{},
+		key:    key,
+	}
+
+	var err error
+
+	if meta.UnencryptedRegex != "" {
+		w.unencryptedRegex, err = regexp.Compile(meta.UnencryptedRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unencrypted_regex: %w", err)
+		}
+	}
+
+	if meta.EncryptedRegex != "" {
+		w.encryptedRegex, err = regexp.Compile(meta.EncryptedRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encrypted_regex: %w", err)
+		}
+	}
+
+	if meta.MACOnlyEncrypted {
+		w.mac.Write(sopsMACOnlyEncryptedInit)
+	}
+
+	return w, nil
+}
+
+// walk decrypts the values of the tree into values, by their slash separated
+// name, e.g. db/password or hosts/0. path is the path which sops binds the
+// values to: unlike the name, it omits the indexes of list items.
+func (w *sopsWalker) walk(node *yaml.Node, name, path []string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k := node.Content[i].Value
+
+			err := w.walk(node.Content[i+1], append(slices.Clip(name), k), append(slices.Clip(path), k))
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			err := w.walk(child, append(slices.Clip(name), strconv.Itoa(i)), path)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.AliasNode:
+		return w.walk(node.Alias, name, path)
+	case yaml.ScalarNode:
+		return w.leaf(node, name, path)
+	}
+
+	return nil
+}
+
+func (w *sopsWalker) leaf(node *yaml.Node, name, path []string) error {
+	if node.ShortTag() == "!!null" {
+		return nil
+	}
+
+	encrypted := w.shouldBeEncrypted(path)
+
+	var value, macValue []byte
+
+	if encrypted {
+		// A value which the rules of the file say is encrypted, but isn't,
+		// was not written by sops.
+		if node.ShortTag() != "!!str" {
+			return fmt.Errorf("value at %s is not encrypted", strings.Join(path, "/"))
+		}
+
+		dt, typ, err := decryptSOPSValue(node.Value, w.key, []byte(strings.Join(path, ":")+":"))
+		if err != nil {
+			return fmt.Errorf("value at %s: %w", strings.Join(path, "/"), err)
+		}
+
+		value = dt
+
+		macValue, err = sopsMACValue(dt, typ)
+		if err != nil {
+			return fmt.Errorf("value at %s: %w", strings.Join(path, "/"), err)
+		}
+	} else {
+		var v any
+
+		err := node.Decode(&v)
+		if err != nil {
+			return err
+		}
+
+		value = []byte(node.Value)
+
+		macValue, err = sopsToBytes(v)
+		if err != nil {
+			return fmt.Errorf("value at %s: %w", strings.Join(path, "/"), err)
+		}
+	}
+
+	if encrypted || !w.meta.MACOnlyEncrypted {
+		w.mac.Write(macValue)
+	}
+
+	w.values[strings.Join(name, "/")] = value
+
+	return nil
+}
+
+// shouldBeEncrypted reports whether sops encrypts the value at the path,
+// according to the suffixes and regexes of the metadata.
+func (w *sopsWalker) shouldBeEncrypted(path []string) bool {
+	encrypted := true
+
+	if suffix := w.meta.UnencryptedSuffix; suffix != "" &&
+		slices.ContainsFunc(path, func(k string) bool { return strings.HasSuffix(k, suffix) }) {
+		encrypted = false
+	}
+
+	if suffix := w.meta.EncryptedSuffix; suffix != "" {
+		encrypted = slices.ContainsFunc(path, func(k string) bool { return strings.HasSuffix(k, suffix) })
+	}
+
+	if w.unencryptedRegex != nil && slices.ContainsFunc(path, w.unencryptedRegex.MatchString) {
+		encrypted = false
+	}
+
+	if w.encryptedRegex != nil {
+		encrypted = slices.ContainsFunc(path, w.encryptedRegex.MatchString)
+	}
+
+	return encrypted
+}
+
+// verify checks the MAC of the walked values against the MAC of the file, so
+// that values which were changed, added or removed without the data key are
+// rejected.
+func (w *sopsWalker) verify() error {
+	if w.meta.MAC == "" {
+		return errors.New("the file has no MAC")
+	}
+
+	lastModified, err := time.Parse(time.RFC3339, w.meta.LastModified)
+	if err != nil {
+		return fmt.Errorf("invalid lastmodified: %w", err)
+	}
+
+	mac, _, err := decryptSOPSValue(w.meta.MAC, w.key, []byte(lastModified.Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the MAC: %w", err)
+	}
+
+	if !hmac.Equal(mac, fmt.Appendf(nil, "%X", w.mac.Sum(nil))) {
+		return errors.New("MAC mismatch: the file was modified without the sops data key")
+	}
+
+	return nil
+}
+
+// identities returns the age identities from the configured key file, or
+// from the same places as sops.
+func (ss *SOPSStore) identities() ([]age.Identity, error) {
+	var sources []string
+
+	if ss.ageKeyFile != "" {
+		sources = append(sources, ss.ageKeyFile)
+	} else {
+		if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+			ids, err := age.ParseIdentities(strings.NewReader(key))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse SOPS_AGE_KEY: %w", err)
+			}
+
+			return ids, nil
+		}
+
+		if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+			sources = append(sources, file)
+		} else if dir, err := os.UserConfigDir(); err == nil {
+			sources = append(sources, filepath.Join(dir, "sops", "age", "keys.txt"))
+		}
+	}
+
+	var ids []age.Identity
+
+	for _, file := range sources {
+		f, err := os.Open(file) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("failed to open age key file: %w", err)
+		}
+
+		fileIDs, err := age.ParseIdentities(f)
+		f.Close() // #nosec G104
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age key file %s: %w", file, err)
+		}
+
+		ids = append(ids, fileIDs...)
+	}
+
+	return ids, nil
+}
+
+// decryptSOPSValue decrypts a value encrypted by SOPS, bound to the additional
+// data aad, and returns it with its type.
+func decryptSOPSValue(v string, key, aad []byte) ([]byte, string, error) {
+	// sops leaves empty values empty.
+	if v == "" {
+		return []byte{}, "str", nil
+	}
+
+	m := sopsValueRE.FindStringSubmatch(v)
+	if m == nil {
+		return nil, "", errors.New("the value is not encrypted")
+	}
+
+	var parts [3][]byte
+
+	for i, s := range m[1:4] {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid encrypted value: %w", err)
+		}
+
+		parts[i] = b
+	}
+
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, "", err
+	}
+
+	dt, err := gcm.Open(nil, iv, append(data, tag...), aad)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return dt, m[4], nil
+}
+
+// sopsMACValue returns the bytes which sops adds to the MAC for a decrypted
+// value of the given type.
+func sopsMACValue(dt []byte, typ string) ([]byte, error) {
+	switch typ {
+	case "int":
+		i, err := strconv.Atoi(string(dt))
+		if err != nil {
+			return nil, err
+		}
+
+		return sopsToBytes(i)
+	case "float":
+		f, err := strconv.ParseFloat(string(dt), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return sopsToBytes(f)
+	case "bool":
+		b, err := strconv.ParseBool(string(dt))
+		if err != nil {
+			return nil, err
+		}
+
+		return sopsToBytes(b)
+	case "time":
+		var t time.Time
+
+		err := t.UnmarshalText(dt)
+		if err != nil {
+			return nil, err
+		}
+
+		return sopsToBytes(t)
+	default:
+		return dt, nil
+	}
+}
+
+// sopsToBytes returns the bytes which sops adds to the MAC for a value.
+func sopsToBytes(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+
+		return []byte("False"), nil
+	case time.Time:
+		return v.MarshalText()
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
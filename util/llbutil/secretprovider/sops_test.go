@@ -0,0 +1,242 @@
+package secretprovider
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/EarthBuild/earthbuild/config"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+// sopsEncrypt encrypts a value like sops does, binding it to its path.
+func sopsEncrypt(t *testing.T, key []byte, value string, path ...string) string {
+	t.Helper()
+
+	return sopsSeal(t, key, value, []byte(strings.Join(path, ":")+":"))
+}
+
+// sopsSeal encrypts a value like sops does, binding it to aad.
+func sopsSeal(t *testing.T, key []byte, value string, aad []byte) string {
+	t.Helper()
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, 32)
+	require.NoError(t, err)
+
+	iv := make([]byte, 32)
+	_, err = rand.Read(iv)
+	require.NoError(t, err)
+
+	out := gcm.Seal(nil, iv, []byte(value), aad)
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag))
+}
+
+// writeSOPSFile writes a sops file whose data key is encrypted for the
+// recipient, and returns its path. tamper, if set, edits the file after it
+// was encrypted.
+func writeSOPSFile(t *testing.T, recipient age.Recipient, tamper func(doc string) string) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	var enc bytes.Buffer
+
+	aw := armor.NewWriter(&enc)
+	w, err := age.Encrypt(aw, recipient)
+	require.NoError(t, err)
+	_, err = w.Write(key)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, aw.Close())
+
+	// The MAC is the SHA-512 of the plaintext values, in order.
+	lastModified := "2025-01-10T00:00:00Z"
+	mac := sha512.Sum512([]byte("hunter2" + "5432" + "db.internal"))
+
+	doc := fmt.Sprintf(`db:
+    password: %s
+    port_unencrypted: 5432
+hosts:
+    - %s
+sops:
+    age:
+        - recipient: %s
+          enc: |
+%s
+    lastmodified: "%s"
+    mac: %s
+    unencrypted_suffix: _unencrypted
+    version: 3.9.0
+`,
+		sopsEncrypt(t, key, "hunter2", "db", "password"),
+		sopsEncrypt(t, key, "db.internal", "hosts"),
+		recipient,
+		"            "+strings.ReplaceAll(strings.TrimSpace(enc.String()), "\n", "\n            "),
+		lastModified,
+		sopsSeal(t, key, fmt.Sprintf("%X", mac), []byte(lastModified)))
+
+	if tamper != nil {
+		doc = tamper(doc)
+	}
+
+	path := filepath.Join(t.TempDir(), "secrets.enc.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(doc), 0o600))
+
+	return path
+}
+
+func TestSOPSStore(t *testing.T) {
+	t.Parallel()
+
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(id.String()+"\n"), 0o600))
+
+	ss, err := NewSOPSStore(config.SecretStoreConfig{
+		File:       writeSOPSFile(t, id.Recipient(), nil),
+		AgeKeyFile: keyFile,
+	})
+	require.NoError(t, err)
+
+	for name, want := range map[string]string{
+		"db/password":         "hunter2",
+		"db/port_unencrypted": "5432",
+		"hosts/0":             "db.internal",
+	} {
+		dt, err := ss.GetSecret(t.Context(), secretID(name))
+		require.NoError(t, err, name)
+		require.Equal(t, want, string(dt), name)
+	}
+
+	_, err = ss.GetSecret(t.Context(), secretID("db/user"))
+	require.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestSOPSStoreWrongIdentity(t *testing.T) {
+	t.Parallel()
+
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(other.String()+"\n"), 0o600))
+
+	ss, err := NewSOPSStore(config.SecretStoreConfig{
+		File:       writeSOPSFile(t, id.Recipient(), nil),
+		AgeKeyFile: keyFile,
+	})
+	require.NoError(t, err)
+
+	_, err = ss.GetSecret(t.Context(), secretID("db/password"))
+	require.ErrorContains(t, err, "none of the age identities can decrypt")
+}
+
+func TestDecryptSOPSValueWrongPath(t *testing.T) {
+	t.Parallel()
+
+	key := make([]byte, 32)
+	v := sopsEncrypt(t, key, "hunter2", "db", "password")
+
+	dt, typ, err := decryptSOPSValue(v, key, []byte("db:password:"))
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", string(dt))
+	require.Equal(t, "str", typ)
+
+	// A value moved to another key can't be decrypted.
+	_, _, err = decryptSOPSValue(v, key, []byte("db:user:"))
+	require.Error(t, err)
+}
+
+func TestSOPSStoreTampered(t *testing.T) {
+	t.Parallel()
+
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(id.String()+"\n"), 0o600))
+
+	tests := []struct {
+		name   string
+		tamper func(doc string) string
+		want   string
+	}{
+		{
+			name: "changed unencrypted value",
+			tamper: func(doc string) string {
+				return strings.Replace(doc, "port_unencrypted: 5432", "port_unencrypted: 5433", 1)
+			},
+			want: "MAC mismatch",
+		},
+		{
+			name: "added unencrypted value",
+			tamper: func(doc string) string {
+				return strings.Replace(doc, "sops:", "extra_unencrypted: x\nsops:", 1)
+			},
+			want: "MAC mismatch",
+		},
+		{
+			name: "removed MAC",
+			tamper: func(doc string) string {
+				return regexp.MustCompile(`(?m)^    mac: .*\n`).ReplaceAllString(doc, "")
+			},
+			want: "the file has no MAC",
+		},
+		{
+			name: "plaintext under an encrypted key",
+			tamper: func(doc string) string {
+				return regexp.MustCompile(`password: ENC\[.*\]`).ReplaceAllString(doc, "password: hunter3")
+			},
+			want: "value at db/password: the value is not encrypted",
+		},
+		{
+			name: "plaintext number under an encrypted key",
+			tamper: func(doc string) string {
+				return regexp.MustCompile(`- ENC\[.*\]`).ReplaceAllString(doc, "- 42")
+			},
+			want: "value at hosts is not encrypted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ss, err := NewSOPSStore(config.SecretStoreConfig{
+				File:       writeSOPSFile(t, id.Recipient(), tt.tamper),
+				AgeKeyFile: keyFile,
+			})
+			require.NoError(t, err)
+
+			_, err = ss.GetSecret(t.Context(), secretID("db/port_unencrypted"))
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package secretprovider
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/EarthBuild/earthbuild/config"
+	"github.com/moby/buildkit/session/secrets"
+)
+
+// VaultStore is a SecretStore which reads secrets from the KV v2 secrets
+// engine of a HashiCorp Vault server. A secret name is the path of a Vault
+// secret followed by one of its keys, e.g. db/prod/password. The secrets read
+// are cached for the lifetime of the store.
+type VaultStore struct {
+	client    *http.Client
+	cache     map[string]map[string]any // secret path -> data, nil if not found
+	address   string
+	namespace string
+	mount     string
+	path      string
+	auth      string
+	token     string
+	roleID    string
+	secretID  string
+	authMount string
+	mu        sync.Mutex
+}
+
+// NewVaultStore returns a VaultStore for the given config, falling back to the
+// standard VAULT_* environment variables. A nil client uses
+// http.DefaultClient.
+func NewVaultStore(cfg config.SecretStoreConfig, client *http.Client) (*VaultStore, error) {
+	vs := &VaultStore{
+		client:    cmp.Or(client, http.DefaultClient),
+		cache:     map[string]map[string]any{},
+		address:   strings.TrimSuffix(cmp.Or(cfg.Address, os.Getenv("VAULT_ADDR")), "/"),
+		namespace: cmp.Or(cfg.Namespace, os.Getenv("VAULT_NAMESPACE")),
+		mount:     strings.Trim(cmp.Or(cfg.Mount, "secret"), "/"),
+		path:      strings.Trim(cfg.Path, "/"),
+		auth:      cmp.Or(cfg.Auth, "token"),
+		authMount: strings.Trim(cmp.Or(cfg.AuthMount, "approle"), "/"),
+	}
+
+	if vs.address == "" {
+		return nil, errors.New("no vault address configured, set address or VAULT_ADDR")
+	}
+
+	switch vs.auth {
+	case "token":
+		vs.token = cmp.Or(cfg.Token, os.Getenv("VAULT_TOKEN"))
+		if vs.token == "" {
+			home, err := os.UserHomeDir()
+			if err == nil {
+				dt, err := os.ReadFile(filepath.Join(home, ".vault-token")) // #nosec G304
+				if err == nil {
+					vs.token = strings.TrimSpace(string(dt))
+				}
+			}
+		}
+
+		if vs.token == "" {
+			return nil, errors.New("no vault token configured, set token, VAULT_TOKEN or log in with vault login")
+		}
+	case "approle":
+		vs.roleID = cmp.Or(cfg.RoleID, os.Getenv("VAULT_ROLE_ID"))
+		vs.secretID = cmp.Or(cfg.SecretID, os.Getenv("VAULT_SECRET_ID"))
+
+		if vs.roleID == "" {
+			return nil, errors.New("no approle role ID configured, set role_id or VAULT_ROLE_ID")
+		}
+	default:
+		return nil, fmt.Errorf("unknown vault auth %q, valid options are token and approle", vs.auth)
+	}
+
+	return vs, nil
+}
+
+// GetSecret gets a secret from Vault.
+func (vs *VaultStore) GetSecret(ctx context.Context, id string) ([]byte, error) {
+	q, err := url.ParseQuery(id)
+	if err != nil {
+		return nil, errors.New("failed to parse secret ID")
+	}
+
+	dir, key := path.Split(q.Get("name"))
+	secretPath := strings.Trim(path.Join(vs.path, dir), "/")
+
+	if key == "" || secretPath == "" {
+		return nil, fmt.Errorf("invalid vault secret name %q, must be <path>/<key>", q.Get("name"))
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	data, ok := vs.cache[secretPath]
+	if !ok {
+		data, err = vs.read(ctx, secretPath)
+		if err != nil {
+			return nil, err
+		}
+
+		vs.cache[secretPath] = data
+	}
+
+	v, ok := data[key]
+	if !ok {
+		return nil, secrets.ErrNotFound
+	}
+
+	if s, ok := v.(string); ok {
+		return []byte(s), nil
+	}
+
+	return json.Marshal(v)
+}
+
+// read reads the data of the secret at path, or nil if there is none.
+func (vs *VaultStore) read(ctx context.Context, secretPath string) (map[string]any, error) {
+	if vs.token == "" {
+		err := vs.login(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var resp struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+
+	status, err := vs.do(ctx, http.MethodGet, vs.mount+"/data/"+secretPath, nil, &resp)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret %s: %w", secretPath, err)
+	}
+
+	return resp.Data.Data, nil
+}
+
+// login logs in with AppRole.
+func (vs *VaultStore) login(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{
+		"role_id":   vs.roleID,
+		"secret_id": vs.secretID,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+
+	_, err = vs.do(ctx, http.MethodPost, "auth/"+vs.authMount+"/login", body, &resp)
+	if err != nil {
+		return fmt.Errorf("failed to log in to vault with approle: %w", err)
+	}
+
+	if resp.Auth.ClientToken == "" {
+		return errors.New("failed to log in to vault with approle: no client token returned")
+	}
+
+	vs.token = resp.Auth.ClientToken
+
+	return nil
+}
+
+// do sends a request to the Vault API, decoding its response into out, and
+// returns the status code of the response.
+func (vs *VaultStore) do(ctx context.Context, method, apiPath string, body []byte, out any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, vs.address+"/v1/"+apiPath, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	if vs.token != "" {
+		req.Header.Set("X-Vault-Token", vs.token)
+	}
+
+	if vs.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vs.namespace)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := vs.client.Do(req) // #nosec G704
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	dt, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+
+		_ = json.Unmarshal(dt, &vaultErr)
+
+		msg := strings.Join(vaultErr.Errors, "; ")
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+
+		return resp.StatusCode, fmt.Errorf("vault returned %d: %s", resp.StatusCode, msg)
+	}
+
+	err = json.Unmarshal(dt, out)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,167 @@
+package secretprovider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/config"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault is a stand-in for the KV v2 and AppRole APIs of a Vault server.
+type fakeVault struct {
+	secrets   map[string]map[string]any // mount/path -> data
+	token     string
+	namespace string
+	roleID    string
+	secretID  string
+	reads     atomic.Int32
+}
+
+func (fv *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Namespace") != fv.namespace {
+		http.Error(w, `{"errors":["wrong namespace"]}`, http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Path == "/v1/auth/approle/login" {
+		var req map[string]string
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req["role_id"] != fv.roleID || req["secret_id"] != fv.secretID {
+			http.Error(w, `{"errors":["invalid role or secret ID"]}`, http.StatusBadRequest)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": fv.token}})
+
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != fv.token {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+
+	fv.reads.Add(1)
+
+	data, ok := fv.secrets[r.URL.Path]
+	if r.Method != http.MethodGet || !ok {
+		http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": data}})
+}
+
+func secretID(name string) string {
+	return url.Values{"name": {name}}.Encode()
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	fv := &fakeVault{
+		token:     "s.token",
+		namespace: "ci",
+		roleID:    "role",
+		secretID:  "secret",
+		secrets: map[string]map[string]any{
+			"/v1/secret/data/db/prod": {"password": "hunter2", "port": 5432},
+			"/v1/kv/data/base/app":    {"key": "value"},
+		},
+	}
+
+	srv := httptest.NewServer(fv)
+	t.Cleanup(srv.Close)
+
+	return fv, srv
+}
+
+func TestVaultStoreToken(t *testing.T) {
+	t.Parallel()
+
+	fv, srv := newFakeVault(t)
+
+	vs, err := NewVaultStore(config.SecretStoreConfig{
+		Address:   srv.URL,
+		Namespace: "ci",
+		Token:     "s.token",
+	}, srv.Client())
+	require.NoError(t, err)
+
+	dt, err := vs.GetSecret(t.Context(), secretID("db/prod/password"))
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", string(dt))
+
+	dt, err = vs.GetSecret(t.Context(), secretID("db/prod/port"))
+	require.NoError(t, err)
+	require.Equal(t, "5432", string(dt))
+
+	_, err = vs.GetSecret(t.Context(), secretID("db/prod/user"))
+	require.ErrorIs(t, err, secrets.ErrNotFound)
+
+	// The secret is read once for the lifetime of the store.
+	require.Equal(t, int32(1), fv.reads.Load())
+
+	_, err = vs.GetSecret(t.Context(), secretID("db/staging/password"))
+	require.ErrorIs(t, err, secrets.ErrNotFound)
+
+	_, err = vs.GetSecret(t.Context(), secretID("password"))
+	require.ErrorContains(t, err, "must be <path>/<key>")
+}
+
+func TestVaultStoreAppRole(t *testing.T) {
+	t.Parallel()
+
+	_, srv := newFakeVault(t)
+
+	vs, err := NewVaultStore(config.SecretStoreConfig{
+		Address:   srv.URL,
+		Namespace: "ci",
+		Mount:     "kv",
+		Path:      "base",
+		Auth:      "approle",
+		RoleID:    "role",
+		SecretID:  "secret",
+	}, srv.Client())
+	require.NoError(t, err)
+
+	dt, err := vs.GetSecret(t.Context(), secretID("app/key"))
+	require.NoError(t, err)
+	require.Equal(t, "value", string(dt))
+
+	vs, err = NewVaultStore(config.SecretStoreConfig{
+		Address:   srv.URL,
+		Namespace: "ci",
+		Auth:      "approle",
+		RoleID:    "role",
+		SecretID:  "wrong",
+	}, srv.Client())
+	require.NoError(t, err)
+
+	_, err = vs.GetSecret(t.Context(), secretID("db/prod/password"))
+	require.ErrorContains(t, err, "invalid role or secret ID")
+}
+
+func TestVaultStorePermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	_, srv := newFakeVault(t)
+
+	vs, err := NewVaultStore(config.SecretStoreConfig{
+		Address:   srv.URL,
+		Namespace: "ci",
+		Token:     "s.other",
+	}, srv.Client())
+	require.NoError(t, err)
+
+	_, err = vs.GetSecret(t.Context(), secretID("db/prod/password"))
+	require.ErrorContains(t, err, "vault returned 403: permission denied")
+	require.NotErrorIs(t, err, secrets.ErrNotFound)
+}
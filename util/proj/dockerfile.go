@@ -0,0 +1,99 @@
+package proj
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	dockerfile = "Dockerfile"
+
+	dockerBuild = `
+# {{.Prefix}}build builds the Dockerfile, saving it as an image.
+{{.Prefix}}build:
+    FROM DOCKERFILE .
+
+    # tag sets the tag of the saved image.
+    ARG tag = "latest"
+
+    SAVE IMAGE {{.Data.Image}}:$tag`
+
+	dockerLint = `
+# {{.Prefix}}lint runs hadolint against the Dockerfile.
+{{.Prefix}}lint:
+    FROM hadolint/hadolint:latest-alpine
+
+    COPY Dockerfile .
+    RUN hadolint Dockerfile`
+)
+
+// invalidImageChars matches the characters which aren't valid in an image
+// name.
+var invalidImageChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+type dockerData struct {
+	Image string
+}
+
+// Dockerfile is used to auto-generate Earthfiles for directories which only
+// contain a Dockerfile.
+type Dockerfile struct {
+	fs   FS
+	root string
+	data dockerData
+}
+
+// NewDockerfile returns a new Dockerfile.
+func NewDockerfile(fs FS) *Dockerfile {
+	return &Dockerfile{fs: fs}
+}
+
+// Type returns 'docker'.
+func (d *Dockerfile) Type(context.Context) string {
+	return "docker"
+}
+
+// ForDir returns a Project for the given directory. It returns ErrSkip if the
+// directory does not contain a Dockerfile.
+func (d *Dockerfile) ForDir(_ context.Context, dir string) (Project, error) {
+	ok, err := exists(d.fs, filepath.Join(dir, dockerfile))
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("no Dockerfile found: %w", ErrSkip)
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute path for directory %q: %w", dir, err)
+	}
+
+	image := strings.Trim(invalidImageChars.ReplaceAllString(strings.ToLower(filepath.Base(root)), "-"), "._-")
+	if image == "" {
+		image = "app"
+	}
+
+	return &Dockerfile{
+		fs:   d.fs,
+		root: root,
+		data: dockerData{Image: image},
+	}, nil
+}
+
+// Root returns the root path of this Dockerfile project.
+func (d *Dockerfile) Root(context.Context) string {
+	return d.root
+}
+
+// Targets returns the targets that should be used for this Dockerfile project.
+func (d *Dockerfile) Targets() ([]Target, error) {
+	return []Target{
+		&targetFormatter{name: "build", template: dockerBuild, data: d.data},
+		&targetFormatter{name: "lint", template: dockerLint, data: d.data},
+	}, nil
+}
@@ -8,7 +8,6 @@ import (
 	"io/fs"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	"github.com/EarthBuild/earthbuild/util/hint"
 )
@@ -160,7 +159,7 @@ func (g *Golang) ForDir(ctx context.Context, dir string) (Project, error) {
 		return nil, fmt.Errorf("error reading go.mod: %w", err)
 	}
 
-	out, _, err := g.execer.Command("go", "list", "-f", "{{.Dir}}").Run(ctx)
+	out, _, err := g.execer.Command("go", "-C", dir, "list", "-f", "{{.Dir}}").Run(ctx)
 	if errors.Is(err, fs.ErrNotExist) {
 		return nil, hint.Wrap(
 			fmt.Errorf("go.mod and go.sum exist, but go is not installed: %w", err),
@@ -194,52 +193,13 @@ func (g *Golang) Root(context.Context) string {
 func (g *Golang) Targets() ([]Target, error) {
 	return []Target{
 		&targetFormatter{template: goBase},
-		&targetFormatter{template: goDeps},
-		&targetFormatter{template: goTestBase},
-		&targetFormatter{template: goTestRace},
-		&targetFormatter{template: goTestIntegration},
-		&targetFormatter{template: goTest},
-		&targetFormatter{template: goProjBase},
-		&targetFormatter{template: goTidy},
-		&targetFormatter{template: goBuild},
+		&targetFormatter{name: "deps", template: goDeps},
+		&targetFormatter{name: "test-base", template: goTestBase},
+		&targetFormatter{name: "test-race", template: goTestRace},
+		&targetFormatter{name: "test-integration", template: goTestIntegration},
+		&targetFormatter{name: "test", template: goTest},
+		&targetFormatter{name: "proj-base", template: goProjBase},
+		&targetFormatter{name: "mod-tidy", template: goTidy},
+		&targetFormatter{name: "build", template: goBuild},
 	}, nil
 }
-
-type targetFormatter struct {
-	prefix   string
-	template string
-}
-
-func (f *targetFormatter) SetPrefix(pfx string) {
-	if pfx == "" {
-		f.prefix = ""
-		return
-	}
-
-	if !strings.HasSuffix(pfx, "-") {
-		pfx += "-"
-	}
-
-	f.prefix = pfx
-}
-
-func (f *targetFormatter) Format(w io.Writer, indent string) error {
-	t := strings.TrimSpace(f.template) + "\n"
-
-	tmpl, err := template.New("").Parse(t)
-	if err != nil {
-		return fmt.Errorf("golang: failed to parse target template: %w", err)
-	}
-
-	type tmplCtx struct {
-		Prefix string
-		Indent string
-	}
-
-	err = tmpl.Execute(w, tmplCtx{Prefix: f.prefix, Indent: indent})
-	if err != nil {
-		return fmt.Errorf("golang: failed to execute target template: %w", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,246 @@
+package proj
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	pomXML = "pom.xml"
+
+	javaBase = `
+{{- $indent := and .Prefix .Indent}}{{/* if .Prefix is empty string, empty string; otherwise .Indent */}}
+{{- if .Prefix }}{{.Prefix}}base:
+{{ end -}}
+{{$indent}}LET java_version = 21
+
+{{ if eq .Data.Tool "maven" -}}
+{{$indent}}FROM maven:3-eclipse-temurin-${java_version}
+{{- else -}}
+{{$indent}}FROM gradle:jdk${java_version}
+{{- end }}
+{{$indent}}WORKDIR /java-workdir`
+
+	javaDeps = `
+{{.Prefix}}deps:
+    {{- if .Prefix }}
+    FROM +{{.Prefix}}base{{"\n"}}
+    {{- end }}
+    {{- if eq .Data.Tool "gradle" }}
+    # gradle downloads the dependencies to this dir, which is cached by later
+    # targets.
+    ENV GRADLE_USER_HOME = "/.gradle-home"{{"\n"}}
+    {{- end }}
+    # Copying only the build files means that the cache for this target
+    # will only be busted when the build files change. This means that we
+    # can cache the downloaded dependencies.
+    {{- range .Data.Files }}
+    COPY {{.}} {{.}}
+    {{- end }}
+    RUN {{.Data.Command}} {{.Data.Deps}}`
+
+	javaSrc = `
+{{.Prefix}}src:
+    FROM +{{.Prefix}}deps
+
+    # This copies the whole project. If you want better caching, try
+    # limiting this to _just_ files required by your java project.
+    COPY . .`
+
+	javaBuild = `
+# {{.Prefix}}build runs '{{.Data.Build}}', saving the built jars locally.
+{{.Prefix}}build:
+    FROM +{{.Prefix}}src
+
+    CACHE --sharing shared "{{.Data.CacheDir}}"
+    RUN {{.Data.Command}} {{.Data.Build}}
+
+    # outputDir sets the directory that build artifacts will be saved to.
+    ARG outputDir = "./build"
+
+    SAVE ARTIFACT {{.Data.Jars}} AS LOCAL "${outputDir}/"`
+
+	javaTest = `
+# {{.Prefix}}test runs '{{.Data.Test}}'.
+{{.Prefix}}test:
+    FROM +{{.Prefix}}src
+
+    CACHE --sharing shared "{{.Data.CacheDir}}"
+    RUN {{.Data.Command}} {{.Data.Test}}`
+
+	javaLint = `
+# {{.Prefix}}lint runs '{{.Data.Lint}}'.
+{{.Prefix}}lint:
+    FROM +{{.Prefix}}src
+
+    CACHE --sharing shared "{{.Data.CacheDir}}"
+    RUN {{.Data.Command}} {{.Data.Lint}}`
+)
+
+type javaData struct {
+	Tool     string
+	Command  string
+	CacheDir string
+	Deps     string
+	Build    string
+	Test     string
+	Lint     string
+	Jars     string
+	Files    []string
+}
+
+// Java is used to auto-generate Earthfiles for java projects, built by maven
+// or gradle.
+type Java struct {
+	fs   FS
+	root string
+	data javaData
+}
+
+// NewJava returns a new Java.
+func NewJava(fs FS) *Java {
+	return &Java{fs: fs}
+}
+
+// Type returns 'java'.
+func (j *Java) Type(context.Context) string {
+	return "java"
+}
+
+// ForDir returns a Project for the given directory. It returns ErrSkip if the
+// directory contains neither a pom.xml nor a gradle build file.
+func (j *Java) ForDir(_ context.Context, dir string) (Project, error) {
+	data, err := j.maven(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		data, err = j.gradle(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("no pom.xml or build.gradle found: %w", ErrSkip)
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute path for directory %q: %w", dir, err)
+	}
+
+	return &Java{
+		fs:   j.fs,
+		root: root,
+		data: *data,
+	}, nil
+}
+
+// maven returns the data for a maven project in dir, or nil if there is none.
+func (j *Java) maven(dir string) (*javaData, error) {
+	ok, err := exists(j.fs, filepath.Join(dir, pomXML))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	data := &javaData{
+		Tool:     "maven",
+		Command:  "mvn -B",
+		CacheDir: "/root/.m2/repository",
+		Deps:     "dependency:go-offline",
+		Build:    "package -DskipTests",
+		Test:     "test",
+		Lint:     "checkstyle:check",
+		Jars:     "target/*.jar",
+		Files:    []string{pomXML},
+	}
+
+	wrapper, err := j.existing(dir, ".mvn", "mvnw")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapper) == 2 {
+		data.Command = "./mvnw -B"
+	}
+
+	data.Files = append(data.Files, wrapper...)
+
+	return data, nil
+}
+
+// gradle returns the data for a gradle project in dir, or nil if there is
+// none.
+func (j *Java) gradle(dir string) (*javaData, error) {
+	buildFiles, err := j.existing(dir, "build.gradle", "build.gradle.kts")
+	if err != nil || len(buildFiles) == 0 {
+		return nil, err
+	}
+
+	data := &javaData{
+		Tool:     "gradle",
+		Command:  "gradle --no-daemon",
+		CacheDir: "$GRADLE_USER_HOME",
+		Deps:     "dependencies",
+		Build:    "assemble",
+		Test:     "test",
+		Lint:     "check -x test",
+		Jars:     "build/libs/*",
+	}
+
+	settings, err := j.existing(dir, "settings.gradle", "settings.gradle.kts", "gradle.properties")
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper, err := j.existing(dir, "gradle", "gradlew")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapper) == 2 {
+		data.Command = "./gradlew --no-daemon"
+	}
+
+	data.Files = append(append(buildFiles, settings...), wrapper...)
+
+	return data, nil
+}
+
+// existing returns the names which exist in dir.
+func (j *Java) existing(dir string, names ...string) ([]string, error) {
+	var found []string
+
+	for _, name := range names {
+		ok, err := exists(j.fs, filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			found = append(found, name)
+		}
+	}
+
+	return found, nil
+}
+
+// Root returns the root path of this Java project.
+func (j *Java) Root(context.Context) string {
+	return j.root
+}
+
+// Targets returns the targets that should be used for this Java project.
+func (j *Java) Targets() ([]Target, error) {
+	return []Target{
+		&targetFormatter{template: javaBase, data: j.data},
+		&targetFormatter{name: "deps", template: javaDeps, data: j.data},
+		&targetFormatter{name: "src", template: javaSrc, data: j.data},
+		&targetFormatter{name: "build", template: javaBuild, data: j.data},
+		&targetFormatter{name: "test", template: javaTest, data: j.data},
+		&targetFormatter{name: "lint", template: javaLint, data: j.data},
+	}, nil
+}
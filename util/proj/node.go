@@ -0,0 +1,251 @@
+package proj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	packageJSON = "package.json"
+
+	nodeBase = `
+{{- $indent := and .Prefix .Indent}}{{/* if .Prefix is empty string, empty string; otherwise .Indent */}}
+{{- if .Prefix }}{{.Prefix}}base:
+{{ end -}}
+{{$indent}}LET node_version = 24
+
+{{$indent}}FROM node:${node_version}-alpine
+{{$indent}}WORKDIR /node-workdir`
+
+	nodeDeps = `
+{{.Prefix}}deps:
+    {{- if .Prefix }}
+    FROM +{{.Prefix}}base{{"\n"}}
+    {{- end }}
+    {{- if ne .Data.Manager "npm" }}
+    # corepack provides the {{.Data.Manager}} version pinned by package.json.
+    RUN corepack enable{{"\n"}}
+    {{- end }}
+    # This cache dir will be used in later targets to persist the
+    # downloaded packages.
+    #
+    # NOTE: cache only gets persisted on successful builds.
+    ENV {{.Data.CacheEnv}} = "{{.Data.CacheDir}}"
+
+    # Copying only package.json and the lockfile means that the cache for
+    # this target will only be busted when the dependencies change. This
+    # means that we can cache the installed node_modules.
+    COPY package.json{{range .Data.Files}} {{.}}{{end}} .
+    CACHE --sharing shared "${{.Data.CacheEnv}}"
+    RUN {{.Data.Install}}`
+
+	nodeSrc = `
+{{.Prefix}}src:
+    FROM +{{.Prefix}}deps
+
+    # This copies the whole project. Add node_modules to .earthlyignore so
+    # that a local node_modules doesn't replace the installed one.
+    COPY . .`
+
+	nodeBuild = `
+# {{.Prefix}}build runs the build script, saving its output locally.
+{{.Prefix}}build:
+    FROM +{{.Prefix}}src
+
+    RUN {{.Data.Manager}} run build
+
+    # outputDir sets the directory that the build script writes to.
+    ARG outputDir = "dist"
+
+    SAVE ARTIFACT "$outputDir" AS LOCAL "$outputDir"`
+
+	nodeTest = `
+# {{.Prefix}}test runs the test script.
+{{.Prefix}}test:
+    FROM +{{.Prefix}}src
+
+    RUN {{.Data.Manager}} run test`
+
+	nodeLint = `
+# {{.Prefix}}lint runs the lint script.
+{{.Prefix}}lint:
+    FROM +{{.Prefix}}src
+
+    RUN {{.Data.Manager}} run lint`
+)
+
+// nodeManagers lists the supported package managers, in order of precedence,
+// with how their packages are installed and cached.
+var nodeManagers = []nodeData{
+	{
+		Manager:  "pnpm",
+		Lockfile: "pnpm-lock.yaml",
+		Install:  "pnpm install --frozen-lockfile",
+		CacheEnv: "npm_config_store_dir",
+		CacheDir: "/.pnpm-store",
+	},
+	{
+		Manager:  "yarn",
+		Lockfile: "yarn.lock",
+		Install:  "yarn install --frozen-lockfile",
+		CacheEnv: "YARN_CACHE_FOLDER",
+		CacheDir: "/.yarn-cache",
+	},
+	{
+		Manager:  "npm",
+		Lockfile: "package-lock.json",
+		Install:  "npm ci",
+		CacheEnv: "npm_config_cache",
+		CacheDir: "/.npm-cache",
+	},
+}
+
+type nodeData struct {
+	Manager  string
+	Lockfile string
+	Install  string
+	CacheEnv string
+	CacheDir string
+	Files    []string
+}
+
+// Node is used to auto-generate Earthfiles for node projects, using npm, pnpm
+// or yarn.
+type Node struct {
+	fs      FS
+	scripts map[string]string
+	root    string
+	data    nodeData
+}
+
+// NewNode returns a new Node.
+func NewNode(fs FS) *Node {
+	return &Node{fs: fs}
+}
+
+// Type returns 'node'.
+func (n *Node) Type(context.Context) string {
+	return "node"
+}
+
+// ForDir returns a Project for the given directory. It returns ErrSkip if the
+// directory does not contain a package.json.
+func (n *Node) ForDir(_ context.Context, dir string) (Project, error) {
+	dt, err := fs.ReadFile(n.fs, filepath.Join(dir, packageJSON))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no package.json found: %w", ErrSkip)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading package.json: %w", err)
+	}
+
+	var pkg struct {
+		Scripts        map[string]string `json:"scripts"`
+		PackageManager string            `json:"packageManager"`
+	}
+
+	err = json.Unmarshal(dt, &pkg)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse package.json: %w", err)
+	}
+
+	data, err := n.manager(dir, pkg.PackageManager)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute path for directory %q: %w", dir, err)
+	}
+
+	return &Node{
+		fs:      n.fs,
+		scripts: pkg.Scripts,
+		root:    root,
+		data:    data,
+	}, nil
+}
+
+// manager returns the package manager of the project: the one with a lockfile
+// or else the one named by the packageManager field of package.json.
+func (n *Node) manager(dir, packageManager string) (nodeData, error) {
+	for _, m := range nodeManagers {
+		ok, err := exists(n.fs, filepath.Join(dir, m.Lockfile))
+		if err != nil {
+			return nodeData{}, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		m.Files = []string{m.Lockfile}
+
+		if m.Manager == "yarn" {
+			// Yarn 2+ is configured by .yarnrc.yml, and has a different
+			// flag for installing from the lockfile.
+			berry, err := exists(n.fs, filepath.Join(dir, ".yarnrc.yml"))
+			if err != nil {
+				return nodeData{}, err
+			}
+
+			if berry {
+				m.Files = append(m.Files, ".yarnrc.yml")
+				m.Install = "yarn install --immutable"
+			}
+		}
+
+		return m, nil
+	}
+
+	name, _, _ := strings.Cut(packageManager, "@")
+	for _, m := range nodeManagers {
+		if m.Manager == name && name != "npm" {
+			m.Install = name + " install"
+			return m, nil
+		}
+	}
+
+	m := nodeManagers[len(nodeManagers)-1]
+	m.Install = "npm install"
+
+	return m, nil
+}
+
+// Root returns the root path of this Node project.
+func (n *Node) Root(context.Context) string {
+	return n.root
+}
+
+// Targets returns the targets that should be used for this Node project. The
+// build, test and lint targets run the scripts of the same name, so they are
+// only generated for the scripts in package.json.
+func (n *Node) Targets() ([]Target, error) {
+	tgts := []Target{
+		&targetFormatter{template: nodeBase, data: n.data},
+		&targetFormatter{name: "deps", template: nodeDeps, data: n.data},
+		&targetFormatter{name: "src", template: nodeSrc, data: n.data},
+	}
+
+	for _, script := range []struct {
+		name     string
+		template string
+	}{
+		{"build", nodeBuild},
+		{"test", nodeTest},
+		{"lint", nodeLint},
+	} {
+		if _, ok := n.scripts[script.name]; ok {
+			tgts = append(tgts, &targetFormatter{name: script.name, template: script.template, data: n.data})
+		}
+	}
+
+	return tgts, nil
+}
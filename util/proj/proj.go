@@ -11,6 +11,9 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
 )
 
 // FS represents the type that proj types need to inspect files in the
@@ -82,6 +85,20 @@ func StdExecer() Execer {
 	return stdExecer{}
 }
 
+// exists reports whether the named file exists.
+func exists(fsys FS, name string) (bool, error) {
+	_, err := fs.Stat(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("error reading %s: %w", filepath.Base(name), err)
+	}
+
+	return true, nil
+}
+
 // ErrSkip is an error that means that the project should skip this generator.
 var ErrSkip = errors.New("proj: this project is not a supported type")
 
@@ -91,6 +108,10 @@ type Target interface {
 	// SetPrefix sets a prefix to prepend to this target's name.
 	SetPrefix(string)
 
+	// Name returns the name of this target, including its prefix. It is empty
+	// for the base target of an unprefixed project.
+	Name() string
+
 	// Format writes out the target with the given indentation string.
 	Format(w io.Writer, indent string) error
 }
@@ -119,10 +140,46 @@ type Project interface {
 
 // All returns all available project types for the given dir.
 func All(ctx context.Context, dir string) ([]Project, error) {
-	known := []ProjectType{
-		NewGolang(StdFS(), StdExecer()),
+	return NewDetector(StdFS(), StdExecer()).ForDir(ctx, dir)
+}
+
+// Detector detects the projects in directories.
+type Detector struct {
+	fs       FS
+	known    []ProjectType
+	fallback []ProjectType
+}
+
+// NewDetector returns a Detector for all the known project types. A
+// Dockerfile is only used as a fallback, for directories with no other project
+// type.
+func NewDetector(fs FS, execer Execer) *Detector {
+	return &Detector{
+		fs: fs,
+		known: []ProjectType{
+			NewGolang(fs, execer),
+			NewNode(fs),
+			NewPython(fs),
+			NewRust(fs),
+			NewJava(fs),
+		},
+		fallback: []ProjectType{
+			NewDockerfile(fs),
+		},
 	}
+}
 
+// ForDir returns the projects in dir.
+func (d *Detector) ForDir(ctx context.Context, dir string) ([]Project, error) {
+	active, err := forDir(ctx, dir, d.known)
+	if err != nil || len(active) > 0 {
+		return active, err
+	}
+
+	return forDir(ctx, dir, d.fallback)
+}
+
+func forDir(ctx context.Context, dir string, known []ProjectType) ([]Project, error) {
 	active := make([]Project, 0, len(known))
 	for _, proj := range known {
 		forDir, err := proj.ForDir(ctx, dir)
@@ -139,3 +196,295 @@ func All(ctx context.Context, dir string) ([]Project, error) {
 
 	return active, nil
 }
+
+// maxSubdirDepth is how deep Find looks for projects under a directory which
+// isn't a project itself.
+const maxSubdirDepth = 3
+
+// Dir is a directory containing projects.
+type Dir struct {
+	Path     string
+	Projects []Project
+}
+
+// Find returns the directories which need an Earthfile for the projects in
+// dir. That's dir itself when it contains a project, or else, for monorepos,
+// the subdirectories containing projects, up to maxSubdirDepth deep. The
+// subdirectories of a project, as well as hidden and dependency directories,
+// aren't searched.
+func (d *Detector) Find(ctx context.Context, dir string) ([]Dir, error) {
+	projs, err := forDir(ctx, dir, d.known)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(projs) > 0 {
+		return []Dir{{Path: dir, Projects: projs}}, nil
+	}
+
+	var dirs []Dir
+
+	err = d.findSubdirs(ctx, dir, 1, &dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dirs) > 0 {
+		return dirs, nil
+	}
+
+	projs, err = forDir(ctx, dir, d.fallback)
+	if err != nil || len(projs) == 0 {
+		return nil, err
+	}
+
+	return []Dir{{Path: dir, Projects: projs}}, nil
+}
+
+func (d *Detector) findSubdirs(ctx context.Context, dir string, depth int, dirs *[]Dir) error {
+	entries, err := fs.ReadDir(d.fs, dir)
+	if err != nil {
+		return fmt.Errorf("could not read directory %q: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || skipDir(entry.Name()) {
+			continue
+		}
+
+		sub := filepath.Join(dir, entry.Name())
+
+		projs, err := d.ForDir(ctx, sub)
+		if err != nil {
+			return err
+		}
+
+		if len(projs) > 0 {
+			*dirs = append(*dirs, Dir{Path: sub, Projects: projs})
+			continue
+		}
+
+		if depth < maxSubdirDepth {
+			err := d.findSubdirs(ctx, sub, depth+1, dirs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// skipDir reports whether a directory is never searched for projects.
+func skipDir(name string) bool {
+	switch name {
+	case "node_modules", "vendor", "target", "build", "dist", "venv", "__pycache__", "testdata":
+		return true
+	}
+
+	return strings.HasPrefix(name, ".")
+}
+
+// aggregates are the targets which are generated to build the targets of the
+// same name of multiple projects, with their doc comments.
+var aggregates = []struct {
+	name string
+	doc  string
+}{
+	{"build", "builds all the projects"},
+	{"test", "tests all the projects"},
+	{"lint", "lints all the projects"},
+}
+
+// WriteEarthfile writes the targets of the projects of a directory to w.
+// When there are multiple projects, their targets are prefixed by their type,
+// and build, test and lint targets build the targets of all of them.
+func WriteEarthfile(ctx context.Context, w io.Writer, projs []Project, indent string) error {
+	var (
+		tgts []Target
+		refs = map[string][]string{}
+	)
+
+	for _, p := range projs {
+		projTgts, err := p.Targets()
+		if err != nil {
+			return fmt.Errorf("could not generate targets for project type %T: %w", p, err)
+		}
+
+		for _, tgt := range projTgts {
+			if len(projs) == 1 {
+				tgt.SetPrefix("")
+				continue
+			}
+
+			tgt.SetPrefix(p.Type(ctx))
+
+			for _, agg := range aggregates {
+				if tgt.Name() == p.Type(ctx)+"-"+agg.name {
+					refs[agg.name] = append(refs[agg.name], "+"+tgt.Name())
+				}
+			}
+		}
+
+		tgts = append(tgts, projTgts...)
+	}
+
+	for _, agg := range aggregates {
+		if len(refs[agg.name]) > 0 {
+			tgts = append(tgts, &aggregateTarget{name: agg.name, doc: agg.doc, refs: refs[agg.name]})
+		}
+	}
+
+	return writeTargets(w, tgts, indent)
+}
+
+// WriteAggregateEarthfile writes build, test and lint targets to w which build
+// the targets of the same name of the Earthfiles of dirs, relative to root.
+func WriteAggregateEarthfile(w io.Writer, root string, dirs []Dir, indent string) error {
+	var tgts []Target
+
+	for _, agg := range aggregates {
+		var refs []string
+
+		for _, dir := range dirs {
+			if !hasTarget(dir.Projects, agg.name) {
+				continue
+			}
+
+			rel, err := filepath.Rel(root, dir.Path)
+			if err != nil {
+				return fmt.Errorf("could not get path of %q relative to %q: %w", dir.Path, root, err)
+			}
+
+			refs = append(refs, "./"+filepath.ToSlash(rel)+"+"+agg.name)
+		}
+
+		if len(refs) > 0 {
+			tgts = append(tgts, &aggregateTarget{name: agg.name, doc: agg.doc, refs: refs})
+		}
+	}
+
+	return writeTargets(w, tgts, indent)
+}
+
+// hasTarget reports whether the Earthfile written by WriteEarthfile for projs
+// has the named target.
+func hasTarget(projs []Project, name string) bool {
+	for _, p := range projs {
+		tgts, err := p.Targets()
+		if err != nil {
+			continue
+		}
+
+		for _, tgt := range tgts {
+			tgt.SetPrefix("")
+
+			if tgt.Name() == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func writeTargets(w io.Writer, tgts []Target, indent string) error {
+	for i, tgt := range tgts {
+		if i > 0 {
+			_, err := w.Write([]byte("\n"))
+			if err != nil {
+				return fmt.Errorf("could not write newline separator between targets: %w", err)
+			}
+		}
+
+		err := tgt.Format(w, indent)
+		if err != nil {
+			return fmt.Errorf("could not format target %q: %w", tgt.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+type aggregateTarget struct {
+	prefix string
+	name   string
+	doc    string
+	refs   []string
+}
+
+func (t *aggregateTarget) SetPrefix(pfx string) {
+	t.prefix = pfx
+}
+
+func (t *aggregateTarget) Name() string {
+	return t.prefix + t.name
+}
+
+func (t *aggregateTarget) Format(w io.Writer, indent string) error {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# %s %s.\n%s:\n", t.Name(), t.doc, t.Name())
+
+	for _, ref := range t.refs {
+		fmt.Fprintf(&buf, "%sBUILD %s\n", indent, ref)
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// targetFormatter is a Target written from a template. The template is
+// executed with the target prefix as .Prefix, the indentation as .Indent and
+// the project specific data as .Data.
+type targetFormatter struct {
+	data     any
+	prefix   string
+	name     string
+	template string
+}
+
+func (f *targetFormatter) SetPrefix(pfx string) {
+	if pfx == "" {
+		f.prefix = ""
+		return
+	}
+
+	if !strings.HasSuffix(pfx, "-") {
+		pfx += "-"
+	}
+
+	f.prefix = pfx
+}
+
+func (f *targetFormatter) Name() string {
+	if f.name == "" && f.prefix != "" {
+		// The base recipe becomes a base target when prefixed.
+		return f.prefix + "base"
+	}
+
+	return f.prefix + f.name
+}
+
+func (f *targetFormatter) Format(w io.Writer, indent string) error {
+	t := strings.TrimSpace(f.template) + "\n"
+
+	tmpl, err := template.New("").Parse(t)
+	if err != nil {
+		return fmt.Errorf("failed to parse target template: %w", err)
+	}
+
+	type tmplCtx struct {
+		Data   any
+		Prefix string
+		Indent string
+	}
+
+	err = tmpl.Execute(w, tmplCtx{Prefix: f.prefix, Indent: indent, Data: f.data})
+	if err != nil {
+		return fmt.Errorf("failed to execute target template: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package proj_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EarthBuild/earthbuild/internal/earthfile"
+	"github.com/EarthBuild/earthbuild/util/proj"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestWriteEarthfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fs   fstest.MapFS
+		name string
+	}{
+		{
+			name: "node_npm",
+			fs: fstest.MapFS{
+				"app/package.json":      file(`{"scripts": {"build": "tsc", "test": "jest"}}`),
+				"app/package-lock.json": file("{}"),
+			},
+		},
+		{
+			name: "node_pnpm",
+			fs: fstest.MapFS{
+				"app/package.json":   file(`{"scripts": {"lint": "eslint ."}, "packageManager": "pnpm@9.0.0"}`),
+				"app/pnpm-lock.yaml": file(""),
+			},
+		},
+		{
+			name: "node_yarn_berry",
+			fs: fstest.MapFS{
+				"app/package.json": file(`{"scripts": {"build": "vite build"}}`),
+				"app/yarn.lock":    file(""),
+				"app/.yarnrc.yml":  file(""),
+			},
+		},
+		{
+			name: "python_pip",
+			fs: fstest.MapFS{
+				"app/requirements.txt": file("requests\n"),
+			},
+		},
+		{
+			name: "python_pyproject",
+			fs: fstest.MapFS{
+				"app/pyproject.toml": file("[project]\nname = \"app\"\n"),
+			},
+		},
+		{
+			name: "python_uv",
+			fs: fstest.MapFS{
+				"app/pyproject.toml": file("[project]\nname = \"app\"\n"),
+				"app/uv.lock":        file(""),
+			},
+		},
+		{
+			name: "python_poetry",
+			fs: fstest.MapFS{
+				"app/pyproject.toml": file("[tool.poetry]\nname = \"app\"\n"),
+				"app/poetry.lock":    file(""),
+			},
+		},
+		{
+			name: "rust",
+			fs: fstest.MapFS{
+				"app/Cargo.toml": file("[package]\nname = \"app\"\n"),
+			},
+		},
+		{
+			name: "rust_workspace",
+			fs: fstest.MapFS{
+				"app/Cargo.toml": file("[workspace]\nmembers = [\"a\", \"b\"]\n"),
+			},
+		},
+		{
+			name: "java_maven",
+			fs: fstest.MapFS{
+				"app/pom.xml": file("<project/>"),
+				"app/mvnw":    file(""),
+				"app/.mvn/wrapper/maven-wrapper.properties": file(""),
+			},
+		},
+		{
+			name: "java_gradle",
+			fs: fstest.MapFS{
+				"app/build.gradle.kts":    file(""),
+				"app/settings.gradle.kts": file(""),
+			},
+		},
+		{
+			name: "dockerfile",
+			fs: fstest.MapFS{
+				"My_App/Dockerfile": file("FROM alpine\n"),
+			},
+		},
+		{
+			name: "multiple",
+			fs: fstest.MapFS{
+				"app/package.json": file(`{"scripts": {"build": "vite build", "test": "vitest"}}`),
+				"app/Cargo.toml":   file("[package]\nname = \"app\"\n"),
+				"app/Dockerfile":   file("FROM alpine\n"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dirs, err := proj.NewDetector(tt.fs, fakeExecer{}).Find(t.Context(), ".")
+			require.NoError(t, err)
+			require.Len(t, dirs, 1)
+
+			buf := bytes.NewBufferString(version)
+
+			err = proj.WriteEarthfile(t.Context(), buf, dirs[0].Projects, "    ")
+			require.NoError(t, err)
+
+			compareGolden(t, filepath.Join("testdata", tt.name+".out"), buf.String())
+		})
+	}
+}
+
+func TestDetector_Find(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"README.md":                                file(""),
+		"services/api/go.mod":                      file("module api\n"),
+		"services/api/cmd/tool/package.json":       file("{}"),
+		"services/web/package.json":                file(`{"scripts": {"build": "vite build"}}`),
+		"services/web/node_modules/x/package.json": file("{}"),
+		"images/proxy/Dockerfile":                  file("FROM nginx\n"),
+		"a/b/c/d/Cargo.toml":                       file(""),
+		".git/Cargo.toml":                          file(""),
+		"testdata/pyproject.toml":                  file(""),
+	}
+
+	dirs, err := proj.NewDetector(fsys, fakeExecer{stdout: "."}).Find(t.Context(), ".")
+	require.NoError(t, err)
+
+	paths := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		paths = append(paths, dir.Path)
+	}
+
+	require.Equal(t, []string{"images/proxy", "services/api", "services/web"}, paths)
+
+	var buf bytes.Buffer
+
+	err = proj.WriteAggregateEarthfile(&buf, ".", dirs, "    ")
+	require.NoError(t, err)
+
+	require.Equal(t, `# build builds all the projects.
+build:
+    BUILD ./images/proxy+build
+    BUILD ./services/api+build
+    BUILD ./services/web+build
+
+# test tests all the projects.
+test:
+    BUILD ./services/api+test
+
+# lint lints all the projects.
+lint:
+    BUILD ./images/proxy+lint
+`, buf.String())
+}
+
+func TestDetector_Find_Root(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"Dockerfile":        file("FROM alpine\n"),
+		"web/package.json":  file("{}"),
+		"docs/requirements": file(""),
+	}
+
+	// The Dockerfile of the root is only a fallback, so the projects in
+	// subdirectories win.
+	dirs, err := proj.NewDetector(fsys, fakeExecer{}).Find(t.Context(), ".")
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	require.Equal(t, "web", dirs[0].Path)
+
+	delete(fsys, "web/package.json")
+
+	dirs, err = proj.NewDetector(fsys, fakeExecer{}).Find(t.Context(), ".")
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	require.Equal(t, ".", dirs[0].Path)
+	require.Equal(t, "docker", dirs[0].Projects[0].Type(t.Context()))
+}
+
+func compareGolden(t *testing.T, path, got string) {
+	t.Helper()
+
+	_, err := earthfile.Parse(path, got)
+	if err != nil {
+		t.Errorf("generated Earthfile is invalid: %v", err)
+	}
+
+	saveGoldenFile(t, path, []byte(got))
+
+	want, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+
+	diff := cmp.Diff(string(want), got)
+	if diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,233 @@
+package proj
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	pyproject    = "pyproject.toml"
+	requirements = "requirements.txt"
+
+	pythonBase = `
+{{- $indent := and .Prefix .Indent}}{{/* if .Prefix is empty string, empty string; otherwise .Indent */}}
+{{- if .Prefix }}{{.Prefix}}base:
+{{ end -}}
+{{$indent}}LET python_version = 3.13
+
+{{$indent}}FROM python:${python_version}-slim
+{{$indent}}WORKDIR /python-workdir`
+
+	pythonDeps = `
+{{.Prefix}}deps:
+    {{- if .Prefix }}
+    FROM +{{.Prefix}}base{{"\n"}}
+    {{- end }}
+    {{- if ne .Data.Manager "pip" }}
+    RUN pip install {{.Data.Manager}}{{"\n"}}
+    {{- end }}
+    # This cache dir will be used in later targets to persist the
+    # downloaded packages.
+    #
+    # NOTE: cache only gets persisted on successful builds.
+    {{- if eq .Data.Manager "uv" }}
+    ENV UV_CACHE_DIR = "/.uv-cache"
+    # The cache is on another filesystem, so uv can't hardlink from it.
+    ENV UV_LINK_MODE = "copy"
+    {{- else if eq .Data.Manager "poetry" }}
+    ENV POETRY_CACHE_DIR = "/.poetry-cache"
+    ENV POETRY_VIRTUALENVS_IN_PROJECT = "true"
+    {{- else }}
+    ENV PIP_CACHE_DIR = "/.pip-cache"
+    {{- end }}
+
+    # Copying only the dependency files means that the cache for this
+    # target will only be busted when the dependencies change. This means
+    # that we can cache the installed packages.
+    COPY{{range .Data.Files}} {{.}}{{end}} .
+    CACHE --sharing shared "{{.Data.CacheDir}}"
+    {{- if eq .Data.Manager "uv" }}
+    RUN uv sync --frozen --no-install-project
+    {{- else if eq .Data.Manager "poetry" }}
+    RUN poetry install --no-root
+    {{- else if .Data.Requirements }}
+    RUN pip install -r requirements.txt
+    {{- else }}
+    # pip can't install just the dependencies of a project, so they are
+    # read from pyproject.toml. The project itself is installed in src.
+    RUN python -c 'import tomllib; \
+        deps = tomllib.load(open("pyproject.toml", "rb"))["project"].get("dependencies", []); \
+        print("\n".join(deps))' > /tmp/requirements.txt
+    RUN pip install -r /tmp/requirements.txt
+    {{- end }}`
+
+	pythonSrc = `
+{{.Prefix}}src:
+    FROM +{{.Prefix}}deps
+
+    # This copies the whole project. If you want better caching, try
+    # limiting this to _just_ files required by your python project.
+    COPY . .
+    {{- if eq .Data.Manager "uv" }}
+    RUN uv sync --frozen
+    {{- else if eq .Data.Manager "poetry" }}
+    RUN poetry install --only-root
+    {{- else if .Data.Pyproject }}
+    RUN pip install --no-deps -e .
+    {{- end }}`
+
+	pythonBuild = `
+# {{.Prefix}}build builds the distribution packages, saving them locally.
+{{.Prefix}}build:
+    FROM +{{.Prefix}}src
+
+    {{ if eq .Data.Manager "uv" -}}
+    RUN uv build
+    {{- else if eq .Data.Manager "poetry" -}}
+    RUN poetry build
+    {{- else -}}
+    RUN pip install build
+    RUN python -m build
+    {{- end }}
+    SAVE ARTIFACT dist/* AS LOCAL dist/`
+
+	pythonTest = `
+# {{.Prefix}}test runs pytest.
+{{.Prefix}}test:
+    FROM +{{.Prefix}}src
+
+    {{ if eq .Data.Manager "pip" -}}
+    RUN pip install pytest
+    RUN python -m pytest
+    {{- else -}}
+    RUN {{.Data.Manager}} run pytest
+    {{- end }}`
+
+	pythonLint = `
+# {{.Prefix}}lint runs ruff.
+{{.Prefix}}lint:
+    FROM +{{.Prefix}}src
+
+    RUN pip install ruff
+    RUN ruff check .
+    RUN ruff format --check .`
+)
+
+type pythonData struct {
+	Manager      string
+	CacheDir     string
+	Files        []string
+	Requirements bool
+	Pyproject    bool
+}
+
+// Python is used to auto-generate Earthfiles for python projects, using pip,
+// poetry or uv.
+type Python struct {
+	fs   FS
+	root string
+	data pythonData
+}
+
+// NewPython returns a new Python.
+func NewPython(fs FS) *Python {
+	return &Python{fs: fs}
+}
+
+// Type returns 'python'.
+func (p *Python) Type(context.Context) string {
+	return "python"
+}
+
+// ForDir returns a Project for the given directory. It returns ErrSkip if the
+// directory contains neither a pyproject.toml nor a requirements.txt.
+func (p *Python) ForDir(_ context.Context, dir string) (Project, error) {
+	var (
+		data pythonData
+		err  error
+	)
+
+	data.Pyproject, err = exists(p.fs, filepath.Join(dir, pyproject))
+	if err != nil {
+		return nil, err
+	}
+
+	data.Requirements, err = exists(p.fs, filepath.Join(dir, requirements))
+	if err != nil {
+		return nil, err
+	}
+
+	if !data.Pyproject && !data.Requirements {
+		return nil, fmt.Errorf("no pyproject.toml or requirements.txt found: %w", ErrSkip)
+	}
+
+	data.Manager = "pip"
+	data.CacheDir = "$PIP_CACHE_DIR"
+
+	if data.Pyproject {
+		for _, m := range []struct {
+			name     string
+			lockfile string
+			cacheDir string
+		}{
+			{"uv", "uv.lock", "$UV_CACHE_DIR"},
+			{"poetry", "poetry.lock", "$POETRY_CACHE_DIR"},
+		} {
+			ok, err := exists(p.fs, filepath.Join(dir, m.lockfile))
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				data.Manager, data.CacheDir = m.name, m.cacheDir
+				data.Files = []string{pyproject, m.lockfile}
+
+				break
+			}
+		}
+	}
+
+	if data.Files == nil {
+		data.Files = []string{requirements}
+		if !data.Requirements {
+			data.Files = []string{pyproject}
+		}
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute path for directory %q: %w", dir, err)
+	}
+
+	return &Python{
+		fs:   p.fs,
+		root: root,
+		data: data,
+	}, nil
+}
+
+// Root returns the root path of this Python project.
+func (p *Python) Root(context.Context) string {
+	return p.root
+}
+
+// Targets returns the targets that should be used for this Python project.
+// Projects without a pyproject.toml can't be packaged, so they get no build
+// target.
+func (p *Python) Targets() ([]Target, error) {
+	tgts := []Target{
+		&targetFormatter{template: pythonBase, data: p.data},
+		&targetFormatter{name: "deps", template: pythonDeps, data: p.data},
+		&targetFormatter{name: "src", template: pythonSrc, data: p.data},
+	}
+
+	if p.data.Pyproject {
+		tgts = append(tgts, &targetFormatter{name: "build", template: pythonBuild, data: p.data})
+	}
+
+	return append(tgts,
+		&targetFormatter{name: "test", template: pythonTest, data: p.data},
+		&targetFormatter{name: "lint", template: pythonLint, data: p.data},
+	), nil
+}
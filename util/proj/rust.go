@@ -0,0 +1,147 @@
+package proj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	cargoToml = "Cargo.toml"
+
+	rustBase = `
+{{- $indent := and .Prefix .Indent}}{{/* if .Prefix is empty string, empty string; otherwise .Indent */}}
+{{- if .Prefix }}{{.Prefix}}base:
+{{ end -}}
+{{$indent}}LET rust_version = 1.90
+
+{{$indent}}FROM rust:${rust_version}-slim
+{{$indent}}WORKDIR /rust-workdir
+{{$indent}}RUN rustup component add clippy rustfmt`
+
+	rustDeps = `
+{{.Prefix}}deps:
+    {{- if .Prefix }}
+    FROM +{{.Prefix}}base{{"\n"}}
+    {{- end }}
+    # This cache dir will be used in later test and build targets to
+    # persist compiled crates.
+    #
+    # NOTE: cache only gets persisted on successful builds. A test
+    # failure will prevent the cargo cache from being persisted.
+    ENV CARGO_TARGET_DIR = "/.cargo-target"
+
+    # This copies the whole project, since cargo needs the sources of all
+    # the crates to resolve them. If you want better caching, try limiting
+    # this to _just_ files required by your rust project.
+    COPY --keep-ts . .
+    RUN cargo fetch`
+
+	rustBuild = `
+# {{.Prefix}}build runs 'cargo build --release', saving executables locally.
+{{.Prefix}}build:
+    FROM +{{.Prefix}}deps
+
+    CACHE --sharing shared "$CARGO_TARGET_DIR"
+    RUN cargo build --release{{.Data.Flags}} && \
+        mkdir -p /tmp/build && \
+        find "$CARGO_TARGET_DIR/release" -maxdepth 1 -type f -executable -exec cp {} /tmp/build \;
+
+    # outputDir sets the directory that build artifacts will be saved to.
+    ARG outputDir = "./build"
+
+    FOR bin IN $(ls -1 "/tmp/build")
+        SAVE ARTIFACT "/tmp/build/${bin}" AS LOCAL "${outputDir}/${bin}"
+    END`
+
+	rustTest = `
+# {{.Prefix}}test runs 'cargo test'.
+{{.Prefix}}test:
+    FROM +{{.Prefix}}deps
+
+    CACHE --sharing shared "$CARGO_TARGET_DIR"
+    RUN cargo test{{.Data.Flags}}`
+
+	rustLint = `
+# {{.Prefix}}lint runs 'cargo fmt' and 'cargo clippy'.
+{{.Prefix}}lint:
+    FROM +{{.Prefix}}deps
+
+    CACHE --sharing shared "$CARGO_TARGET_DIR"
+    RUN cargo fmt --all --check
+    RUN cargo clippy{{.Data.Flags}} --all-targets -- -D warnings`
+)
+
+type rustData struct {
+	// Flags are the flags passed to cargo to select the crates to build.
+	Flags string
+}
+
+// Rust is used to auto-generate Earthfiles for rust projects.
+type Rust struct {
+	fs   FS
+	root string
+	data rustData
+}
+
+// NewRust returns a new Rust.
+func NewRust(fs FS) *Rust {
+	return &Rust{fs: fs}
+}
+
+// Type returns 'rust'.
+func (r *Rust) Type(context.Context) string {
+	return "rust"
+}
+
+// ForDir returns a Project for the given directory. It returns ErrSkip if the
+// directory does not contain a Cargo.toml.
+func (r *Rust) ForDir(_ context.Context, dir string) (Project, error) {
+	dt, err := fs.ReadFile(r.fs, filepath.Join(dir, cargoToml))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no Cargo.toml found: %w", ErrSkip)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading Cargo.toml: %w", err)
+	}
+
+	var data rustData
+
+	for line := range strings.Lines(string(dt)) {
+		if strings.TrimSpace(line) == "[workspace]" {
+			data.Flags = " --workspace"
+			break
+		}
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute path for directory %q: %w", dir, err)
+	}
+
+	return &Rust{
+		fs:   r.fs,
+		root: root,
+		data: data,
+	}, nil
+}
+
+// Root returns the root path of this Rust project.
+func (r *Rust) Root(context.Context) string {
+	return r.root
+}
+
+// Targets returns the targets that should be used for this Rust project.
+func (r *Rust) Targets() ([]Target, error) {
+	return []Target{
+		&targetFormatter{template: rustBase, data: r.data},
+		&targetFormatter{name: "deps", template: rustDeps, data: r.data},
+		&targetFormatter{name: "build", template: rustBuild, data: r.data},
+		&targetFormatter{name: "test", template: rustTest, data: r.data},
+		&targetFormatter{name: "lint", template: rustLint, data: r.data},
+	}, nil
+}
@@ -0,0 +1,9 @@
+package stringutil
+
+import "strings"
+
+// IsHTTPURL returns true if s is an HTTP or HTTPS URL, as accepted by the ADD
+// command.
+func IsHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
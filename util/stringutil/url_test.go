@@ -0,0 +1,14 @@
+package stringutil
+
+import (
+	"testing"
+)
+
+func TestIsHTTPURL(t *testing.T) {
+	t.Parallel()
+
+	True(t, IsHTTPURL("https://example.com/app.tar.gz"))
+	True(t, IsHTTPURL("http://example.com"))
+	False(t, IsHTTPURL("git://example.com/repo.git"))
+	False(t, IsHTTPURL("./https/file"))
+}
@@ -281,10 +281,11 @@ func (c *Collection) declareOldArg(
 }
 
 type declarePrefs struct {
-	pncvf  ProcessNonConstantVariableFunc
-	val    string
-	global bool
-	arg    bool
+	pncvf      ProcessNonConstantVariableFunc
+	constraint *Constraint
+	val        string
+	global     bool
+	arg        bool
 }
 
 // DeclareOpt is an option function for declaring variables.
@@ -326,6 +327,15 @@ func WithPNCVFunc(f ProcessNonConstantVariableFunc) DeclareOpt {
 	}
 }
 
+// WithConstraint is an option function to restrict the values of an ARG. Both
+// its default and effective values are validated.
+func WithConstraint(constraint *Constraint) DeclareOpt {
+	return func(o declarePrefs) declarePrefs {
+		o.constraint = constraint
+		return o
+	}
+}
+
 // DeclareVar declares a variable. The effective value may be
 // different than the default, if the variable has been overridden.
 func (c *Collection) DeclareVar(name string, opts ...DeclareOpt) (string, string, error) {
@@ -334,6 +344,30 @@ func (c *Collection) DeclareVar(name string, opts ...DeclareOpt) (string, string
 		prefs = o(prefs)
 	}
 
+	if prefs.constraint == nil {
+		return c.declareVar(name, prefs)
+	}
+
+	err := prefs.constraint.Validate(prefs.val)
+	if err != nil {
+		return "", "", fmt.Errorf("default value of ARG %s: %w", name, err)
+	}
+
+	effective, effectiveDefault, err := c.declareVar(name, prefs)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = prefs.constraint.Validate(effective)
+	if err != nil {
+		return "", "", hint.Wrapf(fmt.Errorf("value of ARG %s: %w", name, err),
+			"ARG %s accepts: %s", name, prefs.constraint)
+	}
+
+	return effective, effectiveDefault, nil
+}
+
+func (c *Collection) declareVar(name string, prefs declarePrefs) (string, string, error) {
 	if !c.errorOnRedeclare {
 		if !prefs.arg {
 			return "", "", errors.New("LET requires the --arg-scope-and-set feature")
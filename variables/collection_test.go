@@ -35,6 +35,22 @@ func TestCollection(t *testing.T) {
 			require.True(t, ok)
 			require.Equal(t, "some version", v)
 		})
+
+		t.Run("constrained args are validated", func(t *testing.T) {
+			tc := getCtx(t)
+
+			constraint, err := variables.ParseConstraint("int", "", "")
+			require.NoError(t, err)
+
+			_, _, err = tc.coll.DeclareVar("count", variables.AsArg(), variables.WithValue("three"),
+				variables.WithConstraint(constraint))
+			require.ErrorIs(t, err, variables.ErrConstraint)
+
+			v, _, err := tc.coll.DeclareVar("count", variables.AsArg(), variables.WithValue("3"),
+				variables.WithConstraint(constraint))
+			require.NoError(t, err)
+			require.Equal(t, "3", v)
+		})
 	}
 
 	//nolint:goconst
@@ -0,0 +1,153 @@
+package variables
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Types of ARG values.
+const (
+	TypeString = "string"
+	TypeBool   = "bool"
+	TypeInt    = "int"
+)
+
+// ErrConstraint is returned when an ARG value doesn't satisfy its constraint.
+var ErrConstraint = errors.New("invalid ARG value")
+
+// Constraint restricts the values of an ARG to a type, a set of values or a
+// pattern. Empty values always satisfy it: ARG --required is used to reject
+// them.
+type Constraint struct {
+	pattern *regexp.Regexp
+	typ     string
+	enum    []string
+}
+
+// ParseConstraint returns the Constraint of the ARG --type, --enum and
+// --pattern options. enum is a comma-separated list of values. The options are
+// not expanded, so that patterns can contain '$', but may be quoted. When they
+// are all empty, the Constraint allows any value.
+func ParseConstraint(typ, enum, pattern string) (*Constraint, error) {
+	typ, enum, pattern = unquote(typ), unquote(enum), unquote(pattern)
+
+	c := &Constraint{typ: typ}
+
+	switch typ {
+	case "", TypeString, TypeBool, TypeInt:
+	default:
+		return nil, fmt.Errorf("unknown ARG type %q, expected one of %s, %s or %s", typ, TypeString, TypeBool, TypeInt)
+	}
+
+	if enum != "" {
+		c.enum = strings.Split(enum, ",")
+
+		for _, v := range c.enum {
+			err := c.validateType(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --enum value: %w", err)
+			}
+		}
+	}
+
+	if pattern != "" {
+		var err error
+
+		// The pattern needs to match the whole value.
+		c.pattern, err = regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid --pattern: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Values returns the values allowed by the constraint, if they are limited.
+func (c *Constraint) Values() []string {
+	if len(c.enum) > 0 {
+		return c.enum
+	}
+
+	if c.typ == TypeBool {
+		return []string{"true", "false"}
+	}
+
+	return nil
+}
+
+// Validate returns an error wrapping ErrConstraint if value doesn't satisfy
+// the constraint.
+func (c *Constraint) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	err := c.validateType(value)
+	if err != nil {
+		return err
+	}
+
+	if len(c.enum) > 0 && !slices.Contains(c.enum, value) {
+		return fmt.Errorf("%w: %q is not one of %s", ErrConstraint, value, strings.Join(c.enum, ", "))
+	}
+
+	if c.pattern != nil && !c.pattern.MatchString(value) {
+		return fmt.Errorf("%w: %q does not match %s", ErrConstraint, value, c.patternString())
+	}
+
+	return nil
+}
+
+func (c *Constraint) validateType(value string) error {
+	switch c.typ {
+	case TypeBool:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%w: %q is not a bool, expected true or false", ErrConstraint, value)
+		}
+	case TypeInt:
+		_, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not an int", ErrConstraint, value)
+		}
+	}
+
+	return nil
+}
+
+// String describes the constraint, e.g. `int` or `one of dev, prod`. It is
+// empty when any value is allowed.
+func (c *Constraint) String() string {
+	var parts []string
+	if c.typ != "" {
+		parts = append(parts, c.typ)
+	}
+
+	if len(c.enum) > 0 {
+		parts = append(parts, "one of "+strings.Join(c.enum, ", "))
+	}
+
+	if c.pattern != nil {
+		parts = append(parts, "matching "+c.patternString())
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+func (c *Constraint) patternString() string {
+	s := c.pattern.String()
+	return strings.TrimSuffix(strings.TrimPrefix(s, "^(?:"), ")$")
+}
+
+// unquote removes the quotes around s, if any.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
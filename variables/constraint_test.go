@@ -0,0 +1,106 @@
+package variables_test
+
+import (
+	"testing"
+
+	"github.com/EarthBuild/earthbuild/variables"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		typ     string
+		enum    string
+		pattern string
+		want    string
+		valid   []string
+		invalid []string
+		values  []string
+	}{
+		{
+			name:  "none",
+			valid: []string{"", "anything"},
+		},
+		{
+			name:    "bool",
+			typ:     "bool",
+			want:    "bool",
+			valid:   []string{"", "true", "false"},
+			invalid: []string{"yes", "1", "True"},
+			values:  []string{"true", "false"},
+		},
+		{
+			name:    "int",
+			typ:     "int",
+			want:    "int",
+			valid:   []string{"", "0", "-12", "42"},
+			invalid: []string{"1.5", "forty-two", "0x10"},
+		},
+		{
+			name:    "enum",
+			enum:    "dev,staging,prod",
+			want:    "one of dev, staging, prod",
+			valid:   []string{"", "dev", "prod"},
+			invalid: []string{"qa", "Dev", "dev,prod"},
+			values:  []string{"dev", "staging", "prod"},
+		},
+		{
+			name:    "int enum",
+			typ:     "int",
+			enum:    "8,16",
+			want:    "int; one of 8, 16",
+			valid:   []string{"8"},
+			invalid: []string{"32"},
+			values:  []string{"8", "16"},
+		},
+		{
+			name:    "quoted pattern",
+			pattern: `'v[0-9]+\.[0-9]+'`,
+			want:    `matching v[0-9]+\.[0-9]+`,
+			valid:   []string{"v1.2", "v10.0"},
+			invalid: []string{"1.2", "v1.2-rc1", "xv1.2"},
+		},
+		{
+			name:    "alternation pattern",
+			pattern: "a|b",
+			want:    "matching a|b",
+			valid:   []string{"a", "b"},
+			invalid: []string{"ab"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := variables.ParseConstraint(tt.typ, tt.enum, tt.pattern)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, c.String())
+			require.Equal(t, tt.values, c.Values())
+
+			for _, v := range tt.valid {
+				require.NoError(t, c.Validate(v), "value %q", v)
+			}
+
+			for _, v := range tt.invalid {
+				require.ErrorIs(t, c.Validate(v), variables.ErrConstraint, "value %q", v)
+			}
+		})
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := variables.ParseConstraint("float", "", "")
+	require.ErrorContains(t, err, `unknown ARG type "float"`)
+
+	_, err = variables.ParseConstraint("int", "1,two", "")
+	require.ErrorContains(t, err, `invalid --enum value`)
+
+	_, err = variables.ParseConstraint("", "", "(")
+	require.ErrorContains(t, err, "invalid --pattern")
+}